@@ -2,242 +2,302 @@ package awscloud
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
-	"log"
+	"namaste-cloud/clouds"
 	"namaste-cloud/internal"
+	"net/http"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
 )
 
-// ExecuteAWSCommand performs AWS operations based on the given command.
-func ExecuteAWSCommand(command string, args ...string) {
+// DefaultInstanceType is used when an instance spec does not name a type.
+const DefaultInstanceType = "t2.micro"
+
+// Provider manages EC2 resources.
+type Provider struct {
+	client *ec2.Client
+	ssm    *ssm.Client
+
+	// defaultTags are added to every resource the provider creates.
+	defaultTags map[string]string
+}
+
+// New creates an AWS provider from the stored credentials and the given settings.
+func New(ctx context.Context, settings internal.Config) (*Provider, error) {
+	cfg, err := loadConfig(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the EC2 and Systems Manager clients, optionally against a custom
+	// endpoint such as LocalStack
+	client := ec2.NewFromConfig(cfg, func(o *ec2.Options) {
+		if settings.EndpointURL != "" {
+			o.BaseEndpoint = aws.String(settings.EndpointURL)
+		}
+	})
+	ssmClient := ssm.NewFromConfig(cfg, func(o *ssm.Options) {
+		if settings.EndpointURL != "" {
+			o.BaseEndpoint = aws.String(settings.EndpointURL)
+		}
+	})
+	return &Provider{client: client, ssm: ssmClient, defaultTags: settings.DefaultTags}, nil
+}
+
+// loadConfig loads the AWS configuration with the stored credentials of the
+// settings' profile.
+func loadConfig(ctx context.Context, settings internal.Config) (aws.Config, error) {
 	// Load AWS credentials from storage
-	cred, err := internal.GetCredential("aws")
+	cred, err := internal.GetProfileCredential("aws", settings.Profile)
 	if err != nil {
-		log.Fatalf("Failed to load AWS credentials: %v", err)
+		return aws.Config{}, fmt.Errorf("failed to load AWS credentials: %w", err)
 	}
 
 	// Load AWS configuration with credentials
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
+	options := []func(*config.LoadOptions) error{
 		config.WithCredentialsProvider(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
 			return aws.Credentials{
 				AccessKeyID:     cred.AccessKey,
 				SecretAccessKey: cred.SecretKey,
 			}, nil
 		})),
-	)
+	}
+	if settings.Region != "" {
+		options = append(options, config.WithRegion(settings.Region))
+	}
+	options = append(options, config.WithRetryer(func() aws.Retryer {
+		return newRetryer(settings.Retry)
+	}))
+	options = append(options, config.WithHTTPClient(httpClient(settings.SkipsTLSVerify())))
+	cfg, err := config.LoadDefaultConfig(ctx, options...)
 	if err != nil {
-		log.Fatalf("Failed to load AWS configuration: %v", err)
+		return aws.Config{}, fmt.Errorf("failed to load AWS configuration: %w", err)
 	}
+	return cfg, nil
+}
 
-	// Create an EC2 client
-	ec2Client := ec2.NewFromConfig(cfg)
-
-	// Dispatch command based on the input
-	switch command {
-	case "list-instances":
-		listInstances(ec2Client)
-	case "create-instance":
-		if len(args) < 1 {
-			fmt.Println("Please specify the AMI ID for the instance.")
-			return
-		}
-		createInstance(ec2Client, args[0])
-	case "stop-instance":
-		if len(args) < 1 {
-			fmt.Println("Please specify the Instance ID to stop.")
-			return
-		}
-		stopInstance(ec2Client, args[0])
-	case "start-instance":
-		if len(args) < 1 {
-			fmt.Println("Please specify the Instance ID to start.")
-			return
-		}
-		startInstance(ec2Client, args[0])
-	case "terminate-instance":
-		if len(args) < 1 {
-			fmt.Println("Please specify the Instance ID to terminate.")
-			return
-		}
-		terminateInstance(ec2Client, args[0])
-	case "describe-instance":
-		if len(args) < 1 {
-			fmt.Println("Please specify the Instance ID to describe.")
-			return
-		}
-		describeInstance(ec2Client, args[0])
-	case "list-regions":
-		listRegions(ec2Client)
-	case "create-key-pair":
-		if len(args) < 1 {
-			fmt.Println("Please specify a name for the key pair.")
-			return
+// httpClient returns the HTTP client for AWS calls. The SDK's own buildable
+// client is kept where possible so that settings such as AWS_CA_BUNDLE apply.
+func httpClient(skipTLSVerify bool) config.HTTPClient {
+	if internal.Transport != nil {
+		return internal.NewHTTPClient(skipTLSVerify)
+	}
+	return awshttp.NewBuildableClient().WithTransportOptions(func(t *http.Transport) {
+		if skipTLSVerify {
+			t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 		}
-		createKeyPair(ec2Client, args[0])
-	case "create-security-group":
-		if len(args) < 2 {
-			fmt.Println("Please specify a name and description for the security group.")
-			return
+	})
+}
+
+// Name implements clouds.Provider.
+func (p *Provider) Name() string {
+	return "aws"
+}
+
+// Close implements clouds.Provider.
+func (p *Provider) Close() error {
+	return nil
+}
+
+// ListInstances lists all EC2 instances.
+func (p *Provider) ListInstances(ctx context.Context) ([]clouds.Instance, error) {
+	op := internal.StartOperation("list EC2 instances")
+
+	var instances []clouds.Instance
+	paginator := ec2.NewDescribeInstancesPaginator(p.client, &ec2.DescribeInstancesInput{})
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list instances: %w", op.Finish(err))
 		}
-		createSecurityGroup(ec2Client, args[0], args[1])
-	case "authorize-security-group":
-		if len(args) < 3 {
-			fmt.Println("Please specify the Security Group ID, protocol, and port range.")
-			return
+		for _, reservation := range resp.Reservations {
+			for _, instance := range reservation.Instances {
+				instances = append(instances, toInstance(instance))
+			}
 		}
-		authorizeSecurityGroup(ec2Client, args[0], args[1], args[2])
-	default:
-		fmt.Println("Unsupported AWS command.")
 	}
+	op.Finish(nil)
+
+	return instances, nil
 }
 
-// listInstances lists all EC2 instances.
-func listInstances(client *ec2.Client) {
-	resp, err := client.DescribeInstances(context.TODO(), &ec2.DescribeInstancesInput{})
-	if err != nil {
-		log.Fatalf("Failed to list instances: %v", err)
+// CreateInstance creates an EC2 instance from the spec's AMI ID.
+func (p *Provider) CreateInstance(ctx context.Context, spec clouds.InstanceSpec) (clouds.Instance, error) {
+	if spec.Image == "" {
+		return clouds.Instance{}, fmt.Errorf("an AMI ID is required to create an EC2 instance")
 	}
-
-	for _, reservation := range resp.Reservations {
-		for _, instance := range reservation.Instances {
-			fmt.Printf("Instance ID: %s, State: %s\n", *instance.InstanceId, instance.State.Name)
-		}
+	instanceType := spec.Type
+	if instanceType == "" {
+		instanceType = DefaultInstanceType
 	}
-}
 
-// createInstance creates an EC2 instance with the specified AMI ID.
-func createInstance(client *ec2.Client, amiID string) {
-	resp, err := client.RunInstances(context.TODO(), &ec2.RunInstancesInput{
-		ImageId:      aws.String(amiID),
-		InstanceType: "t2.micro",
+	input := &ec2.RunInstancesInput{
+		ImageId:      aws.String(spec.Image),
+		InstanceType: ec2types.InstanceType(instanceType),
 		MinCount:     aws.Int32(1),
 		MaxCount:     aws.Int32(1),
-	})
-	if err != nil {
-		log.Fatalf("Failed to create instance: %v", err)
 	}
+	if spec.KeyName != "" {
+		input.KeyName = aws.String(spec.KeyName)
+	}
+	input.SecurityGroupIds = spec.SecurityGroups
+	if spec.DiskSizeGiB > 0 {
+		rootDisk, err := p.rootDisk(ctx, spec.Image, spec.DiskSizeGiB)
+		if err != nil {
+			return clouds.Instance{}, err
+		}
+		input.BlockDeviceMappings = []ec2types.BlockDeviceMapping{rootDisk}
+	}
+	tags := clouds.MergeTags(p.defaultTags, spec.Tags)
+	if spec.Name != "" {
+		tags = clouds.MergeTags(tags, map[string]string{"Name": spec.Name})
+	}
+	input.TagSpecifications = tagSpecifications(ec2types.ResourceTypeInstance, tags)
 
-	for _, instance := range resp.Instances {
-		fmt.Printf("Created instance with ID: %s\n", *instance.InstanceId)
+	op := internal.StartOperation(fmt.Sprintf("create EC2 instance from %s", spec.Image))
+	resp, err := p.client.RunInstances(ctx, input)
+	if err := op.Finish(err); err != nil {
+		return clouds.Instance{}, fmt.Errorf("failed to create instance: %w", err)
 	}
+	if len(resp.Instances) == 0 {
+		return clouds.Instance{}, fmt.Errorf("failed to create instance: no instance returned")
+	}
+
+	return toInstance(resp.Instances[0]), nil
 }
 
-// stopInstance stops an EC2 instance.
-func stopInstance(client *ec2.Client, instanceID string) {
-	_, err := client.StopInstances(context.TODO(), &ec2.StopInstancesInput{
+// StopInstance stops an EC2 instance.
+func (p *Provider) StopInstance(ctx context.Context, instanceID string) error {
+	op := internal.StartOperation(fmt.Sprintf("stop instance %s", instanceID))
+	_, err := p.client.StopInstances(ctx, &ec2.StopInstancesInput{
 		InstanceIds: []string{instanceID},
 	})
-	if err != nil {
-		log.Fatalf("Failed to stop instance: %v", err)
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to stop instance: %w", err)
 	}
-
-	fmt.Printf("Stopping instance with ID: %s\n", instanceID)
+	return nil
 }
 
-// startInstance starts an EC2 instance.
-func startInstance(client *ec2.Client, instanceID string) {
-	_, err := client.StartInstances(context.TODO(), &ec2.StartInstancesInput{
+// StartInstance starts an EC2 instance.
+func (p *Provider) StartInstance(ctx context.Context, instanceID string) error {
+	op := internal.StartOperation(fmt.Sprintf("start instance %s", instanceID))
+	_, err := p.client.StartInstances(ctx, &ec2.StartInstancesInput{
 		InstanceIds: []string{instanceID},
 	})
-	if err != nil {
-		log.Fatalf("Failed to start instance: %v", err)
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to start instance: %w", err)
 	}
-
-	fmt.Printf("Starting instance with ID: %s\n", instanceID)
+	return nil
 }
 
-// terminateInstance terminates an EC2 instance.
-func terminateInstance(client *ec2.Client, instanceID string) {
-	_, err := client.TerminateInstances(context.TODO(), &ec2.TerminateInstancesInput{
+// RebootInstance reboots an EC2 instance.
+func (p *Provider) RebootInstance(ctx context.Context, instanceID string) error {
+	op := internal.StartOperation(fmt.Sprintf("reboot instance %s", instanceID))
+	_, err := p.client.RebootInstances(ctx, &ec2.RebootInstancesInput{
 		InstanceIds: []string{instanceID},
 	})
-	if err != nil {
-		log.Fatalf("Failed to terminate instance: %v", err)
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to reboot instance: %w", err)
 	}
+	return nil
+}
 
-	fmt.Printf("Terminating instance with ID: %s\n", instanceID)
+// TerminateInstance terminates an EC2 instance.
+func (p *Provider) TerminateInstance(ctx context.Context, instanceID string) error {
+	op := internal.StartOperation(fmt.Sprintf("terminate instance %s", instanceID))
+	_, err := p.client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{
+		InstanceIds: []string{instanceID},
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to terminate instance: %w", err)
+	}
+	return nil
 }
 
-// describeInstance provides details of an EC2 instance.
-func describeInstance(client *ec2.Client, instanceID string) {
-	resp, err := client.DescribeInstances(context.TODO(), &ec2.DescribeInstancesInput{
+// DescribeInstance provides details of an EC2 instance.
+func (p *Provider) DescribeInstance(ctx context.Context, instanceID string) (clouds.Instance, error) {
+	op := internal.StartOperation(fmt.Sprintf("describe instance %s", instanceID))
+	resp, err := p.client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
 		InstanceIds: []string{instanceID},
 	})
-	if err != nil {
-		log.Fatalf("Failed to describe instance: %v", err)
+	if err := op.Finish(err); err != nil {
+		return clouds.Instance{}, fmt.Errorf("failed to describe instance: %w", err)
 	}
 
 	for _, reservation := range resp.Reservations {
 		for _, instance := range reservation.Instances {
-			fmt.Printf("Instance ID: %s, State: %s, Public IP: %s\n",
-				*instance.InstanceId, instance.State.Name, aws.ToString(instance.PublicIpAddress))
+			return toInstance(instance), nil
 		}
 	}
+	return clouds.Instance{}, fmt.Errorf("instance %s: %w", instanceID, clouds.ErrNotFound)
 }
 
-// listRegions lists all available AWS regions.
-func listRegions(client *ec2.Client) {
-	resp, err := client.DescribeRegions(context.TODO(), &ec2.DescribeRegionsInput{})
-	if err != nil {
-		log.Fatalf("Failed to list regions: %v", err)
+// rootDisk returns the mapping that resizes the root volume of an AMI. The
+// device name of the root volume differs between AMIs.
+func (p *Provider) rootDisk(ctx context.Context, imageID string, sizeGiB int) (ec2types.BlockDeviceMapping, error) {
+	op := internal.StartOperation(fmt.Sprintf("describe image %s", imageID))
+	resp, err := p.client.DescribeImages(ctx, &ec2.DescribeImagesInput{ImageIds: []string{imageID}})
+	if err := op.Finish(err); err != nil {
+		return ec2types.BlockDeviceMapping{}, fmt.Errorf("failed to describe image: %w", err)
 	}
-
-	for _, region := range resp.Regions {
-		fmt.Printf("Region Name: %s\n", *region.RegionName)
+	if len(resp.Images) == 0 {
+		return ec2types.BlockDeviceMapping{}, fmt.Errorf("image %s: %w", imageID, clouds.ErrNotFound)
 	}
+	return ec2types.BlockDeviceMapping{
+		DeviceName: resp.Images[0].RootDeviceName,
+		Ebs: &ec2types.EbsBlockDevice{
+			VolumeSize:          aws.Int32(int32(sizeGiB)),
+			VolumeType:          ec2types.VolumeTypeGp3,
+			DeleteOnTermination: aws.Bool(true),
+		},
+	}, nil
 }
 
-// createKeyPair creates a new key pair.
-func createKeyPair(client *ec2.Client, keyName string) {
-	resp, err := client.CreateKeyPair(context.TODO(), &ec2.CreateKeyPairInput{
-		KeyName: aws.String(keyName),
-	})
-	if err != nil {
-		log.Fatalf("Failed to create key pair: %v", err)
+// ListRegions lists all available AWS regions.
+func (p *Provider) ListRegions(ctx context.Context) ([]string, error) {
+	op := internal.StartOperation("list AWS regions")
+	resp, err := p.client.DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
+	if err := op.Finish(err); err != nil {
+		return nil, fmt.Errorf("failed to list regions: %w", err)
 	}
 
-	fmt.Printf("Key Pair Created: %s\nPrivate Key:\n%s\n", *resp.KeyName, *resp.KeyMaterial)
-}
-
-// createSecurityGroup creates a new security group.
-func createSecurityGroup(client *ec2.Client, name, description string) {
-	resp, err := client.CreateSecurityGroup(context.TODO(), &ec2.CreateSecurityGroupInput{
-		GroupName:   aws.String(name),
-		Description: aws.String(description),
-	})
-	if err != nil {
-		log.Fatalf("Failed to create security group: %v", err)
+	var regions []string
+	for _, region := range resp.Regions {
+		regions = append(regions, aws.ToString(region.RegionName))
 	}
-
-	fmt.Printf("Security Group Created: %s\n", *resp.GroupId)
+	return regions, nil
 }
 
-// authorizeSecurityGroup adds a rule to a security group.
-func authorizeSecurityGroup(client *ec2.Client, groupID, protocol, portRange string) {
-	// Parse the port range
-	var fromPort, toPort int32
-	fmt.Sscanf(portRange, "%d-%d", &fromPort, &toPort)
-
-	_, err := client.AuthorizeSecurityGroupIngress(context.TODO(), &ec2.AuthorizeSecurityGroupIngressInput{
-		GroupId: aws.String(groupID),
-		IpPermissions: []ec2types.IpPermission{
-			{
-				IpProtocol: aws.String(protocol),
-				FromPort:   aws.Int32(fromPort),
-				ToPort:     aws.Int32(toPort),
-				IpRanges: []ec2types.IpRange{
-					{CidrIp: aws.String("0.0.0.0/0")},
-				},
-			},
-		},
-	})
-	if err != nil {
-		log.Fatalf("Failed to authorize security group ingress: %v", err)
+// toInstance converts an EC2 instance to the provider-neutral representation.
+func toInstance(instance ec2types.Instance) clouds.Instance {
+	result := clouds.Instance{
+		ID:         aws.ToString(instance.InstanceId),
+		Type:       string(instance.InstanceType),
+		PublicIP:   aws.ToString(instance.PublicIpAddress),
+		PrivateIP:  aws.ToString(instance.PrivateIpAddress),
+		LaunchTime: aws.ToTime(instance.LaunchTime),
+		KeyName:    aws.ToString(instance.KeyName),
 	}
-
-	fmt.Printf("Ingress rule added to Security Group: %s\n", groupID)
+	if instance.State != nil {
+		result.State = string(instance.State.Name)
+	}
+	if instance.Placement != nil {
+		result.Location = aws.ToString(instance.Placement.AvailabilityZone)
+	}
+	for _, tag := range instance.Tags {
+		if aws.ToString(tag.Key) == "Name" {
+			result.Name = aws.ToString(tag.Value)
+		}
+		if result.Tags == nil {
+			result.Tags = make(map[string]string)
+		}
+		result.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return result
 }
@@ -0,0 +1,90 @@
+package awscloud
+
+import (
+	"context"
+	"errors"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+	"namaste-cloud/internal/cassette"
+	"path/filepath"
+	"testing"
+)
+
+// newTestProvider returns a provider whose API calls are replayed from the
+// test's cassette. When recording, the stored credentials are used instead
+// of placeholders.
+func newTestProvider(t *testing.T) *Provider {
+	t.Helper()
+
+	rec := cassette.Use(t, filepath.Join("testdata", "cassettes", t.Name()+".yaml"))
+	if !rec.Recording() {
+		t.Setenv(internal.ConfigDirEnv, t.TempDir())
+		t.Setenv("AWS_CA_BUNDLE", "")
+		if err := internal.SaveCredential(internal.Credential{Cloud: "aws", AccessKey: "AKIDEXAMPLE", SecretKey: "secret"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p, err := New(context.Background(), internal.Config{
+		Region: "us-east-1",
+		Retry:  internal.RetryPolicy{MaxAttempts: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestListInstances(t *testing.T) {
+	p := newTestProvider(t)
+
+	instances, err := p.ListInstances(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 2 {
+		t.Fatalf("got %d instances, want 2", len(instances))
+	}
+
+	web := instances[0]
+	if web.ID != "i-0123456789abcdef0" || web.Name != "web" || web.State != "running" ||
+		web.Type != "t3.micro" || web.Location != "us-east-1a" ||
+		web.PublicIP != "203.0.113.10" || web.PrivateIP != "10.0.1.10" {
+		t.Errorf("unexpected instance: %+v", web)
+	}
+	if instances[1].State != "stopped" || instances[1].PublicIP != "" {
+		t.Errorf("unexpected instance: %+v", instances[1])
+	}
+}
+
+func TestDescribeInstance(t *testing.T) {
+	p := newTestProvider(t)
+
+	instance, err := p.DescribeInstance(context.Background(), "i-0123456789abcdef0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instance.Name != "web" || instance.State != "running" {
+		t.Errorf("unexpected instance: %+v", instance)
+	}
+}
+
+func TestDescribeInstanceNotFound(t *testing.T) {
+	p := newTestProvider(t)
+
+	_, err := p.DescribeInstance(context.Background(), "i-00000000000000000")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if errors.Is(err, clouds.ErrNotFound) {
+		t.Errorf("a failed API call should not look like an empty result: %v", err)
+	}
+}
+
+func TestStopInstance(t *testing.T) {
+	p := newTestProvider(t)
+
+	if err := p.StopInstance(context.Background(), "i-0123456789abcdef0"); err != nil {
+		t.Fatal(err)
+	}
+}
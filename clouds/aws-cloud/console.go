@@ -0,0 +1,47 @@
+package awscloud
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"namaste-cloud/internal"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+// ConsoleOutput returns the serial console output of an EC2 instance. EC2
+// only keeps the most recent 64 KB of it.
+func (p *Provider) ConsoleOutput(ctx context.Context, instanceID string) (string, error) {
+	op := internal.StartOperation(fmt.Sprintf("get console output of instance %s", instanceID))
+	resp, err := p.client.GetConsoleOutput(ctx, &ec2.GetConsoleOutputInput{
+		InstanceId: aws.String(instanceID),
+	})
+	if err := op.Finish(err); err != nil {
+		return "", fmt.Errorf("failed to get console output: %w", err)
+	}
+
+	output, err := base64.StdEncoding.DecodeString(aws.ToString(resp.Output))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode console output: %w", err)
+	}
+	return string(output), nil
+}
+
+// ConsoleScreenshot returns a JPEG screenshot of an EC2 instance's display.
+func (p *Provider) ConsoleScreenshot(ctx context.Context, instanceID string) ([]byte, error) {
+	op := internal.StartOperation(fmt.Sprintf("get console screenshot of instance %s", instanceID))
+	resp, err := p.client.GetConsoleScreenshot(ctx, &ec2.GetConsoleScreenshotInput{
+		InstanceId: aws.String(instanceID),
+		WakeUp:     aws.Bool(true),
+	})
+	if err := op.Finish(err); err != nil {
+		return nil, fmt.Errorf("failed to get console screenshot: %w", err)
+	}
+
+	image, err := base64.StdEncoding.DecodeString(aws.ToString(resp.ImageData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode console screenshot: %w", err)
+	}
+	return image, nil
+}
@@ -0,0 +1,18 @@
+package awscloud
+
+import (
+	"context"
+	"testing"
+)
+
+func TestConsoleOutput(t *testing.T) {
+	p := newTestProvider(t)
+
+	output, err := p.ConsoleOutput(context.Background(), "i-0123456789abcdef0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Booting Linux\nip-10-0-1-10 login:\n"; output != want {
+		t.Errorf("got %q, want %q", output, want)
+	}
+}
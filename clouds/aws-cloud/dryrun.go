@@ -0,0 +1,44 @@
+package awscloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/smithy-go"
+)
+
+// DryRunInstanceAction checks with EC2's DryRun parameter whether a
+// lifecycle action on an instance would be allowed. EC2 answers a dry run
+// that would succeed with a DryRunOperation error.
+func (p *Provider) DryRunInstanceAction(ctx context.Context, action clouds.InstanceAction, instanceID string) error {
+	ids := []string{instanceID}
+	op := internal.StartOperation(fmt.Sprintf("dry run %s instance %s", action, instanceID))
+
+	var err error
+	switch action {
+	case clouds.ActionStart:
+		_, err = p.client.StartInstances(ctx, &ec2.StartInstancesInput{InstanceIds: ids, DryRun: aws.Bool(true)})
+	case clouds.ActionStop:
+		_, err = p.client.StopInstances(ctx, &ec2.StopInstancesInput{InstanceIds: ids, DryRun: aws.Bool(true)})
+	case clouds.ActionReboot:
+		_, err = p.client.RebootInstances(ctx, &ec2.RebootInstancesInput{InstanceIds: ids, DryRun: aws.Bool(true)})
+	case clouds.ActionTerminate:
+		_, err = p.client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{InstanceIds: ids, DryRun: aws.Bool(true)})
+	default:
+		err = fmt.Errorf("unknown instance action %q", action)
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "DryRunOperation" {
+		err = nil
+	}
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to %s instance: %w", action, err)
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package awscloud
+
+import (
+	"context"
+	"namaste-cloud/clouds"
+	"strings"
+	"testing"
+)
+
+func TestDryRunInstanceAction(t *testing.T) {
+	p := newTestProvider(t)
+
+	if err := p.DryRunInstanceAction(context.Background(), clouds.ActionStop, "i-0123456789abcdef0"); err != nil {
+		t.Errorf("stop: %v", err)
+	}
+	err := p.DryRunInstanceAction(context.Background(), clouds.ActionTerminate, "i-0123456789abcdef0")
+	if err == nil || !strings.Contains(err.Error(), "UnauthorizedOperation") {
+		t.Errorf("terminate: got %v, want UnauthorizedOperation", err)
+	}
+}
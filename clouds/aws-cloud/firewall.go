@@ -0,0 +1,444 @@
+package awscloud
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// Tags that mark the security groups managed as firewall rules.
+const (
+	firewallRuleTag    = "namaste:firewall-rule"
+	firewallTargetsTag = "namaste:firewall-targets"
+)
+
+// ListFirewallRules lists the firewall rules. Each rule is a security group
+// tagged with the rule name in every VPC it targets instances in.
+func (p *Provider) ListFirewallRules(ctx context.Context) ([]clouds.FirewallRule, error) {
+	op := internal.StartOperation("list firewall rules")
+
+	var rules []clouds.FirewallRule
+	seen := make(map[string]bool)
+	paginator := ec2.NewDescribeSecurityGroupsPaginator(p.client, &ec2.DescribeSecurityGroupsInput{
+		Filters: []ec2types.Filter{{Name: aws.String("tag-key"), Values: []string{firewallRuleTag}}},
+	})
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list firewall rules: %w", op.Finish(err))
+		}
+		for _, group := range resp.SecurityGroups {
+			rule := toFirewallRule(group)
+			if !seen[rule.Name] {
+				seen[rule.Name] = true
+				rules = append(rules, rule)
+			}
+		}
+	}
+	op.Finish(nil)
+
+	return rules, nil
+}
+
+// ApplyFirewallRule creates the security groups of a firewall rule, or
+// replaces the permissions of the existing ones, and attaches them to the
+// network interfaces of the instances with one of the rule's target tags.
+// Security groups belong to a VPC, so the rule has a group in each VPC of
+// its instances. Instances created or tagged later are only attached when
+// the rule is applied again.
+func (p *Provider) ApplyFirewallRule(ctx context.Context, rule clouds.FirewallRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	permissions, err := firewallPermissions(rule)
+	if err != nil {
+		return err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("apply firewall rule %s", rule.Name))
+	err = p.applyFirewallGroups(ctx, rule, permissions)
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to apply firewall rule: %w", err)
+	}
+	return nil
+}
+
+// DeleteFirewallRule detaches the security groups of a firewall rule from
+// their network interfaces and deletes them.
+func (p *Provider) DeleteFirewallRule(ctx context.Context, name string) error {
+	op := internal.StartOperation(fmt.Sprintf("delete firewall rule %s", name))
+	err := p.deleteFirewallGroups(ctx, name)
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to delete firewall rule: %w", err)
+	}
+	return nil
+}
+
+// applyFirewallGroups creates or updates the security groups of a firewall
+// rule and syncs the network interfaces they are attached to.
+func (p *Provider) applyFirewallGroups(ctx context.Context, rule clouds.FirewallRule, permissions []ec2types.IpPermission) error {
+	targets, err := p.firewallTargets(ctx, rule.TargetTags)
+	if err != nil {
+		return err
+	}
+	groups, err := p.findFirewallGroups(ctx, rule.Name)
+	if err != nil {
+		return err
+	}
+
+	// Every VPC with a target needs a group, and a rule that targets nothing
+	// yet still needs one, in the default VPC, to exist at all
+	groupIDs := make(map[string]string)
+	for _, group := range groups {
+		groupIDs[aws.ToString(group.VpcId)] = aws.ToString(group.GroupId)
+	}
+	var vpcs []string
+	for _, id := range slices.Sorted(maps.Keys(targets)) {
+		vpc := aws.ToString(targets[id].VpcId)
+		if _, ok := groupIDs[vpc]; !ok && !slices.Contains(vpcs, vpc) {
+			vpcs = append(vpcs, vpc)
+		}
+	}
+	if len(groups) == 0 && len(vpcs) == 0 {
+		vpcs = append(vpcs, "")
+	}
+	for _, vpc := range vpcs {
+		group, err := p.createFirewallGroup(ctx, rule, vpc)
+		if err != nil {
+			return err
+		}
+		groups = append(groups, *group)
+		groupIDs[aws.ToString(group.VpcId)] = aws.ToString(group.GroupId)
+	}
+
+	for _, group := range groups {
+		if err := p.updateFirewallGroup(ctx, group, rule, permissions); err != nil {
+			return err
+		}
+	}
+	for _, group := range groups {
+		if err := p.syncFirewallTargets(ctx, aws.ToString(group.GroupId), aws.ToString(group.VpcId), targets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createFirewallGroup creates the security group of a firewall rule in a
+// VPC, or in the default VPC when vpc is empty.
+func (p *Provider) createFirewallGroup(ctx context.Context, rule clouds.FirewallRule, vpc string) (*ec2types.SecurityGroup, error) {
+	description := rule.Description
+	if description == "" {
+		description = "Firewall rule " + rule.Name
+	}
+	input := &ec2.CreateSecurityGroupInput{
+		GroupName:         aws.String(rule.Name),
+		Description:       aws.String(description),
+		TagSpecifications: []ec2types.TagSpecification{{ResourceType: ec2types.ResourceTypeSecurityGroup, Tags: firewallTags(rule)}},
+	}
+	if vpc != "" {
+		input.VpcId = aws.String(vpc)
+	}
+	resp, err := p.client.CreateSecurityGroup(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the group back for its VPC and the default egress rule EC2 adds to it
+	described, err := p.client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
+		GroupIds: []string{aws.ToString(resp.GroupId)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(described.SecurityGroups) == 0 {
+		return nil, fmt.Errorf("security group %s: %w", aws.ToString(resp.GroupId), clouds.ErrNotFound)
+	}
+	return &described.SecurityGroups[0], nil
+}
+
+// updateFirewallGroup replaces whatever a security group allowed with
+// exactly the rule's permissions, and updates its tags.
+func (p *Provider) updateFirewallGroup(ctx context.Context, group ec2types.SecurityGroup, rule clouds.FirewallRule, permissions []ec2types.IpPermission) error {
+	if _, err := p.client.CreateTags(ctx, &ec2.CreateTagsInput{
+		Resources: []string{aws.ToString(group.GroupId)},
+		Tags:      firewallTags(rule),
+	}); err != nil {
+		return err
+	}
+
+	if len(group.IpPermissions) > 0 {
+		if _, err := p.client.RevokeSecurityGroupIngress(ctx, &ec2.RevokeSecurityGroupIngressInput{
+			GroupId:       group.GroupId,
+			IpPermissions: group.IpPermissions,
+		}); err != nil {
+			return err
+		}
+	}
+	if len(group.IpPermissionsEgress) > 0 {
+		if _, err := p.client.RevokeSecurityGroupEgress(ctx, &ec2.RevokeSecurityGroupEgressInput{
+			GroupId:       group.GroupId,
+			IpPermissions: group.IpPermissionsEgress,
+		}); err != nil {
+			return err
+		}
+	}
+	var err error
+	if rule.Direction == clouds.Egress {
+		_, err = p.client.AuthorizeSecurityGroupEgress(ctx, &ec2.AuthorizeSecurityGroupEgressInput{
+			GroupId:       group.GroupId,
+			IpPermissions: permissions,
+		})
+	} else {
+		_, err = p.client.AuthorizeSecurityGroupIngress(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
+			GroupId:       group.GroupId,
+			IpPermissions: permissions,
+		})
+	}
+	return err
+}
+
+// deleteFirewallGroups detaches and deletes the security groups of a firewall rule.
+func (p *Provider) deleteFirewallGroups(ctx context.Context, name string) error {
+	groups, err := p.findFirewallGroups(ctx, name)
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		return fmt.Errorf("firewall rule %s: %w", name, clouds.ErrNotFound)
+	}
+
+	for _, group := range groups {
+		if err := p.syncFirewallTargets(ctx, aws.ToString(group.GroupId), aws.ToString(group.VpcId), nil); err != nil {
+			return err
+		}
+		if _, err := p.client.DeleteSecurityGroup(ctx, &ec2.DeleteSecurityGroupInput{GroupId: group.GroupId}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findFirewallGroups returns the security groups of a firewall rule.
+func (p *Provider) findFirewallGroups(ctx context.Context, name string) ([]ec2types.SecurityGroup, error) {
+	var groups []ec2types.SecurityGroup
+	paginator := ec2.NewDescribeSecurityGroupsPaginator(p.client, &ec2.DescribeSecurityGroupsInput{
+		Filters: []ec2types.Filter{{Name: aws.String("tag:" + firewallRuleTag), Values: []string{name}}},
+	})
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, resp.SecurityGroups...)
+	}
+	return groups, nil
+}
+
+// firewallTargets returns the network interfaces of the instances with one
+// of the target tags, by ID.
+func (p *Provider) firewallTargets(ctx context.Context, targetTags []string) (map[string]ec2types.InstanceNetworkInterface, error) {
+	targets := make(map[string]ec2types.InstanceNetworkInterface)
+	for _, tag := range targetTags {
+		key, value, err := clouds.ParseTargetTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		instances, err := p.describeInstances(ctx, []ec2types.Filter{
+			{Name: aws.String("instance-state-name"), Values: []string{"pending", "running", "stopping", "stopped"}},
+			{Name: aws.String("tag:" + key), Values: []string{value}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, instance := range instances {
+			for _, nic := range instance.NetworkInterfaces {
+				targets[aws.ToString(nic.NetworkInterfaceId)] = nic
+			}
+		}
+	}
+	return targets, nil
+}
+
+// syncFirewallTargets attaches the security group to the target network
+// interfaces in its VPC and detaches it from the instance network
+// interfaces that are no longer targets.
+func (p *Provider) syncFirewallTargets(ctx context.Context, groupID, vpc string, targets map[string]ec2types.InstanceNetworkInterface) error {
+	for _, id := range slices.Sorted(maps.Keys(targets)) {
+		nic := targets[id]
+		if aws.ToString(nic.VpcId) != vpc {
+			continue
+		}
+		groups := groupIDs(nic.Groups)
+		if !slices.Contains(groups, groupID) {
+			if err := p.setInterfaceGroups(ctx, id, append(groups, groupID)); err != nil {
+				return err
+			}
+		}
+	}
+
+	var attached []ec2types.NetworkInterface
+	paginator := ec2.NewDescribeNetworkInterfacesPaginator(p.client, &ec2.DescribeNetworkInterfacesInput{
+		Filters: []ec2types.Filter{{Name: aws.String("group-id"), Values: []string{groupID}}},
+	})
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		attached = append(attached, resp.NetworkInterfaces...)
+	}
+	for _, nic := range attached {
+		id := aws.ToString(nic.NetworkInterfaceId)
+		// Only instance interfaces are ever attached, leave the others alone
+		if _, ok := targets[id]; ok || nic.Attachment == nil || nic.Attachment.InstanceId == nil {
+			continue
+		}
+		if err := p.setInterfaceGroups(ctx, id, without(groupIDs(nic.Groups), groupID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// describeInstances returns the instances matching the filters.
+func (p *Provider) describeInstances(ctx context.Context, filters []ec2types.Filter) ([]ec2types.Instance, error) {
+	var instances []ec2types.Instance
+	paginator := ec2.NewDescribeInstancesPaginator(p.client, &ec2.DescribeInstancesInput{Filters: filters})
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, reservation := range resp.Reservations {
+			instances = append(instances, reservation.Instances...)
+		}
+	}
+	return instances, nil
+}
+
+// setInterfaceGroups replaces the security groups of a network interface.
+func (p *Provider) setInterfaceGroups(ctx context.Context, id string, groups []string) error {
+	if len(groups) == 0 {
+		return fmt.Errorf("cannot remove the last security group of network interface %s", id)
+	}
+	_, err := p.client.ModifyNetworkInterfaceAttribute(ctx, &ec2.ModifyNetworkInterfaceAttributeInput{
+		NetworkInterfaceId: aws.String(id),
+		Groups:             groups,
+	})
+	return err
+}
+
+// groupIDs returns the IDs of security groups.
+func groupIDs(groups []ec2types.GroupIdentifier) []string {
+	var ids []string
+	for _, group := range groups {
+		ids = append(ids, aws.ToString(group.GroupId))
+	}
+	return ids
+}
+
+// without returns the IDs other than id.
+func without(ids []string, id string) []string {
+	var result []string
+	for _, other := range ids {
+		if other != id {
+			result = append(result, other)
+		}
+	}
+	return result
+}
+
+// firewallTags returns the tags that mark the security groups of a rule.
+func firewallTags(rule clouds.FirewallRule) []ec2types.Tag {
+	return []ec2types.Tag{
+		{Key: aws.String(firewallRuleTag), Value: aws.String(rule.Name)},
+		{Key: aws.String(firewallTargetsTag), Value: aws.String(strings.Join(rule.TargetTags, ","))},
+	}
+}
+
+// firewallPermissions converts a firewall rule to EC2 permissions, one per port range.
+func firewallPermissions(rule clouds.FirewallRule) ([]ec2types.IpPermission, error) {
+	ports := rule.Ports
+	if len(ports) == 0 {
+		ports = []string{""}
+	}
+
+	var permissions []ec2types.IpPermission
+	for _, port := range ports {
+		r, err := NewRule(rule.Protocol, port)
+		if err != nil {
+			return nil, err
+		}
+		r.Description = rule.Description
+		for _, cidr := range rule.Ranges() {
+			if err := r.AddCIDR(cidr); err != nil {
+				return nil, err
+			}
+		}
+		permissions = append(permissions, ipPermission(r))
+	}
+	return permissions, nil
+}
+
+// toFirewallRule converts the security group of a firewall rule back to the rule.
+func toFirewallRule(group ec2types.SecurityGroup) clouds.FirewallRule {
+	rule := clouds.FirewallRule{
+		Name:        aws.ToString(group.GroupName),
+		Description: aws.ToString(group.Description),
+		Direction:   clouds.Ingress,
+	}
+	for _, tag := range group.Tags {
+		switch aws.ToString(tag.Key) {
+		case firewallRuleTag:
+			rule.Name = aws.ToString(tag.Value)
+		case firewallTargetsTag:
+			if targets := aws.ToString(tag.Value); targets != "" {
+				rule.TargetTags = strings.Split(targets, ",")
+			}
+		}
+	}
+
+	permissions := group.IpPermissions
+	if len(permissions) == 0 {
+		rule.Direction = clouds.Egress
+		permissions = group.IpPermissionsEgress
+	}
+	var cidrs []string
+	for _, permission := range permissions {
+		r := toRule(permission)
+		switch r.Protocol {
+		case protocolAll:
+			rule.Protocol = clouds.ProtocolAll
+		case "tcp", "udp":
+			rule.Protocol = r.Protocol
+			ports := strconv.Itoa(int(r.FromPort))
+			if r.ToPort != r.FromPort {
+				ports += "-" + strconv.Itoa(int(r.ToPort))
+			}
+			rule.Ports = append(rule.Ports, ports)
+		default:
+			rule.Protocol = r.Protocol
+		}
+		for _, cidr := range r.CIDRs {
+			if !slices.Contains(cidrs, cidr) {
+				cidrs = append(cidrs, cidr)
+			}
+		}
+	}
+	if rule.Direction == clouds.Egress {
+		rule.Destinations = cidrs
+	} else {
+		rule.Sources = cidrs
+	}
+	return rule
+}
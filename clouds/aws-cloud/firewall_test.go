@@ -0,0 +1,27 @@
+package awscloud
+
+import (
+	"context"
+	"namaste-cloud/clouds"
+	"testing"
+)
+
+// TestApplyFirewallRule applies a rule whose targets are in two VPCs: the
+// existing group in the first is updated, a group is created in the second,
+// and every network interface of the targets is attached while an instance
+// that lost its tag is detached.
+func TestApplyFirewallRule(t *testing.T) {
+	p := newTestProvider(t)
+
+	rule := clouds.FirewallRule{
+		Name:       "allow-ssh",
+		Direction:  clouds.Ingress,
+		Protocol:   clouds.ProtocolTCP,
+		Ports:      []string{"22"},
+		Sources:    []string{"10.0.0.0/8"},
+		TargetTags: []string{"role=web"},
+	}
+	if err := p.ApplyFirewallRule(context.Background(), rule); err != nil {
+		t.Fatal(err)
+	}
+}
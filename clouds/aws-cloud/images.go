@@ -0,0 +1,101 @@
+package awscloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// amiSource is where the current AMI of a release is found: the public SSM
+// parameter its publisher keeps up to date, or else the newest AMI of the
+// publisher's account with a matching name.
+type amiSource struct {
+	parameter string
+	owner     string
+	name      string
+}
+
+// amiSources are the AMI sources of each release alias by architecture.
+var amiSources = map[string]map[string]amiSource{
+	"ubuntu-24.04": {
+		clouds.ArchX86: {parameter: "/aws/service/canonical/ubuntu/server/24.04/stable/current/amd64/hvm/ebs-gp3/ami-id"},
+		clouds.ArchARM: {parameter: "/aws/service/canonical/ubuntu/server/24.04/stable/current/arm64/hvm/ebs-gp3/ami-id"},
+	},
+	"ubuntu-22.04": {
+		clouds.ArchX86: {parameter: "/aws/service/canonical/ubuntu/server/22.04/stable/current/amd64/hvm/ebs-gp2/ami-id"},
+		clouds.ArchARM: {parameter: "/aws/service/canonical/ubuntu/server/22.04/stable/current/arm64/hvm/ebs-gp2/ami-id"},
+	},
+	"debian-12": {
+		clouds.ArchX86: {parameter: "/aws/service/debian/release/12/latest/amd64"},
+		clouds.ArchARM: {parameter: "/aws/service/debian/release/12/latest/arm64"},
+	},
+	"debian-11": {
+		clouds.ArchX86: {parameter: "/aws/service/debian/release/11/latest/amd64"},
+		clouds.ArchARM: {parameter: "/aws/service/debian/release/11/latest/arm64"},
+	},
+	"amazon-linux-2023": {
+		clouds.ArchX86: {parameter: "/aws/service/ami-amazon-linux-latest/al2023-ami-kernel-default-x86_64"},
+		clouds.ArchARM: {parameter: "/aws/service/ami-amazon-linux-latest/al2023-ami-kernel-default-arm64"},
+	},
+	"rhel-9": {
+		clouds.ArchX86: {owner: "309956199498", name: "RHEL-9.*_HVM-*-x86_64-*-Hourly2-GP3"},
+		clouds.ArchARM: {owner: "309956199498", name: "RHEL-9.*_HVM-*-arm64-*-Hourly2-GP3"},
+	},
+	"rocky-9": {
+		clouds.ArchX86: {owner: "792107900819", name: "Rocky-9-EC2-Base-9.*x86_64"},
+		clouds.ArchARM: {owner: "792107900819", name: "Rocky-9-EC2-Base-9.*aarch64"},
+	},
+}
+
+// ResolveImage looks up the current AMI of a release in the region.
+func (p *Provider) ResolveImage(ctx context.Context, alias, arch string) (clouds.Image, error) {
+	source, ok := amiSources[alias][arch]
+	if !ok {
+		return clouds.Image{}, fmt.Errorf("%s image for %s on AWS: %w", alias, arch, clouds.ErrNotFound)
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("look up the %s AMI for %s", alias, arch))
+	input := &ec2.DescribeImagesInput{}
+	if source.parameter != "" {
+		resp, err := p.ssm.GetParameter(ctx, &ssm.GetParameterInput{Name: aws.String(source.parameter)})
+		if err != nil {
+			return clouds.Image{}, fmt.Errorf("failed to look up the %s AMI: %w", alias, op.Finish(err))
+		}
+		input.ImageIds = []string{aws.ToString(resp.Parameter.Value)}
+	} else {
+		input.Owners = []string{source.owner}
+		input.Filters = []ec2types.Filter{
+			{Name: aws.String("name"), Values: []string{source.name}},
+			{Name: aws.String("state"), Values: []string{"available"}},
+		}
+	}
+	resp, err := p.client.DescribeImages(ctx, input)
+	if err := op.Finish(err); err != nil {
+		return clouds.Image{}, fmt.Errorf("failed to look up the %s AMI: %w", alias, err)
+	}
+	if len(resp.Images) == 0 {
+		return clouds.Image{}, fmt.Errorf("%s image for %s in this region: %w", alias, arch, clouds.ErrNotFound)
+	}
+
+	// Publishers keep older releases available; the newest is current
+	images := resp.Images
+	sort.Slice(images, func(i, j int) bool {
+		return aws.ToString(images[i].CreationDate) > aws.ToString(images[j].CreationDate)
+	})
+	created, _ := time.Parse(time.RFC3339, aws.ToString(images[0].CreationDate))
+	return clouds.Image{
+		ID:        aws.ToString(images[0].ImageId),
+		Name:      aws.ToString(images[0].Name),
+		Alias:     alias,
+		Arch:      arch,
+		CreatedAt: created,
+	}, nil
+}
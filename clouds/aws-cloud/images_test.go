@@ -0,0 +1,34 @@
+package awscloud
+
+import (
+	"context"
+	"errors"
+	"namaste-cloud/clouds"
+	"testing"
+)
+
+func TestResolveImage(t *testing.T) {
+	p := newTestProvider(t)
+
+	// Ubuntu publishes its current AMIs as public SSM parameters
+	image, err := p.ResolveImage(context.Background(), "ubuntu-24.04", clouds.ArchX86)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image.ID != "ami-0866a3c8686eaeeba" || image.Name != "ubuntu/images/hvm-ssd-gp3/ubuntu-noble-24.04-amd64-server-20240801" {
+		t.Errorf("unexpected image: %+v", image)
+	}
+
+	// Red Hat does not, so the newest AMI of its account is current
+	image, err = p.ResolveImage(context.Background(), "rhel-9", clouds.ArchX86)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image.ID != "ami-0b5eea76982371e91" || image.CreatedAt.Year() != 2024 || image.CreatedAt.Month() != 6 {
+		t.Errorf("unexpected image: %+v", image)
+	}
+
+	if _, err := p.ResolveImage(context.Background(), "windows-95", clouds.ArchX86); !errors.Is(err, clouds.ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
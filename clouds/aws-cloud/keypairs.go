@@ -0,0 +1,106 @@
+package awscloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// errInstanceKeys is returned for key pairs limited to an instance, which EC2 does not support.
+var errInstanceKeys = fmt.Errorf("instance-specific key pairs: %w", clouds.ErrNotSupported)
+
+// ListKeyPairs lists the EC2 key pairs of the region.
+func (p *Provider) ListKeyPairs(ctx context.Context) ([]clouds.KeyPair, error) {
+	op := internal.StartOperation("list key pairs")
+	resp, err := p.client.DescribeKeyPairs(ctx, &ec2.DescribeKeyPairsInput{
+		IncludePublicKey: aws.Bool(true),
+	})
+	if err := op.Finish(err); err != nil {
+		return nil, fmt.Errorf("failed to list key pairs: %w", err)
+	}
+
+	var keys []clouds.KeyPair
+	for _, info := range resp.KeyPairs {
+		keys = append(keys, toKeyPair(info))
+	}
+	return keys, nil
+}
+
+// CreateKeyPair creates an EC2 key pair and returns its private key material.
+func (p *Provider) CreateKeyPair(ctx context.Context, key clouds.KeyPair) (clouds.KeyPair, string, error) {
+	if key.Instance != "" {
+		return clouds.KeyPair{}, "", errInstanceKeys
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("create key pair %s", key.Name))
+	resp, err := p.client.CreateKeyPair(ctx, &ec2.CreateKeyPairInput{
+		KeyName:           aws.String(key.Name),
+		TagSpecifications: tagSpecifications(ec2types.ResourceTypeKeyPair, p.defaultTags),
+	})
+	if err := op.Finish(err); err != nil {
+		return clouds.KeyPair{}, "", fmt.Errorf("failed to create key pair: %w", err)
+	}
+
+	created := clouds.KeyPair{
+		Name:        aws.ToString(resp.KeyName),
+		Fingerprint: aws.ToString(resp.KeyFingerprint),
+	}
+	return created, aws.ToString(resp.KeyMaterial), nil
+}
+
+// ImportKeyPair uploads an existing public key as an EC2 key pair.
+func (p *Provider) ImportKeyPair(ctx context.Context, key clouds.KeyPair) (clouds.KeyPair, error) {
+	if key.Instance != "" {
+		return clouds.KeyPair{}, errInstanceKeys
+	}
+	parsed, err := clouds.ParsePublicKey(key.PublicKey)
+	if err != nil {
+		return clouds.KeyPair{}, err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("import key pair %s", key.Name))
+	resp, err := p.client.ImportKeyPair(ctx, &ec2.ImportKeyPairInput{
+		KeyName:           aws.String(key.Name),
+		PublicKeyMaterial: []byte(parsed.PublicKey),
+		TagSpecifications: tagSpecifications(ec2types.ResourceTypeKeyPair, p.defaultTags),
+	})
+	if err := op.Finish(err); err != nil {
+		return clouds.KeyPair{}, fmt.Errorf("failed to import key pair: %w", err)
+	}
+
+	return clouds.KeyPair{
+		Name:        aws.ToString(resp.KeyName),
+		Fingerprint: aws.ToString(resp.KeyFingerprint),
+		PublicKey:   parsed.PublicKey,
+	}, nil
+}
+
+// DeleteKeyPair deletes an EC2 key pair.
+func (p *Provider) DeleteKeyPair(ctx context.Context, key clouds.KeyPair) error {
+	if key.Instance != "" {
+		return errInstanceKeys
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("delete key pair %s", key.Name))
+	_, err := p.client.DeleteKeyPair(ctx, &ec2.DeleteKeyPairInput{
+		KeyName: aws.String(key.Name),
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to delete key pair: %w", err)
+	}
+	return nil
+}
+
+// toKeyPair converts an EC2 key pair to the provider-neutral representation.
+func toKeyPair(info ec2types.KeyPairInfo) clouds.KeyPair {
+	return clouds.KeyPair{
+		Name:        aws.ToString(info.KeyName),
+		Fingerprint: aws.ToString(info.KeyFingerprint),
+		PublicKey:   aws.ToString(info.PublicKey),
+	}
+}
@@ -0,0 +1,38 @@
+package awscloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/internal"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// TerminationProtection reports whether termination protection
+// (DisableApiTermination) is enabled for an EC2 instance.
+func (p *Provider) TerminationProtection(ctx context.Context, instanceID string) (bool, error) {
+	op := internal.StartOperation(fmt.Sprintf("get termination protection of instance %s", instanceID))
+	resp, err := p.client.DescribeInstanceAttribute(ctx, &ec2.DescribeInstanceAttributeInput{
+		InstanceId: aws.String(instanceID),
+		Attribute:  ec2types.InstanceAttributeNameDisableApiTermination,
+	})
+	if err := op.Finish(err); err != nil {
+		return false, fmt.Errorf("failed to get termination protection: %w", err)
+	}
+	return resp.DisableApiTermination != nil && aws.ToBool(resp.DisableApiTermination.Value), nil
+}
+
+// SetTerminationProtection enables or disables termination protection for an EC2 instance.
+func (p *Provider) SetTerminationProtection(ctx context.Context, instanceID string, enabled bool) error {
+	op := internal.StartOperation(fmt.Sprintf("set termination protection of instance %s", instanceID))
+	_, err := p.client.ModifyInstanceAttribute(ctx, &ec2.ModifyInstanceAttributeInput{
+		InstanceId:            aws.String(instanceID),
+		DisableApiTermination: &ec2types.AttributeBooleanValue{Value: aws.Bool(enabled)},
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to set termination protection: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package awscloud
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTerminationProtection(t *testing.T) {
+	p := newTestProvider(t)
+	ctx := context.Background()
+
+	if err := p.SetTerminationProtection(ctx, "i-0123456789abcdef0", true); err != nil {
+		t.Fatal(err)
+	}
+	protected, err := p.TerminationProtection(ctx, "i-0123456789abcdef0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !protected {
+		t.Error("termination protection is not enabled")
+	}
+}
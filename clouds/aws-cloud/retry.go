@@ -0,0 +1,52 @@
+package awscloud
+
+import (
+	"errors"
+	"namaste-cloud/internal"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go"
+)
+
+// retryableErrorCodes are EC2 error codes that are safe to retry, in addition
+// to the transient errors the SDK already recognises.
+var retryableErrorCodes = map[string]bool{
+	"RequestLimitExceeded":     true,
+	"Throttling":               true,
+	"ThrottlingException":      true,
+	"RequestThrottled":         true,
+	"TooManyRequestsException": true,
+	"ServiceUnavailable":       true,
+	"Unavailable":              true,
+	"InternalError":            true,
+	"InternalFailure":          true,
+}
+
+// isRetryable classifies EC2 errors that are safe to retry.
+func isRetryable(err error) aws.Ternary {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && retryableErrorCodes[apiErr.ErrorCode()] {
+		return aws.TrueTernary
+	}
+	return aws.UnknownTernary
+}
+
+// newRetryer builds an SDK retryer that follows the shared retry policy.
+func newRetryer(policy internal.RetryPolicy) aws.Retryer {
+	policy = policy.WithDefaults()
+
+	return retry.NewStandard(func(o *retry.StandardOptions) {
+		o.MaxAttempts = policy.MaxAttempts
+		o.MaxBackoff = time.Duration(policy.MaxDelay)
+		o.Backoff = retry.BackoffDelayerFunc(func(attempt int, err error) (time.Duration, error) {
+			return policy.Backoff(attempt), nil
+		})
+		o.Retryables = append([]retry.IsErrorRetryable{retry.IsErrorRetryableFunc(isRetryable)}, o.Retryables...)
+
+		// Large fan-out operations would otherwise exhaust the client-side retry quota.
+		o.RateLimiter = ratelimit.None
+	})
+}
@@ -0,0 +1,391 @@
+package awscloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// protocolAll is the EC2 protocol value that matches all traffic.
+const protocolAll = "-1"
+
+// SecurityGroup is an EC2 security group and its rules.
+type SecurityGroup struct {
+	ID          string
+	Name        string
+	Description string
+	VPCID       string
+	Ingress     []Rule
+	Egress      []Rule
+}
+
+// Rule is a single security group rule. For TCP and UDP the ports are a
+// range; for ICMP they hold the type and code, where -1 means any. Traffic
+// is allowed from, or for egress to, the CIDRs and security groups listed.
+type Rule struct {
+	Protocol     string
+	FromPort     int32
+	ToPort       int32
+	CIDRs        []string
+	SourceGroups []string
+	Description  string
+}
+
+// NewRule returns a rule for the protocol and ports, without any sources yet.
+// The protocol is tcp, udp, icmp, icmpv6, all or an IP protocol number. Ports
+// are a port or range such as 22 or 8000-8080 for TCP and UDP, optional
+// TYPE[:CODE] for ICMP, and must be empty for any other protocol.
+func NewRule(protocol, ports string) (Rule, error) {
+	protocol = strings.ToLower(strings.TrimSpace(protocol))
+	switch protocol {
+	case "tcp", "udp":
+		from, to, err := parsePortRange(ports)
+		if err != nil {
+			return Rule{}, err
+		}
+		return Rule{Protocol: protocol, FromPort: from, ToPort: to}, nil
+	case "icmp", "icmpv6":
+		icmpType, code, err := parseICMP(ports)
+		if err != nil {
+			return Rule{}, err
+		}
+		return Rule{Protocol: protocol, FromPort: icmpType, ToPort: code}, nil
+	case "all", protocolAll:
+		protocol = protocolAll
+	case "":
+		return Rule{}, errors.New("a protocol is required")
+	default:
+		n, err := strconv.Atoi(protocol)
+		if err != nil || n < 0 || n > 255 {
+			return Rule{}, fmt.Errorf("invalid protocol %q: use tcp, udp, icmp, icmpv6, all or a protocol number", protocol)
+		}
+	}
+
+	if ports != "" {
+		return Rule{}, fmt.Errorf("ports cannot be set for protocol %s", protocol)
+	}
+	return Rule{Protocol: protocol}, nil
+}
+
+// AddCIDR adds a source CIDR, IPv4 or IPv6. A bare address is taken as a
+// single host.
+func (r *Rule) AddCIDR(cidr string) error {
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return fmt.Errorf("invalid CIDR %q", cidr)
+		}
+		if ip.To4() != nil {
+			cidr += "/32"
+		} else {
+			cidr += "/128"
+		}
+	}
+
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return fmt.Errorf("invalid CIDR %q", cidr)
+	}
+	r.CIDRs = append(r.CIDRs, network.String())
+	return nil
+}
+
+// AddSourceGroup adds a security group whose members the rule applies to.
+func (r *Rule) AddSourceGroup(groupID string) error {
+	if !strings.HasPrefix(groupID, "sg-") {
+		return fmt.Errorf("invalid security group ID %q", groupID)
+	}
+	r.SourceGroups = append(r.SourceGroups, groupID)
+	return nil
+}
+
+// Validate checks that the rule names at least one source.
+func (r Rule) Validate() error {
+	if r.Protocol == "" {
+		return errors.New("a protocol is required")
+	}
+	if len(r.CIDRs) == 0 && len(r.SourceGroups) == 0 {
+		return errors.New("at least one CIDR or source security group is required")
+	}
+	return nil
+}
+
+// OpenToWorld reports whether the rule applies to every IPv4 or IPv6 address.
+func (r Rule) OpenToWorld() bool {
+	for _, cidr := range r.CIDRs {
+		if cidr == "0.0.0.0/0" || cidr == "::/0" {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePortRange parses a single port or an inclusive range of ports.
+func parsePortRange(ports string) (int32, int32, error) {
+	if ports == "" {
+		return 0, 0, errors.New("a port or port range is required for TCP and UDP")
+	}
+
+	fromText, toText, isRange := strings.Cut(ports, "-")
+	from, err := parsePort(fromText)
+	if err != nil {
+		return 0, 0, err
+	}
+	to := from
+	if isRange {
+		if to, err = parsePort(toText); err != nil {
+			return 0, 0, err
+		}
+	}
+	if from > to {
+		return 0, 0, fmt.Errorf("invalid port range %q: the first port is higher than the last", ports)
+	}
+	return from, to, nil
+}
+
+// parsePort parses a port number between 0 and 65535.
+func parsePort(port string) (int32, error) {
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 {
+		return 0, fmt.Errorf("invalid port %q: ports are numbers between 0 and 65535", port)
+	}
+	return int32(n), nil
+}
+
+// parseICMP parses an ICMP TYPE[:CODE], where an empty type or code means any.
+func parseICMP(spec string) (int32, int32, error) {
+	if spec == "" {
+		return -1, -1, nil
+	}
+
+	typeText, codeText, hasCode := strings.Cut(spec, ":")
+	icmpType, err := strconv.Atoi(typeText)
+	if err != nil || icmpType < 0 || icmpType > 255 {
+		return 0, 0, fmt.Errorf("invalid ICMP type %q: use a number between 0 and 255", typeText)
+	}
+	code := -1
+	if hasCode {
+		code, err = strconv.Atoi(codeText)
+		if err != nil || code < 0 || code > 255 {
+			return 0, 0, fmt.Errorf("invalid ICMP code %q: use a number between 0 and 255", codeText)
+		}
+	}
+	return int32(icmpType), int32(code), nil
+}
+
+// CreateSecurityGroup creates a new security group, in the default VPC when
+// vpcID is empty, and returns its ID.
+func (p *Provider) CreateSecurityGroup(ctx context.Context, name, description, vpcID string) (string, error) {
+	input := &ec2.CreateSecurityGroupInput{
+		GroupName:         aws.String(name),
+		Description:       aws.String(description),
+		TagSpecifications: tagSpecifications(ec2types.ResourceTypeSecurityGroup, p.defaultTags),
+	}
+	if vpcID != "" {
+		input.VpcId = aws.String(vpcID)
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("create security group %s", name))
+	resp, err := p.client.CreateSecurityGroup(ctx, input)
+	if err := op.Finish(err); err != nil {
+		return "", fmt.Errorf("failed to create security group: %w", err)
+	}
+
+	return aws.ToString(resp.GroupId), nil
+}
+
+// ListSecurityGroups lists all security groups in the region.
+func (p *Provider) ListSecurityGroups(ctx context.Context) ([]SecurityGroup, error) {
+	op := internal.StartOperation("list security groups")
+
+	var groups []SecurityGroup
+	paginator := ec2.NewDescribeSecurityGroupsPaginator(p.client, &ec2.DescribeSecurityGroupsInput{})
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list security groups: %w", op.Finish(err))
+		}
+		for _, group := range resp.SecurityGroups {
+			groups = append(groups, toSecurityGroup(group))
+		}
+	}
+	op.Finish(nil)
+
+	return groups, nil
+}
+
+// DescribeSecurityGroup provides details of a security group, including its rules.
+func (p *Provider) DescribeSecurityGroup(ctx context.Context, groupID string) (SecurityGroup, error) {
+	op := internal.StartOperation(fmt.Sprintf("describe security group %s", groupID))
+	resp, err := p.client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
+		GroupIds: []string{groupID},
+	})
+	if err := op.Finish(err); err != nil {
+		return SecurityGroup{}, fmt.Errorf("failed to describe security group: %w", err)
+	}
+	if len(resp.SecurityGroups) == 0 {
+		return SecurityGroup{}, fmt.Errorf("security group %s: %w", groupID, clouds.ErrNotFound)
+	}
+
+	return toSecurityGroup(resp.SecurityGroups[0]), nil
+}
+
+// DeleteSecurityGroup deletes a security group.
+func (p *Provider) DeleteSecurityGroup(ctx context.Context, groupID string) error {
+	op := internal.StartOperation(fmt.Sprintf("delete security group %s", groupID))
+	_, err := p.client.DeleteSecurityGroup(ctx, &ec2.DeleteSecurityGroupInput{
+		GroupId: aws.String(groupID),
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to delete security group: %w", err)
+	}
+	return nil
+}
+
+// AuthorizeIngress adds an inbound rule to a security group.
+func (p *Provider) AuthorizeIngress(ctx context.Context, groupID string, rule Rule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("authorize ingress on security group %s", groupID))
+	_, err := p.client.AuthorizeSecurityGroupIngress(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId:       aws.String(groupID),
+		IpPermissions: []ec2types.IpPermission{ipPermission(rule)},
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to authorize security group ingress: %w", err)
+	}
+	return nil
+}
+
+// RevokeIngress removes an inbound rule from a security group.
+func (p *Provider) RevokeIngress(ctx context.Context, groupID string, rule Rule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("revoke ingress on security group %s", groupID))
+	_, err := p.client.RevokeSecurityGroupIngress(ctx, &ec2.RevokeSecurityGroupIngressInput{
+		GroupId:       aws.String(groupID),
+		IpPermissions: []ec2types.IpPermission{ipPermission(rule)},
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to revoke security group ingress: %w", err)
+	}
+	return nil
+}
+
+// AuthorizeEgress adds an outbound rule to a security group.
+func (p *Provider) AuthorizeEgress(ctx context.Context, groupID string, rule Rule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("authorize egress on security group %s", groupID))
+	_, err := p.client.AuthorizeSecurityGroupEgress(ctx, &ec2.AuthorizeSecurityGroupEgressInput{
+		GroupId:       aws.String(groupID),
+		IpPermissions: []ec2types.IpPermission{ipPermission(rule)},
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to authorize security group egress: %w", err)
+	}
+	return nil
+}
+
+// RevokeEgress removes an outbound rule from a security group.
+func (p *Provider) RevokeEgress(ctx context.Context, groupID string, rule Rule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("revoke egress on security group %s", groupID))
+	_, err := p.client.RevokeSecurityGroupEgress(ctx, &ec2.RevokeSecurityGroupEgressInput{
+		GroupId:       aws.String(groupID),
+		IpPermissions: []ec2types.IpPermission{ipPermission(rule)},
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to revoke security group egress: %w", err)
+	}
+	return nil
+}
+
+// ipPermission converts a rule to its EC2 representation.
+func ipPermission(rule Rule) ec2types.IpPermission {
+	permission := ec2types.IpPermission{IpProtocol: aws.String(rule.Protocol)}
+	switch rule.Protocol {
+	case "tcp", "udp", "icmp", "icmpv6":
+		permission.FromPort = aws.Int32(rule.FromPort)
+		permission.ToPort = aws.Int32(rule.ToPort)
+	}
+
+	var description *string
+	if rule.Description != "" {
+		description = aws.String(rule.Description)
+	}
+	for _, cidr := range rule.CIDRs {
+		if strings.Contains(cidr, ":") {
+			permission.Ipv6Ranges = append(permission.Ipv6Ranges, ec2types.Ipv6Range{CidrIpv6: aws.String(cidr), Description: description})
+		} else {
+			permission.IpRanges = append(permission.IpRanges, ec2types.IpRange{CidrIp: aws.String(cidr), Description: description})
+		}
+	}
+	for _, group := range rule.SourceGroups {
+		permission.UserIdGroupPairs = append(permission.UserIdGroupPairs, ec2types.UserIdGroupPair{GroupId: aws.String(group), Description: description})
+	}
+	return permission
+}
+
+// toSecurityGroup converts an EC2 security group to its representation here.
+func toSecurityGroup(group ec2types.SecurityGroup) SecurityGroup {
+	result := SecurityGroup{
+		ID:          aws.ToString(group.GroupId),
+		Name:        aws.ToString(group.GroupName),
+		Description: aws.ToString(group.Description),
+		VPCID:       aws.ToString(group.VpcId),
+	}
+	for _, permission := range group.IpPermissions {
+		result.Ingress = append(result.Ingress, toRule(permission))
+	}
+	for _, permission := range group.IpPermissionsEgress {
+		result.Egress = append(result.Egress, toRule(permission))
+	}
+	return result
+}
+
+// toRule converts an EC2 permission to a rule.
+func toRule(permission ec2types.IpPermission) Rule {
+	rule := Rule{
+		Protocol: aws.ToString(permission.IpProtocol),
+		FromPort: aws.ToInt32(permission.FromPort),
+		ToPort:   aws.ToInt32(permission.ToPort),
+	}
+	for _, r := range permission.IpRanges {
+		rule.CIDRs = append(rule.CIDRs, aws.ToString(r.CidrIp))
+		if rule.Description == "" {
+			rule.Description = aws.ToString(r.Description)
+		}
+	}
+	for _, r := range permission.Ipv6Ranges {
+		rule.CIDRs = append(rule.CIDRs, aws.ToString(r.CidrIpv6))
+		if rule.Description == "" {
+			rule.Description = aws.ToString(r.Description)
+		}
+	}
+	for _, pair := range permission.UserIdGroupPairs {
+		rule.SourceGroups = append(rule.SourceGroups, aws.ToString(pair.GroupId))
+		if rule.Description == "" {
+			rule.Description = aws.ToString(pair.Description)
+		}
+	}
+	return rule
+}
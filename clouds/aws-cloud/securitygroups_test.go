@@ -0,0 +1,126 @@
+package awscloud
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestNewRule(t *testing.T) {
+	tests := []struct {
+		protocol, ports string
+		want            Rule
+		wantErr         bool
+	}{
+		{protocol: "tcp", ports: "22", want: Rule{Protocol: "tcp", FromPort: 22, ToPort: 22}},
+		{protocol: "UDP", ports: "8000-8080", want: Rule{Protocol: "udp", FromPort: 8000, ToPort: 8080}},
+		{protocol: "icmp", want: Rule{Protocol: "icmp", FromPort: -1, ToPort: -1}},
+		{protocol: "icmp", ports: "3:4", want: Rule{Protocol: "icmp", FromPort: 3, ToPort: 4}},
+		{protocol: "all", want: Rule{Protocol: "-1"}},
+		{protocol: "50", want: Rule{Protocol: "50"}},
+		{protocol: "tcp", wantErr: true},
+		{protocol: "tcp", ports: "22-", wantErr: true},
+		{protocol: "tcp", ports: "443-80", wantErr: true},
+		{protocol: "tcp", ports: "70000", wantErr: true},
+		{protocol: "tcp", ports: "ssh", wantErr: true},
+		{protocol: "icmp", ports: "300", wantErr: true},
+		{protocol: "all", ports: "22", wantErr: true},
+		{protocol: "sctp", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := NewRule(tt.protocol, tt.ports)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("NewRule(%q, %q): expected an error", tt.protocol, tt.ports)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NewRule(%q, %q): %v", tt.protocol, tt.ports, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NewRule(%q, %q) = %+v, want %+v", tt.protocol, tt.ports, got, tt.want)
+		}
+	}
+}
+
+func TestRuleSources(t *testing.T) {
+	rule, err := NewRule("tcp", "22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rule.Validate(); err == nil {
+		t.Error("a rule without sources should not validate")
+	}
+
+	for _, cidr := range []string{"198.51.100.7", "10.1.2.3/16", "2001:db8::/32"} {
+		if err := rule.AddCIDR(cidr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rule.AddCIDR("10.0.0.0/33"); err == nil {
+		t.Error("expected an error for an invalid CIDR")
+	}
+	if err := rule.AddSourceGroup("web"); err == nil {
+		t.Error("expected an error for an invalid security group ID")
+	}
+	if err := rule.AddSourceGroup("sg-0123456789abcdef0"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"198.51.100.7/32", "10.1.0.0/16", "2001:db8::/32"}
+	if !reflect.DeepEqual(rule.CIDRs, want) {
+		t.Errorf("got CIDRs %v, want %v", rule.CIDRs, want)
+	}
+	if err := rule.Validate(); err != nil {
+		t.Error(err)
+	}
+	if rule.OpenToWorld() {
+		t.Error("rule is not open to the world")
+	}
+}
+
+func TestAuthorizeIngress(t *testing.T) {
+	p := newTestProvider(t)
+
+	rule, err := NewRule("tcp", "22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule.Description = "office"
+	if err := rule.AddCIDR("198.51.100.7"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rule.AddCIDR("2001:db8::/64"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rule.AddSourceGroup("sg-0fedcba9876543210"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.AuthorizeIngress(context.Background(), "sg-0123456789abcdef0", rule); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDescribeSecurityGroup(t *testing.T) {
+	p := newTestProvider(t)
+
+	group, err := p.DescribeSecurityGroup(context.Background(), "sg-0123456789abcdef0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group.Name != "web" || group.VPCID != "vpc-0abc1234" {
+		t.Errorf("unexpected group: %+v", group)
+	}
+
+	wantIngress := []Rule{{Protocol: "tcp", FromPort: 443, ToPort: 443, CIDRs: []string{"0.0.0.0/0", "::/0"}, Description: "https"}}
+	if !reflect.DeepEqual(group.Ingress, wantIngress) {
+		t.Errorf("got ingress %+v, want %+v", group.Ingress, wantIngress)
+	}
+	wantEgress := []Rule{{Protocol: "-1", CIDRs: []string{"0.0.0.0/0"}}}
+	if !reflect.DeepEqual(group.Egress, wantEgress) {
+		t.Errorf("got egress %+v, want %+v", group.Egress, wantEgress)
+	}
+}
@@ -0,0 +1,63 @@
+package awscloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// ListSizes lists the instance types offered in the region. Mac instances,
+// which run on dedicated hosts only, are left out.
+func (p *Provider) ListSizes(ctx context.Context) ([]clouds.Size, error) {
+	op := internal.StartOperation("list EC2 instance types")
+
+	var sizes []clouds.Size
+	paginator := ec2.NewDescribeInstanceTypesPaginator(p.client, &ec2.DescribeInstanceTypesInput{})
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list instance types: %w", op.Finish(err))
+		}
+		for _, info := range resp.InstanceTypes {
+			if size, ok := toSize(info); ok {
+				sizes = append(sizes, size)
+			}
+		}
+	}
+	op.Finish(nil)
+
+	return sizes, nil
+}
+
+// toSize converts an EC2 instance type to a size.
+func toSize(info ec2types.InstanceTypeInfo) (clouds.Size, bool) {
+	size := clouds.Size{Type: string(info.InstanceType)}
+	if info.ProcessorInfo != nil {
+		switch {
+		case slices.Contains(info.ProcessorInfo.SupportedArchitectures, ec2types.ArchitectureTypeX8664):
+			size.Arch = clouds.ArchX86
+		case slices.Contains(info.ProcessorInfo.SupportedArchitectures, ec2types.ArchitectureTypeArm64):
+			size.Arch = clouds.ArchARM
+		default:
+			return size, false
+		}
+	}
+	if info.VCpuInfo != nil {
+		size.CPUs = int(aws.ToInt32(info.VCpuInfo.DefaultVCpus))
+	}
+	if info.MemoryInfo != nil {
+		size.MemoryGiB = float64(aws.ToInt64(info.MemoryInfo.SizeInMiB)) / 1024
+	}
+	if info.GpuInfo != nil {
+		for _, gpu := range info.GpuInfo.Gpus {
+			size.GPUs += int(aws.ToInt32(gpu.Count))
+		}
+	}
+	return size, true
+}
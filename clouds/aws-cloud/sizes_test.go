@@ -0,0 +1,25 @@
+package awscloud
+
+import (
+	"context"
+	"namaste-cloud/clouds"
+	"reflect"
+	"testing"
+)
+
+func TestListSizes(t *testing.T) {
+	p := newTestProvider(t)
+
+	sizes, err := p.ListSizes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []clouds.Size{
+		{Type: "t3.micro", CPUs: 2, MemoryGiB: 1, Arch: clouds.ArchX86},
+		{Type: "m6g.large", CPUs: 2, MemoryGiB: 8, Arch: clouds.ArchARM},
+		{Type: "g4dn.xlarge", CPUs: 4, MemoryGiB: 16, GPUs: 1, Arch: clouds.ArchX86},
+	}
+	if !reflect.DeepEqual(sizes, want) {
+		t.Errorf("got %+v, want %+v", sizes, want)
+	}
+}
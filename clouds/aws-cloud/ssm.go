@@ -0,0 +1,70 @@
+package awscloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// ssmPollInterval is how often the result of a Systems Manager command is checked.
+var ssmPollInterval = 2 * time.Second
+
+// RunCommand runs a shell command on an instance with Systems Manager's
+// AWS-RunShellScript document and waits for it to finish. The instance must
+// run the SSM agent with an instance profile that allows it.
+func (p *Provider) RunCommand(ctx context.Context, instanceID, command string) (clouds.CommandResult, error) {
+	op := internal.StartOperation(fmt.Sprintf("run command on instance %s", instanceID))
+	sent, err := p.ssm.SendCommand(ctx, &ssm.SendCommandInput{
+		DocumentName: aws.String("AWS-RunShellScript"),
+		InstanceIds:  []string{instanceID},
+		Parameters:   map[string][]string{"commands": {command}},
+	})
+	if err != nil {
+		return clouds.CommandResult{}, fmt.Errorf("failed to run command: %w", op.Finish(err))
+	}
+	commandID := aws.ToString(sent.Command.CommandId)
+
+	for {
+		select {
+		case <-time.After(ssmPollInterval):
+		case <-ctx.Done():
+			return clouds.CommandResult{}, fmt.Errorf("failed to run command: %w", op.Finish(ctx.Err()))
+		}
+
+		invocation, err := p.ssm.GetCommandInvocation(ctx, &ssm.GetCommandInvocationInput{
+			CommandId:  aws.String(commandID),
+			InstanceId: aws.String(instanceID),
+		})
+		// The invocation only appears once the agent has picked up the command
+		var notYet *ssmtypes.InvocationDoesNotExist
+		if errors.As(err, &notYet) {
+			continue
+		}
+		if err != nil {
+			return clouds.CommandResult{}, fmt.Errorf("failed to run command: %w", op.Finish(err))
+		}
+
+		switch invocation.Status {
+		case ssmtypes.CommandInvocationStatusPending, ssmtypes.CommandInvocationStatusInProgress,
+			ssmtypes.CommandInvocationStatusDelayed, ssmtypes.CommandInvocationStatusCancelling:
+			continue
+		case ssmtypes.CommandInvocationStatusSuccess, ssmtypes.CommandInvocationStatusFailed:
+			op.Finish(nil)
+			return clouds.CommandResult{
+				Stdout:   aws.ToString(invocation.StandardOutputContent),
+				Stderr:   aws.ToString(invocation.StandardErrorContent),
+				ExitCode: int(invocation.ResponseCode),
+			}, nil
+		default:
+			err := fmt.Errorf("command ended with status %s", invocation.Status)
+			return clouds.CommandResult{}, fmt.Errorf("failed to run command: %w", op.Finish(err))
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package awscloud
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRunCommand(t *testing.T) {
+	p := newTestProvider(t)
+	interval := ssmPollInterval
+	ssmPollInterval = 0
+	t.Cleanup(func() { ssmPollInterval = interval })
+
+	result, err := p.RunCommand(context.Background(), "i-0123456789abcdef0", "uptime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode != 2 || result.Stderr != "uptime: not found\n" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
@@ -0,0 +1,117 @@
+package awscloud
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"namaste-cloud/internal"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// defaultStateKey is the state object used when none is configured.
+const defaultStateKey = "namaste-cloud/state.json"
+
+// S3StateBackend stores the state in an object of an S3 bucket, or of
+// S3-compatible storage. Conditional writes on the object's ETag keep two
+// users from overwriting each other's changes.
+type S3StateBackend struct {
+	client *s3.Client
+	bucket string
+	key    string
+}
+
+// NewS3StateBackend creates the backend configured in the state settings.
+// The profile, region and endpoint of the state settings take precedence
+// over the global ones.
+func NewS3StateBackend(ctx context.Context, settings internal.Config) (*S3StateBackend, error) {
+	state := settings.State
+	if state.Bucket == "" {
+		return nil, fmt.Errorf("state.bucket is required for the s3 state backend")
+	}
+	if state.Profile != "" {
+		settings.Profile = state.Profile
+	}
+	if state.Region != "" {
+		settings.Region = state.Region
+	}
+	if state.EndpointURL != "" {
+		settings.EndpointURL = state.EndpointURL
+	}
+
+	cfg, err := loadConfig(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if settings.EndpointURL != "" {
+			o.BaseEndpoint = aws.String(settings.EndpointURL)
+			o.UsePathStyle = true
+		}
+	})
+
+	key := state.Key
+	if key == "" {
+		key = defaultStateKey
+	}
+	return &S3StateBackend{client: client, bucket: state.Bucket, key: key}, nil
+}
+
+// Read implements internal.StateBackend. The version is the object's ETag.
+func (b *S3StateBackend) Read(ctx context.Context) ([]byte, string, error) {
+	op := internal.StartOperation(fmt.Sprintf("read state from %s", b.Location()))
+	resp, err := b.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.key),
+	})
+	var noSuchKey *s3types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		op.Finish(nil)
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", op.Finish(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err := op.Finish(err); err != nil {
+		return nil, "", err
+	}
+	return data, aws.ToString(resp.ETag), nil
+}
+
+// Write implements internal.StateBackend. The object is only written if its
+// ETag is still the version read, or if it still does not exist.
+func (b *S3StateBackend) Write(ctx context.Context, data []byte, version string) error {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(b.bucket),
+		Key:         aws.String(b.key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	}
+	if version != "" {
+		input.IfMatch = aws.String(version)
+	} else {
+		input.IfNoneMatch = aws.String("*")
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("write state to %s", b.Location()))
+	_, err := b.client.PutObject(ctx, input)
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "PreconditionFailed" || apiErr.ErrorCode() == "ConditionalRequestConflict") {
+		op.Finish(nil)
+		return internal.ErrStateConflict
+	}
+	return op.Finish(err)
+}
+
+// Location implements internal.StateBackend.
+func (b *S3StateBackend) Location() string {
+	return fmt.Sprintf("s3://%s/%s", b.bucket, b.key)
+}
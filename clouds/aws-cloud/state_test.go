@@ -0,0 +1,42 @@
+package awscloud
+
+import (
+	"context"
+	"errors"
+	"namaste-cloud/internal"
+	"namaste-cloud/internal/cassette"
+	"path/filepath"
+	"testing"
+)
+
+func TestS3StateBackend(t *testing.T) {
+	rec := cassette.Use(t, filepath.Join("testdata", "cassettes", t.Name()+".yaml"))
+	if !rec.Recording() {
+		t.Setenv(internal.ConfigDirEnv, t.TempDir())
+		t.Setenv("AWS_CA_BUNDLE", "")
+		if err := internal.SaveCredential(internal.Credential{Cloud: "aws", AccessKey: "AKIDEXAMPLE", SecretKey: "secret"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backend, err := NewS3StateBackend(context.Background(), internal.Config{
+		Retry: internal.RetryPolicy{MaxAttempts: 1},
+		State: internal.StateConfig{Backend: "s3", Bucket: "namaste-state", Region: "us-east-1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	data, version, err := backend.Read(ctx)
+	if err != nil || data != nil || version != "" {
+		t.Fatalf("got %q, %q, %v for a missing state, want nothing", data, version, err)
+	}
+	if err := backend.Write(ctx, []byte(`{"resources": []}`), ""); err != nil {
+		t.Fatal(err)
+	}
+	err = backend.Write(ctx, []byte(`{"resources": []}`), "")
+	if !errors.Is(err, internal.ErrStateConflict) {
+		t.Errorf("got %v when the state exists, want a conflict", err)
+	}
+}
@@ -0,0 +1,61 @@
+package awscloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// TagInstance adds tags to an EC2 instance.
+func (p *Provider) TagInstance(ctx context.Context, instanceID string, tags map[string]string) error {
+	op := internal.StartOperation(fmt.Sprintf("tag instance %s", instanceID))
+	_, err := p.client.CreateTags(ctx, &ec2.CreateTagsInput{
+		Resources: []string{instanceID},
+		Tags:      ec2Tags(tags),
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to tag instance: %w", err)
+	}
+	return nil
+}
+
+// UntagInstance removes tags from an EC2 instance.
+func (p *Provider) UntagInstance(ctx context.Context, instanceID string, keys []string) error {
+	var tags []ec2types.Tag
+	for _, key := range keys {
+		tags = append(tags, ec2types.Tag{Key: aws.String(key)})
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("untag instance %s", instanceID))
+	_, err := p.client.DeleteTags(ctx, &ec2.DeleteTagsInput{
+		Resources: []string{instanceID},
+		Tags:      tags,
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to untag instance: %w", err)
+	}
+	return nil
+}
+
+// ec2Tags converts tags to EC2 tags, ordered by key.
+func ec2Tags(tags map[string]string) []ec2types.Tag {
+	var result []ec2types.Tag
+	for _, key := range clouds.SortedKeys(tags) {
+		result = append(result, ec2types.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+	}
+	return result
+}
+
+// tagSpecifications returns the tag specifications that tag a resource on
+// creation, or nil when there are no tags.
+func tagSpecifications(resourceType ec2types.ResourceType, tags map[string]string) []ec2types.TagSpecification {
+	if len(tags) == 0 {
+		return nil
+	}
+	return []ec2types.TagSpecification{{ResourceType: resourceType, Tags: ec2Tags(tags)}}
+}
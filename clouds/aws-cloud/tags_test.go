@@ -0,0 +1,15 @@
+package awscloud
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTagInstance(t *testing.T) {
+	p := newTestProvider(t)
+
+	err := p.TagInstance(context.Background(), "i-0123456789abcdef0", map[string]string{"owner": "platform", "env": "prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
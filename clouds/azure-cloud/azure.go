@@ -1,133 +1,391 @@
 package azurecloud
 
 import (
-	// "context"
-	// "fmt"
-	// "log"
-	// "namaste-cloud/internal"
+	"context"
+	"errors"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+	"net/http"
+	"strings"
 
-	// // "github.com/Azure/azure-sdk-for-go/sdk/azidentity"
-	// "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
 )
 
-// // ExecuteAzureCommand performs Azure operations based on the given command.
-// func ExecuteAzureCommand(command string, args ...string) {
-// 	// Load Azure credentials from storage
-// 	cred, err := internal.GetCredential("azure")
-// 	if err != nil {
-// 		log.Fatalf("Failed to load Azure credentials: %v", err)
-// 	}
-
-// 	// Use Azure credentials to create a client
-// 	// credClient, err := azidentity.NewClientSecretCredential(cred.TenantID, cred.AccessKey, cred.SecretKey, nil)
-// 	if err != nil {
-// 		log.Fatalf("Failed to create Azure client: %v", err)
-// 	}
-
-// 	// // Create an Azure compute client
-// 	// client := armcompute.NewVirtualMachinesClient(cred.SubscriptionID, credClient)
-
-// 	// Dispatch command based on the input
-// 	switch command {
-// 	case "list-instances":
-// 		// listInstances(client)
-// 	case "create-instance":
-// 		if len(args) < 1 {
-// 			fmt.Println("Please specify the VM size for the instance.")
-// 			return
-// 		}
-// 		// createInstance(client, args[0])
-// 	case "stop-instance":
-// 		if len(args) < 1 {
-// 			fmt.Println("Please specify the Instance ID to stop.")
-// 			return
-// 		}
-// 		// stopInstance(client, args[0])
-// 	case "start-instance":
-// 		if len(args) < 1 {
-// 			fmt.Println("Please specify the Instance ID to start.")
-// 			return
-// 		}
-// 		// startInstance(client, args[0])
-// 	case "terminate-instance":
-// 		if len(args) < 1 {
-// 			fmt.Println("Please specify the Instance ID to terminate.")
-// 			return
-// 		}
-// 		// terminateInstance(client, args[0])
-// 	default:
-// 		fmt.Println("Unsupported Azure command.")
-// 	}
-// }
-
-// // listInstances lists all Azure virtual machines.
-// func listInstances(client *armcompute.VirtualMachinesClient) {
-// 	ctx := context.Background()
-// 	pager := client.NewListPager("", nil)
-
-// 	for pager.More() {
-// 		page, err := pager.NextPage(ctx)
-// 		if err != nil {
-// 			log.Fatalf("Failed to list instances: %v", err)
-// 		}
-
-// 		for _, vm := range page.Value {
-// 			fmt.Printf("VM ID: %s, State: %s\n", *vm.Name, *vm.Status)
-// 		}
-// 	}
-// }
-
-// // createInstance creates an Azure virtual machine with the specified size.
-// func createInstance(client *armcompute.VirtualMachinesClient, vmSize string) {
-// 	// Example of creating a VM (you would need to customize the config)
-// 	vm := armcompute.VirtualMachine{
-// 		Location: "eastus",
-// 		Properties: &armcompute.VirtualMachineProperties{
-// 			HardwareProfile: &armcompute.HardwareProfile{
-// 				VMSize: armcompute.VirtualMachineSizeTypes(vmSize),
-// 			},
-// 		},
-// 	}
-
-// 	// Create VM
-// 	ctx := context.Background()
-// 	_, err := client.BeginCreateOrUpdate(ctx, "your-resource-group", "new-vm", vm, nil)
-// 	if err != nil {
-// 		log.Fatalf("Failed to create instance: %v", err)
-// 	}
-
-// 	fmt.Println("VM created successfully.")
-// }
-
-// // stopInstance stops an Azure virtual machine.
-// func stopInstance(client *armcompute.VirtualMachinesClient, instanceID string) {
-// 	ctx := context.Background()
-// 	_, err := client.BeginDeallocate(ctx, "your-resource-group", instanceID, nil)
-// 	if err != nil {
-// 		log.Fatalf("Failed to stop instance: %v", err)
-// 	}
-
-// 	fmt.Printf("Stopping instance with ID: %s\n", instanceID)
-// }
-
-// // startInstance starts an Azure virtual machine.
-// func startInstance(client *armcompute.VirtualMachinesClient, instanceID string) {
-// 	ctx := context.Background()
-// 	_, err := client.BeginStart(ctx, "your-resource-group", instanceID, nil)
-// 	if err != nil {
-// 		log.Fatalf("Failed to start instance: %v", err)
-// 	}
-
-// 	fmt.Printf("Starting instance with ID: %s\n", instanceID)
-// }
-
-// // terminateInstance terminates an Azure virtual machine.
-// func terminateInstance(client *armcompute.VirtualMachinesClient, instanceID string) {
-// 	ctx := context.Background()
-// 	_, err := client.BeginDelete(ctx, "your-resource-group", instanceID, nil)
-// 	if err != nil {
-// 		log.Fatalf("Failed to terminate instance: %v", err)
-// 	}
-
-// 	fmt.Printf("Terminating instance with ID: %s\n", instanceID)
-// }
+// errNoResourceGroup is returned by operations on a single VM when no resource group is configured.
+var errNoResourceGroup = errors.New("no Azure resource group set; use --project or set `project` in the configuration")
+
+// errNoLocation is returned by operations that need a location when no region is configured.
+var errNoLocation = errors.New("no Azure location set; use --region or set `region` in the configuration")
+
+// Provider manages Azure virtual machines. The configured project is used as
+// the resource group and the configured region as the location.
+type Provider struct {
+	client        *armcompute.VirtualMachinesClient
+	sshKeys       *armcompute.SSHPublicKeysClient
+	sizes         *armcompute.VirtualMachineSizesClient
+	images        *armcompute.VirtualMachineImagesClient
+	network       *networkClients
+	locks         *locksClient
+	blobs         *http.Client
+	resourceGroup string
+	location      string
+
+	// defaultTags are added to every resource the provider creates.
+	defaultTags map[string]string
+}
+
+// New creates an Azure provider from the stored credentials and the given settings.
+func New(ctx context.Context, cfg internal.Config) (*Provider, error) {
+	// Load Azure credentials from storage
+	cred, err := internal.GetProfileCredential("azure", cfg.Profile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Azure credentials: %w", err)
+	}
+
+	// Use Azure credentials to create a client
+	credClient, err := azidentity.NewClientSecretCredential(cred.TenantID, cred.AccessKey, cred.SecretKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure client: %w", err)
+	}
+
+	return newProvider(cfg, cred.SubscriptionID, credClient)
+}
+
+// newProvider creates an Azure provider for the subscription using the given token credential.
+func newProvider(cfg internal.Config, subscriptionID string, credential azcore.TokenCredential) (*Provider, error) {
+	// Create an Azure compute client
+	options := clientOptions(cfg)
+	client, err := armcompute.NewVirtualMachinesClient(subscriptionID, credential, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure compute client: %w", err)
+	}
+	sshKeys, err := armcompute.NewSSHPublicKeysClient(subscriptionID, credential, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure compute client: %w", err)
+	}
+	sizes, err := armcompute.NewVirtualMachineSizesClient(subscriptionID, credential, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure compute client: %w", err)
+	}
+	images, err := armcompute.NewVirtualMachineImagesClient(subscriptionID, credential, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure compute client: %w", err)
+	}
+	network, err := newNetworkClients(subscriptionID, credential, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure network client: %w", err)
+	}
+	locks, err := newLocksClient(subscriptionID, credential, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure locks client: %w", err)
+	}
+
+	// Boot diagnostics are downloaded from storage with SAS URIs, which need no credentials
+	blobs := internal.NewHTTPClient(cfg.SkipsTLSVerify())
+
+	return &Provider{client: client, sshKeys: sshKeys, sizes: sizes, images: images, network: network, locks: locks, blobs: blobs, resourceGroup: cfg.Project, location: cfg.Region, defaultTags: cfg.DefaultTags}, nil
+}
+
+// Name implements clouds.Provider.
+func (p *Provider) Name() string {
+	return "azure"
+}
+
+// Close implements clouds.Provider.
+func (p *Provider) Close() error {
+	return nil
+}
+
+// ListInstances lists Azure virtual machines in the resource group, or in the
+// whole subscription when no resource group is set, limited to the configured location.
+func (p *Provider) ListInstances(ctx context.Context) ([]clouds.Instance, error) {
+	op := internal.StartOperation("list Azure virtual machines")
+
+	var vms []*armcompute.VirtualMachine
+	if p.resourceGroup == "" {
+		pager := p.client.NewListAllPager(nil)
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list instances: %w", op.Finish(err))
+			}
+			vms = append(vms, page.Value...)
+		}
+	} else {
+		pager := p.client.NewListPager(p.resourceGroup, nil)
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list instances: %w", op.Finish(err))
+			}
+			vms = append(vms, page.Value...)
+		}
+	}
+	statuses, err := p.instanceViews(ctx)
+	if err := op.Finish(err); err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+
+	var instances []clouds.Instance
+	for _, vm := range vms {
+		if p.location != "" && !strings.EqualFold(deref(vm.Location), p.location) {
+			continue
+		}
+		if vm.Properties != nil && vm.Properties.InstanceView == nil {
+			vm.Properties.InstanceView = statuses[strings.ToLower(deref(vm.ID))]
+		}
+		instances = append(instances, toInstance(vm))
+	}
+	return instances, nil
+}
+
+// instanceViews returns the run-time status of every VM in the subscription
+// by lower-case resource ID. Listing VMs does not return their power state.
+func (p *Provider) instanceViews(ctx context.Context) (map[string]*armcompute.VirtualMachineInstanceView, error) {
+	views := make(map[string]*armcompute.VirtualMachineInstanceView)
+	pager := p.client.NewListAllPager(&armcompute.VirtualMachinesClientListAllOptions{StatusOnly: to("true")})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, vm := range page.Value {
+			if vm.Properties != nil {
+				views[strings.ToLower(deref(vm.ID))] = vm.Properties.InstanceView
+			}
+		}
+	}
+	return views, nil
+}
+
+// ErrCreateInstance is what creating any Azure VM fails with: it also
+// requires a network interface, which this CLI does not manage.
+var ErrCreateInstance = fmt.Errorf("creating Azure VMs: %w", clouds.ErrNotSupported)
+
+// CreateInstance is not supported yet, see ErrCreateInstance.
+func (p *Provider) CreateInstance(ctx context.Context, spec clouds.InstanceSpec) (clouds.Instance, error) {
+	return clouds.Instance{}, ErrCreateInstance
+}
+
+// CheckCreateInstance implements clouds.CreateChecker.
+func (p *Provider) CheckCreateInstance(spec clouds.InstanceSpec) error {
+	return ErrCreateInstance
+}
+
+// StopInstance stops (deallocates) an Azure virtual machine.
+func (p *Provider) StopInstance(ctx context.Context, instanceID string) error {
+	resourceGroup, name, err := p.vmRef(instanceID)
+	if err != nil {
+		return err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("stop instance %s", instanceID))
+	_, err = p.client.BeginDeallocate(ctx, resourceGroup, name, nil)
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to stop instance: %w", err)
+	}
+	return nil
+}
+
+// StartInstance starts an Azure virtual machine.
+func (p *Provider) StartInstance(ctx context.Context, instanceID string) error {
+	resourceGroup, name, err := p.vmRef(instanceID)
+	if err != nil {
+		return err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("start instance %s", instanceID))
+	_, err = p.client.BeginStart(ctx, resourceGroup, name, nil)
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to start instance: %w", err)
+	}
+	return nil
+}
+
+// RebootInstance restarts an Azure virtual machine.
+func (p *Provider) RebootInstance(ctx context.Context, instanceID string) error {
+	resourceGroup, name, err := p.vmRef(instanceID)
+	if err != nil {
+		return err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("reboot instance %s", instanceID))
+	_, err = p.client.BeginRestart(ctx, resourceGroup, name, nil)
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to reboot instance: %w", err)
+	}
+	return nil
+}
+
+// TerminateInstance deletes an Azure virtual machine.
+func (p *Provider) TerminateInstance(ctx context.Context, instanceID string) error {
+	resourceGroup, name, err := p.vmRef(instanceID)
+	if err != nil {
+		return err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("terminate instance %s", instanceID))
+	_, err = p.client.BeginDelete(ctx, resourceGroup, name, nil)
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to terminate instance: %w", err)
+	}
+	return nil
+}
+
+// DescribeInstance provides details of an Azure virtual machine.
+func (p *Provider) DescribeInstance(ctx context.Context, instanceID string) (clouds.Instance, error) {
+	resourceGroup, name, err := p.vmRef(instanceID)
+	if err != nil {
+		return clouds.Instance{}, err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("describe instance %s", instanceID))
+	expand := armcompute.InstanceViewTypesInstanceView
+	resp, err := p.client.Get(ctx, resourceGroup, name, &armcompute.VirtualMachinesClientGetOptions{Expand: &expand})
+	if err != nil {
+		return clouds.Instance{}, fmt.Errorf("failed to describe instance: %w", op.Finish(err))
+	}
+	instance := toInstance(&resp.VirtualMachine)
+	instance.PrivateIP, instance.PublicIP, err = p.addresses(ctx, &resp.VirtualMachine)
+	if err := op.Finish(err); err != nil {
+		return clouds.Instance{}, fmt.Errorf("failed to describe instance: %w", err)
+	}
+
+	return instance, nil
+}
+
+// addresses returns the private and public IP addresses of a VM's primary
+// network interface. Azure keeps them on separate network resources, so
+// listing VMs does not return them.
+func (p *Provider) addresses(ctx context.Context, vm *armcompute.VirtualMachine) (string, string, error) {
+	if vm.Properties == nil || vm.Properties.NetworkProfile == nil {
+		return "", "", nil
+	}
+	var nicID string
+	for _, ref := range vm.Properties.NetworkProfile.NetworkInterfaces {
+		if nicID == "" || (ref.Properties != nil && isTrue(ref.Properties.Primary)) {
+			nicID = deref(ref.ID)
+		}
+	}
+	if nicID == "" {
+		return "", "", nil
+	}
+
+	id, err := arm.ParseResourceID(nicID)
+	if err != nil {
+		return "", "", err
+	}
+	nic, err := p.network.interfaces.Get(ctx, id.ResourceGroupName, id.Name, nil)
+	if err != nil {
+		return "", "", err
+	}
+	var config *armnetwork.InterfaceIPConfigurationPropertiesFormat
+	if nic.Properties != nil {
+		for _, c := range nic.Properties.IPConfigurations {
+			if c.Properties != nil && (config == nil || isTrue(c.Properties.Primary)) {
+				config = c.Properties
+			}
+		}
+	}
+	if config == nil {
+		return "", "", nil
+	}
+	if config.PublicIPAddress == nil {
+		return deref(config.PrivateIPAddress), "", nil
+	}
+
+	id, err = arm.ParseResourceID(deref(config.PublicIPAddress.ID))
+	if err != nil {
+		return "", "", err
+	}
+	address, err := p.network.publicIPAddresses.Get(ctx, id.ResourceGroupName, id.Name, nil)
+	if err != nil {
+		return "", "", err
+	}
+	var publicIP string
+	if address.Properties != nil {
+		publicIP = deref(address.Properties.IPAddress)
+	}
+	return deref(config.PrivateIPAddress), publicIP, nil
+}
+
+// vmID returns the ID of the VM with the given name in a resource group.
+// Names are only unique within a resource group, so IDs are qualified with it.
+func vmID(resourceGroup, name string) string {
+	return resourceGroup + "/" + name
+}
+
+// vmRef returns the resource group and name of a VM ID. A bare name is
+// taken to be in the configured resource group.
+func (p *Provider) vmRef(id string) (string, string, error) {
+	if resourceGroup, name, ok := strings.Cut(id, "/"); ok {
+		return resourceGroup, name, nil
+	}
+	if p.resourceGroup == "" {
+		return "", "", errNoResourceGroup
+	}
+	return p.resourceGroup, id, nil
+}
+
+// toInstance converts an Azure virtual machine, with its instance view, to the
+// provider-neutral representation. Azure VMs are addressed by resource group
+// and name, which make up their ID.
+func toInstance(vm *armcompute.VirtualMachine) clouds.Instance {
+	result := clouds.Instance{
+		ID:       deref(vm.Name),
+		Name:     deref(vm.Name),
+		Location: deref(vm.Location),
+	}
+	if id, err := arm.ParseResourceID(deref(vm.ID)); err == nil {
+		result.ID = vmID(id.ResourceGroupName, id.Name)
+		result.ResourceGroup = id.ResourceGroupName
+	}
+	for key, value := range vm.Tags {
+		if result.Tags == nil {
+			result.Tags = make(map[string]string)
+		}
+		result.Tags[key] = deref(value)
+	}
+	if vm.Properties != nil {
+		result.State = powerState(vm.Properties)
+		if vm.Properties.HardwareProfile != nil && vm.Properties.HardwareProfile.VMSize != nil {
+			result.Type = string(*vm.Properties.HardwareProfile.VMSize)
+		}
+	}
+	return result
+}
+
+// powerState returns the state of a VM from the PowerState status of its
+// instance view, such as running or deallocated. VMs being deleted are in the
+// deleting state whatever their power state.
+func powerState(properties *armcompute.VirtualMachineProperties) string {
+	if strings.EqualFold(deref(properties.ProvisioningState), "Deleting") {
+		return "deleting"
+	}
+	if properties.InstanceView != nil {
+		for _, status := range properties.InstanceView.Statuses {
+			if code, ok := strings.CutPrefix(deref(status.Code), "PowerState/"); ok {
+				return strings.ToLower(code)
+			}
+		}
+	}
+	return "unknown"
+}
+
+// isTrue returns the value of a bool pointer, or false if it is nil.
+func isTrue(b *bool) bool {
+	return b != nil && *b
+}
+
+// deref returns the value of a string pointer, or an empty string if it is nil.
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
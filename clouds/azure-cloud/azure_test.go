@@ -0,0 +1,88 @@
+package azurecloud
+
+import (
+	"context"
+	"namaste-cloud/internal"
+	"namaste-cloud/internal/cassette"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// testSubscriptionID is the subscription the cassettes were recorded against.
+const testSubscriptionID = "00000000-0000-0000-0000-000000000000"
+
+// staticCredential hands out a fixed token so replays never contact Entra ID.
+type staticCredential struct{}
+
+func (staticCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+// newTestProvider returns a provider whose API calls are replayed from the
+// test's cassette. When recording, the stored credentials are used instead.
+func newTestProvider(t *testing.T) *Provider {
+	t.Helper()
+
+	rec := cassette.Use(t, filepath.Join("testdata", "cassettes", t.Name()+".yaml"))
+	cfg := internal.Config{
+		Project: "namaste-rg",
+		Region:  "westeurope",
+		Retry:   internal.RetryPolicy{MaxAttempts: 1},
+	}
+
+	var p *Provider
+	var err error
+	if rec.Recording() {
+		p, err = New(context.Background(), cfg)
+	} else {
+		p, err = newProvider(cfg, testSubscriptionID, staticCredential{})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestListInstances(t *testing.T) {
+	p := newTestProvider(t)
+
+	instances, err := p.ListInstances(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The VM in another location is filtered out
+	if len(instances) != 1 {
+		t.Fatalf("got %d instances, want 1", len(instances))
+	}
+	if vm := instances[0]; vm.ID != "namaste-rg/web" || vm.ResourceGroup != "namaste-rg" || vm.State != "running" || vm.Type != "Standard_B1s" || vm.Location != "westeurope" {
+		t.Errorf("unexpected instance: %+v", vm)
+	}
+}
+
+func TestDescribeInstance(t *testing.T) {
+	p := newTestProvider(t)
+
+	instance, err := p.DescribeInstance(context.Background(), "namaste-rg/web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instance.Name != "web" || instance.Type != "Standard_B1s" || instance.State != "deallocated" {
+		t.Errorf("unexpected instance: %+v", instance)
+	}
+	if instance.PrivateIP != "10.1.0.4" || instance.PublicIP != "198.51.100.20" {
+		t.Errorf("got addresses %s and %s, want 10.1.0.4 and 198.51.100.20", instance.PrivateIP, instance.PublicIP)
+	}
+}
+
+func TestDescribeInstanceRequiresResourceGroup(t *testing.T) {
+	p := &Provider{}
+
+	if _, err := p.DescribeInstance(context.Background(), "web"); err != errNoResourceGroup {
+		t.Errorf("got %v, want %v", err, errNoResourceGroup)
+	}
+}
@@ -0,0 +1,71 @@
+package azurecloud
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"namaste-cloud/internal"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+)
+
+// ConsoleOutput returns the serial console log of an Azure virtual machine
+// from its boot diagnostics, which must be enabled on the VM.
+func (p *Provider) ConsoleOutput(ctx context.Context, instanceID string) (string, error) {
+	log, err := p.bootDiagnostics(ctx, instanceID, "console output", func(r armcompute.RetrieveBootDiagnosticsDataResult) *string {
+		return r.SerialConsoleLogBlobURI
+	})
+	return string(log), err
+}
+
+// ConsoleScreenshot returns a screenshot of an Azure virtual machine's
+// display from its boot diagnostics, which must be enabled on the VM.
+func (p *Provider) ConsoleScreenshot(ctx context.Context, instanceID string) ([]byte, error) {
+	return p.bootDiagnostics(ctx, instanceID, "console screenshot", func(r armcompute.RetrieveBootDiagnosticsDataResult) *string {
+		return r.ConsoleScreenshotBlobURI
+	})
+}
+
+// bootDiagnostics downloads one of the boot diagnostics blobs of a VM,
+// selected by blob from the SAS URIs Azure returns for them.
+func (p *Provider) bootDiagnostics(ctx context.Context, instanceID, what string, blob func(armcompute.RetrieveBootDiagnosticsDataResult) *string) ([]byte, error) {
+	resourceGroup, name, err := p.vmRef(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("get %s of instance %s", what, instanceID))
+	resp, err := p.client.RetrieveBootDiagnosticsData(ctx, resourceGroup, name, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", what, op.Finish(err))
+	}
+	uri := deref(blob(resp.RetrieveBootDiagnosticsDataResult))
+	if uri == "" {
+		err := fmt.Errorf("no %s available; boot diagnostics may be disabled for instance %s", what, instanceID)
+		return nil, fmt.Errorf("failed to get %s: %w", what, op.Finish(err))
+	}
+
+	data, err := p.download(ctx, uri)
+	if err := op.Finish(err); err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", what, err)
+	}
+	return data, nil
+}
+
+// download fetches a blob by its SAS URI.
+func (p *Provider) download(ctx context.Context, uri string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.blobs.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading boot diagnostics returned %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
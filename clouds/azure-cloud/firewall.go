@@ -0,0 +1,514 @@
+package azurecloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+)
+
+// firewallSecurityGroup is the network security group, in the configured
+// resource group, that holds the firewall rules.
+const firewallSecurityGroup = "namaste-cloud"
+
+// firewallTargetsTag is the tag of the application security group of a
+// firewall rule that holds the rule's target tags.
+const firewallTargetsTag = "namaste-firewall-targets"
+
+// Priorities given to new security rules: Azure evaluates lower numbers first.
+const (
+	firstRulePriority = 100
+	lastRulePriority  = 4096
+	rulePriorityStep  = 10
+)
+
+// networkClients are the Azure network clients used for firewall rules and
+// for looking up the addresses of virtual machines.
+type networkClients struct {
+	securityGroups            *armnetwork.SecurityGroupsClient
+	securityRules             *armnetwork.SecurityRulesClient
+	applicationSecurityGroups *armnetwork.ApplicationSecurityGroupsClient
+	interfaces                *armnetwork.InterfacesClient
+	publicIPAddresses         *armnetwork.PublicIPAddressesClient
+}
+
+// newNetworkClients creates the network clients for the subscription.
+func newNetworkClients(subscriptionID string, credential azcore.TokenCredential, options *arm.ClientOptions) (*networkClients, error) {
+	securityGroups, err := armnetwork.NewSecurityGroupsClient(subscriptionID, credential, options)
+	if err != nil {
+		return nil, err
+	}
+	securityRules, err := armnetwork.NewSecurityRulesClient(subscriptionID, credential, options)
+	if err != nil {
+		return nil, err
+	}
+	applicationSecurityGroups, err := armnetwork.NewApplicationSecurityGroupsClient(subscriptionID, credential, options)
+	if err != nil {
+		return nil, err
+	}
+	interfaces, err := armnetwork.NewInterfacesClient(subscriptionID, credential, options)
+	if err != nil {
+		return nil, err
+	}
+	publicIPAddresses, err := armnetwork.NewPublicIPAddressesClient(subscriptionID, credential, options)
+	if err != nil {
+		return nil, err
+	}
+	return &networkClients{securityGroups, securityRules, applicationSecurityGroups, interfaces, publicIPAddresses}, nil
+}
+
+// ListFirewallRules lists the allow rules of the firewall network security group.
+func (p *Provider) ListFirewallRules(ctx context.Context) ([]clouds.FirewallRule, error) {
+	if p.resourceGroup == "" {
+		return nil, errNoResourceGroup
+	}
+
+	rules, err := p.listSecurityRules(ctx)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list firewall rules: %w", err)
+	}
+
+	targets, err := p.firewallTargets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list firewall rules: %w", err)
+	}
+
+	var result []clouds.FirewallRule
+	for _, rule := range rules {
+		if rule.Properties == nil || deref((*string)(rule.Properties.Access)) != string(armnetwork.SecurityRuleAccessAllow) {
+			continue
+		}
+		result = append(result, toFirewallRule(rule, targets))
+	}
+	return result, nil
+}
+
+// ApplyFirewallRule creates a security rule in the firewall network security
+// group, or replaces the existing one of the same name. The rule applies to
+// an application security group named after it, and the network interfaces
+// of the VMs in the resource group with one of the target tags are added to
+// that group and associated with the network security group. VMs created or
+// tagged later are only added when the rule is applied again.
+func (p *Provider) ApplyFirewallRule(ctx context.Context, rule clouds.FirewallRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	if p.resourceGroup == "" {
+		return errNoResourceGroup
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("apply firewall rule %s", rule.Name))
+	err := p.applySecurityRule(ctx, rule)
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to apply firewall rule: %w", err)
+	}
+	return nil
+}
+
+// DeleteFirewallRule deletes a security rule from the firewall network
+// security group, removes the network interfaces from its application
+// security group and deletes that group.
+func (p *Provider) DeleteFirewallRule(ctx context.Context, name string) error {
+	if p.resourceGroup == "" {
+		return errNoResourceGroup
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("delete firewall rule %s", name))
+	err := p.deleteSecurityRule(ctx, name)
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to delete firewall rule: %w", err)
+	}
+	return nil
+}
+
+// applySecurityRule creates or replaces the security rule, creating the
+// network and application security groups it needs along the way, and
+// syncs the network interfaces of the VMs it targets.
+func (p *Provider) applySecurityRule(ctx context.Context, rule clouds.FirewallRule) error {
+	securityGroup, err := p.ensureSecurityGroup(ctx)
+	if err != nil {
+		return err
+	}
+	target, err := p.ensureApplicationSecurityGroup(ctx, rule)
+	if err != nil {
+		return err
+	}
+
+	existing, err := p.listSecurityRules(ctx)
+	if err != nil {
+		return err
+	}
+	priority, err := rulePriority(existing, rule.Name)
+	if err != nil {
+		return err
+	}
+
+	poller, err := p.network.securityRules.BeginCreateOrUpdate(ctx, p.resourceGroup, firewallSecurityGroup, rule.Name,
+		toSecurityRule(rule, priority, target), nil)
+	if err != nil {
+		return err
+	}
+	if _, err := poller.PollUntilDone(ctx, nil); err != nil {
+		return err
+	}
+
+	return p.syncFirewallTargets(ctx, target, securityGroup, func(tags map[string]string) bool {
+		return rule.Targets(tags)
+	})
+}
+
+// deleteSecurityRule deletes the security rule, then its application
+// security group, which cannot be deleted while in use.
+func (p *Provider) deleteSecurityRule(ctx context.Context, name string) error {
+	poller, err := p.network.securityRules.BeginDelete(ctx, p.resourceGroup, firewallSecurityGroup, name, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := poller.PollUntilDone(ctx, nil); err != nil {
+		return err
+	}
+
+	group, err := p.network.applicationSecurityGroups.Get(ctx, p.resourceGroup, name, nil)
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := p.syncFirewallTargets(ctx, deref(group.ID), "", func(map[string]string) bool { return false }); err != nil {
+		return err
+	}
+	deleted, err := p.network.applicationSecurityGroups.BeginDelete(ctx, p.resourceGroup, name, nil)
+	if err != nil {
+		return err
+	}
+	_, err = deleted.PollUntilDone(ctx, nil)
+	return err
+}
+
+// syncFirewallTargets adds the network interfaces of the VMs in the resource
+// group whose tags match to the application security group, associating
+// them with the network security group when they have none, and removes
+// the network interfaces of the other VMs from it.
+func (p *Provider) syncFirewallTargets(ctx context.Context, groupID, securityGroupID string, match func(tags map[string]string) bool) error {
+	pager := p.client.NewListPager(p.resourceGroup, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, vm := range page.Value {
+			if vm.Properties == nil || vm.Properties.NetworkProfile == nil {
+				continue
+			}
+			tags := make(map[string]string, len(vm.Tags))
+			for key, value := range vm.Tags {
+				tags[key] = deref(value)
+			}
+			targeted := match(tags)
+			for _, ref := range vm.Properties.NetworkProfile.NetworkInterfaces {
+				if err := p.syncInterface(ctx, deref(ref.ID), groupID, securityGroupID, targeted); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// syncInterface adds a network interface to the application security group,
+// or removes it, and updates it if anything changed. A targeted interface
+// with another network security group is an error, since the rules of the
+// firewall would not apply to it.
+func (p *Provider) syncInterface(ctx context.Context, nicID, groupID, securityGroupID string, targeted bool) error {
+	id, err := arm.ParseResourceID(nicID)
+	if err != nil {
+		return err
+	}
+	resp, err := p.network.interfaces.Get(ctx, id.ResourceGroupName, id.Name, nil)
+	if err != nil {
+		return err
+	}
+	nic := resp.Interface
+	if nic.Properties == nil {
+		return nil
+	}
+
+	changed := false
+	if targeted {
+		switch current := nic.Properties.NetworkSecurityGroup; {
+		case current == nil:
+			nic.Properties.NetworkSecurityGroup = &armnetwork.SecurityGroup{ID: &securityGroupID}
+			changed = true
+		case !strings.EqualFold(deref(current.ID), securityGroupID):
+			return fmt.Errorf("network interface %s already has the network security group %s", id.Name, path.Base(deref(current.ID)))
+		}
+	}
+	for _, config := range nic.Properties.IPConfigurations {
+		if config.Properties == nil {
+			continue
+		}
+		groups := config.Properties.ApplicationSecurityGroups
+		has := slices.ContainsFunc(groups, func(group *armnetwork.ApplicationSecurityGroup) bool {
+			return strings.EqualFold(deref(group.ID), groupID)
+		})
+		switch {
+		case targeted && !has:
+			config.Properties.ApplicationSecurityGroups = append(groups, &armnetwork.ApplicationSecurityGroup{ID: &groupID})
+			changed = true
+		case !targeted && has:
+			config.Properties.ApplicationSecurityGroups = slices.DeleteFunc(groups, func(group *armnetwork.ApplicationSecurityGroup) bool {
+				return strings.EqualFold(deref(group.ID), groupID)
+			})
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	poller, err := p.network.interfaces.BeginCreateOrUpdate(ctx, id.ResourceGroupName, id.Name, nic, nil)
+	if err != nil {
+		return err
+	}
+	_, err = poller.PollUntilDone(ctx, nil)
+	return err
+}
+
+// listSecurityRules lists the rules of the firewall network security group.
+func (p *Provider) listSecurityRules(ctx context.Context) ([]*armnetwork.SecurityRule, error) {
+	op := internal.StartOperation(fmt.Sprintf("list security rules of %s", firewallSecurityGroup))
+
+	var rules []*armnetwork.SecurityRule
+	pager := p.network.securityRules.NewListPager(p.resourceGroup, firewallSecurityGroup, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, op.Finish(err)
+		}
+		rules = append(rules, page.Value...)
+	}
+	op.Finish(nil)
+
+	return rules, nil
+}
+
+// ensureSecurityGroup returns the ID of the firewall network security group,
+// creating it if it does not exist.
+func (p *Provider) ensureSecurityGroup(ctx context.Context) (string, error) {
+	resp, err := p.network.securityGroups.Get(ctx, p.resourceGroup, firewallSecurityGroup, nil)
+	if err == nil {
+		return deref(resp.ID), nil
+	}
+	if !isNotFound(err) {
+		return "", err
+	}
+	if p.location == "" {
+		return "", errors.New("no Azure location set to create the network security group in; use --region or set `region` in the configuration")
+	}
+
+	poller, err := p.network.securityGroups.BeginCreateOrUpdate(ctx, p.resourceGroup, firewallSecurityGroup,
+		armnetwork.SecurityGroup{Location: &p.location}, nil)
+	if err != nil {
+		return "", err
+	}
+	created, err := poller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	return deref(created.ID), nil
+}
+
+// ensureApplicationSecurityGroup returns the ID of the application security
+// group of a rule, creating it if it does not exist, with the rule's target
+// tags as a tag.
+func (p *Provider) ensureApplicationSecurityGroup(ctx context.Context, rule clouds.FirewallRule) (string, error) {
+	targets := strings.Join(rule.TargetTags, ",")
+	location := p.location
+	resp, err := p.network.applicationSecurityGroups.Get(ctx, p.resourceGroup, rule.Name, nil)
+	switch {
+	case err == nil && deref(resp.Tags[firewallTargetsTag]) == targets:
+		return deref(resp.ID), nil
+	case err == nil:
+		location = deref(resp.Location)
+	case !isNotFound(err):
+		return "", err
+	case location == "":
+		return "", errors.New("no Azure location set to create the application security group in; use --region or set `region` in the configuration")
+	}
+
+	poller, err := p.network.applicationSecurityGroups.BeginCreateOrUpdate(ctx, p.resourceGroup, rule.Name,
+		armnetwork.ApplicationSecurityGroup{Location: &location, Tags: map[string]*string{firewallTargetsTag: &targets}}, nil)
+	if err != nil {
+		return "", err
+	}
+	created, err := poller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	return deref(created.ID), nil
+}
+
+// firewallTargets returns the target tags of the firewall rules by the
+// lower-case ID of their application security group.
+func (p *Provider) firewallTargets(ctx context.Context) (map[string][]string, error) {
+	targets := make(map[string][]string)
+	pager := p.network.applicationSecurityGroups.NewListPager(p.resourceGroup, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range page.Value {
+			if tags, ok := group.Tags[firewallTargetsTag]; ok {
+				targets[strings.ToLower(deref(group.ID))] = nil
+				if deref(tags) != "" {
+					targets[strings.ToLower(deref(group.ID))] = strings.Split(deref(tags), ",")
+				}
+			}
+		}
+	}
+	return targets, nil
+}
+
+// rulePriority returns the priority of the existing rule with the given
+// name, or the first free priority for a new rule.
+func rulePriority(rules []*armnetwork.SecurityRule, name string) (int32, error) {
+	used := make(map[int32]bool)
+	for _, rule := range rules {
+		if rule.Properties == nil || rule.Properties.Priority == nil {
+			continue
+		}
+		if deref(rule.Name) == name {
+			return *rule.Properties.Priority, nil
+		}
+		used[*rule.Properties.Priority] = true
+	}
+
+	for priority := int32(firstRulePriority); priority <= lastRulePriority; priority += rulePriorityStep {
+		if !used[priority] {
+			return priority, nil
+		}
+	}
+	return 0, fmt.Errorf("network security group %s has no free rule priority left", firewallSecurityGroup)
+}
+
+// toSecurityRule converts a firewall rule to an Azure security rule that
+// applies to the given application security group.
+func toSecurityRule(rule clouds.FirewallRule, priority int32, target string) armnetwork.SecurityRule {
+	protocols := map[string]armnetwork.SecurityRuleProtocol{
+		clouds.ProtocolTCP:  armnetwork.SecurityRuleProtocolTCP,
+		clouds.ProtocolUDP:  armnetwork.SecurityRuleProtocolUDP,
+		clouds.ProtocolICMP: armnetwork.SecurityRuleProtocolIcmp,
+		clouds.ProtocolAll:  armnetwork.SecurityRuleProtocolAsterisk,
+	}
+	protocol := protocols[rule.Protocol]
+	access := armnetwork.SecurityRuleAccessAllow
+	wildcard := "*"
+
+	props := &armnetwork.SecurityRulePropertiesFormat{
+		Access:          &access,
+		Protocol:        &protocol,
+		Priority:        &priority,
+		SourcePortRange: &wildcard,
+	}
+	if rule.Description != "" {
+		props.Description = &rule.Description
+	}
+	if len(rule.Ports) == 0 {
+		props.DestinationPortRange = &wildcard
+	}
+	for _, ports := range rule.Ports {
+		props.DestinationPortRanges = append(props.DestinationPortRanges, to(ports))
+	}
+
+	var prefixes []*string
+	for _, cidr := range rule.Ranges() {
+		prefixes = append(prefixes, to(cidr))
+	}
+	targets := []*armnetwork.ApplicationSecurityGroup{{ID: &target}}
+	if rule.Direction == clouds.Egress {
+		direction := armnetwork.SecurityRuleDirectionOutbound
+		props.Direction = &direction
+		props.DestinationAddressPrefixes = prefixes
+		props.SourceApplicationSecurityGroups = targets
+	} else {
+		direction := armnetwork.SecurityRuleDirectionInbound
+		props.Direction = &direction
+		props.SourceAddressPrefixes = prefixes
+		props.DestinationApplicationSecurityGroups = targets
+	}
+
+	return armnetwork.SecurityRule{Properties: props}
+}
+
+// toFirewallRule converts an Azure security rule to a firewall rule, given
+// the target tags of the application security groups of firewall rules.
+// The target tags of rules made outside the CLI are the names of their
+// application security groups.
+func toFirewallRule(rule *armnetwork.SecurityRule, firewallTargets map[string][]string) clouds.FirewallRule {
+	props := rule.Properties
+	result := clouds.FirewallRule{
+		Name:        deref(rule.Name),
+		Description: deref(props.Description),
+		Direction:   clouds.Ingress,
+		Protocol:    strings.ToLower(deref((*string)(props.Protocol))),
+	}
+	if result.Protocol == "*" {
+		result.Protocol = clouds.ProtocolAll
+	}
+	for _, ports := range append([]*string{props.DestinationPortRange}, props.DestinationPortRanges...) {
+		if ports != nil && *ports != "*" && *ports != "" {
+			result.Ports = append(result.Ports, *ports)
+		}
+	}
+
+	prefixes := append([]*string{props.SourceAddressPrefix}, props.SourceAddressPrefixes...)
+	targets := props.DestinationApplicationSecurityGroups
+	if deref((*string)(props.Direction)) == string(armnetwork.SecurityRuleDirectionOutbound) {
+		result.Direction = clouds.Egress
+		prefixes = append([]*string{props.DestinationAddressPrefix}, props.DestinationAddressPrefixes...)
+		targets = props.SourceApplicationSecurityGroups
+	}
+	for _, prefix := range prefixes {
+		if prefix == nil || *prefix == "*" || *prefix == "" {
+			continue
+		}
+		if result.Direction == clouds.Egress {
+			result.Destinations = append(result.Destinations, *prefix)
+		} else {
+			result.Sources = append(result.Sources, *prefix)
+		}
+	}
+	for _, target := range targets {
+		if tags, ok := firewallTargets[strings.ToLower(deref(target.ID))]; ok {
+			result.TargetTags = append(result.TargetTags, tags...)
+		} else {
+			result.TargetTags = append(result.TargetTags, path.Base(deref(target.ID)))
+		}
+	}
+	return result
+}
+
+// isNotFound reports whether an Azure call failed because the resource does not exist.
+func isNotFound(err error) bool {
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound
+}
+
+// to returns a pointer to a copy of s.
+func to(s string) *string {
+	return &s
+}
@@ -0,0 +1,27 @@
+package azurecloud
+
+import (
+	"context"
+	"namaste-cloud/clouds"
+	"testing"
+)
+
+// TestApplyFirewallRule applies a rule targeting role=web: the network
+// interface of the web VM joins the rule's application security group and
+// is associated with the network security group, while the db VM, which no
+// longer has the tag, leaves the group.
+func TestApplyFirewallRule(t *testing.T) {
+	p := newTestProvider(t)
+
+	rule := clouds.FirewallRule{
+		Name:       "allow-ssh",
+		Direction:  clouds.Ingress,
+		Protocol:   clouds.ProtocolTCP,
+		Ports:      []string{"22"},
+		Sources:    []string{"10.0.0.0/8"},
+		TargetTags: []string{"role=web"},
+	}
+	if err := p.ApplyFirewallRule(context.Background(), rule); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,93 @@
+package azurecloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+	"strconv"
+	"strings"
+)
+
+// marketplaceImage is a marketplace image, whose highest version is current.
+type marketplaceImage struct {
+	publisher string
+	offer     string
+	sku       string
+}
+
+// marketplaceImages are the marketplace images of each release alias by architecture.
+var marketplaceImages = map[string]map[string]marketplaceImage{
+	"ubuntu-24.04": {
+		clouds.ArchX86: {"Canonical", "ubuntu-24_04-lts", "server"},
+		clouds.ArchARM: {"Canonical", "ubuntu-24_04-lts", "server-arm64"},
+	},
+	"ubuntu-22.04": {
+		clouds.ArchX86: {"Canonical", "0001-com-ubuntu-server-jammy", "22_04-lts-gen2"},
+		clouds.ArchARM: {"Canonical", "0001-com-ubuntu-server-jammy", "22_04-lts-arm64"},
+	},
+	"debian-12": {
+		clouds.ArchX86: {"Debian", "debian-12", "12-gen2"},
+		clouds.ArchARM: {"Debian", "debian-12", "12-arm64"},
+	},
+	"debian-11": {
+		clouds.ArchX86: {"Debian", "debian-11", "11-gen2"},
+	},
+	"rhel-9": {
+		clouds.ArchX86: {"RedHat", "RHEL", "9-lvm-gen2"},
+	},
+	"rocky-9": {
+		clouds.ArchX86: {"resf", "rockylinux-x86_64", "9-base"},
+		clouds.ArchARM: {"resf", "rockylinux-aarch64", "9-base"},
+	},
+}
+
+// ResolveImage looks up the highest version of a release's marketplace
+// image in the location. The image ID is its URN.
+func (p *Provider) ResolveImage(ctx context.Context, alias, arch string) (clouds.Image, error) {
+	source, ok := marketplaceImages[alias][arch]
+	if !ok {
+		return clouds.Image{}, fmt.Errorf("%s image for %s on Azure: %w", alias, arch, clouds.ErrNotFound)
+	}
+	if p.location == "" {
+		return clouds.Image{}, errNoLocation
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("list versions of image %s:%s:%s", source.publisher, source.offer, source.sku))
+	resp, err := p.images.List(ctx, p.location, source.publisher, source.offer, source.sku, nil)
+	if err := op.Finish(err); err != nil {
+		return clouds.Image{}, fmt.Errorf("failed to look up the %s image: %w", alias, err)
+	}
+
+	latest := ""
+	for _, version := range resp.VirtualMachineImageResourceArray {
+		if name := deref(version.Name); latest == "" || newerVersion(name, latest) {
+			latest = name
+		}
+	}
+	if latest == "" {
+		return clouds.Image{}, fmt.Errorf("%s image for %s in %s: %w", alias, arch, p.location, clouds.ErrNotFound)
+	}
+	urn := strings.Join([]string{source.publisher, source.offer, source.sku, latest}, ":")
+	return clouds.Image{ID: urn, Name: urn, Alias: alias, Arch: arch}, nil
+}
+
+// newerVersion reports whether image version a, such as 24.04.202410170,
+// is higher than b, comparing the numbers between the dots.
+func newerVersion(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, errX := strconv.Atoi(as[i])
+		y, errY := strconv.Atoi(bs[i])
+		if errX != nil || errY != nil {
+			if as[i] != bs[i] {
+				return as[i] > bs[i]
+			}
+			continue
+		}
+		if x != y {
+			return x > y
+		}
+	}
+	return len(as) > len(bs)
+}
@@ -0,0 +1,154 @@
+package azurecloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+)
+
+// errInstanceKeys is returned for key pairs limited to an instance, which Azure SSH public keys do not support.
+var errInstanceKeys = fmt.Errorf("instance-specific key pairs: %w", clouds.ErrNotSupported)
+
+// ListKeyPairs lists the SSH public key resources in the resource group, or
+// in the whole subscription when no resource group is set.
+func (p *Provider) ListKeyPairs(ctx context.Context) ([]clouds.KeyPair, error) {
+	op := internal.StartOperation("list Azure SSH public keys")
+
+	var resources []*armcompute.SSHPublicKeyResource
+	if p.resourceGroup == "" {
+		pager := p.sshKeys.NewListBySubscriptionPager(nil)
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list key pairs: %w", op.Finish(err))
+			}
+			resources = append(resources, page.Value...)
+		}
+	} else {
+		pager := p.sshKeys.NewListByResourceGroupPager(p.resourceGroup, nil)
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list key pairs: %w", op.Finish(err))
+			}
+			resources = append(resources, page.Value...)
+		}
+	}
+	op.Finish(nil)
+
+	var keys []clouds.KeyPair
+	for _, resource := range resources {
+		keys = append(keys, toKeyPair(resource))
+	}
+	return keys, nil
+}
+
+// CreateKeyPair creates an SSH public key resource and has Azure generate its
+// key pair, returning the private key.
+func (p *Provider) CreateKeyPair(ctx context.Context, key clouds.KeyPair) (clouds.KeyPair, string, error) {
+	if err := p.checkKeyScope(key); err != nil {
+		return clouds.KeyPair{}, "", err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("create key pair %s", key.Name))
+	_, err := p.sshKeys.Create(ctx, p.resourceGroup, key.Name, armcompute.SSHPublicKeyResource{
+		Location: to(p.location),
+		Tags:     toTags(p.defaultTags),
+	}, nil)
+	if err != nil {
+		return clouds.KeyPair{}, "", fmt.Errorf("failed to create key pair: %w", op.Finish(err))
+	}
+	resp, err := p.sshKeys.GenerateKeyPair(ctx, p.resourceGroup, key.Name, nil)
+	if err := op.Finish(err); err != nil {
+		return clouds.KeyPair{}, "", fmt.Errorf("failed to create key pair: %w", err)
+	}
+
+	created, err := clouds.ParsePublicKey(deref(resp.PublicKey))
+	if err != nil {
+		return clouds.KeyPair{}, "", fmt.Errorf("failed to create key pair: %w", err)
+	}
+	created.Name = key.Name
+	return created, deref(resp.PrivateKey), nil
+}
+
+// ImportKeyPair creates an SSH public key resource from an existing public
+// key, replacing the key of a resource of the same name. Azure only accepts
+// RSA keys of at least 2048 bits.
+func (p *Provider) ImportKeyPair(ctx context.Context, key clouds.KeyPair) (clouds.KeyPair, error) {
+	if err := p.checkKeyScope(key); err != nil {
+		return clouds.KeyPair{}, err
+	}
+	parsed, err := clouds.ParsePublicKey(key.PublicKey)
+	if err != nil {
+		return clouds.KeyPair{}, err
+	}
+	if !strings.HasPrefix(parsed.PublicKey, "ssh-rsa ") {
+		return clouds.KeyPair{}, fmt.Errorf("Azure only accepts ssh-rsa public keys")
+	}
+	parsed.Name = key.Name
+
+	op := internal.StartOperation(fmt.Sprintf("import key pair %s", key.Name))
+	_, err = p.sshKeys.Create(ctx, p.resourceGroup, key.Name, armcompute.SSHPublicKeyResource{
+		Location: to(p.location),
+		Tags:     toTags(p.defaultTags),
+		Properties: &armcompute.SSHPublicKeyResourceProperties{
+			PublicKey: to(parsed.PublicKey),
+		},
+	}, nil)
+	if err := op.Finish(err); err != nil {
+		return clouds.KeyPair{}, fmt.Errorf("failed to import key pair: %w", err)
+	}
+	return parsed, nil
+}
+
+// DeleteKeyPair deletes an SSH public key resource.
+func (p *Provider) DeleteKeyPair(ctx context.Context, key clouds.KeyPair) error {
+	if key.Instance != "" {
+		return errInstanceKeys
+	}
+	if p.resourceGroup == "" {
+		return errNoResourceGroup
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("delete key pair %s", key.Name))
+	_, err := p.sshKeys.Delete(ctx, p.resourceGroup, key.Name, nil)
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to delete key pair: %w", err)
+	}
+	return nil
+}
+
+// checkKeyScope checks that a key pair can be created: SSH public key
+// resources live in the resource group and location.
+func (p *Provider) checkKeyScope(key clouds.KeyPair) error {
+	switch {
+	case key.Instance != "":
+		return errInstanceKeys
+	case p.resourceGroup == "":
+		return errNoResourceGroup
+	case p.location == "":
+		return fmt.Errorf("no Azure location set; use --region or set `region` in the configuration")
+	}
+	return nil
+}
+
+// toKeyPair converts an SSH public key resource to the provider-neutral
+// representation. Resources whose key has not been generated yet have no
+// public key or fingerprint.
+func toKeyPair(resource *armcompute.SSHPublicKeyResource) clouds.KeyPair {
+	var publicKey string
+	if resource.Properties != nil {
+		publicKey = deref(resource.Properties.PublicKey)
+	}
+
+	key, err := clouds.ParsePublicKey(publicKey)
+	if err != nil {
+		key = clouds.KeyPair{PublicKey: publicKey}
+	}
+	key.Name = deref(resource.Name)
+	return key
+}
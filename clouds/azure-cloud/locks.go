@@ -0,0 +1,170 @@
+package azurecloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/internal"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+// Management locks are read and written with the REST API directly, since
+// the SDK's locks module is not a dependency of this CLI.
+const (
+	locksAPIVersion = "2016-09-01"
+
+	// protectionLock is the name of the lock that SetTerminationProtection
+	// creates; locks created by others are never removed.
+	protectionLock = "namaste-termination-protection"
+)
+
+// locksClient manages the management locks of virtual machines.
+type locksClient struct {
+	client         *arm.Client
+	subscriptionID string
+}
+
+// managementLock is a management lock as returned by the API.
+type managementLock struct {
+	Name       string `json:"name"`
+	Properties struct {
+		Level string `json:"level"`
+		Notes string `json:"notes,omitempty"`
+	} `json:"properties"`
+}
+
+// newLocksClient creates a client for the management locks API.
+func newLocksClient(subscriptionID string, credential azcore.TokenCredential, options *arm.ClientOptions) (*locksClient, error) {
+	client, err := arm.NewClient("namaste-cloud/locks", "v1.0.0", credential, options)
+	if err != nil {
+		return nil, err
+	}
+	return &locksClient{client: client, subscriptionID: subscriptionID}, nil
+}
+
+// TerminationProtection reports whether a delete or read-only lock applies
+// to an Azure virtual machine.
+func (p *Provider) TerminationProtection(ctx context.Context, instanceID string) (bool, error) {
+	resourceGroup, name, err := p.vmRef(instanceID)
+	if err != nil {
+		return false, err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("get locks of instance %s", instanceID))
+	locks, err := p.locks.list(ctx, resourceGroup, name)
+	if err := op.Finish(err); err != nil {
+		return false, fmt.Errorf("failed to get termination protection: %w", err)
+	}
+	return len(locks) > 0, nil
+}
+
+// SetTerminationProtection adds or removes a CanNotDelete lock on an Azure
+// virtual machine. Only the lock this CLI adds is removed; if other locks
+// still apply to the VM, that is an error.
+func (p *Provider) SetTerminationProtection(ctx context.Context, instanceID string, enabled bool) error {
+	resourceGroup, name, err := p.vmRef(instanceID)
+	if err != nil {
+		return err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("set locks of instance %s", instanceID))
+	if enabled {
+		err = p.locks.create(ctx, resourceGroup, name)
+	} else if err = p.locks.delete(ctx, resourceGroup, name); err == nil {
+		var locks []managementLock
+		if locks, err = p.locks.list(ctx, resourceGroup, name); err == nil && len(locks) > 0 {
+			var names []string
+			for _, lock := range locks {
+				names = append(names, lock.Name)
+			}
+			err = fmt.Errorf("instance %s is still locked by %s, which must be removed in Azure", instanceID, strings.Join(names, ", "))
+		}
+	}
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to set termination protection: %w", err)
+	}
+	return nil
+}
+
+// list returns the delete and read-only locks that apply to a VM, including
+// those inherited from its resource group and subscription.
+func (c *locksClient) list(ctx context.Context, resourceGroup, vm string) ([]managementLock, error) {
+	req, err := runtime.NewRequest(ctx, http.MethodGet, c.url(resourceGroup, vm, ""))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Pipeline().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK) {
+		return nil, runtime.NewResponseError(resp)
+	}
+
+	var page struct {
+		Value []managementLock `json:"value"`
+	}
+	if err := runtime.UnmarshalAsJSON(resp, &page); err != nil {
+		return nil, err
+	}
+	var locks []managementLock
+	for _, lock := range page.Value {
+		if lock.Properties.Level == "CanNotDelete" || lock.Properties.Level == "ReadOnly" {
+			locks = append(locks, lock)
+		}
+	}
+	return locks, nil
+}
+
+// create adds the CanNotDelete lock of this CLI to a VM.
+func (c *locksClient) create(ctx context.Context, resourceGroup, vm string) error {
+	req, err := runtime.NewRequest(ctx, http.MethodPut, c.url(resourceGroup, vm, protectionLock))
+	if err != nil {
+		return err
+	}
+	var lock managementLock
+	lock.Properties.Level = "CanNotDelete"
+	lock.Properties.Notes = "Termination protection set by namaste-cloud"
+	if err := runtime.MarshalAsJSON(req, lock); err != nil {
+		return err
+	}
+	resp, err := c.client.Pipeline().Do(req)
+	if err != nil {
+		return err
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK, http.StatusCreated) {
+		return runtime.NewResponseError(resp)
+	}
+	return nil
+}
+
+// delete removes the lock of this CLI from a VM, if it has one.
+func (c *locksClient) delete(ctx context.Context, resourceGroup, vm string) error {
+	req, err := runtime.NewRequest(ctx, http.MethodDelete, c.url(resourceGroup, vm, protectionLock))
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Pipeline().Do(req)
+	if err != nil {
+		return err
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK, http.StatusNoContent) {
+		return runtime.NewResponseError(resp)
+	}
+	return nil
+}
+
+// url returns the URL of a lock on a VM, or of all its locks when name is empty.
+func (c *locksClient) url(resourceGroup, vm, name string) string {
+	path := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Compute/virtualMachines/%s/providers/Microsoft.Authorization/locks",
+		url.PathEscape(c.subscriptionID), url.PathEscape(resourceGroup), url.PathEscape(vm))
+	if name != "" {
+		path += "/" + url.PathEscape(name)
+	}
+	return runtime.JoinPaths(c.client.Endpoint(), path) + "?api-version=" + locksAPIVersion
+}
@@ -0,0 +1,25 @@
+package azurecloud
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestTerminationProtection(t *testing.T) {
+	p := newTestProvider(t)
+
+	protected, err := p.TerminationProtection(context.Background(), "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !protected {
+		t.Error("instance with delete locks is not reported as protected")
+	}
+
+	// The resource group's lock is not removed along with the CLI's own
+	err = p.SetTerminationProtection(context.Background(), "web", false)
+	if err == nil || !strings.Contains(err.Error(), "still locked by keep-rg") {
+		t.Errorf("got %v, want an error about the remaining lock", err)
+	}
+}
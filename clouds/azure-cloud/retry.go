@@ -0,0 +1,61 @@
+package azurecloud
+
+import (
+	"namaste-cloud/internal"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// retryableStatusCodes are the Azure Resource Manager responses that are safe
+// to retry: throttling and transient server-side failures.
+var retryableStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// clientOptions returns ARM client options that retry with the shared policy
+// and talk to the configured endpoint, if any.
+func clientOptions(cfg internal.Config) *arm.ClientOptions {
+	retry := cfg.Retry.WithDefaults()
+
+	// The SDK treats zero retries as "use the default", so -1 disables retrying.
+	maxRetries := int32(retry.MaxAttempts - 1)
+	if maxRetries == 0 {
+		maxRetries = -1
+	}
+
+	options := &arm.ClientOptions{
+		ClientOptions: policy.ClientOptions{
+			Retry: policy.RetryOptions{
+				MaxRetries:    maxRetries,
+				RetryDelay:    time.Duration(retry.BaseDelay),
+				MaxRetryDelay: time.Duration(retry.MaxDelay),
+				StatusCodes:   retryableStatusCodes,
+			},
+			Transport: internal.NewHTTPClient(cfg.SkipsTLSVerify()),
+		},
+	}
+
+	// Point Resource Manager at the custom endpoint, keeping the public cloud's
+	// token audience so that the usual credentials still work.
+	if cfg.EndpointURL != "" {
+		options.Cloud = cloud.Configuration{
+			ActiveDirectoryAuthorityHost: cloud.AzurePublic.ActiveDirectoryAuthorityHost,
+			Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+				cloud.ResourceManager: {
+					Endpoint: cfg.EndpointURL,
+					Audience: cloud.AzurePublic.Services[cloud.ResourceManager].Audience,
+				},
+			},
+		}
+	}
+	return options
+}
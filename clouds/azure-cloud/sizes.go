@@ -0,0 +1,55 @@
+package azurecloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+	"regexp"
+)
+
+// armSize matches the names of Arm-based VM sizes, which have a "p" among
+// the feature letters after the number of vCPUs, as in Standard_D4ps_v5.
+var armSize = regexp.MustCompile(`^Standard_[A-Z]+\d+[a-z]*p[a-z]*_v\d+$`)
+
+// ListSizes lists the VM sizes offered in the location. The API does not
+// return the number of GPUs, which is taken from the bundled catalog.
+func (p *Provider) ListSizes(ctx context.Context) ([]clouds.Size, error) {
+	if p.location == "" {
+		return nil, errNoLocation
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("list VM sizes in %s", p.location))
+
+	var sizes []clouds.Size
+	pager := p.sizes.NewListPager(p.location, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list VM sizes: %w", op.Finish(err))
+		}
+		for _, vmSize := range page.Value {
+			size := clouds.Size{
+				Type:      deref(vmSize.Name),
+				CPUs:      int(derefInt32(vmSize.NumberOfCores)),
+				MemoryGiB: float64(derefInt32(vmSize.MemoryInMB)) / 1024,
+				Arch:      clouds.ArchX86,
+			}
+			if armSize.MatchString(size.Type) {
+				size.Arch = clouds.ArchARM
+			}
+			sizes = append(sizes, size)
+		}
+	}
+	op.Finish(nil)
+
+	return sizes, nil
+}
+
+// derefInt32 returns the value of an optional number, or zero.
+func derefInt32(n *int32) int32 {
+	if n == nil {
+		return 0
+	}
+	return *n
+}
@@ -0,0 +1,74 @@
+package azurecloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/internal"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+)
+
+// TagInstance adds tags to an Azure virtual machine.
+func (p *Provider) TagInstance(ctx context.Context, instanceID string, tags map[string]string) error {
+	op := internal.StartOperation(fmt.Sprintf("tag instance %s", instanceID))
+	err := p.updateTags(ctx, instanceID, func(current map[string]*string) {
+		for k, v := range tags {
+			current[k] = to(v)
+		}
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to tag instance: %w", err)
+	}
+	return nil
+}
+
+// UntagInstance removes tags from an Azure virtual machine.
+func (p *Provider) UntagInstance(ctx context.Context, instanceID string, keys []string) error {
+	op := internal.StartOperation(fmt.Sprintf("untag instance %s", instanceID))
+	err := p.updateTags(ctx, instanceID, func(current map[string]*string) {
+		for _, key := range keys {
+			delete(current, key)
+		}
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to untag instance: %w", err)
+	}
+	return nil
+}
+
+// updateTags rewrites the tags of a VM. Updating a VM replaces all of its
+// tags, so the current tags are read and changed first.
+func (p *Provider) updateTags(ctx context.Context, instanceID string, update func(map[string]*string)) error {
+	resourceGroup, name, err := p.vmRef(instanceID)
+	if err != nil {
+		return err
+	}
+	vm, err := p.client.Get(ctx, resourceGroup, name, nil)
+	if err != nil {
+		return err
+	}
+
+	tags := make(map[string]*string, len(vm.Tags))
+	for k, v := range vm.Tags {
+		tags[k] = v
+	}
+	update(tags)
+	poller, err := p.client.BeginUpdate(ctx, resourceGroup, name, armcompute.VirtualMachineUpdate{Tags: tags}, nil)
+	if err != nil {
+		return err
+	}
+	_, err = poller.PollUntilDone(ctx, nil)
+	return err
+}
+
+// toTags converts tags to Azure tags, or nil when there are none.
+func toTags(tags map[string]string) map[string]*string {
+	if len(tags) == 0 {
+		return nil
+	}
+	result := make(map[string]*string, len(tags))
+	for k, v := range tags {
+		result[k] = to(v)
+	}
+	return result
+}
@@ -0,0 +1,17 @@
+package clouds
+
+import "context"
+
+// CommandResult is the outcome of a command run on an instance.
+type CommandResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// CommandRunner is implemented by providers that can run shell commands on
+// instances through an agent, without connecting to them, such as AWS
+// Systems Manager.
+type CommandRunner interface {
+	RunCommand(ctx context.Context, instanceID, command string) (CommandResult, error)
+}
@@ -0,0 +1,42 @@
+package clouds
+
+import (
+	"context"
+	"strings"
+)
+
+// Console is implemented by providers that can read an instance's serial
+// console and capture a screenshot of its display, which helps to find out
+// why an instance does not boot.
+type Console interface {
+	// ConsoleOutput returns the serial console output collected so far. Some
+	// clouds only keep the most recent part of it.
+	ConsoleOutput(ctx context.Context, id string) (string, error)
+
+	// ConsoleScreenshot returns a JPEG or PNG image of the instance's display.
+	ConsoleScreenshot(ctx context.Context, id string) ([]byte, error)
+}
+
+// NewConsoleOutput returns the part of current that follows previous, for
+// following the console output of an instance. Clouds that only keep the
+// most recent output drop its start, so current is matched against the end
+// of previous rather than all of it. If no part of previous matches, all of
+// current is new.
+func NewConsoleOutput(previous, current string) string {
+	if current == "" {
+		return ""
+	}
+
+	// Only offsets where current's first byte occurs in previous can match
+	for offset := 0; offset < len(previous); offset++ {
+		i := strings.IndexByte(previous[offset:], current[0])
+		if i < 0 {
+			break
+		}
+		offset += i
+		if strings.HasPrefix(current, previous[offset:]) {
+			return current[len(previous)-offset:]
+		}
+	}
+	return current
+}
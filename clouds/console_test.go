@@ -0,0 +1,31 @@
+package clouds
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewConsoleOutput(t *testing.T) {
+	long := strings.Repeat("x", 2048) + "booting\n"
+
+	tests := []struct {
+		name     string
+		previous string
+		current  string
+		want     string
+	}{
+		{"first", "", "line 1\n", "line 1\n"},
+		{"appended", "line 1\n", "line 1\nline 2\n", "line 2\n"},
+		{"unchanged", "line 1\n", "line 1\n", ""},
+		{"start dropped", "line 1\nline 2\n", "line 2\nline 3\n", "line 3\n"},
+		{"long start dropped", "early\n" + long, long[10:] + "login:", "login:"},
+		{"replaced", "line 1\n", "rebooted\n", "rebooted\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewConsoleOutput(tt.previous, tt.current); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
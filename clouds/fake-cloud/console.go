@@ -0,0 +1,69 @@
+package fakecloud
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"namaste-cloud/internal"
+	"strings"
+)
+
+// ConsoleOutput returns a simulated boot log, which grows as the instance
+// boots and is empty while it is stopped.
+func (p *Provider) ConsoleOutput(ctx context.Context, id string) (string, error) {
+	op := internal.StartOperation(fmt.Sprintf("get console output of instance %s", id))
+
+	var output string
+	err := p.update(ctx, func(s *state) error {
+		inst, err := s.get(id)
+		if err != nil {
+			return err
+		}
+		output = bootLog(inst)
+		return nil
+	})
+	if err := op.Finish(err); err != nil {
+		return "", fmt.Errorf("failed to get console output: %w", err)
+	}
+	return output, nil
+}
+
+// ConsoleScreenshot returns a black PNG image the size of a text console.
+func (p *Provider) ConsoleScreenshot(ctx context.Context, id string) ([]byte, error) {
+	op := internal.StartOperation(fmt.Sprintf("get console screenshot of instance %s", id))
+	err := p.update(ctx, func(s *state) error {
+		_, err := s.get(id)
+		return err
+	})
+	if err := op.Finish(err); err != nil {
+		return nil, fmt.Errorf("failed to get console screenshot: %w", err)
+	}
+
+	screen := image.NewGray(image.Rect(0, 0, 640, 400))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, screen); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// bootLog returns the console output of an instance in its current state.
+func bootLog(inst *instance) string {
+	lines := []string{
+		"[    0.000000] Linux version 6.1.0-fake (fake@cloud)",
+		"[    0.412000] Booting the fake cloud kernel",
+	}
+	switch inst.State {
+	case stateRunning, stateStopping, stateShuttingDown:
+		lines = append(lines,
+			"[    2.918000] systemd[1]: Reached target Multi-User System.",
+			fmt.Sprintf("%s login:", inst.Name),
+		)
+	case statePending:
+	default:
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,451 @@
+package fakecloud
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults used by New for the simulated behaviour of the fake cloud.
+const (
+	DefaultLatency        = 200 * time.Millisecond
+	DefaultTransitionTime = 5 * time.Second
+	DefaultRegion         = "fake-region-1"
+	DefaultInstanceType   = "fake.small"
+
+	// terminatedRetention is how long terminated instances stay visible, as on EC2.
+	terminatedRetention = time.Hour
+)
+
+// Instance states, named after their EC2 equivalents.
+const (
+	statePending      = "pending"
+	stateRunning      = "running"
+	stateStopping     = "stopping"
+	stateStopped      = "stopped"
+	stateShuttingDown = "shutting-down"
+	stateTerminated   = "terminated"
+)
+
+// Options configure the simulated behaviour of the fake provider.
+type Options struct {
+	// StatePath is the file the state is persisted to between invocations.
+	// When empty, the state is kept in memory only.
+	StatePath string
+
+	// Latency is added to every call to simulate a remote API.
+	Latency time.Duration
+
+	// TransitionTime is how long instances stay in transitional states such
+	// as "pending" or "stopping".
+	TransitionTime time.Duration
+
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+
+	// DefaultTags are added to every instance the provider creates.
+	DefaultTags map[string]string
+}
+
+// Provider is an in-memory or file-backed cloud that needs no credentials.
+// It is meant for tests, demos and developing new commands.
+type Provider struct {
+	mu     sync.Mutex
+	opts   Options
+	region string
+	memory *state
+}
+
+// state is everything the fake cloud knows about.
+type state struct {
+	Instances     map[string]*instance           `json:"instances"`
+	FirewallRules map[string]clouds.FirewallRule `json:"firewall_rules,omitempty"`
+	KeyPairs      map[string]clouds.KeyPair      `json:"key_pairs,omitempty"`
+}
+
+// newState returns an empty state.
+func newState() *state {
+	return &state{
+		Instances:     make(map[string]*instance),
+		FirewallRules: make(map[string]clouds.FirewallRule),
+		KeyPairs:      make(map[string]clouds.KeyPair),
+	}
+}
+
+// instance is a simulated instance, including its pending state transition.
+type instance struct {
+	clouds.Instance
+	Target       string    `json:"target,omitempty"`
+	TransitionAt time.Time `json:"transition_at,omitempty"`
+
+	// Protected instances cannot be terminated, as with EC2 termination protection.
+	Protected bool `json:"protected,omitempty"`
+}
+
+// New creates a fake provider whose state is stored in the configuration directory.
+func New(cfg internal.Config) (*Provider, error) {
+	configDir, err := internal.GetUserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWithOptions(cfg.Region, Options{
+		StatePath:      filepath.Join(configDir, "fake-cloud.json"),
+		Latency:        DefaultLatency,
+		TransitionTime: DefaultTransitionTime,
+		DefaultTags:    cfg.DefaultTags,
+	}), nil
+}
+
+// NewWithOptions creates a fake provider for the given region with explicit options.
+func NewWithOptions(region string, opts Options) *Provider {
+	if region == "" {
+		region = DefaultRegion
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Provider{opts: opts, region: region}
+}
+
+// Name implements clouds.Provider.
+func (p *Provider) Name() string {
+	return "fake"
+}
+
+// Close implements clouds.Provider.
+func (p *Provider) Close() error {
+	return nil
+}
+
+// ListInstances lists all fake instances, oldest first.
+func (p *Provider) ListInstances(ctx context.Context) ([]clouds.Instance, error) {
+	op := internal.StartOperation("list fake instances")
+
+	var instances []clouds.Instance
+	err := p.update(ctx, func(s *state) error {
+		for _, inst := range s.Instances {
+			instances = append(instances, inst.Instance)
+		}
+		return nil
+	})
+	if err := op.Finish(err); err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].LaunchTime.Before(instances[j].LaunchTime)
+	})
+	return instances, nil
+}
+
+// DescribeInstance provides details of a fake instance.
+func (p *Provider) DescribeInstance(ctx context.Context, id string) (clouds.Instance, error) {
+	op := internal.StartOperation(fmt.Sprintf("describe instance %s", id))
+
+	var result clouds.Instance
+	err := p.update(ctx, func(s *state) error {
+		inst, err := s.get(id)
+		if err != nil {
+			return err
+		}
+		result = inst.Instance
+		return nil
+	})
+	if err := op.Finish(err); err != nil {
+		return clouds.Instance{}, fmt.Errorf("failed to describe instance: %w", err)
+	}
+	return result, nil
+}
+
+// CreateInstance creates a fake instance, which starts out pending.
+func (p *Provider) CreateInstance(ctx context.Context, spec clouds.InstanceSpec) (clouds.Instance, error) {
+	if len(spec.SecurityGroups) > 0 {
+		return clouds.Instance{}, fmt.Errorf("security groups on fake: %w", clouds.ErrNotSupported)
+	}
+	op := internal.StartOperation(fmt.Sprintf("create fake instance %s", spec.Name))
+
+	instanceType := spec.Type
+	if instanceType == "" {
+		instanceType = DefaultInstanceType
+	}
+
+	var result clouds.Instance
+	err := p.update(ctx, func(s *state) error {
+		if _, ok := s.KeyPairs[spec.KeyName]; spec.KeyName != "" && !ok {
+			return fmt.Errorf("key pair %s: %w", spec.KeyName, clouds.ErrNotFound)
+		}
+
+		now := p.opts.Now()
+		inst := &instance{
+			Instance: clouds.Instance{
+				ID:         "fake-" + randomHex(8),
+				Name:       spec.Name,
+				Type:       instanceType,
+				Location:   p.region + "a",
+				PrivateIP:  randomIP("10.0"),
+				LaunchTime: now,
+				KeyName:    spec.KeyName,
+				Tags:       clouds.MergeTags(p.opts.DefaultTags, spec.Tags),
+			},
+		}
+		p.transition(inst, statePending, stateRunning, now)
+		s.Instances[inst.ID] = inst
+		result = inst.Instance
+		return nil
+	})
+	if err := op.Finish(err); err != nil {
+		return clouds.Instance{}, fmt.Errorf("failed to create instance: %w", err)
+	}
+	return result, nil
+}
+
+// StartInstance starts a stopped fake instance.
+func (p *Provider) StartInstance(ctx context.Context, id string) error {
+	return p.applyAction(ctx, clouds.ActionStart, id)
+}
+
+// StopInstance stops a running fake instance.
+func (p *Provider) StopInstance(ctx context.Context, id string) error {
+	return p.applyAction(ctx, clouds.ActionStop, id)
+}
+
+// RebootInstance reboots a running fake instance, which stays running.
+func (p *Provider) RebootInstance(ctx context.Context, id string) error {
+	return p.applyAction(ctx, clouds.ActionReboot, id)
+}
+
+// TerminateInstance terminates a fake instance.
+func (p *Provider) TerminateInstance(ctx context.Context, id string) error {
+	return p.applyAction(ctx, clouds.ActionTerminate, id)
+}
+
+// DryRunInstanceAction checks whether a lifecycle action is allowed in the
+// instance's current state without applying it.
+func (p *Provider) DryRunInstanceAction(ctx context.Context, action clouds.InstanceAction, id string) error {
+	op := internal.StartOperation(fmt.Sprintf("dry run %s instance %s", action, id))
+	err := p.update(ctx, func(s *state) error {
+		inst, err := s.get(id)
+		if err != nil {
+			return err
+		}
+		return checkAction(inst, action)
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to %s instance: %w", action, err)
+	}
+	return nil
+}
+
+// applyAction applies a lifecycle action to a fake instance.
+func (p *Provider) applyAction(ctx context.Context, action clouds.InstanceAction, id string) error {
+	op := internal.StartOperation(fmt.Sprintf("%s instance %s", action, id))
+	err := p.update(ctx, func(s *state) error {
+		inst, err := s.get(id)
+		if err != nil {
+			return err
+		}
+		if err := checkAction(inst, action); err != nil {
+			return err
+		}
+
+		now := p.opts.Now()
+		switch action {
+		case clouds.ActionStart:
+			p.transition(inst, statePending, stateRunning, now)
+		case clouds.ActionStop:
+			p.transition(inst, stateStopping, stateStopped, now)
+		case clouds.ActionTerminate:
+			p.transition(inst, stateShuttingDown, stateTerminated, now)
+		}
+		return nil
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to %s instance: %w", action, err)
+	}
+	return nil
+}
+
+// checkAction returns an error if the instance's state does not allow the action.
+func checkAction(inst *instance, action clouds.InstanceAction) error {
+	var allowed bool
+	switch action {
+	case clouds.ActionStart:
+		allowed = inst.State == stateStopped
+	case clouds.ActionStop:
+		allowed = inst.State == stateRunning || inst.State == statePending
+	case clouds.ActionReboot:
+		allowed = inst.State == stateRunning
+	case clouds.ActionTerminate:
+		if inst.Protected {
+			return fmt.Errorf("instance %s has termination protection enabled", inst.ID)
+		}
+		allowed = inst.State != stateTerminated && inst.State != stateShuttingDown
+	default:
+		return fmt.Errorf("unknown instance action %q", action)
+	}
+	if !allowed {
+		return incorrectState(inst)
+	}
+	return nil
+}
+
+// transition moves an instance into a transitional state that settles into
+// target once the configured transition time has passed.
+func (p *Provider) transition(inst *instance, current, target string, now time.Time) {
+	inst.State = current
+	inst.Target = target
+	inst.TransitionAt = now.Add(p.opts.TransitionTime)
+	if p.opts.TransitionTime <= 0 {
+		settle(inst, now)
+	}
+}
+
+// settle completes the pending transition of an instance if it is due.
+func settle(inst *instance, now time.Time) {
+	if inst.Target == "" || now.Before(inst.TransitionAt) {
+		return
+	}
+
+	inst.State = inst.Target
+	inst.Target = ""
+	switch inst.State {
+	case stateRunning:
+		inst.PublicIP = randomIP("203.0.113")
+	case stateStopped, stateTerminated:
+		inst.PublicIP = ""
+	}
+}
+
+// update loads the state, settles due transitions, applies fn and saves the
+// result. The configured latency is simulated before anything happens.
+func (p *Provider) update(ctx context.Context, fn func(*state) error) error {
+	if p.opts.Latency > 0 {
+		select {
+		case <-time.After(p.opts.Latency):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, err := p.load()
+	if err != nil {
+		return err
+	}
+
+	now := p.opts.Now()
+	for id, inst := range s.Instances {
+		settle(inst, now)
+		if inst.State == stateTerminated && now.Sub(inst.TransitionAt) > terminatedRetention {
+			delete(s.Instances, id)
+		}
+	}
+
+	if err := fn(s); err != nil {
+		return err
+	}
+	return p.save(s)
+}
+
+// load reads the state from memory or from the state file.
+func (p *Provider) load() (*state, error) {
+	if p.opts.StatePath == "" {
+		if p.memory == nil {
+			p.memory = newState()
+		}
+		return p.memory, nil
+	}
+
+	s := newState()
+	data, err := os.ReadFile(p.opts.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fake cloud state: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse fake cloud state: %w", err)
+	}
+	if s.Instances == nil {
+		s.Instances = make(map[string]*instance)
+	}
+	if s.FirewallRules == nil {
+		s.FirewallRules = make(map[string]clouds.FirewallRule)
+	}
+	if s.KeyPairs == nil {
+		s.KeyPairs = make(map[string]clouds.KeyPair)
+	}
+	return s, nil
+}
+
+// save writes the state to the state file, if there is one.
+func (p *Provider) save(s *state) error {
+	if p.opts.StatePath == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize fake cloud state: %w", err)
+	}
+
+	// Write to a temporary file first so an interrupted write never corrupts the state.
+	if err := os.MkdirAll(filepath.Dir(p.opts.StatePath), 0700); err != nil {
+		return err
+	}
+	tmp := p.opts.StatePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write fake cloud state: %w", err)
+	}
+	return os.Rename(tmp, p.opts.StatePath)
+}
+
+// get returns the instance with the given ID.
+func (s *state) get(id string) (*instance, error) {
+	inst, ok := s.Instances[id]
+	if !ok {
+		return nil, fmt.Errorf("instance %s: %w", id, clouds.ErrNotFound)
+	}
+	return inst, nil
+}
+
+// incorrectState returns the error for an operation that is not allowed in
+// the instance's current state.
+func incorrectState(inst *instance) error {
+	return fmt.Errorf("instance %s is in state %s, which does not allow this operation", inst.ID, inst.State)
+}
+
+// randomHex returns n random bytes encoded as hex.
+func randomHex(n int) string {
+	b := make([]byte, n/2)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// randomIP returns a random address in the given /16 or /24 prefix.
+func randomIP(prefix string) string {
+	octet := func() int64 {
+		n, _ := rand.Int(rand.Reader, big.NewInt(254))
+		return n.Int64() + 1
+	}
+	if strings.Count(prefix, ".") == 1 {
+		return fmt.Sprintf("%s.%d.%d", prefix, octet(), octet())
+	}
+	return fmt.Sprintf("%s.%d", prefix, octet())
+}
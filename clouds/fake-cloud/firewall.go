@@ -0,0 +1,63 @@
+package fakecloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+	"sort"
+)
+
+// ListFirewallRules lists the fake firewall rules by name.
+func (p *Provider) ListFirewallRules(ctx context.Context) ([]clouds.FirewallRule, error) {
+	op := internal.StartOperation("list fake firewall rules")
+
+	var rules []clouds.FirewallRule
+	err := p.update(ctx, func(s *state) error {
+		for _, rule := range s.FirewallRules {
+			rules = append(rules, rule)
+		}
+		return nil
+	})
+	if err := op.Finish(err); err != nil {
+		return nil, fmt.Errorf("failed to list firewall rules: %w", err)
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Name < rules[j].Name
+	})
+	return rules, nil
+}
+
+// ApplyFirewallRule creates or replaces a fake firewall rule.
+func (p *Provider) ApplyFirewallRule(ctx context.Context, rule clouds.FirewallRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("apply firewall rule %s", rule.Name))
+	err := p.update(ctx, func(s *state) error {
+		s.FirewallRules[rule.Name] = rule
+		return nil
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to apply firewall rule: %w", err)
+	}
+	return nil
+}
+
+// DeleteFirewallRule deletes a fake firewall rule.
+func (p *Provider) DeleteFirewallRule(ctx context.Context, name string) error {
+	op := internal.StartOperation(fmt.Sprintf("delete firewall rule %s", name))
+	err := p.update(ctx, func(s *state) error {
+		if _, ok := s.FirewallRules[name]; !ok {
+			return fmt.Errorf("firewall rule %s: %w", name, clouds.ErrNotFound)
+		}
+		delete(s.FirewallRules, name)
+		return nil
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to delete firewall rule: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package fakecloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+)
+
+// ResolveImage returns the fake image of a release. As on the other clouds
+// but AWS, there are no Amazon Linux images.
+func (p *Provider) ResolveImage(ctx context.Context, alias, arch string) (clouds.Image, error) {
+	op := internal.StartOperation(fmt.Sprintf("look up the fake %s image", alias))
+	err := p.update(ctx, func(s *state) error {
+		if !clouds.IsOSAlias(alias) || alias == "amazon-linux-2023" || (arch != clouds.ArchX86 && arch != clouds.ArchARM) {
+			return fmt.Errorf("%s image for %s on fake: %w", alias, arch, clouds.ErrNotFound)
+		}
+		return nil
+	})
+	if err := op.Finish(err); err != nil {
+		return clouds.Image{}, err
+	}
+
+	id := fmt.Sprintf("fake-%s-%s", alias, arch)
+	return clouds.Image{ID: id, Name: id, Alias: alias, Arch: arch}, nil
+}
@@ -0,0 +1,93 @@
+package fakecloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+	"sort"
+)
+
+// errInstanceKeys is returned for key pairs limited to an instance, which the fake cloud does not simulate.
+var errInstanceKeys = fmt.Errorf("instance-specific key pairs: %w", clouds.ErrNotSupported)
+
+// ListKeyPairs lists the fake key pairs by name.
+func (p *Provider) ListKeyPairs(ctx context.Context) ([]clouds.KeyPair, error) {
+	op := internal.StartOperation("list fake key pairs")
+
+	var keys []clouds.KeyPair
+	err := p.update(ctx, func(s *state) error {
+		for _, key := range s.KeyPairs {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err := op.Finish(err); err != nil {
+		return nil, fmt.Errorf("failed to list key pairs: %w", err)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+	return keys, nil
+}
+
+// CreateKeyPair generates a key pair and stores its public key.
+func (p *Provider) CreateKeyPair(ctx context.Context, key clouds.KeyPair) (clouds.KeyPair, string, error) {
+	publicKey, privateKey, err := clouds.GenerateKeyPair()
+	if err != nil {
+		return clouds.KeyPair{}, "", err
+	}
+
+	key.PublicKey = publicKey
+	created, err := p.ImportKeyPair(ctx, key)
+	if err != nil {
+		return clouds.KeyPair{}, "", err
+	}
+	return created, privateKey, nil
+}
+
+// ImportKeyPair stores a public key. As on EC2, key pair names are unique.
+func (p *Provider) ImportKeyPair(ctx context.Context, key clouds.KeyPair) (clouds.KeyPair, error) {
+	if key.Instance != "" {
+		return clouds.KeyPair{}, errInstanceKeys
+	}
+	parsed, err := clouds.ParsePublicKey(key.PublicKey)
+	if err != nil {
+		return clouds.KeyPair{}, err
+	}
+	parsed.Name = key.Name
+
+	op := internal.StartOperation(fmt.Sprintf("import key pair %s", key.Name))
+	err = p.update(ctx, func(s *state) error {
+		if _, exists := s.KeyPairs[key.Name]; exists {
+			return fmt.Errorf("key pair %s already exists", key.Name)
+		}
+		s.KeyPairs[key.Name] = parsed
+		return nil
+	})
+	if err := op.Finish(err); err != nil {
+		return clouds.KeyPair{}, fmt.Errorf("failed to import key pair: %w", err)
+	}
+	return parsed, nil
+}
+
+// DeleteKeyPair deletes a fake key pair.
+func (p *Provider) DeleteKeyPair(ctx context.Context, key clouds.KeyPair) error {
+	if key.Instance != "" {
+		return errInstanceKeys
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("delete key pair %s", key.Name))
+	err := p.update(ctx, func(s *state) error {
+		if _, ok := s.KeyPairs[key.Name]; !ok {
+			return fmt.Errorf("key pair %s: %w", key.Name, clouds.ErrNotFound)
+		}
+		delete(s.KeyPairs, key.Name)
+		return nil
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to delete key pair: %w", err)
+	}
+	return nil
+}
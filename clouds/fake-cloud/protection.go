@@ -0,0 +1,43 @@
+package fakecloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/internal"
+)
+
+// TerminationProtection reports whether a fake instance is protected from termination.
+func (p *Provider) TerminationProtection(ctx context.Context, id string) (bool, error) {
+	op := internal.StartOperation(fmt.Sprintf("get termination protection of instance %s", id))
+
+	var protected bool
+	err := p.update(ctx, func(s *state) error {
+		inst, err := s.get(id)
+		if err != nil {
+			return err
+		}
+		protected = inst.Protected
+		return nil
+	})
+	if err := op.Finish(err); err != nil {
+		return false, fmt.Errorf("failed to get termination protection: %w", err)
+	}
+	return protected, nil
+}
+
+// SetTerminationProtection enables or disables termination protection for a fake instance.
+func (p *Provider) SetTerminationProtection(ctx context.Context, id string, enabled bool) error {
+	op := internal.StartOperation(fmt.Sprintf("set termination protection of instance %s", id))
+	err := p.update(ctx, func(s *state) error {
+		inst, err := s.get(id)
+		if err != nil {
+			return err
+		}
+		inst.Protected = enabled
+		return nil
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to set termination protection: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package fakecloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+)
+
+// sizes are the instance types offered by the fake cloud. Like the real
+// APIs, it does not return their prices.
+var sizes = []clouds.Size{
+	{Type: "fake.small", CPUs: 1, MemoryGiB: 1, Arch: clouds.ArchX86},
+	{Type: "fake.medium", CPUs: 2, MemoryGiB: 4, Arch: clouds.ArchX86},
+	{Type: "fake.large", CPUs: 4, MemoryGiB: 16, Arch: clouds.ArchX86},
+	{Type: "fake.xlarge", CPUs: 8, MemoryGiB: 32, Arch: clouds.ArchX86},
+	{Type: "fake.large-arm", CPUs: 4, MemoryGiB: 16, Arch: clouds.ArchARM},
+	{Type: "fake.gpu", CPUs: 8, MemoryGiB: 32, GPUs: 1, Arch: clouds.ArchX86},
+}
+
+// ListSizes lists the fake instance types.
+func (p *Provider) ListSizes(ctx context.Context) ([]clouds.Size, error) {
+	op := internal.StartOperation("list fake instance types")
+	// The types are fixed, but the call takes as long as any other
+	err := p.update(ctx, func(s *state) error { return nil })
+	if err := op.Finish(err); err != nil {
+		return nil, fmt.Errorf("failed to list instance types: %w", err)
+	}
+	return append([]clouds.Size(nil), sizes...), nil
+}
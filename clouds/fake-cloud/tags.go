@@ -0,0 +1,48 @@
+package fakecloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/internal"
+)
+
+// TagInstance adds tags to a fake instance.
+func (p *Provider) TagInstance(ctx context.Context, id string, tags map[string]string) error {
+	op := internal.StartOperation(fmt.Sprintf("tag instance %s", id))
+	err := p.update(ctx, func(s *state) error {
+		inst, err := s.get(id)
+		if err != nil {
+			return err
+		}
+		if inst.Tags == nil {
+			inst.Tags = make(map[string]string, len(tags))
+		}
+		for k, v := range tags {
+			inst.Tags[k] = v
+		}
+		return nil
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to tag instance: %w", err)
+	}
+	return nil
+}
+
+// UntagInstance removes tags from a fake instance.
+func (p *Provider) UntagInstance(ctx context.Context, id string, keys []string) error {
+	op := internal.StartOperation(fmt.Sprintf("untag instance %s", id))
+	err := p.update(ctx, func(s *state) error {
+		inst, err := s.get(id)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			delete(inst.Tags, key)
+		}
+		return nil
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to untag instance: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package clouds
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Filter selects instances by one of their attributes. Values are shell
+// patterns, so "web-*" matches every name starting with "web-".
+type Filter struct {
+	// Key is id, name, state, type or location, or tag:KEY for a tag.
+	Key   string
+	Value string
+}
+
+// filterKeys are the instance attributes that can be filtered on, besides tags.
+var filterKeys = map[string]func(Instance) string{
+	"id":       func(i Instance) string { return i.ID },
+	"name":     func(i Instance) string { return i.Name },
+	"state":    func(i Instance) string { return strings.ToLower(i.State) },
+	"type":     func(i Instance) string { return i.Type },
+	"location": func(i Instance) string { return i.Location },
+}
+
+// ParseFilter parses a filter of the form KEY=VALUE, such as state=running
+// or tag:role=web. A tag filter without a value, tag:KEY, matches any
+// instance that has the tag.
+func ParseFilter(s string) (Filter, error) {
+	key, value, hasValue := strings.Cut(s, "=")
+	if tag, ok := strings.CutPrefix(key, "tag:"); ok {
+		if tag == "" {
+			return Filter{}, fmt.Errorf("invalid filter %q: the tag key is missing", s)
+		}
+		if !hasValue {
+			value = "*"
+		}
+	} else if _, ok := filterKeys[key]; !ok || !hasValue {
+		return Filter{}, fmt.Errorf("invalid filter %q: use KEY=VALUE with id, name, state, type or location, or tag:KEY[=VALUE]", s)
+	}
+	if _, err := path.Match(value, ""); err != nil {
+		return Filter{}, fmt.Errorf("invalid filter %q: %w", s, err)
+	}
+	return Filter{Key: key, Value: value}, nil
+}
+
+// ParseFilters parses several filters.
+func ParseFilters(values []string) ([]Filter, error) {
+	var filters []Filter
+	for _, value := range values {
+		filter, err := ParseFilter(value)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// Matches reports whether the instance matches the filter.
+func (f Filter) Matches(instance Instance) bool {
+	var value string
+	if tag, ok := strings.CutPrefix(f.Key, "tag:"); ok {
+		v, exists := instance.Tags[tag]
+		if !exists {
+			return false
+		}
+		value = v
+	} else {
+		value = filterKeys[f.Key](instance)
+	}
+
+	matched, _ := path.Match(f.Value, value)
+	return matched
+}
+
+// FilterInstances returns the instances that match all the filters.
+func FilterInstances(instances []Instance, filters []Filter) []Instance {
+	var result []Instance
+	for _, instance := range instances {
+		if matchesAll(instance, filters) {
+			result = append(result, instance)
+		}
+	}
+	return result
+}
+
+// matchesAll reports whether the instance matches every filter.
+func matchesAll(instance Instance, filters []Filter) bool {
+	for _, filter := range filters {
+		if !filter.Matches(instance) {
+			return false
+		}
+	}
+	return true
+}
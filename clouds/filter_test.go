@@ -0,0 +1,52 @@
+package clouds
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilters(t *testing.T) {
+	instance := Instance{
+		ID:    "i-1",
+		Name:  "web-1",
+		State: "RUNNING",
+		Type:  "t3.micro",
+		Tags:  map[string]string{"role": "web", "env": ""},
+	}
+
+	tests := []struct {
+		filter string
+		match  bool
+		err    string
+	}{
+		{"name=web-*", true, ""},
+		{"name=web", false, ""},
+		{"state=running", true, ""}, // states are compared in lower case
+		{"type=t3.*", true, ""},
+		{"tag:role=web", true, ""},
+		{"tag:role=db", false, ""},
+		{"tag:env", true, ""}, // a tag without a value only needs to exist
+		{"tag:owner", false, ""},
+		{"tag:=web", false, "tag key is missing"},
+		{"size=small", false, "invalid filter"},
+		{"name", false, "invalid filter"},
+		{"name=[web", false, "syntax error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			filter, err := ParseFilter(tt.filter)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := filter.Matches(instance); got != tt.match {
+				t.Errorf("got match %v, want %v", got, tt.match)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package clouds
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Firewall rule directions.
+const (
+	Ingress = "ingress"
+	Egress  = "egress"
+)
+
+// Firewall rule protocols. ProtocolAll matches all traffic.
+const (
+	ProtocolTCP  = "tcp"
+	ProtocolUDP  = "udp"
+	ProtocolICMP = "icmp"
+	ProtocolAll  = "all"
+)
+
+// FirewallRule is a provider-neutral firewall rule. An ingress rule allows
+// traffic from Sources to the instances carrying any of the TargetTags; an
+// egress rule allows traffic from those instances to Destinations. Target
+// tags are instance tags written KEY=VALUE, the same on every cloud, and a
+// rule without target tags applies to no instance. The instances are matched
+// when the rule is applied, not when they are created or tagged later.
+type FirewallRule struct {
+	Name         string   `json:"name" yaml:"name"`
+	Description  string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Direction    string   `json:"direction" yaml:"direction"`
+	Protocol     string   `json:"protocol" yaml:"protocol"`
+	Ports        []string `json:"ports,omitempty" yaml:"ports,omitempty"`
+	Sources      []string `json:"sources,omitempty" yaml:"sources,omitempty"`
+	Destinations []string `json:"destinations,omitempty" yaml:"destinations,omitempty"`
+	TargetTags   []string `json:"target_tags,omitempty" yaml:"target_tags,omitempty"`
+}
+
+// Firewall is implemented by providers that can manage firewall rules.
+type Firewall interface {
+	// ListFirewallRules lists the firewall rules.
+	ListFirewallRules(ctx context.Context) ([]FirewallRule, error)
+
+	// ApplyFirewallRule creates the rule, or replaces the rule of the same
+	// name, and applies it to the instances that have one of its target tags
+	// now. Instances created or tagged later need the rule applied again.
+	ApplyFirewallRule(ctx context.Context, rule FirewallRule) error
+
+	// DeleteFirewallRule deletes the rule with the given name.
+	DeleteFirewallRule(ctx context.Context, name string) error
+}
+
+// firewallName matches names that are valid on every cloud, which in
+// practice means GCP resource names.
+var firewallName = regexp.MustCompile(`^[a-z]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+// WithDefaults returns the rule with the direction defaulted to ingress and
+// the direction and protocol in lower case.
+func (r FirewallRule) WithDefaults() FirewallRule {
+	r.Direction = strings.ToLower(r.Direction)
+	if r.Direction == "" {
+		r.Direction = Ingress
+	}
+	r.Protocol = strings.ToLower(r.Protocol)
+	return r
+}
+
+// Validate checks that the rule can be applied on every cloud. A rule must
+// name its sources or destinations: nothing is open to the world by default.
+func (r FirewallRule) Validate() error {
+	if !firewallName.MatchString(r.Name) {
+		return fmt.Errorf("invalid firewall rule name %q: use lower-case letters, digits and hyphens, starting with a letter", r.Name)
+	}
+
+	switch r.Protocol {
+	case ProtocolTCP, ProtocolUDP:
+		if len(r.Ports) == 0 {
+			return fmt.Errorf("firewall rule %s: ports are required for %s", r.Name, r.Protocol)
+		}
+		for _, ports := range r.Ports {
+			if _, _, err := ParsePortRange(ports); err != nil {
+				return fmt.Errorf("firewall rule %s: %w", r.Name, err)
+			}
+		}
+	case ProtocolICMP, ProtocolAll:
+		if len(r.Ports) > 0 {
+			return fmt.Errorf("firewall rule %s: ports cannot be set for %s", r.Name, r.Protocol)
+		}
+	default:
+		return fmt.Errorf("firewall rule %s: invalid protocol %q: use tcp, udp, icmp or all", r.Name, r.Protocol)
+	}
+
+	var ranges []string
+	switch r.Direction {
+	case Ingress:
+		if len(r.Destinations) > 0 {
+			return fmt.Errorf("firewall rule %s: ingress rules have sources, not destinations", r.Name)
+		}
+		if len(r.Sources) == 0 {
+			return fmt.Errorf("firewall rule %s: at least one source CIDR is required", r.Name)
+		}
+		ranges = r.Sources
+	case Egress:
+		if len(r.Sources) > 0 {
+			return fmt.Errorf("firewall rule %s: egress rules have destinations, not sources", r.Name)
+		}
+		if len(r.Destinations) == 0 {
+			return fmt.Errorf("firewall rule %s: at least one destination CIDR is required", r.Name)
+		}
+		ranges = r.Destinations
+	default:
+		return fmt.Errorf("firewall rule %s: invalid direction %q: use ingress or egress", r.Name, r.Direction)
+	}
+	for _, cidr := range ranges {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("firewall rule %s: invalid CIDR %q", r.Name, cidr)
+		}
+	}
+
+	for _, tag := range r.TargetTags {
+		if _, _, err := ParseTargetTag(tag); err != nil {
+			return fmt.Errorf("firewall rule %s: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+// ParseTargetTag splits a target tag such as role=web into the key and value
+// of the instance tag it selects.
+func ParseTargetTag(tag string) (string, string, error) {
+	key, value, ok := strings.Cut(tag, "=")
+	if !ok || key == "" || value == "" || strings.Contains(tag, ",") {
+		return "", "", fmt.Errorf("invalid target tag %q: use KEY=VALUE, without commas", tag)
+	}
+	return key, value, nil
+}
+
+// Targets reports whether the rule applies to an instance with the given tags.
+func (r FirewallRule) Targets(tags map[string]string) bool {
+	for _, tag := range r.TargetTags {
+		key, value, err := ParseTargetTag(tag)
+		if err == nil && tags[key] == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Ranges returns the CIDRs of the rule: its sources for ingress, its
+// destinations for egress.
+func (r FirewallRule) Ranges() []string {
+	if r.Direction == Egress {
+		return r.Destinations
+	}
+	return r.Sources
+}
+
+// ParsePortRange parses a single port such as 22 or an inclusive range such as 8000-8080.
+func ParsePortRange(ports string) (int, int, error) {
+	fromText, toText, isRange := strings.Cut(ports, "-")
+	if !isRange {
+		toText = fromText
+	}
+
+	from, err := strconv.Atoi(fromText)
+	if err != nil || from < 0 || from > 65535 {
+		return 0, 0, fmt.Errorf("invalid port %q: ports are numbers between 0 and 65535", fromText)
+	}
+	to, err := strconv.Atoi(toText)
+	if err != nil || to < 0 || to > 65535 {
+		return 0, 0, fmt.Errorf("invalid port %q: ports are numbers between 0 and 65535", toText)
+	}
+	if from > to {
+		return 0, 0, fmt.Errorf("invalid port range %q: the first port is higher than the last", ports)
+	}
+	return from, to, nil
+}
@@ -0,0 +1,61 @@
+package clouds
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFirewallRuleValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		rule FirewallRule
+		err  string
+	}{
+		{"ingress", FirewallRule{Name: "allow-ssh", Protocol: "TCP", Ports: []string{"22"}, Sources: []string{"10.0.0.0/8"}}, ""},
+		{"egress", FirewallRule{Name: "allow-out", Direction: "egress", Protocol: "all", Destinations: []string{"0.0.0.0/0"}}, ""},
+		{"port range", FirewallRule{Name: "web", Protocol: "tcp", Ports: []string{"8000-8080"}, Sources: []string{"10.0.0.0/8"}}, ""},
+		{"invalid name", FirewallRule{Name: "Allow_SSH", Protocol: "tcp", Ports: []string{"22"}, Sources: []string{"10.0.0.0/8"}}, "invalid firewall rule name"},
+		{"missing ports", FirewallRule{Name: "web", Protocol: "tcp", Sources: []string{"10.0.0.0/8"}}, "ports are required"},
+		{"ports on icmp", FirewallRule{Name: "ping", Protocol: "icmp", Ports: []string{"8"}, Sources: []string{"10.0.0.0/8"}}, "ports cannot be set"},
+		{"reversed range", FirewallRule{Name: "web", Protocol: "tcp", Ports: []string{"90-80"}, Sources: []string{"10.0.0.0/8"}}, "first port is higher"},
+		{"no sources", FirewallRule{Name: "web", Protocol: "tcp", Ports: []string{"80"}}, "source CIDR is required"},
+		{"destinations on ingress", FirewallRule{Name: "web", Protocol: "tcp", Ports: []string{"80"}, Sources: []string{"10.0.0.0/8"}, Destinations: []string{"10.0.0.0/8"}}, "not destinations"},
+		{"invalid CIDR", FirewallRule{Name: "web", Protocol: "tcp", Ports: []string{"80"}, Sources: []string{"10.0.0.1"}}, "invalid CIDR"},
+		{"invalid protocol", FirewallRule{Name: "web", Protocol: "sctp", Sources: []string{"10.0.0.0/8"}}, "invalid protocol"},
+		{"target tags", FirewallRule{Name: "web", Protocol: "all", Sources: []string{"10.0.0.0/8"}, TargetTags: []string{"role=web", "Team=Payments"}}, ""},
+		{"tag without value", FirewallRule{Name: "web", Protocol: "all", Sources: []string{"10.0.0.0/8"}, TargetTags: []string{"web"}}, "invalid target tag"},
+		{"tag with comma", FirewallRule{Name: "web", Protocol: "all", Sources: []string{"10.0.0.0/8"}, TargetTags: []string{"role=web,api"}}, "invalid target tag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.WithDefaults().Validate()
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("got error %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestFirewallRuleTargets(t *testing.T) {
+	rule := FirewallRule{TargetTags: []string{"role=web", "role=api"}}
+	for _, tt := range []struct {
+		tags map[string]string
+		want bool
+	}{
+		{map[string]string{"role": "web"}, true},
+		{map[string]string{"role": "api", "env": "prod"}, true},
+		{map[string]string{"role": "db"}, false},
+		{map[string]string{"web": "role"}, false},
+		{nil, false},
+	} {
+		if got := rule.Targets(tt.tags); got != tt.want {
+			t.Errorf("Targets(%v) = %v, want %v", tt.tags, got, tt.want)
+		}
+	}
+	if (FirewallRule{}).Targets(map[string]string{"role": "web"}) {
+		t.Error("a rule without target tags targets an instance")
+	}
+}
@@ -0,0 +1,57 @@
+package gcpcloud
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"namaste-cloud/internal"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+// ConsoleOutput returns the output of a GCP instance's first serial port.
+// Compute Engine keeps the most recent 1 MB of it.
+func (p *Provider) ConsoleOutput(ctx context.Context, instanceID string) (string, error) {
+	zone, name, err := p.instanceRef(instanceID)
+	if err != nil {
+		return "", err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("get console output of instance %s", instanceID))
+	resp, err := p.client.GetSerialPortOutput(ctx, &computepb.GetSerialPortOutputInstanceRequest{
+		Project:  p.project,
+		Zone:     zone,
+		Instance: name,
+		Port:     proto.Int32(1),
+	})
+	if err := op.Finish(err); err != nil {
+		return "", fmt.Errorf("failed to get console output: %w", err)
+	}
+	return resp.GetContents(), nil
+}
+
+// ConsoleScreenshot returns a PNG screenshot of a GCP instance's display,
+// which requires the instance to have a display device enabled.
+func (p *Provider) ConsoleScreenshot(ctx context.Context, instanceID string) ([]byte, error) {
+	zone, name, err := p.instanceRef(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("get console screenshot of instance %s", instanceID))
+	resp, err := p.client.GetScreenshot(ctx, &computepb.GetScreenshotInstanceRequest{
+		Project:  p.project,
+		Zone:     zone,
+		Instance: name,
+	})
+	if err := op.Finish(err); err != nil {
+		return nil, fmt.Errorf("failed to get console screenshot: %w", err)
+	}
+
+	image, err := base64.StdEncoding.DecodeString(resp.GetContents())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode console screenshot: %w", err)
+	}
+	return image, nil
+}
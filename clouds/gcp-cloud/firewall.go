@@ -0,0 +1,241 @@
+package gcpcloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+	"path"
+	"slices"
+	"strings"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/proto"
+)
+
+// targetsLine starts the line of a firewall description that holds the
+// target tags of the rule, which GCP firewalls have no other place for.
+const targetsLine = "namaste-targets: "
+
+// ListFirewallRules lists the VPC firewall rules of the default network.
+// Rules that deny traffic have no provider-neutral equivalent and are skipped.
+func (p *Provider) ListFirewallRules(ctx context.Context) ([]clouds.FirewallRule, error) {
+	op := internal.StartOperation(fmt.Sprintf("list firewall rules in project %s", p.project))
+	it := p.firewalls.List(ctx, &computepb.ListFirewallsRequest{
+		Project: p.project,
+	})
+
+	var rules []clouds.FirewallRule
+	for {
+		firewall, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list firewall rules: %w", op.Finish(err))
+		}
+		if path.Base(firewall.GetNetwork()) != path.Base(defaultNetwork) || len(firewall.GetDenied()) > 0 {
+			continue
+		}
+		rules = append(rules, toFirewallRule(firewall)...)
+	}
+	op.Finish(nil)
+
+	return rules, nil
+}
+
+// ApplyFirewallRule creates a VPC firewall rule, or replaces the existing one
+// of the same name. GCP firewalls target network tags rather than labels, so
+// the firewall targets a network tag named after the rule, which is added to
+// the instances whose labels match one of the target tags and removed from
+// the others. Instances created or labelled later only get the network tag
+// when the rule is applied again.
+func (p *Provider) ApplyFirewallRule(ctx context.Context, rule clouds.FirewallRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	firewall := toFirewall(rule)
+
+	op := internal.StartOperation(fmt.Sprintf("apply firewall rule %s", rule.Name))
+	_, err := p.firewalls.Get(ctx, &computepb.GetFirewallRequest{
+		Project:  p.project,
+		Firewall: rule.Name,
+	})
+	var operation *compute.Operation
+	switch {
+	case isNotFound(err):
+		operation, err = p.firewalls.Insert(ctx, &computepb.InsertFirewallRequest{
+			Project:          p.project,
+			FirewallResource: firewall,
+		})
+	case err == nil:
+		operation, err = p.firewalls.Update(ctx, &computepb.UpdateFirewallRequest{
+			Project:          p.project,
+			Firewall:         rule.Name,
+			FirewallResource: firewall,
+		})
+	}
+	if err == nil {
+		err = operation.Wait(ctx)
+	}
+	if err == nil {
+		err = p.syncNetworkTag(ctx, rule.Name, func(labels map[string]string) bool {
+			return targetsLabels(rule, labels)
+		})
+	}
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to apply firewall rule: %w", err)
+	}
+	return nil
+}
+
+// DeleteFirewallRule deletes a VPC firewall rule and removes its network tag
+// from the instances.
+func (p *Provider) DeleteFirewallRule(ctx context.Context, name string) error {
+	op := internal.StartOperation(fmt.Sprintf("delete firewall rule %s", name))
+	operation, err := p.firewalls.Delete(ctx, &computepb.DeleteFirewallRequest{
+		Project:  p.project,
+		Firewall: name,
+	})
+	if err == nil {
+		err = operation.Wait(ctx)
+	}
+	if err == nil {
+		err = p.syncNetworkTag(ctx, name, func(map[string]string) bool { return false })
+	}
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to delete firewall rule: %w", err)
+	}
+	return nil
+}
+
+// syncNetworkTag adds the network tag to the instances whose labels match
+// and removes it from the others.
+func (p *Provider) syncNetworkTag(ctx context.Context, tag string, match func(labels map[string]string) bool) error {
+	it := p.client.AggregatedList(ctx, &computepb.AggregatedListInstancesRequest{
+		Project: p.project,
+	})
+	for {
+		resp, err := it.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, instance := range resp.Value.GetInstances() {
+			items := instance.GetTags().GetItems()
+			want := match(instance.GetLabels())
+			if slices.Contains(items, tag) == want {
+				continue
+			}
+			if want {
+				items = append(slices.Clone(items), tag)
+			} else {
+				items = slices.DeleteFunc(slices.Clone(items), func(item string) bool { return item == tag })
+			}
+			// The fingerprint makes the update fail rather than overwrite a
+			// concurrent change, as with labels
+			operation, err := p.client.SetTags(ctx, &computepb.SetTagsInstanceRequest{
+				Project:  p.project,
+				Zone:     path.Base(instance.GetZone()),
+				Instance: instance.GetName(),
+				TagsResource: &computepb.Tags{
+					Items:       items,
+					Fingerprint: instance.GetTags().Fingerprint,
+				},
+			})
+			if err != nil {
+				return err
+			}
+			if err := operation.Wait(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// targetsLabels reports whether the rule applies to an instance with the
+// given labels, to which target tags are normalized like any tag.
+func targetsLabels(rule clouds.FirewallRule, labels map[string]string) bool {
+	for _, tag := range rule.TargetTags {
+		key, value, err := clouds.ParseTargetTag(tag)
+		if err != nil {
+			continue
+		}
+		target, err := toLabels(map[string]string{key: value})
+		if err != nil {
+			continue
+		}
+		for k, v := range target {
+			if labels[k] == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// toFirewall converts a firewall rule to a GCP firewall in the default
+// network, which targets the network tag named after the rule.
+func toFirewall(rule clouds.FirewallRule) *computepb.Firewall {
+	description := targetsLine + strings.Join(rule.TargetTags, ",")
+	if rule.Description != "" {
+		description = rule.Description + "\n" + description
+	}
+	firewall := &computepb.Firewall{
+		Name:        proto.String(rule.Name),
+		Description: proto.String(description),
+		Network:     proto.String(defaultNetwork),
+		Direction:   proto.String(strings.ToUpper(rule.Direction)),
+		TargetTags:  []string{rule.Name},
+		Allowed: []*computepb.Allowed{
+			{IPProtocol: proto.String(rule.Protocol), Ports: rule.Ports},
+		},
+	}
+	if rule.Direction == clouds.Egress {
+		firewall.DestinationRanges = rule.Destinations
+	} else {
+		firewall.SourceRanges = rule.Sources
+	}
+	return firewall
+}
+
+// toFirewallRule converts a GCP firewall to firewall rules. A GCP firewall may
+// allow several protocols, each of which becomes a rule; all but the first
+// are named after their protocol. The target tags of firewalls made outside
+// the CLI are their network tags.
+func toFirewallRule(firewall *computepb.Firewall) []clouds.FirewallRule {
+	description, targetTags := firewall.GetDescription(), firewall.GetTargetTags()
+	if i := strings.LastIndex(description, targetsLine); i == 0 || (i > 0 && description[i-1] == '\n') {
+		targetTags = nil
+		if targets := description[i+len(targetsLine):]; targets != "" {
+			targetTags = strings.Split(targets, ",")
+		}
+		description = strings.TrimSuffix(description[:i], "\n")
+	}
+
+	var rules []clouds.FirewallRule
+	for i, allowed := range firewall.GetAllowed() {
+		rule := clouds.FirewallRule{
+			Name:        firewall.GetName(),
+			Description: description,
+			Direction:   strings.ToLower(firewall.GetDirection()),
+			Protocol:    allowed.GetIPProtocol(),
+			Ports:       allowed.GetPorts(),
+			TargetTags:  targetTags,
+		}
+		if i > 0 {
+			rule.Name += "-" + rule.Protocol
+		}
+		if rule.Direction == clouds.Egress {
+			rule.Destinations = firewall.GetDestinationRanges()
+		} else {
+			rule.Sources = firewall.GetSourceRanges()
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
@@ -0,0 +1,69 @@
+package gcpcloud
+
+import (
+	"context"
+	"namaste-cloud/clouds"
+	"reflect"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDeleteFirewallRule(t *testing.T) {
+	p := newTestProvider(t)
+
+	// The rule is still in use, which only the operation reports
+	err := p.DeleteFirewallRule(context.Background(), "allow-ssh")
+	if err == nil || !strings.Contains(err.Error(), "is already being used by") {
+		t.Errorf("got %v, want the error of the operation", err)
+	}
+}
+
+// TestApplyFirewallRule creates a rule targeting Team=Payments, which adds
+// its network tag to the instances labelled team=payments in every zone and
+// removes it from an instance that no longer matches.
+func TestApplyFirewallRule(t *testing.T) {
+	p := newTestProvider(t)
+
+	rule := clouds.FirewallRule{
+		Name:       "allow-ssh",
+		Direction:  clouds.Ingress,
+		Protocol:   clouds.ProtocolTCP,
+		Ports:      []string{"22"},
+		Sources:    []string{"10.0.0.0/8"},
+		TargetTags: []string{"Team=Payments"},
+	}
+	if err := p.ApplyFirewallRule(context.Background(), rule); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestToFirewallRule(t *testing.T) {
+	rule := clouds.FirewallRule{
+		Name:        "allow-ssh",
+		Description: "SSH from the office",
+		Direction:   clouds.Ingress,
+		Protocol:    clouds.ProtocolTCP,
+		Ports:       []string{"22"},
+		Sources:     []string{"10.0.0.0/8"},
+		TargetTags:  []string{"role=web", "role=api"},
+	}
+	rules := toFirewallRule(toFirewall(rule))
+	if len(rules) != 1 || !reflect.DeepEqual(rules[0], rule) {
+		t.Errorf("got %+v, want %+v", rules, rule)
+	}
+
+	// Firewalls made elsewhere target network tags
+	other := toFirewallRule(&computepb.Firewall{
+		Name:        proto.String("default-allow-http"),
+		Description: proto.String("Allow HTTP"),
+		Direction:   proto.String("INGRESS"),
+		TargetTags:  []string{"http-server"},
+		Allowed:     []*computepb.Allowed{{IPProtocol: proto.String("tcp"), Ports: []string{"80"}}},
+	})
+	if other[0].Description != "Allow HTTP" || !reflect.DeepEqual(other[0].TargetTags, []string{"http-server"}) {
+		t.Errorf("got %+v", other[0])
+	}
+}
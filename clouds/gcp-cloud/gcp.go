@@ -2,139 +2,402 @@ package gcpcloud
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"namaste-cloud/clouds"
 	"namaste-cloud/internal"
+	"net/http"
+	"path"
+	"strings"
+	"time"
 
 	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+	"google.golang.org/protobuf/proto"
 )
 
-// ExecuteGCPCommand performs GCP operations based on the given command.
-func ExecuteGCPCommand(command string, args ...string) {
-	// Load GCP credentials from storage
-	cred, err := internal.GetCredential("gcp")
-	if err != nil {
-		log.Fatalf("Failed to load GCP credentials: %v", err)
+// Defaults used when an instance spec leaves the machine type or image unset.
+const (
+	DefaultMachineType = "e2-micro"
+	defaultImage       = "projects/debian-cloud/global/images/family/debian-12"
+)
+
+// defaultNetwork is the VPC network instances and firewall rules are created in.
+const defaultNetwork = "global/networks/default"
+
+// errNoZone is returned by zonal operations when no zone is configured.
+var errNoZone = errors.New("no GCP zone set; use --zone or set `zone` in the configuration")
+
+// Provider manages Compute Engine resources in a single project.
+type Provider struct {
+	client    *compute.InstancesClient
+	firewalls *compute.FirewallsClient
+	projects  *compute.ProjectsClient
+	machines  *compute.MachineTypesClient
+	images    *compute.ImagesClient
+	project   string
+	zone      string
+
+	// defaultTags are added as labels to every instance the provider creates.
+	defaultTags map[string]string
+}
+
+// New creates a GCP provider from the stored credentials and the given settings.
+func New(ctx context.Context, cfg internal.Config) (*Provider, error) {
+	// Load GCP credentials from storage; emulators behind a custom endpoint
+	// may be used without them
+	cred, err := internal.GetProfileCredential("gcp", cfg.Profile)
+	if err != nil && cfg.EndpointURL == "" {
+		return nil, fmt.Errorf("failed to load GCP credentials: %w", err)
+	}
+
+	// Fall back to the project of the service account when none is configured
+	project := cfg.Project
+	if project == "" {
+		project = credentialProject(cred)
+	}
+	if project == "" {
+		return nil, fmt.Errorf("no GCP project set; use --project or set `project` in the configuration")
 	}
 
 	// Load GCP configuration with credentials
-	ctx := context.Background()
-	client, err := compute.NewInstancesRESTClient(ctx, option.WithCredentialsJSON([]byte(cred.AccessKey)))
+	options, err := clientOptions(ctx, cfg, cred)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCP client: %w", err)
+	}
+	client, err := compute.NewInstancesRESTClient(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCP client: %w", err)
+	}
+	firewalls, err := compute.NewFirewallsRESTClient(ctx, options...)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to create GCP client: %w", err)
+	}
+	projects, err := compute.NewProjectsRESTClient(ctx, options...)
+	if err != nil {
+		client.Close()
+		firewalls.Close()
+		return nil, fmt.Errorf("failed to create GCP client: %w", err)
+	}
+	machines, err := compute.NewMachineTypesRESTClient(ctx, options...)
+	if err != nil {
+		client.Close()
+		firewalls.Close()
+		projects.Close()
+		return nil, fmt.Errorf("failed to create GCP client: %w", err)
+	}
+	images, err := compute.NewImagesRESTClient(ctx, options...)
+	if err != nil {
+		client.Close()
+		firewalls.Close()
+		projects.Close()
+		machines.Close()
+		return nil, fmt.Errorf("failed to create GCP client: %w", err)
+	}
+
+	instanceCalls, firewallCalls, projectCalls, machineCalls, imageCalls := client.CallOptions, firewalls.CallOptions, projects.CallOptions, machines.CallOptions, images.CallOptions
+	applyRetryPolicy(cfg.Retry,
+		&instanceCalls.AggregatedList, &instanceCalls.Insert, &instanceCalls.Stop, &instanceCalls.Start, &instanceCalls.Reset,
+		&instanceCalls.Delete, &instanceCalls.Get, &instanceCalls.SetMetadata, &instanceCalls.SetLabels, &instanceCalls.SetTags,
+		&instanceCalls.GetSerialPortOutput, &instanceCalls.GetScreenshot, &instanceCalls.SetDeletionProtection,
+		&firewallCalls.List, &firewallCalls.Get, &firewallCalls.Insert, &firewallCalls.Update, &firewallCalls.Delete,
+		&projectCalls.Get, &projectCalls.SetCommonInstanceMetadata,
+		&machineCalls.List, &imageCalls.GetFromFamily)
+
+	return &Provider{client: client, firewalls: firewalls, projects: projects, machines: machines, images: images, project: project, zone: cfg.Zone, defaultTags: cfg.DefaultTags}, nil
+}
+
+// clientOptions returns the client options for the stored credentials and the
+// configured endpoint. Emulators are usually served without authentication,
+// so no credentials are sent to a custom endpoint unless some are stored.
+func clientOptions(ctx context.Context, cfg internal.Config, cred internal.Credential) ([]option.ClientOption, error) {
+	var options []option.ClientOption
+	if cfg.EndpointURL != "" {
+		options = append(options, option.WithEndpoint(cfg.EndpointURL))
+	}
+
+	auth := option.WithCredentialsJSON([]byte(cred.AccessKey))
+	if cred.AccessKey == "" {
+		auth = option.WithoutAuthentication()
+	}
+	// A custom HTTP client bypasses authentication, so wrap our transport
+	// with the credentials ourselves.
+	transport, err := htransport.NewTransport(ctx, internal.NewHTTPClient(cfg.SkipsTLSVerify()).Transport, auth)
 	if err != nil {
-		log.Fatalf("Failed to create GCP client: %v", err)
-	}
-	defer client.Close()
-
-	// Dispatch command based on the input
-	switch command {
-	case "list-instances":
-		// listInstances(client)
-	case "create-instance":
-		if len(args) < 1 {
-			fmt.Println("Please specify the machine type for the instance.")
-			return
+		return nil, err
+	}
+	return append(options, option.WithHTTPClient(&http.Client{Transport: transport})), nil
+}
+
+// credentialProject returns the project ID embedded in a service account key, if any.
+func credentialProject(cred internal.Credential) string {
+	var key struct {
+		ProjectID string `json:"project_id"`
+	}
+	if err := json.Unmarshal([]byte(cred.AccessKey), &key); err != nil {
+		return ""
+	}
+	return key.ProjectID
+}
+
+// Name implements clouds.Provider.
+func (p *Provider) Name() string {
+	return "gcp"
+}
+
+// Close implements clouds.Provider.
+func (p *Provider) Close() error {
+	return errors.Join(p.client.Close(), p.firewalls.Close(), p.projects.Close(), p.machines.Close(), p.images.Close())
+}
+
+// ListInstances lists all GCP instances across all zones of the project.
+func (p *Provider) ListInstances(ctx context.Context) ([]clouds.Instance, error) {
+	op := internal.StartOperation(fmt.Sprintf("list instances in project %s", p.project))
+	it := p.client.AggregatedList(ctx, &computepb.AggregatedListInstancesRequest{
+		Project: p.project,
+	})
+
+	var instances []clouds.Instance
+	for {
+		resp, err := it.Next()
+		if err == iterator.Done {
+			break
 		}
-		// createInstance(client, args[0])
-	case "stop-instance":
-		if len(args) < 1 {
-			fmt.Println("Please specify the Instance ID to stop.")
-			return
+		if err != nil {
+			return nil, fmt.Errorf("failed to list instances: %w", op.Finish(err))
 		}
-		// stopInstance(client, args[0])
-	case "start-instance":
-		if len(args) < 1 {
-			fmt.Println("Please specify the Instance ID to start.")
-			return
+		for _, instance := range resp.Value.GetInstances() {
+			instances = append(instances, toInstance(instance))
 		}
-		// startInstance(client, args[0])
-	case "terminate-instance":
-		if len(args) < 1 {
-			fmt.Println("Please specify the Instance ID to terminate.")
-			return
+	}
+	op.Finish(nil)
+
+	return instances, nil
+}
+
+// CreateInstance creates a GCP instance in the configured zone.
+func (p *Provider) CreateInstance(ctx context.Context, spec clouds.InstanceSpec) (clouds.Instance, error) {
+	if p.zone == "" {
+		return clouds.Instance{}, errNoZone
+	}
+	if spec.Name == "" {
+		return clouds.Instance{}, fmt.Errorf("a name is required to create a GCP instance")
+	}
+	if len(spec.SecurityGroups) > 0 {
+		return clouds.Instance{}, fmt.Errorf("security groups on gcp: %w; use firewall rules with target tags", clouds.ErrNotSupported)
+	}
+	machineType := spec.Type
+	if machineType == "" {
+		machineType = DefaultMachineType
+	}
+	image := spec.Image
+	if image == "" {
+		image = defaultImage
+	}
+
+	labels, err := toLabels(clouds.MergeTags(p.defaultTags, spec.Tags))
+	if err != nil {
+		return clouds.Instance{}, err
+	}
+	disk := &computepb.AttachedDiskInitializeParams{SourceImage: proto.String(image)}
+	if spec.DiskSizeGiB > 0 {
+		disk.DiskSizeGb = proto.Int64(int64(spec.DiskSizeGiB))
+	}
+
+	instance := &computepb.Instance{
+		Name:        proto.String(spec.Name),
+		Labels:      labels,
+		MachineType: proto.String(fmt.Sprintf("zones/%s/machineTypes/%s", p.zone, machineType)),
+		Disks: []*computepb.AttachedDisk{
+			{
+				AutoDelete:       proto.Bool(true),
+				Boot:             proto.Bool(true),
+				InitializeParams: disk,
+			},
+		},
+		NetworkInterfaces: []*computepb.NetworkInterface{
+			{
+				Network: proto.String(defaultNetwork),
+				AccessConfigs: []*computepb.AccessConfig{
+					{Name: proto.String("External NAT"), Type: proto.String("ONE_TO_ONE_NAT")},
+				},
+			},
+		},
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("create instance %s", spec.Name))
+	_, err = p.client.Insert(ctx, &computepb.InsertInstanceRequest{
+		Project:          p.project,
+		Zone:             p.zone,
+		InstanceResource: instance,
+	})
+	if err := op.Finish(err); err != nil {
+		return clouds.Instance{}, fmt.Errorf("failed to create instance: %w", err)
+	}
+
+	return clouds.Instance{
+		ID:       zonalID(p.zone, spec.Name),
+		Name:     spec.Name,
+		State:    "PROVISIONING",
+		Type:     machineType,
+		Location: p.zone,
+		Tags:     labels,
+	}, nil
+}
+
+// StopInstance stops a GCP instance.
+func (p *Provider) StopInstance(ctx context.Context, instanceID string) error {
+	zone, name, err := p.instanceRef(instanceID)
+	if err != nil {
+		return err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("stop instance %s", instanceID))
+	_, err = p.client.Stop(ctx, &computepb.StopInstanceRequest{
+		Project:  p.project,
+		Zone:     zone,
+		Instance: name,
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to stop instance: %w", err)
+	}
+	return nil
+}
+
+// StartInstance starts a GCP instance.
+func (p *Provider) StartInstance(ctx context.Context, instanceID string) error {
+	zone, name, err := p.instanceRef(instanceID)
+	if err != nil {
+		return err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("start instance %s", instanceID))
+	_, err = p.client.Start(ctx, &computepb.StartInstanceRequest{
+		Project:  p.project,
+		Zone:     zone,
+		Instance: name,
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to start instance: %w", err)
+	}
+	return nil
+}
+
+// RebootInstance resets a GCP instance. Compute Engine has no graceful
+// reboot: a reset is like pressing the reset button.
+func (p *Provider) RebootInstance(ctx context.Context, instanceID string) error {
+	zone, name, err := p.instanceRef(instanceID)
+	if err != nil {
+		return err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("reboot instance %s", instanceID))
+	_, err = p.client.Reset(ctx, &computepb.ResetInstanceRequest{
+		Project:  p.project,
+		Zone:     zone,
+		Instance: name,
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to reboot instance: %w", err)
+	}
+	return nil
+}
+
+// TerminateInstance deletes a GCP instance.
+func (p *Provider) TerminateInstance(ctx context.Context, instanceID string) error {
+	zone, name, err := p.instanceRef(instanceID)
+	if err != nil {
+		return err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("terminate instance %s", instanceID))
+	_, err = p.client.Delete(ctx, &computepb.DeleteInstanceRequest{
+		Project:  p.project,
+		Zone:     zone,
+		Instance: name,
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to terminate instance: %w", err)
+	}
+	return nil
+}
+
+// DescribeInstance provides details of a GCP instance.
+func (p *Provider) DescribeInstance(ctx context.Context, instanceID string) (clouds.Instance, error) {
+	zone, name, err := p.instanceRef(instanceID)
+	if err != nil {
+		return clouds.Instance{}, err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("describe instance %s", instanceID))
+	instance, err := p.client.Get(ctx, &computepb.GetInstanceRequest{
+		Project:  p.project,
+		Zone:     zone,
+		Instance: name,
+	})
+	if err := op.Finish(err); err != nil {
+		return clouds.Instance{}, fmt.Errorf("failed to describe instance: %w", err)
+	}
+
+	return toInstance(instance), nil
+}
+
+// zonalID returns the ID of the instance with the given name in a zone.
+// Names are only unique within a zone, so IDs are qualified with it.
+func zonalID(zone, name string) string {
+	return zone + "/" + name
+}
+
+// instanceRef returns the zone and name of an instance ID. A bare name is
+// taken to be in the configured zone.
+func (p *Provider) instanceRef(id string) (string, string, error) {
+	if zone, name, ok := strings.Cut(id, "/"); ok {
+		return zone, name, nil
+	}
+	if p.zone == "" {
+		return "", "", errNoZone
+	}
+	return p.zone, id, nil
+}
+
+// toState converts a GCP instance status to an instance state. GCP reports
+// stopped instances as TERMINATED, which every other cloud uses for deleted
+// instances.
+func toState(status string) string {
+	if status == "TERMINATED" {
+		return "STOPPED"
+	}
+	return status
+}
+
+// toInstance converts a Compute Engine instance to the provider-neutral representation.
+// GCP instances are addressed by zone and name, which make up their ID.
+func toInstance(instance *computepb.Instance) clouds.Instance {
+	result := clouds.Instance{
+		ID:       zonalID(path.Base(instance.GetZone()), instance.GetName()),
+		Name:     instance.GetName(),
+		State:    toState(instance.GetStatus()),
+		Type:     path.Base(instance.GetMachineType()),
+		Location: path.Base(instance.GetZone()),
+		Tags:     instance.GetLabels(),
+	}
+	if created, err := time.Parse(time.RFC3339, instance.GetCreationTimestamp()); err == nil {
+		result.LaunchTime = created
+	}
+	for _, nic := range instance.GetNetworkInterfaces() {
+		if result.PrivateIP == "" {
+			result.PrivateIP = nic.GetNetworkIP()
 		}
-		// terminateInstance(client, args[0])
-	default:
-		fmt.Println("Unsupported GCP command.")
-	}
-}
-
-// // listInstances lists all GCP instances.
-// func listInstances(client *compute.InstancesClient) {
-// 	ctx := context.Background()
-// 	req := &compute.AggregatedListInstancesRequest{}
-// 	it := client.AggregatedList(ctx, req)
-
-// 	for {
-// 		resp, err := it.Next()
-// 		if err != nil {
-// 			if err == iterator.Done {
-// 				break
-// 			}
-// 			log.Fatalf("Failed to list instances: %v", err)
-// 		}
-
-// 		for _, instances := range resp.Items {
-// 			for _, instance := range instances.Instances {
-// 				fmt.Printf("Instance ID: %s, State: %s\n", instance.Name, instance.Status)
-// 			}
-// 		}
-// 	}
-// }
-
-// // createInstance creates a GCP instance with the specified machine type.
-// func createInstance(client *compute.InstancesClient, machineType string) {
-// 	// Example of creating an instance (you would need to customize the config)
-// 	instance := &compute.Instance{
-// 		Name:        "new-instance",
-// 		MachineType: fmt.Sprintf("zones/us-central1-a/machineTypes/%s", machineType),
-// 	}
-
-// 	// Set other parameters like disk, image, network, etc.
-
-// 	// Create instance
-// 	ctx := context.Background()
-// 	_, err := client.Insert(ctx, &compute.InsertInstanceRequest{
-// 		Project:  "your-project-id",
-// 		Zone:     "us-central1-a",
-// 		Instance: instance,
-// 	})
-// 	if err != nil {
-// 		log.Fatalf("Failed to create instance: %v", err)
-// 	}
-
-// 	fmt.Println("Instance created successfully.")
-// }
-
-// // stopInstance stops a GCP instance.
-// func stopInstance(client *compute.InstancesClient, instanceID string) {
-// 	ctx := context.Background()
-// 	_, err := client.Stop(ctx, "your-project-id", "us-central1-a", instanceID)
-// 	if err != nil {
-// 		log.Fatalf("Failed to stop instance: %v", err)
-// 	}
-
-// 	fmt.Printf("Stopping instance with ID: %s\n", instanceID)
-// }
-
-// // startInstance starts a GCP instance.
-// func startInstance(client *compute.InstancesClient, instanceID string) {
-// 	ctx := context.Background()
-// 	_, err := client.Start(ctx, "your-project-id", "us-central1-a", instanceID)
-// 	if err != nil {
-// 		log.Fatalf("Failed to start instance: %v", err)
-// 	}
-
-// 	fmt.Printf("Starting instance with ID: %s\n", instanceID)
-// }
-
-// // terminateInstance terminates a GCP instance.
-// func terminateInstance(client *compute.InstancesClient, instanceID string) {
-// 	ctx := context.Background()
-// 	_, err := client.Delete(ctx, "your-project-id", "us-central1-a", instanceID)
-// 	if err != nil {
-// 		log.Fatalf("Failed to terminate instance: %v", err)
-// 	}
-
-// 	fmt.Printf("Terminating instance with ID: %s\n", instanceID)
-// }
+		for _, access := range nic.GetAccessConfigs() {
+			if access.GetNatIP() != "" {
+				result.PublicIP = access.GetNatIP()
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,74 @@
+package gcpcloud
+
+import (
+	"context"
+	"namaste-cloud/internal"
+	"namaste-cloud/internal/cassette"
+	"path/filepath"
+	"testing"
+)
+
+// newTestProvider returns a provider whose API calls are replayed from the
+// test's cassette. Replays run unauthenticated against the default endpoint;
+// when recording, the stored credentials are used.
+func newTestProvider(t *testing.T) *Provider {
+	t.Helper()
+
+	rec := cassette.Use(t, filepath.Join("testdata", "cassettes", t.Name()+".yaml"))
+	if !rec.Recording() {
+		t.Setenv(internal.ConfigDirEnv, t.TempDir())
+	}
+
+	p, err := New(context.Background(), internal.Config{
+		Project:     "namaste-test",
+		Zone:        "us-central1-a",
+		EndpointURL: "https://compute.googleapis.com",
+		Retry:       internal.RetryPolicy{MaxAttempts: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestListInstances(t *testing.T) {
+	p := newTestProvider(t)
+
+	instances, err := p.ListInstances(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 1 {
+		t.Fatalf("got %d instances, want 1", len(instances))
+	}
+
+	web := instances[0]
+	if web.ID != "us-central1-a/web" || web.State != "RUNNING" || web.Type != "e2-micro" ||
+		web.Location != "us-central1-a" || web.PublicIP != "203.0.113.20" || web.PrivateIP != "10.128.0.2" {
+		t.Errorf("unexpected instance: %+v", web)
+	}
+	if web.LaunchTime.IsZero() {
+		t.Error("launch time was not parsed")
+	}
+}
+
+func TestDescribeInstance(t *testing.T) {
+	p := newTestProvider(t)
+
+	instance, err := p.DescribeInstance(context.Background(), "us-central1-a/web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instance.Name != "web" || instance.State != "STOPPED" || instance.PublicIP != "" {
+		t.Errorf("unexpected instance: %+v", instance)
+	}
+}
+
+func TestStopInstanceRequiresZone(t *testing.T) {
+	p := &Provider{project: "namaste-test"}
+
+	if err := p.StopInstance(context.Background(), "web"); err != errNoZone {
+		t.Errorf("got %v, want %v", err, errNoZone)
+	}
+}
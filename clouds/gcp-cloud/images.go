@@ -0,0 +1,72 @@
+package gcpcloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+	"time"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+)
+
+// imageFamily is a public image family, whose newest image is current.
+type imageFamily struct {
+	project string
+	family  string
+}
+
+// imageFamilies are the image families of each release alias by architecture.
+var imageFamilies = map[string]map[string]imageFamily{
+	"ubuntu-24.04": {
+		clouds.ArchX86: {"ubuntu-os-cloud", "ubuntu-2404-lts-amd64"},
+		clouds.ArchARM: {"ubuntu-os-cloud", "ubuntu-2404-lts-arm64"},
+	},
+	"ubuntu-22.04": {
+		clouds.ArchX86: {"ubuntu-os-cloud", "ubuntu-2204-lts"},
+		clouds.ArchARM: {"ubuntu-os-cloud", "ubuntu-2204-lts-arm64"},
+	},
+	"debian-12": {
+		clouds.ArchX86: {"debian-cloud", "debian-12"},
+		clouds.ArchARM: {"debian-cloud", "debian-12-arm64"},
+	},
+	"debian-11": {
+		clouds.ArchX86: {"debian-cloud", "debian-11"},
+		clouds.ArchARM: {"debian-cloud", "debian-11-arm64"},
+	},
+	"rhel-9": {
+		clouds.ArchX86: {"rhel-cloud", "rhel-9"},
+		clouds.ArchARM: {"rhel-cloud", "rhel-9-arm64"},
+	},
+	"rocky-9": {
+		clouds.ArchX86: {"rocky-linux-cloud", "rocky-linux-9"},
+		clouds.ArchARM: {"rocky-linux-cloud", "rocky-linux-9-arm64"},
+	},
+}
+
+// ResolveImage looks up the current image of a release's image family.
+// Images are global, so the zone does not matter.
+func (p *Provider) ResolveImage(ctx context.Context, alias, arch string) (clouds.Image, error) {
+	source, ok := imageFamilies[alias][arch]
+	if !ok {
+		return clouds.Image{}, fmt.Errorf("%s image for %s on GCP: %w", alias, arch, clouds.ErrNotFound)
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("get the latest image of family %s", source.family))
+	image, err := p.images.GetFromFamily(ctx, &computepb.GetFromFamilyImageRequest{
+		Project: source.project,
+		Family:  source.family,
+	})
+	if err := op.Finish(err); err != nil {
+		return clouds.Image{}, fmt.Errorf("failed to look up the %s image: %w", alias, err)
+	}
+
+	created, _ := time.Parse(time.RFC3339, image.GetCreationTimestamp())
+	return clouds.Image{
+		ID:        fmt.Sprintf("projects/%s/global/images/%s", source.project, image.GetName()),
+		Name:      image.GetName(),
+		Alias:     alias,
+		Arch:      arch,
+		CreatedAt: created,
+	}, nil
+}
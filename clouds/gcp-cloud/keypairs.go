@@ -0,0 +1,236 @@
+package gcpcloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+	"path"
+	"strings"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/proto"
+)
+
+// sshKeysMetadata is the metadata key holding SSH keys, one "USER:KEY COMMENT"
+// entry per line. The comment is used as the key pair name.
+const sshKeysMetadata = "ssh-keys"
+
+// ListKeyPairs lists the SSH keys in the project metadata and in the metadata
+// of every instance of the project.
+func (p *Provider) ListKeyPairs(ctx context.Context) ([]clouds.KeyPair, error) {
+	op := internal.StartOperation(fmt.Sprintf("list SSH keys in project %s", p.project))
+	project, err := p.projects.Get(ctx, &computepb.GetProjectRequest{
+		Project: p.project,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list key pairs: %w", op.Finish(err))
+	}
+	keys := parseSSHKeys(project.GetCommonInstanceMetadata(), "")
+
+	it := p.client.AggregatedList(ctx, &computepb.AggregatedListInstancesRequest{
+		Project: p.project,
+	})
+	for {
+		resp, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list key pairs: %w", op.Finish(err))
+		}
+		for _, instance := range resp.Value.GetInstances() {
+			keys = append(keys, parseSSHKeys(instance.GetMetadata(), zonalID(path.Base(instance.GetZone()), instance.GetName()))...)
+		}
+	}
+	op.Finish(nil)
+
+	return keys, nil
+}
+
+// CreateKeyPair generates a key pair locally and adds its public key to the
+// project metadata, or to the metadata of the key's instance.
+func (p *Provider) CreateKeyPair(ctx context.Context, key clouds.KeyPair) (clouds.KeyPair, string, error) {
+	publicKey, privateKey, err := clouds.GenerateKeyPair()
+	if err != nil {
+		return clouds.KeyPair{}, "", err
+	}
+
+	key.PublicKey = publicKey
+	created, err := p.ImportKeyPair(ctx, key)
+	if err != nil {
+		return clouds.KeyPair{}, "", err
+	}
+	return created, privateKey, nil
+}
+
+// ImportKeyPair adds a public key to the project metadata, or to the metadata
+// of the key's instance, for the key's user. A key of the same name is replaced.
+func (p *Provider) ImportKeyPair(ctx context.Context, key clouds.KeyPair) (clouds.KeyPair, error) {
+	if key.User == "" {
+		return clouds.KeyPair{}, fmt.Errorf("a user is required for GCP SSH keys")
+	}
+	parsed, err := clouds.ParsePublicKey(key.PublicKey)
+	if err != nil {
+		return clouds.KeyPair{}, err
+	}
+	parsed.Name, parsed.User, parsed.Instance = key.Name, key.User, key.Instance
+
+	op := internal.StartOperation(fmt.Sprintf("import key pair %s", key.Name))
+	err = p.updateSSHKeys(ctx, key.Instance, func(keys []clouds.KeyPair) []clouds.KeyPair {
+		return append(withoutKey(keys, key.Name), parsed)
+	})
+	if err := op.Finish(err); err != nil {
+		return clouds.KeyPair{}, fmt.Errorf("failed to import key pair: %w", err)
+	}
+	return parsed, nil
+}
+
+// DeleteKeyPair removes the SSH keys with the key's name from the project
+// metadata, or from the metadata of the key's instance.
+func (p *Provider) DeleteKeyPair(ctx context.Context, key clouds.KeyPair) error {
+	op := internal.StartOperation(fmt.Sprintf("delete key pair %s", key.Name))
+	err := p.updateSSHKeys(ctx, key.Instance, func(keys []clouds.KeyPair) []clouds.KeyPair {
+		return withoutKey(keys, key.Name)
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to delete key pair: %w", err)
+	}
+	return nil
+}
+
+// updateSSHKeys rewrites the SSH keys in the project metadata, or in the
+// metadata of an instance. The metadata fingerprint
+// makes the update fail rather than overwrite a concurrent change, and the
+// update is waited for so that the keys can be used straight away.
+func (p *Provider) updateSSHKeys(ctx context.Context, instanceID string, update func([]clouds.KeyPair) []clouds.KeyPair) error {
+	if instanceID == "" {
+		project, err := p.projects.Get(ctx, &computepb.GetProjectRequest{
+			Project: p.project,
+		})
+		if err != nil {
+			return err
+		}
+
+		metadata := project.GetCommonInstanceMetadata()
+		if metadata == nil {
+			metadata = &computepb.Metadata{}
+		}
+		setSSHKeys(metadata, update(parseSSHKeys(metadata, "")))
+		operation, err := p.projects.SetCommonInstanceMetadata(ctx, &computepb.SetCommonInstanceMetadataProjectRequest{
+			Project:          p.project,
+			MetadataResource: metadata,
+		})
+		if err != nil {
+			return err
+		}
+		return operation.Wait(ctx)
+	}
+
+	zone, name, err := p.instanceRef(instanceID)
+	if err != nil {
+		return err
+	}
+	instance, err := p.client.Get(ctx, &computepb.GetInstanceRequest{
+		Project:  p.project,
+		Zone:     zone,
+		Instance: name,
+	})
+	if err != nil {
+		return err
+	}
+
+	metadata := instance.GetMetadata()
+	if metadata == nil {
+		metadata = &computepb.Metadata{}
+	}
+	setSSHKeys(metadata, update(parseSSHKeys(metadata, instanceID)))
+	operation, err := p.client.SetMetadata(ctx, &computepb.SetMetadataInstanceRequest{
+		Project:          p.project,
+		Zone:             zone,
+		Instance:         name,
+		MetadataResource: metadata,
+	})
+	if err != nil {
+		return err
+	}
+	return operation.Wait(ctx)
+}
+
+// parseSSHKeys returns the SSH keys in the metadata, skipping entries that
+// are not valid keys.
+func parseSSHKeys(metadata *computepb.Metadata, instanceID string) []clouds.KeyPair {
+	var keys []clouds.KeyPair
+	for _, line := range sshKeyLines(metadata) {
+		user, publicKey, ok := strings.Cut(line, ":")
+		if !ok || user == "" {
+			continue
+		}
+		key, err := clouds.ParsePublicKey(publicKey)
+		if err != nil {
+			continue
+		}
+		if key.Name == "" {
+			key.Name = user
+		}
+		key.User, key.Instance = user, instanceID
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// setSSHKeys replaces the SSH keys in the metadata, keeping any entries that
+// are not valid keys untouched.
+func setSSHKeys(metadata *computepb.Metadata, keys []clouds.KeyPair) {
+	var lines []string
+	for _, line := range sshKeyLines(metadata) {
+		user, publicKey, ok := strings.Cut(line, ":")
+		if _, err := clouds.ParsePublicKey(publicKey); ok && user != "" && err == nil {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf("%s:%s %s", key.User, key.PublicKey, key.Name))
+	}
+	value := strings.Join(lines, "\n")
+
+	for _, item := range metadata.Items {
+		if item.GetKey() == sshKeysMetadata {
+			item.Value = proto.String(value)
+			return
+		}
+	}
+	metadata.Items = append(metadata.Items, &computepb.Items{
+		Key:   proto.String(sshKeysMetadata),
+		Value: proto.String(value),
+	})
+}
+
+// sshKeyLines returns the non-empty lines of the ssh-keys metadata entry.
+func sshKeyLines(metadata *computepb.Metadata) []string {
+	var lines []string
+	for _, item := range metadata.GetItems() {
+		if item.GetKey() != sshKeysMetadata {
+			continue
+		}
+		for _, line := range strings.Split(item.GetValue(), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+	return lines
+}
+
+// withoutKey returns the keys except those with the given name.
+func withoutKey(keys []clouds.KeyPair, name string) []clouds.KeyPair {
+	var result []clouds.KeyPair
+	for _, key := range keys {
+		if key.Name != name {
+			result = append(result, key)
+		}
+	}
+	return result
+}
@@ -0,0 +1,51 @@
+package gcpcloud
+
+import (
+	"namaste-cloud/clouds"
+	"testing"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+const testPublicKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAICDHpuYGZs59yi21QuBTSQhM9m1zxhiW1x5MUDTid4Wo"
+
+func TestSSHKeysMetadata(t *testing.T) {
+	metadata := &computepb.Metadata{
+		Items: []*computepb.Items{
+			{Key: proto.String("startup-script"), Value: proto.String("#!/bin/sh")},
+			{Key: proto.String(sshKeysMetadata), Value: proto.String(
+				"alice:" + testPublicKey + " laptop\n" +
+					"bob:" + testPublicKey + "\n" +
+					"not a key\n")},
+		},
+	}
+
+	keys := parseSSHKeys(metadata, "web-1")
+	if len(keys) != 2 {
+		t.Fatalf("got %d keys, want 2: %+v", len(keys), keys)
+	}
+	want := clouds.KeyPair{
+		Name:        "laptop",
+		Fingerprint: "SHA256:RiRh1vtzkl0pWcIFe4QjQLoDTMtUm+Mr5y+p4F8ifU8",
+		PublicKey:   testPublicKey,
+		User:        "alice",
+		Instance:    "web-1",
+	}
+	if keys[0] != want {
+		t.Errorf("got %+v, want %+v", keys[0], want)
+	}
+	// Keys without a comment are named after their user
+	if keys[1].Name != "bob" {
+		t.Errorf("got name %q, want bob", keys[1].Name)
+	}
+
+	setSSHKeys(metadata, withoutKey(keys, "bob"))
+	wantValue := "not a key\nalice:" + testPublicKey + " laptop"
+	if value := metadata.Items[1].GetValue(); value != wantValue {
+		t.Errorf("got ssh-keys %q, want %q", value, wantValue)
+	}
+	if value := metadata.Items[0].GetValue(); value != "#!/bin/sh" {
+		t.Errorf("other metadata changed to %q", value)
+	}
+}
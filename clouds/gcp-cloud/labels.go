@@ -0,0 +1,152 @@
+package gcpcloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+	"strings"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+)
+
+// maxLabelLength is the maximum length of label keys and values.
+const maxLabelLength = 63
+
+// TagInstance adds labels to a GCP instance. Keys and values are normalized
+// to what GCP accepts, see toLabels.
+func (p *Provider) TagInstance(ctx context.Context, instanceID string, tags map[string]string) error {
+	labels, err := toLabels(tags)
+	if err != nil {
+		return err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("tag instance %s", instanceID))
+	err = p.updateLabels(ctx, instanceID, func(current map[string]string) {
+		for k, v := range labels {
+			current[k] = v
+		}
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to tag instance: %w", err)
+	}
+	return nil
+}
+
+// UntagInstance removes labels from a GCP instance. Keys are normalized as
+// when tagging, so the key a label was added with also removes it.
+func (p *Provider) UntagInstance(ctx context.Context, instanceID string, keys []string) error {
+	var labelKeys []string
+	for _, key := range keys {
+		labelKey, err := normalizeLabelKey(key)
+		if err != nil {
+			return err
+		}
+		labelKeys = append(labelKeys, labelKey)
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("untag instance %s", instanceID))
+	err := p.updateLabels(ctx, instanceID, func(current map[string]string) {
+		for _, key := range labelKeys {
+			delete(current, key)
+		}
+	})
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to untag instance: %w", err)
+	}
+	return nil
+}
+
+// NormalizeTags returns the labels tags become on GCP, see toLabels.
+func (p *Provider) NormalizeTags(tags map[string]string) (map[string]string, error) {
+	return toLabels(tags)
+}
+
+// updateLabels rewrites the labels of an instance. As with metadata, the
+// label fingerprint makes the update fail rather than overwrite a concurrent
+// change.
+func (p *Provider) updateLabels(ctx context.Context, instanceID string, update func(map[string]string)) error {
+	zone, name, err := p.instanceRef(instanceID)
+	if err != nil {
+		return err
+	}
+	instance, err := p.client.Get(ctx, &computepb.GetInstanceRequest{
+		Project:  p.project,
+		Zone:     zone,
+		Instance: name,
+	})
+	if err != nil {
+		return err
+	}
+
+	labels := make(map[string]string, len(instance.GetLabels()))
+	for k, v := range instance.GetLabels() {
+		labels[k] = v
+	}
+	update(labels)
+	operation, err := p.client.SetLabels(ctx, &computepb.SetLabelsInstanceRequest{
+		Project:  p.project,
+		Zone:     zone,
+		Instance: name,
+		InstancesSetLabelsRequestResource: &computepb.InstancesSetLabelsRequest{
+			Labels:           labels,
+			LabelFingerprint: instance.LabelFingerprint,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return operation.Wait(ctx)
+}
+
+// toLabels converts tags to GCP labels, which allow lowercase letters,
+// digits, underscores and dashes, up to 63 characters, and whose keys must
+// start with a letter. Tags are lowercased, other characters are replaced
+// with underscores and long tags are truncated, so that "Cost.Center=R&D" becomes
+// "cost_center=r_d". Tags that become the same label are an error.
+func toLabels(tags map[string]string) (map[string]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	labels := make(map[string]string, len(tags))
+	sources := make(map[string]string, len(tags))
+	for _, key := range clouds.SortedKeys(tags) {
+		labelKey, err := normalizeLabelKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := sources[labelKey]; ok {
+			return nil, fmt.Errorf("tags %q and %q both become the GCP label %q", other, key, labelKey)
+		}
+		sources[labelKey] = key
+		labels[labelKey] = normalizeLabel(tags[key])
+	}
+	return labels, nil
+}
+
+// normalizeLabelKey normalizes a tag key to a label key.
+func normalizeLabelKey(key string) (string, error) {
+	labelKey := normalizeLabel(key)
+	if labelKey == "" || labelKey[0] < 'a' || labelKey[0] > 'z' {
+		return "", fmt.Errorf("invalid tag %q: GCP label keys must start with a letter", key)
+	}
+	return labelKey, nil
+}
+
+// normalizeLabel lowercases s, replaces the characters labels do not allow
+// with underscores and truncates it to the maximum label length.
+func normalizeLabel(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+		if b.Len() == maxLabelLength {
+			break
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,41 @@
+package gcpcloud
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToLabels(t *testing.T) {
+	tests := []struct {
+		name string
+		tags map[string]string
+		want map[string]string
+		err  string
+	}{
+		{"none", nil, nil, ""},
+		{"valid", map[string]string{"env": "prod", "created-by": "namaste-cloud"}, map[string]string{"env": "prod", "created-by": "namaste-cloud"}, ""},
+		{"normalized", map[string]string{"Cost.Center": "R&D", "Owner": "Jane Doe"}, map[string]string{"cost_center": "r_d", "owner": "jane_doe"}, ""},
+		{"truncated", map[string]string{"team": strings.Repeat("a", 70)}, map[string]string{"team": strings.Repeat("a", 63)}, ""},
+		{"empty value", map[string]string{"backup": ""}, map[string]string{"backup": ""}, ""},
+		{"digit key", map[string]string{"2fa": "on"}, nil, "must start with a letter"},
+		{"collision", map[string]string{"Owner": "a", "owner": "b"}, nil, `both become the GCP label "owner"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toLabels(tt.tags)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
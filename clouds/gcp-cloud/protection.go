@@ -0,0 +1,53 @@
+package gcpcloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/internal"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+// TerminationProtection reports whether deletion protection is enabled for a GCP instance.
+func (p *Provider) TerminationProtection(ctx context.Context, instanceID string) (bool, error) {
+	zone, name, err := p.instanceRef(instanceID)
+	if err != nil {
+		return false, err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("get deletion protection of instance %s", instanceID))
+	instance, err := p.client.Get(ctx, &computepb.GetInstanceRequest{
+		Project:  p.project,
+		Zone:     zone,
+		Instance: name,
+	})
+	if err := op.Finish(err); err != nil {
+		return false, fmt.Errorf("failed to get deletion protection: %w", err)
+	}
+	return instance.GetDeletionProtection(), nil
+}
+
+// SetTerminationProtection enables or disables deletion protection for a GCP
+// instance and waits for the change, so that it applies straight away.
+func (p *Provider) SetTerminationProtection(ctx context.Context, instanceID string, enabled bool) error {
+	zone, name, err := p.instanceRef(instanceID)
+	if err != nil {
+		return err
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("set deletion protection of instance %s", instanceID))
+	operation, err := p.client.SetDeletionProtection(ctx, &computepb.SetDeletionProtectionInstanceRequest{
+		Project:            p.project,
+		Zone:               zone,
+		Resource:           name,
+		DeletionProtection: proto.Bool(enabled),
+	})
+	if err == nil {
+		err = operation.Wait(ctx)
+	}
+	if err := op.Finish(err); err != nil {
+		return fmt.Errorf("failed to set deletion protection: %w", err)
+	}
+	return nil
+}
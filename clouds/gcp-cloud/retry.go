@@ -0,0 +1,74 @@
+package gcpcloud
+
+import (
+	"errors"
+	"namaste-cloud/internal"
+	"net/http"
+	"time"
+
+	"github.com/googleapis/gax-go/v2"
+	"github.com/googleapis/gax-go/v2/apierror"
+	"google.golang.org/api/googleapi"
+)
+
+// retryer retries GCP calls according to the shared retry policy.
+type retryer struct {
+	policy  internal.RetryPolicy
+	attempt int
+}
+
+// Retry implements gax.Retryer.
+func (r *retryer) Retry(err error) (time.Duration, bool) {
+	r.attempt++
+	if r.attempt >= r.policy.MaxAttempts || !isRetryable(err) {
+		return 0, false
+	}
+	return r.policy.Backoff(r.attempt), true
+}
+
+// isRetryable classifies GCP errors that are safe to retry: rate limiting and
+// transient server-side failures.
+func isRetryable(err error) bool {
+	code := 0
+	var apiErr *apierror.APIError
+	var googleErr *googleapi.Error
+	switch {
+	case errors.As(err, &apiErr):
+		code = apiErr.HTTPCode()
+	case errors.As(err, &googleErr):
+		code = googleErr.Code
+	}
+
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// applyRetryPolicy makes the given calls retry with the shared policy
+// instead of the per-method defaults of the client library.
+func applyRetryPolicy(policy internal.RetryPolicy, calls ...*[]gax.CallOption) {
+	policy = policy.WithDefaults()
+	retry := gax.WithRetry(func() gax.Retryer {
+		return &retryer{policy: policy}
+	})
+
+	for _, opts := range calls {
+		*opts = append(*opts, retry)
+	}
+}
+
+// isNotFound reports whether a GCP call failed because the resource does not exist.
+func isNotFound(err error) bool {
+	var apiErr *apierror.APIError
+	var googleErr *googleapi.Error
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.HTTPCode() == http.StatusNotFound
+	case errors.As(err, &googleErr):
+		return googleErr.Code == http.StatusNotFound
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package gcpcloud
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+	"strings"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/api/iterator"
+)
+
+// ListSizes lists the machine types offered in the zone. Deprecated machine
+// types are left out.
+func (p *Provider) ListSizes(ctx context.Context) ([]clouds.Size, error) {
+	if p.zone == "" {
+		return nil, errNoZone
+	}
+
+	op := internal.StartOperation(fmt.Sprintf("list machine types in zone %s", p.zone))
+	it := p.machines.List(ctx, &computepb.ListMachineTypesRequest{
+		Project: p.project,
+		Zone:    p.zone,
+	})
+
+	var sizes []clouds.Size
+	for {
+		machineType, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list machine types: %w", op.Finish(err))
+		}
+		if machineType.GetDeprecated().GetState() != "" {
+			continue
+		}
+		sizes = append(sizes, toSize(machineType))
+	}
+	op.Finish(nil)
+
+	return sizes, nil
+}
+
+// toSize converts a machine type to a size. Machine types that do not state
+// their architecture are x86.
+func toSize(machineType *computepb.MachineType) clouds.Size {
+	size := clouds.Size{
+		Type:      machineType.GetName(),
+		CPUs:      int(machineType.GetGuestCpus()),
+		MemoryGiB: float64(machineType.GetMemoryMb()) / 1024,
+		Arch:      clouds.ArchX86,
+	}
+	if strings.EqualFold(machineType.GetArchitecture(), "ARM64") {
+		size.Arch = clouds.ArchARM
+	}
+	for _, accelerator := range machineType.GetAccelerators() {
+		size.GPUs += int(accelerator.GetGuestAcceleratorCount())
+	}
+	return size
+}
@@ -0,0 +1,75 @@
+package clouds
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// OS is an operating system release whose images every cloud publishes,
+// looked up by its alias instead of a cloud and region specific image ID.
+type OS struct {
+	// Alias names the release, e.g. ubuntu-24.04.
+	Alias string
+
+	// Name is the name of the operating system, e.g. ubuntu.
+	Name string
+}
+
+// OperatingSystems are the releases that can be given by alias.
+var OperatingSystems = []OS{
+	{Alias: "ubuntu-24.04", Name: "ubuntu"},
+	{Alias: "ubuntu-22.04", Name: "ubuntu"},
+	{Alias: "debian-12", Name: "debian"},
+	{Alias: "debian-11", Name: "debian"},
+	{Alias: "rhel-9", Name: "rhel"},
+	{Alias: "rocky-9", Name: "rocky"},
+	{Alias: "amazon-linux-2023", Name: "amazon-linux"},
+}
+
+// IsOSAlias reports whether an image is given as the alias of an operating
+// system release rather than an image ID.
+func IsOSAlias(image string) bool {
+	_, ok := LookupOS(image)
+	return ok
+}
+
+// LookupOS returns the operating system release with the given alias.
+func LookupOS(alias string) (OS, bool) {
+	for _, os := range OperatingSystems {
+		if os.Alias == alias {
+			return os, true
+		}
+	}
+	return OS{}, false
+}
+
+// MatchOS returns the releases of an operating system given by name, such as
+// ubuntu, or by alias. All releases match an empty name.
+func MatchOS(name string) []OS {
+	var matching []OS
+	for _, os := range OperatingSystems {
+		if name == "" || strings.EqualFold(os.Name, name) || strings.EqualFold(os.Alias, name) {
+			matching = append(matching, os)
+		}
+	}
+	return matching
+}
+
+// Image is the current image of an operating system release in a region.
+type Image struct {
+	// ID is what CreateInstance takes as the image: an AMI ID on AWS, an
+	// image path on GCP and a publisher:offer:sku:version URN on Azure.
+	ID        string
+	Name      string
+	Alias     string
+	Arch      string
+	CreatedAt time.Time
+}
+
+// ImageResolver is implemented by providers that can look up the current
+// image of an operating system release for an architecture. Releases the
+// cloud does not publish for the architecture are ErrNotFound.
+type ImageResolver interface {
+	ResolveImage(ctx context.Context, alias, arch string) (Image, error)
+}
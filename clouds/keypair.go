@@ -0,0 +1,90 @@
+package clouds
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// generatedKeyBits is the size of the RSA keys generated locally. RSA is used
+// because Azure only accepts ssh-rsa public keys.
+const generatedKeyBits = 3072
+
+// KeyPair is an SSH key pair known to a cloud. Clouds only hold the public
+// key; the private key is returned once, when the key pair is created.
+type KeyPair struct {
+	Name        string
+	Fingerprint string
+
+	// PublicKey is the public key in authorized_keys format.
+	PublicKey string
+
+	// User is the login user the key is authorized for, on clouds that
+	// record one (GCP).
+	User string
+
+	// Instance limits the key to a single instance, on clouds that support
+	// it (GCP). Empty means the key applies to the whole project or account.
+	Instance string
+}
+
+// KeyPairs is implemented by providers that can manage SSH key pairs.
+type KeyPairs interface {
+	// ListKeyPairs lists the key pairs.
+	ListKeyPairs(ctx context.Context) ([]KeyPair, error)
+
+	// CreateKeyPair creates a key pair and returns it with its PEM-encoded
+	// private key, which the cloud does not keep.
+	CreateKeyPair(ctx context.Context, key KeyPair) (KeyPair, string, error)
+
+	// ImportKeyPair uploads the public key of an existing key pair.
+	ImportKeyPair(ctx context.Context, key KeyPair) (KeyPair, error)
+
+	// DeleteKeyPair deletes the key pair with the given name and scope.
+	DeleteKeyPair(ctx context.Context, key KeyPair) error
+}
+
+// GenerateKeyPair generates an RSA key pair locally and returns its public
+// key in authorized_keys format and its PEM-encoded private key.
+func GenerateKeyPair() (string, string, error) {
+	private, err := rsa.GenerateKey(rand.Reader, generatedKeyBits)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate key pair: %w", err)
+	}
+	public, err := ssh.NewPublicKey(&private.PublicKey)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate key pair: %w", err)
+	}
+
+	privatePEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(private),
+	})
+	return authorizedKey(public), string(privatePEM), nil
+}
+
+// ParsePublicKey parses a public key in authorized_keys format. The key pair
+// it returns holds the key in canonical form, without its comment, and its
+// SHA256 fingerprint; the comment becomes its name.
+func ParsePublicKey(key string) (KeyPair, error) {
+	public, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("invalid public key: %w", err)
+	}
+	return KeyPair{
+		Name:        comment,
+		Fingerprint: ssh.FingerprintSHA256(public),
+		PublicKey:   authorizedKey(public),
+	}, nil
+}
+
+// authorizedKey formats a public key as an authorized_keys line without a comment.
+func authorizedKey(key ssh.PublicKey) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
@@ -0,0 +1,38 @@
+package clouds
+
+import (
+	"context"
+	"fmt"
+)
+
+// InstanceAction is a lifecycle action that can be applied to instances.
+type InstanceAction string
+
+// The lifecycle actions of instances.
+const (
+	ActionStart     InstanceAction = "start"
+	ActionStop      InstanceAction = "stop"
+	ActionReboot    InstanceAction = "reboot"
+	ActionTerminate InstanceAction = "terminate"
+)
+
+// DryRunner is implemented by providers that can check whether a lifecycle
+// action would succeed, including permissions, without performing it.
+type DryRunner interface {
+	DryRunInstanceAction(ctx context.Context, action InstanceAction, id string) error
+}
+
+// ApplyInstanceAction applies a lifecycle action to an instance.
+func ApplyInstanceAction(ctx context.Context, p Provider, action InstanceAction, id string) error {
+	switch action {
+	case ActionStart:
+		return p.StartInstance(ctx, id)
+	case ActionStop:
+		return p.StopInstance(ctx, id)
+	case ActionReboot:
+		return p.RebootInstance(ctx, id)
+	case ActionTerminate:
+		return p.TerminateInstance(ctx, id)
+	}
+	return fmt.Errorf("unknown instance action %q", action)
+}
@@ -0,0 +1,418 @@
+package clouds
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ChangeAction is what a plan does to a resource.
+type ChangeAction string
+
+// The actions of plan changes. Replacing a resource deletes it and creates
+// it again, for differences the clouds cannot change in place.
+const (
+	ChangeCreate  ChangeAction = "create"
+	ChangeUpdate  ChangeAction = "update"
+	ChangeReplace ChangeAction = "replace"
+	ChangeDelete  ChangeAction = "delete"
+)
+
+// The kinds of resources a spec declares.
+const (
+	KindInstance     = "instance"
+	KindFirewallRule = "firewall rule"
+	KindKeyPair      = "key pair"
+)
+
+// Change is a change to one resource that brings a cloud in line with a spec.
+type Change struct {
+	Action ChangeAction
+	Kind   string
+	Name   string
+
+	// Current is the live instance that is updated, replaced or deleted.
+	Current Instance
+
+	// Details describe the differences found, such as "type: t3.micro -> t3.small".
+	Details []string
+
+	// The desired resource of the change's kind. The tags of Instance
+	// include the StackTag.
+	Instance     InstanceSpec
+	FirewallRule FirewallRule
+	KeyPair      KeyPair
+}
+
+// PlanChanges compares the resources a spec declares for a cloud with the
+// live ones and returns the changes that make them match, in the order they
+// must be applied: key pairs and firewall rules before the instances that use
+// them. Resources are matched by name. Instances of the stack that the spec
+// no longer declares are deleted; firewall rules and key pairs are never
+// deleted, since nothing marks them as belonging to the stack. The images of
+// live instances are not compared, since not every cloud reports them.
+func PlanChanges(ctx context.Context, p Provider, stack string, spec CloudSpec) ([]Change, error) {
+	var changes []Change
+
+	keyChanges, err := planKeyPairs(ctx, p, spec.KeyPairs)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, keyChanges...)
+
+	ruleChanges, err := planFirewallRules(ctx, p, spec.FirewallRules)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, ruleChanges...)
+
+	instanceChanges, err := planInstances(ctx, p, stack, spec.Instances)
+	if err != nil {
+		return nil, err
+	}
+	return append(changes, instanceChanges...), nil
+}
+
+// planKeyPairs imports missing key pairs and replaces those whose public key differs.
+func planKeyPairs(ctx context.Context, p Provider, keys []KeyPairSpec) ([]Change, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	keyPairs, ok := p.(KeyPairs)
+	if !ok {
+		return nil, fmt.Errorf("key pairs on %s: %w", p.Name(), ErrNotSupported)
+	}
+	live, err := keyPairs.ListKeyPairs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]KeyPair)
+	for _, key := range live {
+		if key.Instance == "" {
+			byName[key.Name] = key
+		}
+	}
+
+	var changes []Change
+	for _, key := range keys {
+		desired, err := ParsePublicKey(key.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("key pair %s: %w", key.Name, err)
+		}
+		desired.Name, desired.User = key.Name, key.User
+		change := Change{Kind: KindKeyPair, Name: key.Name, KeyPair: desired}
+
+		current, ok := byName[key.Name]
+		if !ok {
+			change.Action = ChangeCreate
+			changes = append(changes, change)
+			continue
+		}
+		if current.PublicKey == "" {
+			continue
+		}
+		parsed, err := ParsePublicKey(current.PublicKey)
+		if err != nil || parsed.PublicKey != desired.PublicKey {
+			change.Action = ChangeReplace
+			change.Details = []string{fmt.Sprintf("public key: %s -> %s", parsed.Fingerprint, desired.Fingerprint)}
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// planFirewallRules creates missing firewall rules and updates those that differ.
+func planFirewallRules(ctx context.Context, p Provider, rules []FirewallRule) ([]Change, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	firewall, ok := p.(Firewall)
+	if !ok {
+		return nil, fmt.Errorf("firewall rules on %s: %w", p.Name(), ErrNotSupported)
+	}
+	live, err := firewall.ListFirewallRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]FirewallRule)
+	for _, rule := range live {
+		byName[rule.Name] = rule
+	}
+
+	var changes []Change
+	for _, rule := range rules {
+		change := Change{Kind: KindFirewallRule, Name: rule.Name, FirewallRule: rule}
+		current, ok := byName[rule.Name]
+		if !ok {
+			change.Action = ChangeCreate
+			changes = append(changes, change)
+			continue
+		}
+		if details := DiffFirewallRules(current.WithDefaults(), rule); len(details) > 0 {
+			change.Action = ChangeUpdate
+			change.Details = details
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// planInstances creates missing instances, replaces those whose type or key
+// pair differ, updates those whose tags differ and deletes the instances of
+// the stack that are no longer declared.
+func planInstances(ctx context.Context, p Provider, stack string, instances []InstanceSpec) ([]Change, error) {
+	live, err := p.ListInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	declared := make(map[string]bool)
+	var changes []Change
+	for _, spec := range instances {
+		declared[spec.Name] = true
+		spec.Tags = MergeTags(spec.Tags, map[string]string{StackTag: stack})
+		change := Change{Kind: KindInstance, Name: spec.Name, Instance: spec}
+
+		var matches []Instance
+		for _, instance := range live {
+			if instance.Name == spec.Name && !IsTerminated(instance) {
+				matches = append(matches, instance)
+			}
+		}
+		if len(matches) > 1 {
+			return nil, fmt.Errorf("instance name %s matches %d instances; give them unique names or terminate the extra ones", spec.Name, len(matches))
+		}
+		if len(matches) == 0 {
+			if err := checkCreate(p, spec); err != nil {
+				return nil, fmt.Errorf("instance %s: %w", spec.Name, err)
+			}
+			change.Action = ChangeCreate
+			changes = append(changes, change)
+			continue
+		}
+
+		current := matches[0]
+		change.Current = current
+		if spec.Type != "" && current.Type != "" && spec.Type != current.Type {
+			change.Details = append(change.Details, fmt.Sprintf("type: %s -> %s", current.Type, spec.Type))
+		}
+		if spec.KeyName != "" && current.KeyName != "" && spec.KeyName != current.KeyName {
+			change.Details = append(change.Details, fmt.Sprintf("key pair: %s -> %s", current.KeyName, spec.KeyName))
+		}
+		if len(change.Details) > 0 {
+			if err := checkCreate(p, spec); err != nil {
+				return nil, fmt.Errorf("instance %s: %w", spec.Name, err)
+			}
+			change.Action = ChangeReplace
+			changes = append(changes, change)
+			continue
+		}
+
+		desired, err := NormalizeTags(p, spec.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("instance %s: %w", spec.Name, err)
+		}
+		for _, key := range SortedKeys(desired) {
+			if value, ok := current.Tags[key]; !ok || value != desired[key] {
+				change.Details = append(change.Details, fmt.Sprintf("tag %s: %q -> %q", key, value, desired[key]))
+			}
+		}
+		if len(change.Details) > 0 {
+			if _, ok := p.(Tagger); !ok {
+				return nil, fmt.Errorf("instance %s: tags on %s: %w", spec.Name, p.Name(), ErrNotSupported)
+			}
+			change.Action = ChangeUpdate
+			changes = append(changes, change)
+		}
+	}
+
+	stackTags, err := NormalizeTags(p, map[string]string{StackTag: stack})
+	if err != nil {
+		return nil, err
+	}
+	for _, instance := range live {
+		if hasTags(instance, stackTags) && !declared[instance.Name] && !IsTerminated(instance) {
+			changes = append(changes, Change{Action: ChangeDelete, Kind: KindInstance, Name: instance.Name, Current: instance})
+		}
+	}
+	return changes, nil
+}
+
+// normalizeTags returns tags as the provider stores them, for comparison with
+// the tags of live instances.
+func NormalizeTags(p Provider, tags map[string]string) (map[string]string, error) {
+	if normalizer, ok := p.(TagNormalizer); ok {
+		return normalizer.NormalizeTags(tags)
+	}
+	return tags, nil
+}
+
+// hasTags reports whether an instance has all the tags.
+func hasTags(instance Instance, tags map[string]string) bool {
+	for key, value := range tags {
+		if v, ok := instance.Tags[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// ApplyChange makes a planned change and returns the ID of the resource it
+// created or changed: the instance ID for instances, the name otherwise.
+func ApplyChange(ctx context.Context, p Provider, change Change) (string, error) {
+	switch change.Kind {
+	case KindInstance:
+		switch change.Action {
+		case ChangeCreate:
+			spec, err := resolveImage(ctx, p, change.Instance)
+			if err != nil {
+				return "", err
+			}
+			instance, err := p.CreateInstance(ctx, spec)
+			return instance.ID, err
+		case ChangeUpdate:
+			tagger, ok := p.(Tagger)
+			if !ok {
+				return "", fmt.Errorf("tags on %s: %w", p.Name(), ErrNotSupported)
+			}
+			return change.Current.ID, tagger.TagInstance(ctx, change.Current.ID, change.Instance.Tags)
+		case ChangeReplace:
+			// The new instance usually takes the name of the old one, which
+			// the clouds do not allow twice, so it is created afterwards and
+			// what can fail is checked before the old one is terminated
+			spec, err := resolveImage(ctx, p, change.Instance)
+			if err != nil {
+				return "", err
+			}
+			if err := checkCreate(p, spec); err != nil {
+				return "", err
+			}
+			if err := p.TerminateInstance(ctx, change.Current.ID); err != nil {
+				return "", err
+			}
+			instance, err := p.CreateInstance(ctx, spec)
+			return instance.ID, err
+		case ChangeDelete:
+			return change.Current.ID, p.TerminateInstance(ctx, change.Current.ID)
+		}
+
+	case KindFirewallRule:
+		firewall, ok := p.(Firewall)
+		if !ok {
+			return "", fmt.Errorf("firewall rules on %s: %w", p.Name(), ErrNotSupported)
+		}
+		switch change.Action {
+		case ChangeCreate, ChangeUpdate, ChangeReplace:
+			return change.Name, firewall.ApplyFirewallRule(ctx, change.FirewallRule)
+		case ChangeDelete:
+			return change.Name, firewall.DeleteFirewallRule(ctx, change.Name)
+		}
+
+	case KindKeyPair:
+		keyPairs, ok := p.(KeyPairs)
+		if !ok {
+			return "", fmt.Errorf("key pairs on %s: %w", p.Name(), ErrNotSupported)
+		}
+		if change.Action == ChangeReplace || change.Action == ChangeDelete {
+			if err := keyPairs.DeleteKeyPair(ctx, change.KeyPair); err != nil {
+				return "", err
+			}
+		}
+		if change.Action != ChangeDelete {
+			if _, err := keyPairs.ImportKeyPair(ctx, change.KeyPair); err != nil {
+				return "", err
+			}
+		}
+		return change.Name, nil
+	}
+	return "", fmt.Errorf("cannot %s a %s", change.Action, change.Kind)
+}
+
+// checkCreate returns the error creating an instance would fail with, on
+// providers that cannot create every instance.
+func checkCreate(p Provider, spec InstanceSpec) error {
+	if checker, ok := p.(CreateChecker); ok {
+		return checker.CheckCreateInstance(spec)
+	}
+	return nil
+}
+
+// resolveImage replaces an operating system release given as the image of
+// an instance with its current image, for the architecture of the instance
+// type, as create-instance does. Types missing from the bundled size catalog
+// are taken to be x86_64.
+func resolveImage(ctx context.Context, p Provider, spec InstanceSpec) (InstanceSpec, error) {
+	if !IsOSAlias(spec.Image) {
+		return spec, nil
+	}
+	resolver, ok := p.(ImageResolver)
+	if !ok {
+		return spec, fmt.Errorf("looking up images on %s: %w; give an image ID", p.Name(), ErrNotSupported)
+	}
+	arch := ArchX86
+	if sizes, err := BundledSizes(p.Name()); err == nil {
+		for _, size := range sizes {
+			if size.Type == spec.Type && size.Arch != "" {
+				arch = size.Arch
+			}
+		}
+	}
+	image, err := resolver.ResolveImage(ctx, spec.Image, arch)
+	if err != nil {
+		return spec, err
+	}
+	spec.Image = image.ID
+	return spec, nil
+}
+
+// DiffFirewallRules describes how the desired rule differs from the current one.
+func DiffFirewallRules(current, desired FirewallRule) []string {
+	var details []string
+	diff := func(field, from, to string) {
+		if from != to {
+			details = append(details, fmt.Sprintf("%s: %s -> %s", field, orNone(from), orNone(to)))
+		}
+	}
+	diff("description", current.Description, desired.Description)
+	diff("direction", current.Direction, desired.Direction)
+	diff("protocol", current.Protocol, desired.Protocol)
+	diff("ports", portSet(current.Ports), portSet(desired.Ports))
+	diff("sources", set(current.Sources), set(desired.Sources))
+	diff("destinations", set(current.Destinations), set(desired.Destinations))
+	diff("target tags", set(current.TargetTags), set(desired.TargetTags))
+	return details
+}
+
+// set returns values sorted and joined with commas, to compare them regardless of order.
+func set(values []string) string {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	return strings.Join(slices.Compact(sorted), ",")
+}
+
+// portSet is set for port ranges, writing single-port ranges such as 22-22 as 22.
+func portSet(ports []string) string {
+	var normalized []string
+	for _, ports := range ports {
+		from, to, err := ParsePortRange(ports)
+		switch {
+		case err != nil:
+			normalized = append(normalized, ports)
+		case from == to:
+			normalized = append(normalized, strconv.Itoa(from))
+		default:
+			normalized = append(normalized, fmt.Sprintf("%d-%d", from, to))
+		}
+	}
+	return set(normalized)
+}
+
+// orNone returns the value, or "(none)" when it is empty.
+func orNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
@@ -0,0 +1,137 @@
+package clouds
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestPlanStoppedInstances checks that stopped instances are kept, or deleted
+// when the spec drops them, rather than created again like deleted ones.
+func TestPlanStoppedInstances(t *testing.T) {
+	stack := map[string]string{StackTag: "demo"}
+	p := listProvider{instances: []Instance{
+		{ID: "us-central1-a/web", Name: "web", State: "STOPPED", Tags: stack},
+		{ID: "us-central1-a/old", Name: "old", State: "STOPPED", Tags: stack},
+		{ID: "us-central1-a/gone", Name: "gone", State: "terminated", Tags: stack},
+	}}
+
+	changes, err := PlanChanges(context.Background(), p, "demo", CloudSpec{
+		Instances: []InstanceSpec{{Name: "web"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Action != ChangeDelete || changes[0].Name != "old" {
+		t.Errorf("got %+v, want only the deletion of old", changes)
+	}
+}
+
+// labelProvider stores tags lowercased, like GCP labels, resolves images to
+// the release and architecture, and records the instances it creates.
+type labelProvider struct {
+	listProvider
+	created []InstanceSpec
+}
+
+func (p *labelProvider) Name() string { return "fake" }
+
+func (p *labelProvider) NormalizeTags(tags map[string]string) (map[string]string, error) {
+	labels := make(map[string]string, len(tags))
+	for k, v := range tags {
+		labels[strings.ToLower(k)] = strings.ToLower(v)
+	}
+	return labels, nil
+}
+
+func (p *labelProvider) TagInstance(ctx context.Context, id string, tags map[string]string) error {
+	return nil
+}
+
+func (p *labelProvider) UntagInstance(ctx context.Context, id string, keys []string) error {
+	return nil
+}
+
+func (p *labelProvider) ResolveImage(ctx context.Context, alias, arch string) (Image, error) {
+	return Image{ID: alias + "-" + arch}, nil
+}
+
+func (p *labelProvider) CreateInstance(ctx context.Context, spec InstanceSpec) (Instance, error) {
+	p.created = append(p.created, spec)
+	return Instance{ID: "i-new", Name: spec.Name}, nil
+}
+
+// TestPlanNormalizedTags checks that tags are compared as the cloud stores
+// them, so that they converge and stack instances are found.
+func TestPlanNormalizedTags(t *testing.T) {
+	p := &labelProvider{listProvider: listProvider{instances: []Instance{
+		{ID: "web", Name: "web", State: "RUNNING", Tags: map[string]string{"team": "payments", StackTag: "prod"}},
+		{ID: "old", Name: "old", State: "RUNNING", Tags: map[string]string{StackTag: "prod"}},
+	}}}
+
+	changes, err := PlanChanges(context.Background(), p, "Prod", CloudSpec{
+		Instances: []InstanceSpec{{Name: "web", Tags: map[string]string{"Team": "Payments"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Action != ChangeDelete || changes[0].Name != "old" {
+		t.Errorf("got %+v, want only the deletion of old", changes)
+	}
+}
+
+func TestApplyChangeResolvesImage(t *testing.T) {
+	p := &labelProvider{}
+	change := Change{Action: ChangeCreate, Kind: KindInstance, Name: "web",
+		Instance: InstanceSpec{Name: "web", Image: "ubuntu-24.04", Type: "fake.large-arm"}}
+	if _, err := ApplyChange(context.Background(), p, change); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.created) != 1 || p.created[0].Image != "ubuntu-24.04-arm64" {
+		t.Errorf("got %+v, want an instance of image ubuntu-24.04-arm64", p.created)
+	}
+}
+
+// noCreateProvider cannot create instances, like Azure, and records the
+// instances it terminates.
+type noCreateProvider struct {
+	listProvider
+	terminated []string
+}
+
+func (p *noCreateProvider) Name() string { return "fake" }
+
+func (p *noCreateProvider) CheckCreateInstance(spec InstanceSpec) error {
+	return ErrNotSupported
+}
+
+func (p *noCreateProvider) TerminateInstance(ctx context.Context, id string) error {
+	p.terminated = append(p.terminated, id)
+	return nil
+}
+
+// TestPlanUnsupportedCreate checks that plans fail when instances would be
+// created on a cloud that cannot create them, and that replacing one never
+// terminates the old instance first.
+func TestPlanUnsupportedCreate(t *testing.T) {
+	p := &noCreateProvider{listProvider: listProvider{instances: []Instance{
+		{ID: "rg/web", Name: "web", State: "running", Type: "Standard_B1s"},
+	}}}
+
+	for _, spec := range []InstanceSpec{{Name: "api"}, {Name: "web", Type: "Standard_B2s"}} {
+		_, err := PlanChanges(context.Background(), p, "demo", CloudSpec{Instances: []InstanceSpec{spec}})
+		if !errors.Is(err, ErrNotSupported) {
+			t.Errorf("%s: got %v, want ErrNotSupported", spec.Name, err)
+		}
+	}
+
+	change := Change{Action: ChangeReplace, Kind: KindInstance, Name: "web", Current: p.instances[0],
+		Instance: InstanceSpec{Name: "web", Type: "Standard_B2s"}}
+	if _, err := ApplyChange(context.Background(), p, change); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("got %v, want ErrNotSupported", err)
+	}
+	if len(p.terminated) > 0 {
+		t.Errorf("terminated %v before failing to create the new instance", p.terminated)
+	}
+}
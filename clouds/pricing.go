@@ -0,0 +1,125 @@
+package clouds
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+)
+
+// HoursPerMonth is the number of hours a month of running is billed for.
+const HoursPerMonth = 730
+
+// PriceCatalog holds the prices of each cloud by cloud name.
+type PriceCatalog map[string]CloudPrices
+
+// CloudPrices are the on-demand and spot prices of the instance types of a
+// cloud in a reference region, and the price of their boot disks, in US dollars.
+type CloudPrices struct {
+	Region string `json:"region"`
+
+	// DiskType is the type of boot disk instances get, such as gp3 on AWS,
+	// and DiskGiBMonth its price per GiB and month.
+	DiskType     string  `json:"disk_type"`
+	DiskGiBMonth float64 `json:"disk_gib_month"`
+
+	// DefaultDiskGiB is the boot disk size of instances created without one.
+	DefaultDiskGiB int `json:"default_disk_gib"`
+
+	// Instances are the hourly prices of Linux instances by type.
+	Instances map[string]InstancePrices `json:"instances"`
+}
+
+// InstancePrices are the hourly prices of an instance type. Spot is zero
+// when unknown.
+type InstancePrices struct {
+	OnDemand float64 `json:"on_demand"`
+	Spot     float64 `json:"spot,omitempty"`
+}
+
+//go:embed pricing.json
+var bundledPrices []byte
+
+// BundledPrices returns the pricing catalog shipped with the CLI.
+func BundledPrices() (PriceCatalog, error) {
+	catalog, err := ParsePriceCatalog(bundledPrices)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundled pricing catalog: %w", err)
+	}
+	return catalog, nil
+}
+
+// ParsePriceCatalog parses and checks a pricing catalog in the JSON format
+// of the bundled one.
+func ParsePriceCatalog(data []byte) (PriceCatalog, error) {
+	var catalog PriceCatalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, err
+	}
+	if len(catalog) == 0 {
+		return nil, fmt.Errorf("the catalog has no prices")
+	}
+	for cloud, prices := range catalog {
+		if prices.Region == "" {
+			return nil, fmt.Errorf("%s: region is required", cloud)
+		}
+		if prices.DiskGiBMonth < 0 || prices.DefaultDiskGiB < 0 {
+			return nil, fmt.Errorf("%s: invalid disk price", cloud)
+		}
+		for instanceType, price := range prices.Instances {
+			if price.OnDemand <= 0 || price.Spot < 0 {
+				return nil, fmt.Errorf("%s: invalid price of %s", cloud, instanceType)
+			}
+		}
+	}
+	return catalog, nil
+}
+
+// PriceSizes returns the sizes with their on-demand prices.
+func (c CloudPrices) PriceSizes(sizes []Size) []Size {
+	result := make([]Size, 0, len(sizes))
+	for _, size := range sizes {
+		size.HourlyPrice = c.Instances[size.Type].OnDemand
+		result = append(result, size)
+	}
+	return result
+}
+
+// Estimate is the cost of running an instance and its boot disk.
+type Estimate struct {
+	Type    string
+	DiskGiB int
+
+	// OnDemandHourly and SpotHourly are the hourly costs of the instance
+	// with its disk, on demand and as a spot instance. SpotHourly is zero
+	// when the spot price of the type is unknown.
+	OnDemandHourly float64
+	SpotHourly     float64
+}
+
+// Estimate returns the cost of an instance of a type with a boot disk of the
+// given size, or the default size when zero.
+func (c CloudPrices) Estimate(instanceType string, diskGiB int) (Estimate, error) {
+	price, ok := c.Instances[instanceType]
+	if !ok {
+		return Estimate{}, fmt.Errorf("price of %s: %w", instanceType, ErrNotFound)
+	}
+	if diskGiB == 0 {
+		diskGiB = c.DefaultDiskGiB
+	}
+
+	disk := float64(diskGiB) * c.DiskGiBMonth / HoursPerMonth
+	estimate := Estimate{Type: instanceType, DiskGiB: diskGiB, OnDemandHourly: price.OnDemand + disk}
+	if price.Spot > 0 {
+		estimate.SpotHourly = price.Spot + disk
+	}
+	return estimate, nil
+}
+
+// FormatCost formats an amount in US dollars, with cents, or more digits
+// for amounts under a dollar.
+func FormatCost(dollars float64) string {
+	if dollars < 1 {
+		return fmt.Sprintf("$%.4f", dollars)
+	}
+	return fmt.Sprintf("$%.2f", dollars)
+}
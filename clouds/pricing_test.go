@@ -0,0 +1,60 @@
+package clouds
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	prices := CloudPrices{
+		Region:         "us-east-1",
+		DiskType:       "gp3",
+		DiskGiBMonth:   0.073,
+		DefaultDiskGiB: 8,
+		Instances: map[string]InstancePrices{
+			"t3.small": {OnDemand: 0.02, Spot: 0.01},
+			"m5.large": {OnDemand: 0.1},
+		},
+	}
+
+	tests := []struct {
+		instanceType string
+		diskGiB      int
+		want         Estimate
+	}{
+		// A GiB-month of disk is 0.073 / 730 = 0.0001 per hour
+		{"t3.small", 0, Estimate{Type: "t3.small", DiskGiB: 8, OnDemandHourly: 0.0208, SpotHourly: 0.0108}},
+		{"t3.small", 100, Estimate{Type: "t3.small", DiskGiB: 100, OnDemandHourly: 0.03, SpotHourly: 0.02}},
+		{"m5.large", 10, Estimate{Type: "m5.large", DiskGiB: 10, OnDemandHourly: 0.101}},
+	}
+	for _, tt := range tests {
+		got, err := prices.Estimate(tt.instanceType, tt.diskGiB)
+		if err != nil {
+			t.Errorf("Estimate(%s, %d): %v", tt.instanceType, tt.diskGiB, err)
+			continue
+		}
+		if got.Type != tt.want.Type || got.DiskGiB != tt.want.DiskGiB ||
+			math.Abs(got.OnDemandHourly-tt.want.OnDemandHourly) > 1e-9 || math.Abs(got.SpotHourly-tt.want.SpotHourly) > 1e-9 {
+			t.Errorf("Estimate(%s, %d) = %+v, want %+v", tt.instanceType, tt.diskGiB, got, tt.want)
+		}
+	}
+
+	if _, err := prices.Estimate("p3.2xlarge", 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestParsePriceCatalog(t *testing.T) {
+	for _, data := range []string{
+		`{}`,
+		`{"aws": {"instances": {"t3.small": {"on_demand": 0.02}}}}`,
+		`{"aws": {"region": "us-east-1", "instances": {"t3.small": {"on_demand": 0}}}}`,
+		`{"aws": {"region": "us-east-1", "instances": {"t3.small": {"on_demand": 0.02, "spot": -1}}}}`,
+		`[]`,
+	} {
+		if _, err := ParsePriceCatalog([]byte(data)); err == nil {
+			t.Errorf("ParsePriceCatalog(%s) succeeded, want an error", data)
+		}
+	}
+}
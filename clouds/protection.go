@@ -0,0 +1,67 @@
+package clouds
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Protector is implemented by providers that can protect instances from
+// termination on the provider side: EC2 termination protection, GCP
+// deletion protection or an Azure delete lock.
+type Protector interface {
+	TerminationProtection(ctx context.Context, id string) (bool, error)
+	SetTerminationProtection(ctx context.Context, id string, enabled bool) error
+}
+
+// ProtectionRule protects the instances matching all of its filters from
+// some lifecycle actions, unless forced.
+type ProtectionRule struct {
+	Filters []Filter
+	Actions []InstanceAction
+}
+
+// ParseProtectionRule parses a protection rule from the configuration.
+// Rules without actions protect against termination.
+func ParseProtectionRule(filters, actions []string) (ProtectionRule, error) {
+	if len(filters) == 0 {
+		return ProtectionRule{}, fmt.Errorf("invalid protection rule: at least one filter is required")
+	}
+	parsed, err := ParseFilters(filters)
+	if err != nil {
+		return ProtectionRule{}, fmt.Errorf("invalid protection rule: %w", err)
+	}
+
+	rule := ProtectionRule{Filters: parsed}
+	for _, action := range actions {
+		switch a := InstanceAction(action); a {
+		case ActionStop, ActionReboot, ActionTerminate:
+			rule.Actions = append(rule.Actions, a)
+		default:
+			return ProtectionRule{}, fmt.Errorf("invalid protection rule: unknown action %q; use terminate, stop or reboot", action)
+		}
+	}
+	if len(rule.Actions) == 0 {
+		rule.Actions = []InstanceAction{ActionTerminate}
+	}
+	return rule, nil
+}
+
+// Protects reports whether the rule protects the instance from the action.
+func (r ProtectionRule) Protects(instance Instance, action InstanceAction) bool {
+	for _, a := range r.Actions {
+		if a == action {
+			return matchesAll(instance, r.Filters)
+		}
+	}
+	return false
+}
+
+// String returns the rule's filters, as written in the configuration.
+func (r ProtectionRule) String() string {
+	var filters []string
+	for _, filter := range r.Filters {
+		filters = append(filters, filter.Key+"="+filter.Value)
+	}
+	return strings.Join(filters, " ")
+}
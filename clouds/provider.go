@@ -0,0 +1,80 @@
+package clouds
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotSupported is returned by providers for operations they cannot perform.
+var ErrNotSupported = errors.New("not supported by this cloud provider")
+
+// ErrNotFound is returned when a resource does not exist.
+var ErrNotFound = errors.New("not found")
+
+// Instance is a provider-neutral view of a virtual machine.
+type Instance struct {
+	ID         string
+	Name       string
+	State      string
+	Type       string
+	Location   string
+	PublicIP   string
+	PrivateIP  string
+	LaunchTime time.Time
+
+	// KeyName is the key pair the instance was launched with, on clouds
+	// that record one.
+	KeyName string
+
+	// ResourceGroup is the resource group of an Azure VM. VM names are only
+	// unique within a resource group and GCP instance names within a zone,
+	// the Location of GCP instances, so IDs on those clouds are qualified
+	// with them.
+	ResourceGroup string
+
+	// Tags are the instance's tags, or labels on GCP.
+	Tags map[string]string
+}
+
+// CreateChecker is implemented by providers that cannot create every
+// instance. CheckCreateInstance returns the error CreateInstance would fail
+// with, such as ErrNotSupported, without changing anything.
+type CreateChecker interface {
+	CheckCreateInstance(spec InstanceSpec) error
+}
+
+// InstanceSpec describes an instance to create.
+type InstanceSpec struct {
+	Name    string `yaml:"name"`
+	Image   string `yaml:"image,omitempty"`
+	Type    string `yaml:"type,omitempty"`
+	KeyName string `yaml:"key_name,omitempty"`
+
+	// SecurityGroups are the IDs of the security groups to attach, on AWS.
+	SecurityGroups []string `yaml:"security_groups,omitempty"`
+
+	// DiskSizeGiB is the size of the boot disk, or zero for the size of the
+	// image. It only applies when the instance is created.
+	DiskSizeGiB int `yaml:"disk_size_gib,omitempty"`
+
+	// Tags are added to the default tags from the configuration.
+	Tags map[string]string `yaml:"tags,omitempty"`
+}
+
+// Provider is implemented by every supported cloud.
+type Provider interface {
+	// Name returns the short name of the cloud, such as "aws".
+	Name() string
+
+	ListInstances(ctx context.Context) ([]Instance, error)
+	DescribeInstance(ctx context.Context, id string) (Instance, error)
+	CreateInstance(ctx context.Context, spec InstanceSpec) (Instance, error)
+	StartInstance(ctx context.Context, id string) error
+	StopInstance(ctx context.Context, id string) error
+	RebootInstance(ctx context.Context, id string) error
+	TerminateInstance(ctx context.Context, id string) error
+
+	// Close releases any resources held by the provider's clients.
+	Close() error
+}
@@ -0,0 +1,235 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	awscloud "namaste-cloud/clouds/aws-cloud"
+	"namaste-cloud/internal"
+	"slices"
+	"strings"
+)
+
+// Drift is how a recorded resource differs from the live one.
+type Drift struct {
+	Record internal.StateRecord
+
+	// Deleted is set when the resource no longer exists.
+	Deleted bool
+
+	// Details describe what changed, from the recorded value to the live one.
+	Details []string
+}
+
+// DetectDrift compares records of resources in the cloud, region and profile
+// of a provider with the live resources, and returns those that differ.
+// Resources are listed rather than fetched one at a time, so that a resource
+// deleted outside the CLI is told apart from a call that failed.
+func DetectDrift(ctx context.Context, p clouds.Provider, records []internal.StateRecord) ([]Drift, error) {
+	live, err := listLive(ctx, p, records)
+	if err != nil {
+		return nil, err
+	}
+
+	var drifts []Drift
+	for _, record := range records {
+		var details []string
+		found := false
+		switch record.Kind {
+		case clouds.KindInstance:
+			var instance clouds.Instance
+			instance, found = live.instances[record.ID]
+			found = found && !clouds.IsTerminated(instance)
+			details = instanceDrift(record, instance)
+		case clouds.KindFirewallRule:
+			var rule clouds.FirewallRule
+			rule, found = live.firewallRules[record.ID]
+			details = clouds.DiffFirewallRules(recordedFirewallRule(record), rule.WithDefaults())
+		case clouds.KindKeyPair:
+			var key clouds.KeyPair
+			key, found = live.keyPairs[record.ID]
+			if recorded := record.Attributes["fingerprint"]; recorded != "" && recorded != key.Fingerprint {
+				details = append(details, fmt.Sprintf("fingerprint: %s -> %s", recorded, orNone(key.Fingerprint)))
+			}
+		case KindSecurityGroup:
+			var group awscloud.SecurityGroup
+			group, found = live.securityGroups[record.ID]
+			liveRecord := SecurityGroupRecord(p.Name(), group)
+			for _, direction := range []string{"ingress", "egress"} {
+				details = append(details, ruleDrift(record, liveRecord, direction)...)
+			}
+		default:
+			continue
+		}
+
+		if !found {
+			drifts = append(drifts, Drift{Record: record, Deleted: true})
+		} else if len(details) > 0 {
+			drifts = append(drifts, Drift{Record: record, Details: details})
+		}
+	}
+	return drifts, nil
+}
+
+// liveResources are the live resources of the kinds being compared, by ID.
+type liveResources struct {
+	instances      map[string]clouds.Instance
+	firewallRules  map[string]clouds.FirewallRule
+	keyPairs       map[string]clouds.KeyPair
+	securityGroups map[string]awscloud.SecurityGroup
+}
+
+// listLive lists the live resources of the kinds of the records.
+func listLive(ctx context.Context, p clouds.Provider, records []internal.StateRecord) (liveResources, error) {
+	kinds := make(map[string]bool)
+	for _, record := range records {
+		kinds[record.Kind] = true
+	}
+
+	live := liveResources{
+		instances:      make(map[string]clouds.Instance),
+		firewallRules:  make(map[string]clouds.FirewallRule),
+		keyPairs:       make(map[string]clouds.KeyPair),
+		securityGroups: make(map[string]awscloud.SecurityGroup),
+	}
+	if kinds[clouds.KindInstance] {
+		instances, err := p.ListInstances(ctx)
+		if err != nil {
+			return live, err
+		}
+		for _, instance := range instances {
+			live.instances[instance.ID] = instance
+		}
+	}
+	if kinds[clouds.KindFirewallRule] {
+		firewall, ok := p.(clouds.Firewall)
+		if !ok {
+			return live, fmt.Errorf("firewall rules on %s: %w", p.Name(), clouds.ErrNotSupported)
+		}
+		rules, err := firewall.ListFirewallRules(ctx)
+		if err != nil {
+			return live, err
+		}
+		for _, rule := range rules {
+			live.firewallRules[rule.Name] = rule
+		}
+	}
+	if kinds[clouds.KindKeyPair] {
+		keyPairs, ok := p.(clouds.KeyPairs)
+		if !ok {
+			return live, fmt.Errorf("key pairs on %s: %w", p.Name(), clouds.ErrNotSupported)
+		}
+		keys, err := keyPairs.ListKeyPairs(ctx)
+		if err != nil {
+			return live, err
+		}
+		for _, key := range keys {
+			live.keyPairs[key.Name] = key
+		}
+	}
+	if kinds[KindSecurityGroup] {
+		aws, ok := p.(*awscloud.Provider)
+		if !ok {
+			return live, fmt.Errorf("security groups on %s: %w", p.Name(), clouds.ErrNotSupported)
+		}
+		groups, err := aws.ListSecurityGroups(ctx)
+		if err != nil {
+			return live, err
+		}
+		for _, group := range groups {
+			live.securityGroups[group.ID] = group
+		}
+	}
+	return live, nil
+}
+
+// instanceDrift describes how the type, key pair and tags of an instance
+// differ from its record. Tags reserved by AWS, which it adds itself, are
+// not reported.
+func instanceDrift(record internal.StateRecord, instance clouds.Instance) []string {
+	var details []string
+	if recorded := record.Attributes["type"]; recorded != "" && instance.Type != "" && recorded != instance.Type {
+		details = append(details, fmt.Sprintf("type: %s -> %s", recorded, instance.Type))
+	}
+	if recorded := record.Attributes["key_name"]; recorded != "" && recorded != instance.KeyName {
+		details = append(details, fmt.Sprintf("key pair: %s -> %s", recorded, orNone(instance.KeyName)))
+	}
+
+	tags := make(map[string]string)
+	for key, value := range record.Attributes {
+		if tag, ok := strings.CutPrefix(key, "tag:"); ok {
+			tags[tag] = value
+		}
+	}
+	for _, key := range clouds.SortedKeys(clouds.MergeTags(tags, instance.Tags)) {
+		recorded, wasRecorded := tags[key]
+		value, isLive := instance.Tags[key]
+		switch {
+		case !isLive:
+			details = append(details, fmt.Sprintf("tag %s removed (was %q)", key, recorded))
+		case !wasRecorded && !strings.HasPrefix(key, "aws:"):
+			details = append(details, fmt.Sprintf("tag %s added: %q", key, value))
+		case wasRecorded && recorded != value:
+			details = append(details, fmt.Sprintf("tag %s: %q -> %q", key, recorded, value))
+		}
+	}
+	return details
+}
+
+// recordedFirewallRule rebuilds a firewall rule from its record.
+func recordedFirewallRule(record internal.StateRecord) clouds.FirewallRule {
+	list := func(name string) []string {
+		if record.Attributes[name] == "" {
+			return nil
+		}
+		return strings.Split(record.Attributes[name], ",")
+	}
+	return clouds.FirewallRule{
+		Name:         record.ID,
+		Description:  record.Attributes["description"],
+		Direction:    record.Attributes["direction"],
+		Protocol:     record.Attributes["protocol"],
+		Ports:        list("ports"),
+		Sources:      list("sources"),
+		Destinations: list("destinations"),
+		TargetTags:   list("target_tags"),
+	}
+}
+
+// ruleDrift lists the security group rules added and removed in a
+// direction. Groups recorded without their rules are not compared.
+func ruleDrift(recorded, live internal.StateRecord, direction string) []string {
+	if _, ok := recorded.Attributes[direction]; !ok {
+		return nil
+	}
+	recordedRules, liveRules := splitRules(recorded.Attributes[direction]), splitRules(live.Attributes[direction])
+
+	var details []string
+	for _, rule := range recordedRules {
+		if !slices.Contains(liveRules, rule) {
+			details = append(details, fmt.Sprintf("%s rule removed: %s", direction, rule))
+		}
+	}
+	for _, rule := range liveRules {
+		if !slices.Contains(recordedRules, rule) {
+			details = append(details, fmt.Sprintf("%s rule added: %s", direction, rule))
+		}
+	}
+	return details
+}
+
+// splitRules splits rules joined by formatRules.
+func splitRules(rules string) []string {
+	if rules == "" {
+		return nil
+	}
+	return strings.Split(rules, "; ")
+}
+
+// orNone returns the value, or "(none)" when it is empty.
+func orNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
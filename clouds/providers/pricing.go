@@ -0,0 +1,152 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"namaste-cloud/clouds"
+	awscloud "namaste-cloud/clouds/aws-cloud"
+	fakecloud "namaste-cloud/clouds/fake-cloud"
+	gcpcloud "namaste-cloud/clouds/gcp-cloud"
+	"namaste-cloud/internal"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CachedPrices is a pricing catalog as last refreshed.
+type CachedPrices struct {
+	Source      string              `json:"source"`
+	RefreshedAt time.Time           `json:"refreshed_at"`
+	Prices      clouds.PriceCatalog `json:"prices"`
+}
+
+// priceCachePath returns the file the pricing catalog is cached in.
+func priceCachePath() (string, error) {
+	configDir, err := internal.GetUserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "pricing.json"), nil
+}
+
+// Prices returns the pricing catalog cached by the last refresh, or else the
+// bundled one, so that estimates work offline. Clouds missing from the cached
+// catalog keep their bundled prices. The refresh time is zero for the
+// bundled catalog.
+func Prices() (clouds.PriceCatalog, time.Time, error) {
+	bundled, err := clouds.BundledPrices()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	path, err := priceCachePath()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return bundled, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var cached CachedPrices
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, time.Time{}, fmt.Errorf("invalid pricing cache %s: %w; refresh it with `cost refresh`", path, err)
+	}
+	for cloud, prices := range cached.Prices {
+		bundled[cloud] = prices
+	}
+	return bundled, cached.RefreshedAt, nil
+}
+
+// RefreshPrices downloads a pricing catalog, in the JSON format of the
+// bundled one, from a URL or reads it from a file, and caches it for Prices.
+func RefreshPrices(ctx context.Context, cfg internal.Config, source string) (clouds.PriceCatalog, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://") {
+		data, err = download(ctx, cfg, source)
+	} else {
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the pricing catalog: %w", err)
+	}
+	prices, err := clouds.ParsePriceCatalog(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pricing catalog %s: %w", source, err)
+	}
+
+	cached, err := json.MarshalIndent(CachedPrices{Source: source, RefreshedAt: time.Now().UTC(), Prices: prices}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := internal.EnsureConfigDir(); err != nil {
+		return nil, err
+	}
+	path, err := priceCachePath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, cached, 0644); err != nil {
+		return nil, fmt.Errorf("failed to cache the pricing catalog: %w", err)
+	}
+	return prices, nil
+}
+
+// download fetches a URL.
+func download(ctx context.Context, cfg internal.Config, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	op := internal.StartOperation(fmt.Sprintf("download %s", url))
+	resp, err := internal.NewHTTPClient(cfg.SkipsTLSVerify()).Do(req)
+	if err := op.Finish(err); err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// DefaultInstanceType returns the type a cloud's provider creates instances
+// of when a spec names none, or "" when it cannot create instances.
+func DefaultInstanceType(cloud string) string {
+	switch cloud {
+	case "aws":
+		return awscloud.DefaultInstanceType
+	case "gcp":
+		return gcpcloud.DefaultMachineType
+	case "fake":
+		return fakecloud.DefaultInstanceType
+	default:
+		return ""
+	}
+}
+
+// EstimateInstance estimates the cost of an instance of a cloud from a
+// pricing catalog, for the type its provider defaults to when the spec
+// names none.
+func EstimateInstance(prices clouds.PriceCatalog, cloud string, spec clouds.InstanceSpec) (clouds.Estimate, error) {
+	cloudPrices, ok := prices[cloud]
+	if !ok {
+		return clouds.Estimate{}, fmt.Errorf("prices for %s: %w", cloud, clouds.ErrNotFound)
+	}
+	instanceType := spec.Type
+	if instanceType == "" {
+		instanceType = DefaultInstanceType(cloud)
+	}
+	if instanceType == "" {
+		return clouds.Estimate{}, fmt.Errorf("no instance type given")
+	}
+	return cloudPrices.Estimate(instanceType, spec.DiskSizeGiB)
+}
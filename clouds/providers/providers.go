@@ -0,0 +1,103 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"namaste-cloud/clouds"
+	awscloud "namaste-cloud/clouds/aws-cloud"
+	azurecloud "namaste-cloud/clouds/azure-cloud"
+	fakecloud "namaste-cloud/clouds/fake-cloud"
+	gcpcloud "namaste-cloud/clouds/gcp-cloud"
+	"namaste-cloud/internal"
+)
+
+// Names lists the supported cloud providers.
+var Names = []string{"aws", "gcp", "azure", "fake"}
+
+// ErrNoActiveCloud is returned when no cloud provider has been selected.
+var ErrNoActiveCloud = errors.New("no active cloud provider set. Please use `namaste-cloud use-cloud` to select one")
+
+// IsSupported reports whether name is a supported cloud provider.
+func IsSupported(name string) bool {
+	for _, n := range Names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckCreateInstance returns the error that creating any instance on the
+// cloud fails with, or nil, for checks that need no provider.
+func CheckCreateInstance(name string) error {
+	if name == "azure" {
+		return azurecloud.ErrCreateInstance
+	}
+	return nil
+}
+
+// RequiresCredentials reports whether the cloud provider needs stored credentials.
+func RequiresCredentials(name string) bool {
+	return name != "fake"
+}
+
+// New creates the provider for the active cloud of the given configuration.
+func New(ctx context.Context, cfg internal.Config) (clouds.Provider, error) {
+	switch cfg.ActiveCloud {
+	case "aws":
+		return awscloud.New(ctx, cfg)
+	case "gcp":
+		return gcpcloud.New(ctx, cfg)
+	case "azure":
+		return azurecloud.New(ctx, cfg)
+	case "fake":
+		return fakecloud.New(cfg)
+	case "":
+		return nil, ErrNoActiveCloud
+	default:
+		return nil, fmt.Errorf("unsupported cloud provider %q. Please use `namaste-cloud use-cloud` to select a supported cloud provider", cfg.ActiveCloud)
+	}
+}
+
+// Load loads the effective configuration and creates the provider for its active cloud.
+func Load(ctx context.Context) (clouds.Provider, error) {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error loading configuration: %w", err)
+	}
+	return New(ctx, cfg)
+}
+
+// ProtectionRules parses the protection rules of a configuration.
+func ProtectionRules(cfg internal.Config) ([]clouds.ProtectionRule, error) {
+	var rules []clouds.ProtectionRule
+	for _, configured := range cfg.Protection {
+		rule, err := clouds.ParseProtectionRule(configured.Filters, configured.Actions)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// TemplateSpec returns the instance spec of the variant of a template from
+// the configuration for a cloud.
+func TemplateSpec(cfg internal.Config, name, cloud string) (clouds.InstanceSpec, error) {
+	variants, ok := cfg.Templates[name]
+	if !ok {
+		return clouds.InstanceSpec{}, fmt.Errorf("template %s: %w", name, clouds.ErrNotFound)
+	}
+	variant, ok := variants[cloud]
+	if !ok {
+		return clouds.InstanceSpec{}, fmt.Errorf("template %s has no variant for %s; add one with `template create %s --cloud %s`", name, cloud, name, cloud)
+	}
+	return clouds.InstanceSpec{
+		Image:          variant.Image,
+		Type:           variant.Type,
+		KeyName:        variant.KeyName,
+		SecurityGroups: variant.SecurityGroups,
+		Tags:           variant.Tags,
+	}, nil
+}
@@ -0,0 +1,108 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CachedSizes is the size catalog of a cloud as last refreshed from its API.
+type CachedSizes struct {
+	clouds.SizeCatalog
+	RefreshedAt time.Time `json:"refreshed_at"`
+}
+
+// sizeCachePath returns the file the size catalog of a cloud is cached in.
+func sizeCachePath(cloud string) (string, error) {
+	configDir, err := internal.GetUserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "sizes-"+cloud+".json"), nil
+}
+
+// Sizes returns the size catalog of a cloud, the one cached by the last
+// refresh or else the bundled one, with on-demand prices from the pricing
+// catalog. The refresh time is zero for the bundled catalog.
+func Sizes(cloud string) (clouds.SizeCatalog, time.Time, error) {
+	bundled, err := clouds.BundledSizes(cloud)
+	if err != nil {
+		return clouds.SizeCatalog{}, time.Time{}, err
+	}
+	prices, _, err := Prices()
+	if err != nil {
+		return clouds.SizeCatalog{}, time.Time{}, err
+	}
+	path, err := sizeCachePath(cloud)
+	if err != nil {
+		return clouds.SizeCatalog{}, time.Time{}, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return clouds.SizeCatalog{Sizes: prices[cloud].PriceSizes(bundled)}, time.Time{}, nil
+	}
+	if err != nil {
+		return clouds.SizeCatalog{}, time.Time{}, err
+	}
+
+	var cached CachedSizes
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return clouds.SizeCatalog{}, time.Time{}, fmt.Errorf("invalid size cache %s: %w; refresh it with `sizes refresh`", path, err)
+	}
+	cached.Sizes = prices[cloud].PriceSizes(clouds.MergeSizes(cached.Sizes, bundled))
+	return cached.SizeCatalog, cached.RefreshedAt, nil
+}
+
+// RefreshSizes lists the sizes the provider offers in its region and caches
+// them for Sizes.
+func RefreshSizes(ctx context.Context, p clouds.Provider, region string) (clouds.SizeCatalog, error) {
+	lister, ok := p.(clouds.SizeLister)
+	if !ok {
+		return clouds.SizeCatalog{}, fmt.Errorf("listing sizes on %s: %w", p.Name(), clouds.ErrNotSupported)
+	}
+	sizes, err := lister.ListSizes(ctx)
+	if err != nil {
+		return clouds.SizeCatalog{}, err
+	}
+	clouds.SortSizes(sizes)
+
+	cached := CachedSizes{SizeCatalog: clouds.SizeCatalog{Region: region, Sizes: sizes}, RefreshedAt: time.Now().UTC()}
+	data, err := json.MarshalIndent(cached, "", "  ")
+	if err != nil {
+		return clouds.SizeCatalog{}, err
+	}
+	if err := internal.EnsureConfigDir(); err != nil {
+		return clouds.SizeCatalog{}, err
+	}
+	path, err := sizeCachePath(p.Name())
+	if err != nil {
+		return clouds.SizeCatalog{}, err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return clouds.SizeCatalog{}, fmt.Errorf("failed to cache the sizes: %w", err)
+	}
+
+	catalog, _, err := Sizes(p.Name())
+	return catalog, err
+}
+
+// SizeArch returns the architecture of an instance type in the size catalog
+// of a cloud, or "" when it is not in the catalog.
+func SizeArch(cloud, instanceType string) string {
+	catalog, _, err := Sizes(cloud)
+	if err != nil {
+		return ""
+	}
+	for _, size := range catalog.Sizes {
+		if size.Type == instanceType {
+			return size.Arch
+		}
+	}
+	return ""
+}
@@ -0,0 +1,229 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"namaste-cloud/clouds"
+	awscloud "namaste-cloud/clouds/aws-cloud"
+	"namaste-cloud/internal"
+	"slices"
+	"strings"
+	"time"
+)
+
+// OpenState returns the state backend of a configuration.
+func OpenState(ctx context.Context, cfg internal.Config) (internal.StateBackend, error) {
+	switch cfg.State.Backend {
+	case "", "file":
+		return internal.NewFileStateBackend(cfg.State.Path)
+	case "s3":
+		return awscloud.NewS3StateBackend(ctx, cfg)
+	default:
+		return nil, fmt.Errorf("unknown state backend %q; use file or s3", cfg.State.Backend)
+	}
+}
+
+// Record records resources the CLI created or changed, replacing earlier
+// records of the same resources but keeping who created them and when. The
+// cloud, region, profile and project default to those of the configuration.
+func Record(ctx context.Context, records ...internal.StateRecord) error {
+	cfg, backend, err := loadState(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	creator := internal.Creator()
+	for i := range records {
+		records[i] = withDefaults(records[i], cfg)
+		records[i].CreatedBy = creator
+		records[i].CreatedAt = now
+	}
+	return internal.UpdateState(ctx, backend, func(state *internal.State) error {
+		for _, record := range records {
+			if i := slices.IndexFunc(state.Resources, record.Same); i >= 0 {
+				record.CreatedBy = state.Resources[i].CreatedBy
+				record.CreatedAt = state.Resources[i].CreatedAt
+				state.Resources[i] = record
+				continue
+			}
+			state.Resources = append(state.Resources, record)
+		}
+		return nil
+	})
+}
+
+// Forget removes the records of resources the CLI deleted. The cloud, region,
+// profile and project default to those of the configuration.
+func Forget(ctx context.Context, records ...internal.StateRecord) error {
+	cfg, backend, err := loadState(ctx)
+	if err != nil {
+		return err
+	}
+
+	return internal.UpdateState(ctx, backend, func(state *internal.State) error {
+		for _, record := range records {
+			state.Resources = slices.DeleteFunc(state.Resources, withDefaults(record, cfg).Same)
+		}
+		return nil
+	})
+}
+
+// RecordTags updates the recorded tags of an instance after the CLI tagged or
+// untagged it, so that drift does not report the change. Tags are recorded as
+// the provider stores them. Instances that are not recorded are left alone.
+func RecordTags(ctx context.Context, p clouds.Provider, instanceID string, added map[string]string, removed []string) error {
+	added, err := clouds.NormalizeTags(p, added)
+	if err != nil {
+		return err
+	}
+	removedTags := make(map[string]string, len(removed))
+	for _, key := range removed {
+		removedTags[key] = ""
+	}
+	removedTags, err = clouds.NormalizeTags(p, removedTags)
+	if err != nil {
+		return err
+	}
+
+	cfg, backend, err := loadState(ctx)
+	if err != nil {
+		return err
+	}
+	target := withDefaults(internal.StateRecord{Cloud: p.Name(), Kind: clouds.KindInstance, ID: instanceID}, cfg)
+	return internal.UpdateState(ctx, backend, func(state *internal.State) error {
+		i := slices.IndexFunc(state.Resources, target.Same)
+		if i < 0 {
+			return nil
+		}
+		attributes := maps.Clone(state.Resources[i].Attributes)
+		if attributes == nil {
+			attributes = make(map[string]string)
+		}
+		for key := range removedTags {
+			delete(attributes, "tag:"+key)
+		}
+		for key, value := range added {
+			attributes["tag:"+key] = value
+		}
+		state.Resources[i].Attributes = attributes
+		return nil
+	})
+}
+
+// loadState loads the effective configuration and opens its state backend.
+func loadState(ctx context.Context) (internal.Config, internal.StateBackend, error) {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return internal.Config{}, nil, fmt.Errorf("error loading configuration: %w", err)
+	}
+	backend, err := OpenState(ctx, cfg)
+	if err != nil {
+		return internal.Config{}, nil, err
+	}
+	return cfg, backend, nil
+}
+
+// withDefaults fills in the cloud, region, profile and project of a record
+// from the configuration.
+func withDefaults(record internal.StateRecord, cfg internal.Config) internal.StateRecord {
+	if record.Cloud == "" {
+		record.Cloud = cfg.ActiveCloud
+	}
+	if record.Region == "" {
+		record.Region = cfg.Region
+	}
+	if record.Profile == "" {
+		record.Profile = cfg.Profile
+	}
+	if record.Project == "" {
+		record.Project = cfg.Project
+	}
+	return record
+}
+
+// KindSecurityGroup is the kind of the records of EC2 security groups.
+const KindSecurityGroup = "security group"
+
+// SecurityGroupRecord returns the record of an EC2 security group and its
+// rules. The rules of each direction are recorded sorted and joined with
+// semicolons.
+func SecurityGroupRecord(cloud string, group awscloud.SecurityGroup) internal.StateRecord {
+	attributes := map[string]string{
+		"ingress": formatRules(group.Ingress),
+		"egress":  formatRules(group.Egress),
+	}
+	if group.VPCID != "" {
+		attributes["vpc"] = group.VPCID
+	}
+	return internal.StateRecord{Cloud: cloud, Kind: KindSecurityGroup, ID: group.ID, Name: group.Name, Attributes: attributes}
+}
+
+// formatRules writes each security group rule as "PROTOCOL FROM-TO PEERS",
+// e.g. "tcp 22-22 10.0.0.0/8,sg-0123", sorted and joined with semicolons.
+func formatRules(rules []awscloud.Rule) string {
+	var formatted []string
+	for _, rule := range rules {
+		protocol := rule.Protocol
+		if protocol == "-1" {
+			protocol = "all"
+		}
+		peers := append(slices.Clone(rule.CIDRs), rule.SourceGroups...)
+		slices.Sort(peers)
+		formatted = append(formatted, fmt.Sprintf("%s %d-%d %s", protocol, rule.FromPort, rule.ToPort, strings.Join(peers, ",")))
+	}
+	slices.Sort(formatted)
+	return strings.Join(formatted, "; ")
+}
+
+// InstanceRecord returns the record of an instance created from a spec.
+func InstanceRecord(cloud string, instance clouds.Instance, spec clouds.InstanceSpec) internal.StateRecord {
+	attributes := map[string]string{"type": instance.Type}
+	if spec.Image != "" {
+		attributes["image"] = spec.Image
+	}
+	if instance.KeyName != "" {
+		attributes["key_name"] = instance.KeyName
+	}
+	for key, value := range instance.Tags {
+		attributes["tag:"+key] = value
+	}
+	return internal.StateRecord{Cloud: cloud, Kind: clouds.KindInstance, ID: instance.ID, Name: instance.Name, Attributes: attributes}
+}
+
+// FirewallRuleRecord returns the record of a firewall rule. Lists are
+// recorded sorted and joined with commas.
+func FirewallRuleRecord(cloud string, rule clouds.FirewallRule) internal.StateRecord {
+	rule = rule.WithDefaults()
+	attributes := map[string]string{
+		"direction": rule.Direction,
+		"protocol":  rule.Protocol,
+	}
+	if rule.Description != "" {
+		attributes["description"] = rule.Description
+	}
+	lists := map[string][]string{
+		"ports":        rule.Ports,
+		"sources":      rule.Sources,
+		"destinations": rule.Destinations,
+		"target_tags":  rule.TargetTags,
+	}
+	for name, values := range lists {
+		if len(values) > 0 {
+			sorted := slices.Clone(values)
+			slices.Sort(sorted)
+			attributes[name] = strings.Join(sorted, ",")
+		}
+	}
+	return internal.StateRecord{Cloud: cloud, Kind: clouds.KindFirewallRule, ID: rule.Name, Name: rule.Name, Attributes: attributes}
+}
+
+// KeyPairRecord returns the record of a key pair.
+func KeyPairRecord(cloud string, key clouds.KeyPair) internal.StateRecord {
+	attributes := map[string]string{"fingerprint": key.Fingerprint}
+	if key.User != "" {
+		attributes["user"] = key.User
+	}
+	return internal.StateRecord{Cloud: cloud, Kind: clouds.KindKeyPair, ID: key.Name, Name: key.Name, Attributes: attributes}
+}
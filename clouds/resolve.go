@@ -0,0 +1,71 @@
+package clouds
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// FindInstance returns the instance that ref refers to: an instance ID, a
+// name (the Name tag on AWS, the instance or VM name elsewhere) or a tag
+// selector of the form tag:KEY=VALUE. IDs are qualified with the zone on
+// GCP and the resource group on Azure, such as us-central1-a/web. IDs take
+// precedence over names. A reference that matches several instances is an
+// error listing them rather than a guess. Terminated instances are only
+// matched by ID.
+func FindInstance(ctx context.Context, p Provider, ref string) (Instance, error) {
+	var selector *Filter
+	if strings.HasPrefix(ref, "tag:") {
+		filter, err := ParseFilter(ref)
+		if err != nil {
+			return Instance{}, err
+		}
+		selector = &filter
+	}
+
+	instances, err := p.ListInstances(ctx)
+	if err != nil {
+		return Instance{}, err
+	}
+
+	var byID, matches []Instance
+	for _, instance := range instances {
+		if selector == nil && instance.ID == ref {
+			byID = append(byID, instance)
+		}
+		if IsTerminated(instance) {
+			continue
+		}
+		if (selector == nil && instance.Name == ref) || (selector != nil && selector.Matches(instance)) {
+			matches = append(matches, instance)
+		}
+	}
+	if len(byID) > 0 {
+		matches = byID
+	}
+
+	switch len(matches) {
+	case 0:
+		return Instance{}, fmt.Errorf("instance %s: %w", ref, ErrNotFound)
+	case 1:
+		return matches[0], nil
+	}
+	var found []string
+	for _, instance := range matches {
+		if instance.Name != "" {
+			found = append(found, fmt.Sprintf("%s (%s)", instance.ID, instance.Name))
+		} else {
+			found = append(found, instance.ID)
+		}
+	}
+	return Instance{}, fmt.Errorf("%s is ambiguous: it matches %s; use an instance ID", ref, strings.Join(found, ", "))
+}
+
+// IsTerminated reports whether an instance has been, or is being, deleted.
+func IsTerminated(instance Instance) bool {
+	switch strings.ToLower(instance.State) {
+	case "terminated", "shutting-down", "deleting":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package clouds
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// listProvider is a provider that only lists a fixed set of instances.
+type listProvider struct {
+	Provider
+	instances []Instance
+}
+
+func (p listProvider) ListInstances(ctx context.Context) ([]Instance, error) {
+	return p.instances, nil
+}
+
+func TestFindInstance(t *testing.T) {
+	p := listProvider{instances: []Instance{
+		{ID: "i-1", Name: "web", State: "running", Tags: map[string]string{"role": "web"}},
+		{ID: "i-2", Name: "db", State: "running", Tags: map[string]string{"role": "db"}},
+		{ID: "i-3", Name: "db", State: "running", Tags: map[string]string{"role": "db"}},
+		{ID: "i-4", Name: "api", State: "terminated", Tags: map[string]string{"role": "api"}},
+		{ID: "i-5", Name: "api", State: "running", Tags: map[string]string{"role": "api"}},
+		{ID: "web", Name: "other", State: "running"},
+		{ID: "us-central1-a/batch", Name: "batch", State: "running", Location: "us-central1-a"},
+		{ID: "europe-west1-b/batch", Name: "batch", State: "running", Location: "europe-west1-b"},
+	}}
+
+	tests := []struct {
+		ref    string
+		wantID string
+		err    string
+	}{
+		{"i-2", "i-2", ""},
+		{"web", "web", ""}, // IDs take precedence over names
+		{"api", "i-5", ""}, // terminated instances are not matched by name
+		{"db", "", "ambiguous: it matches i-2 (db), i-3 (db)"},
+		{"batch", "", "ambiguous: it matches us-central1-a/batch (batch), europe-west1-b/batch (batch)"},
+		{"europe-west1-b/batch", "europe-west1-b/batch", ""}, // zone-qualified IDs tell them apart
+		{"tag:role=web", "i-1", ""},
+		{"tag:role=api", "i-5", ""}, // nor by tag
+		{"tag:role=d*", "", "tag:role=d* is ambiguous"},
+		{"tag:role=cache", "", "not found"},
+		{"tag:=web", "", "invalid filter"},
+		{"cache", "", "not found"},
+	}
+	for _, tt := range tests {
+		instance, err := FindInstance(context.Background(), p, tt.ref)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", tt.ref, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: got error %v, want one containing %q", tt.ref, err, tt.err)
+		case instance.ID != tt.wantID:
+			t.Errorf("%s: got instance %s, want %s", tt.ref, instance.ID, tt.wantID)
+		}
+	}
+
+	if _, err := FindInstance(context.Background(), p, "cache"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
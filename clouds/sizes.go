@@ -0,0 +1,191 @@
+package clouds
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Architectures of instance sizes, named as on EC2.
+const (
+	ArchX86 = "x86_64"
+	ArchARM = "arm64"
+)
+
+// Size is an instance or machine type with the resources it provides.
+type Size struct {
+	Type      string  `json:"type"`
+	CPUs      int     `json:"cpus"`
+	MemoryGiB float64 `json:"memory_gib"`
+	GPUs      int     `json:"gpus,omitempty"`
+	Arch      string  `json:"arch,omitempty"`
+
+	// HourlyPrice is the on-demand Linux price in US dollars from the
+	// pricing catalog, or zero when unknown.
+	HourlyPrice float64 `json:"hourly_price,omitempty"`
+}
+
+// SizeLister is implemented by providers that can list the instance sizes
+// they offer in the configured region or zone.
+type SizeLister interface {
+	ListSizes(ctx context.Context) ([]Size, error)
+}
+
+// SizeQuery is an abstract size: the least resources an instance needs.
+type SizeQuery struct {
+	CPUs      int
+	MemoryGiB float64
+	GPUs      int
+
+	// Arch limits the sizes to one architecture, when set.
+	Arch string
+}
+
+// ParseSize parses an abstract size written as CPUSxMEMORY or
+// CPUSxMEMORYxGPUS, with the memory in GiB, such as 4x16 or 8x32x1.
+func ParseSize(size string) (SizeQuery, error) {
+	parts := strings.Split(size, "x")
+	if len(parts) < 2 || len(parts) > 3 {
+		return SizeQuery{}, fmt.Errorf("invalid size %q: use CPUSxMEMORY or CPUSxMEMORYxGPUS, e.g. 4x16", size)
+	}
+	cpus, err := strconv.Atoi(parts[0])
+	if err != nil || cpus < 1 {
+		return SizeQuery{}, fmt.Errorf("invalid size %q: %q is not a number of vCPUs", size, parts[0])
+	}
+	memory, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil || memory <= 0 {
+		return SizeQuery{}, fmt.Errorf("invalid size %q: %q is not an amount of memory in GiB", size, parts[1])
+	}
+	query := SizeQuery{CPUs: cpus, MemoryGiB: memory}
+	if len(parts) == 3 {
+		query.GPUs, err = strconv.Atoi(parts[2])
+		if err != nil || query.GPUs < 0 {
+			return SizeQuery{}, fmt.Errorf("invalid size %q: %q is not a number of GPUs", size, parts[2])
+		}
+	}
+	return query, nil
+}
+
+// Matches reports whether a size provides at least the resources of the query.
+func (q SizeQuery) Matches(size Size) bool {
+	return size.CPUs >= q.CPUs && size.MemoryGiB >= q.MemoryGiB && size.GPUs >= q.GPUs &&
+		(q.Arch == "" || size.Arch == q.Arch)
+}
+
+// String describes the query, e.g. "4 vCPUs, 16 GiB".
+func (q SizeQuery) String() string {
+	var parts []string
+	if q.CPUs > 0 {
+		parts = append(parts, fmt.Sprintf("%d vCPUs", q.CPUs))
+	}
+	if q.MemoryGiB > 0 {
+		parts = append(parts, FormatGiB(q.MemoryGiB))
+	}
+	if q.GPUs > 0 {
+		parts = append(parts, fmt.Sprintf("%d GPUs", q.GPUs))
+	}
+	if q.Arch != "" {
+		parts = append(parts, q.Arch)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// MatchingSizes returns the sizes matching the query, cheapest first.
+func MatchingSizes(sizes []Size, query SizeQuery) []Size {
+	var matching []Size
+	for _, size := range sizes {
+		if query.Matches(size) {
+			matching = append(matching, size)
+		}
+	}
+	SortSizes(matching)
+	return matching
+}
+
+// CheapestSize returns the cheapest size matching the query.
+func CheapestSize(sizes []Size, query SizeQuery) (Size, error) {
+	matching := MatchingSizes(sizes, query)
+	if len(matching) == 0 {
+		return Size{}, fmt.Errorf("size with at least %s: %w", query, ErrNotFound)
+	}
+	return matching[0], nil
+}
+
+// SortSizes sorts sizes cheapest first. Sizes of unknown price come after
+// the priced ones, smallest first.
+func SortSizes(sizes []Size) {
+	sort.SliceStable(sizes, func(i, j int) bool {
+		a, b := sizes[i], sizes[j]
+		if (a.HourlyPrice > 0) != (b.HourlyPrice > 0) {
+			return a.HourlyPrice > 0
+		}
+		if a.HourlyPrice != b.HourlyPrice {
+			return a.HourlyPrice < b.HourlyPrice
+		}
+		if a.CPUs != b.CPUs {
+			return a.CPUs < b.CPUs
+		}
+		if a.MemoryGiB != b.MemoryGiB {
+			return a.MemoryGiB < b.MemoryGiB
+		}
+		if a.GPUs != b.GPUs {
+			return a.GPUs < b.GPUs
+		}
+		return a.Type < b.Type
+	})
+}
+
+// FormatGiB formats an amount of memory, e.g. "16 GiB" or "0.5 GiB".
+func FormatGiB(gib float64) string {
+	return strconv.FormatFloat(gib, 'f', -1, 64) + " GiB"
+}
+
+// SizeCatalog is the catalog of the sizes of a cloud.
+type SizeCatalog struct {
+	// Region is where the sizes are offered.
+	Region string `json:"region"`
+	Sizes  []Size `json:"sizes"`
+}
+
+//go:embed sizes.json
+var bundledSizes []byte
+
+// BundledSizes returns the common sizes of a cloud, as shipped with the CLI.
+func BundledSizes(cloud string) ([]Size, error) {
+	var catalogs map[string][]Size
+	if err := json.Unmarshal(bundledSizes, &catalogs); err != nil {
+		return nil, fmt.Errorf("invalid bundled size catalog: %w", err)
+	}
+	sizes, ok := catalogs[cloud]
+	if !ok {
+		return nil, fmt.Errorf("size catalog for %s: %w", cloud, ErrNotFound)
+	}
+	return sizes, nil
+}
+
+// MergeSizes returns the sizes with the GPUs and architecture, when unset,
+// of the sizes of the same type in a reference catalog, since not every API
+// listing sizes returns them.
+func MergeSizes(sizes, reference []Size) []Size {
+	known := make(map[string]Size)
+	for _, size := range reference {
+		known[size.Type] = size
+	}
+	result := make([]Size, 0, len(sizes))
+	for _, size := range sizes {
+		if ref, ok := known[size.Type]; ok {
+			if size.GPUs == 0 {
+				size.GPUs = ref.GPUs
+			}
+			if size.Arch == "" {
+				size.Arch = ref.Arch
+			}
+		}
+		result = append(result, size)
+	}
+	return result
+}
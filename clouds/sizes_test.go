@@ -0,0 +1,97 @@
+package clouds
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size string
+		want SizeQuery
+		err  bool
+	}{
+		{"4x16", SizeQuery{CPUs: 4, MemoryGiB: 16}, false},
+		{"2x0.5", SizeQuery{CPUs: 2, MemoryGiB: 0.5}, false},
+		{"8x32x1", SizeQuery{CPUs: 8, MemoryGiB: 32, GPUs: 1}, false},
+		{"4", SizeQuery{}, true},
+		{"0x16", SizeQuery{}, true},
+		{"4xlarge", SizeQuery{}, true},
+		{"4x16x1x2", SizeQuery{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.size)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseSize(%q) error = %v, want error %v", tt.size, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSize(%q) = %+v, want %+v", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestCheapestSize(t *testing.T) {
+	sizes := []Size{
+		{Type: "unpriced", CPUs: 4, MemoryGiB: 16, Arch: ArchX86},
+		{Type: "general", CPUs: 4, MemoryGiB: 16, Arch: ArchX86, HourlyPrice: 0.19},
+		{Type: "burstable", CPUs: 4, MemoryGiB: 16, Arch: ArchX86, HourlyPrice: 0.17},
+		{Type: "arm", CPUs: 4, MemoryGiB: 16, Arch: ArchARM, HourlyPrice: 0.13},
+		{Type: "small", CPUs: 2, MemoryGiB: 8, Arch: ArchX86, HourlyPrice: 0.08},
+		{Type: "gpu", CPUs: 8, MemoryGiB: 32, GPUs: 1, Arch: ArchX86, HourlyPrice: 0.75},
+	}
+
+	tests := []struct {
+		query SizeQuery
+		want  string
+	}{
+		{SizeQuery{CPUs: 4, MemoryGiB: 16}, "arm"},
+		{SizeQuery{CPUs: 4, MemoryGiB: 16, Arch: ArchX86}, "burstable"},
+		{SizeQuery{CPUs: 1, MemoryGiB: 1, GPUs: 1}, "gpu"},
+		{SizeQuery{CPUs: 1, MemoryGiB: 1}, "small"},
+	}
+	for _, tt := range tests {
+		got, err := CheapestSize(sizes, tt.query)
+		if err != nil {
+			t.Errorf("CheapestSize(%s): %v", tt.query, err)
+			continue
+		}
+		if got.Type != tt.want {
+			t.Errorf("CheapestSize(%s) = %s, want %s", tt.query, got.Type, tt.want)
+		}
+	}
+
+	// Types of unknown price are chosen only when no priced type matches
+	got, err := CheapestSize(sizes[:1], SizeQuery{CPUs: 4, MemoryGiB: 16})
+	if err != nil || got.Type != "unpriced" {
+		t.Errorf("got %+v, %v, want the unpriced type", got, err)
+	}
+	if _, err := CheapestSize(sizes, SizeQuery{CPUs: 64, MemoryGiB: 256}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestBundledSizes(t *testing.T) {
+	prices, err := BundledPrices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cloud := range []string{"aws", "gcp", "azure", "fake"} {
+		sizes, err := BundledSizes(cloud)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sizes) == 0 {
+			t.Errorf("%s: no sizes", cloud)
+		}
+		for _, size := range sizes {
+			if size.CPUs < 1 || size.MemoryGiB <= 0 || (size.Arch != ArchX86 && size.Arch != ArchARM) {
+				t.Errorf("%s: invalid size %+v", cloud, size)
+			}
+			// Every bundled size has a price, so that --size can pick the cheapest
+			if _, ok := prices[cloud].Instances[size.Type]; !ok {
+				t.Errorf("%s: no price for %s", cloud, size.Type)
+			}
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package clouds
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StackTag is the tag that marks the instances created from a spec with the
+// name of its stack, so that instances removed from the spec are deleted.
+const StackTag = "namaste-stack"
+
+// Spec declares the infrastructure of a stack: the instances, firewall rules
+// and key pairs that should exist in each cloud.
+type Spec struct {
+	// Name identifies the stack, in the StackTag of its instances.
+	Name string `yaml:"name"`
+
+	// Clouds holds the resources of each cloud, by cloud name.
+	Clouds map[string]CloudSpec `yaml:"clouds"`
+}
+
+// CloudSpec declares the resources of a stack in one cloud.
+type CloudSpec struct {
+	// Region overrides the region of the configuration.
+	Region string `yaml:"region,omitempty"`
+
+	Instances     []InstanceSpec `yaml:"instances,omitempty"`
+	FirewallRules []FirewallRule `yaml:"firewall_rules,omitempty"`
+	KeyPairs      []KeyPairSpec  `yaml:"key_pairs,omitempty"`
+}
+
+// KeyPairSpec declares a key pair by its public key, given inline or as a
+// file relative to the spec.
+type KeyPairSpec struct {
+	Name          string `yaml:"name"`
+	PublicKey     string `yaml:"public_key,omitempty"`
+	PublicKeyFile string `yaml:"public_key_file,omitempty"`
+
+	// User is the login user the key is authorized for, on GCP.
+	User string `yaml:"user,omitempty"`
+}
+
+// LoadSpec reads and validates a spec file. Public key files are read, so
+// that the key pairs of the spec it returns hold their public keys.
+func LoadSpec(path string) (Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Spec{}, err
+	}
+
+	var spec Spec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return Spec{}, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if !firewallName.MatchString(spec.Name) {
+		return Spec{}, fmt.Errorf("%s: invalid stack name %q: use lower-case letters, digits and hyphens, starting with a letter", path, spec.Name)
+	}
+	if len(spec.Clouds) == 0 {
+		return Spec{}, fmt.Errorf("%s: no clouds declared", path)
+	}
+
+	for cloud, resources := range spec.Clouds {
+		for i, key := range resources.KeyPairs {
+			if key.PublicKeyFile == "" {
+				continue
+			}
+			keyPath := key.PublicKeyFile
+			if !filepath.IsAbs(keyPath) {
+				keyPath = filepath.Join(filepath.Dir(path), keyPath)
+			}
+			publicKey, err := os.ReadFile(keyPath)
+			if err != nil {
+				return Spec{}, fmt.Errorf("%s: key pair %s: %w", cloud, key.Name, err)
+			}
+			resources.KeyPairs[i].PublicKey = string(publicKey)
+		}
+		if err := resources.validate(); err != nil {
+			return Spec{}, fmt.Errorf("%s: %s: %w", path, cloud, err)
+		}
+		spec.Clouds[cloud] = resources
+	}
+	return spec, nil
+}
+
+// MarshalSpec validates a spec and writes it as YAML, as LoadSpec reads it.
+func MarshalSpec(spec Spec) ([]byte, error) {
+	if !firewallName.MatchString(spec.Name) {
+		return nil, fmt.Errorf("invalid stack name %q: use lower-case letters, digits and hyphens, starting with a letter", spec.Name)
+	}
+	for cloud, resources := range spec.Clouds {
+		if err := resources.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", cloud, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(spec); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// validate checks the resources of a cloud, defaulting the direction of
+// firewall rules as `firewall apply` does.
+func (c *CloudSpec) validate() error {
+	instances := make(map[string]bool)
+	for _, instance := range c.Instances {
+		if instance.Name == "" {
+			return fmt.Errorf("every instance needs a name")
+		}
+		if instances[instance.Name] {
+			return fmt.Errorf("instance %s is declared more than once", instance.Name)
+		}
+		instances[instance.Name] = true
+		if _, ok := instance.Tags[StackTag]; ok {
+			return fmt.Errorf("instance %s: the %s tag is set from the stack name", instance.Name, StackTag)
+		}
+		if instance.DiskSizeGiB < 0 {
+			return fmt.Errorf("instance %s: invalid disk size %d", instance.Name, instance.DiskSizeGiB)
+		}
+	}
+
+	rules := make(map[string]bool)
+	for i, rule := range c.FirewallRules {
+		rule = rule.WithDefaults()
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		if rules[rule.Name] {
+			return fmt.Errorf("firewall rule %s is declared more than once", rule.Name)
+		}
+		rules[rule.Name] = true
+		c.FirewallRules[i] = rule
+	}
+
+	keys := make(map[string]bool)
+	for _, key := range c.KeyPairs {
+		if key.Name == "" {
+			return fmt.Errorf("every key pair needs a name")
+		}
+		if keys[key.Name] {
+			return fmt.Errorf("key pair %s is declared more than once", key.Name)
+		}
+		keys[key.Name] = true
+		if _, err := ParsePublicKey(key.PublicKey); err != nil {
+			return fmt.Errorf("key pair %s: %w", key.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package clouds
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadSpec(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		err  string
+	}{
+		{"valid", "name: shop\nclouds:\n  aws:\n    instances:\n      - name: web\n", ""},
+		{"stack name", "name: Shop\nclouds:\n  aws: {}\n", "invalid stack name"},
+		{"no clouds", "name: shop\n", "no clouds declared"},
+		{"unnamed instance", "name: shop\nclouds:\n  aws:\n    instances:\n      - type: t3.micro\n", "needs a name"},
+		{"duplicate instance", "name: shop\nclouds:\n  aws:\n    instances:\n      - name: web\n      - name: web\n", "declared more than once"},
+		{"stack tag", "name: shop\nclouds:\n  aws:\n    instances:\n      - name: web\n        tags: {namaste-stack: other}\n", "set from the stack name"},
+		{"firewall rule", "name: shop\nclouds:\n  aws:\n    firewall_rules:\n      - name: open\n        protocol: tcp\n", "ports are required"},
+		{"public key", "name: shop\nclouds:\n  aws:\n    key_pairs:\n      - name: deploy\n        public_key: nonsense\n", "invalid public key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "infra.yaml")
+			if err := os.WriteFile(path, []byte(tt.spec), 0600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadSpec(path)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package clouds
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Tagger is implemented by providers that can tag instances after they have
+// been created. Tags are labels on GCP.
+type Tagger interface {
+	// TagInstance adds tags to an instance, replacing the values of
+	// existing tags with the same keys.
+	TagInstance(ctx context.Context, id string, tags map[string]string) error
+
+	// UntagInstance removes tags from an instance. Keys it does not have are ignored.
+	UntagInstance(ctx context.Context, id string, keys []string) error
+}
+
+// TagNormalizer is implemented by providers that store tags in a restricted
+// form, such as GCP labels. NormalizeTags returns tags as the cloud stores
+// them, so that they can be compared with the tags of live instances.
+type TagNormalizer interface {
+	NormalizeTags(tags map[string]string) (map[string]string, error)
+}
+
+// ParseTags parses tags of the form KEY=VALUE. The value may be empty, as in
+// "KEY=", but the equals sign is required so that a typo is not taken for
+// an empty tag.
+func ParseTags(values []string) (map[string]string, error) {
+	tags := make(map[string]string, len(values))
+	for _, value := range values {
+		key, v, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid tag %q: use KEY=VALUE", value)
+		}
+		tags[key] = v
+	}
+	return tags, nil
+}
+
+// MergeTags returns the default tags overridden by tags, or nil if both are empty.
+func MergeTags(defaults, tags map[string]string) map[string]string {
+	if len(defaults) == 0 && len(tags) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(defaults)+len(tags))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return merged
+}
+
+// SortedKeys returns the keys of tags in order, for stable output and requests.
+func SortedKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
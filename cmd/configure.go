@@ -30,8 +30,19 @@ func ConfigureCommand() *cobra.Command {
 				return
 			}
 
+			// Credentials are stored for the active profile, if any
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				fmt.Println("Error loading configuration:", err)
+				return
+			}
+
 			// Prompt for credentials
-			fmt.Printf("Enter credentials for %s:\n", cloud)
+			if cfg.Profile != "" {
+				fmt.Printf("Enter credentials for %s (profile %s):\n", cloud, cfg.Profile)
+			} else {
+				fmt.Printf("Enter credentials for %s:\n", cloud)
+			}
 			fmt.Print("Access Key: ")
 			accessKey, _ := reader.ReadString('\n')
 			fmt.Print("Secret Key: ")
@@ -49,9 +60,25 @@ func ConfigureCommand() *cobra.Command {
 			// Save credentials securely
 			cred := internal.Credential{
 				Cloud:     cloud,
+				Profile:   cfg.Profile,
 				AccessKey: accessKey,
 				SecretKey: secretKey,
 			}
+
+			// Azure service principals also need a tenant and a subscription
+			if cloud == "azure" {
+				fmt.Print("Tenant ID: ")
+				tenantID, _ := reader.ReadString('\n')
+				fmt.Print("Subscription ID: ")
+				subscriptionID, _ := reader.ReadString('\n')
+				cred.TenantID = strings.TrimSpace(tenantID)
+				cred.SubscriptionID = strings.TrimSpace(subscriptionID)
+
+				if cred.TenantID == "" || cred.SubscriptionID == "" {
+					fmt.Println("Invalid credentials. Tenant ID and Subscription ID cannot be empty. Please try again.")
+					return
+				}
+			}
 			if err := internal.SaveCredential(cred); err != nil {
 				fmt.Printf("Failed to save credentials: %v\n", err)
 				return
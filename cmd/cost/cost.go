@@ -0,0 +1,200 @@
+package cost
+
+import (
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/clouds/providers"
+	"namaste-cloud/internal"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// CostCommand returns the `cost` command group.
+func CostCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cost",
+		Short: "Estimate the cost of instances from the pricing catalog",
+		Long: "Estimate the hourly and monthly cost of instances, on demand and as spot instances, with\n" +
+			"their boot disks. Prices come from the pricing catalog: the one bundled with the CLI, or\n" +
+			"the one cached by `cost refresh`, so that estimates work offline. Prices are for Linux\n" +
+			"instances in a reference region of each cloud, in USD, and a month is 730 hours.\n\n" +
+			"`create-instance --estimate` estimates a single instance without creating it.",
+	}
+
+	cmd.AddCommand(estimateCommand())
+	cmd.AddCommand(refreshCommand())
+	return cmd
+}
+
+// instanceCost is the estimate of an instance of a spec, or the reason it
+// has none.
+type instanceCost struct {
+	cloud    string
+	name     string
+	estimate clouds.Estimate
+	err      error
+}
+
+func estimateCommand() *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "estimate",
+		Short: "Estimate the cost of the instances of a spec file",
+		Long: "Estimate the cost of the instances a spec file declares, as `apply` would create them, in\n" +
+			"each of its clouds or only the cloud given with --cloud. Instances without a type are\n" +
+			"estimated for the type the cloud's provider creates by default.",
+		Example: "  namaste-cloud cost estimate -f infra.yaml\n" +
+			"  namaste-cloud cost estimate -f infra.yaml --cloud aws",
+		Run: func(cmd *cobra.Command, args []string) {
+			spec, err := clouds.LoadSpec(file)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			var names []string
+			for name := range spec.Clouds {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if flag := cmd.Flag("cloud"); flag != nil && flag.Changed {
+				if _, ok := spec.Clouds[flag.Value.String()]; !ok {
+					fmt.Printf("Error: %s declares nothing for %s\n", file, flag.Value.String())
+					return
+				}
+				names = []string{flag.Value.String()}
+			}
+
+			prices, refreshedAt, err := providers.Prices()
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			var costs []instanceCost
+			for _, name := range names {
+				for _, instance := range spec.Clouds[name].Instances {
+					estimate, err := providers.EstimateInstance(prices, name, instance)
+					costs = append(costs, instanceCost{cloud: name, name: instance.Name, estimate: estimate, err: err})
+				}
+			}
+			if len(costs) == 0 {
+				fmt.Printf("%s declares no instances.\n", file)
+				return
+			}
+			printCosts(costs, prices)
+
+			for _, name := range names {
+				region := spec.Clouds[name].Region
+				if cloudPrices, ok := prices[name]; ok && region != "" && region != cloudPrices.Region {
+					fmt.Printf("Note: %s prices are for %s; prices in %s may differ.\n", name, cloudPrices.Region, region)
+				}
+			}
+			printSource(refreshedAt)
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "YAML file declaring the infrastructure")
+	cmd.MarkFlagRequired("file")
+	return cmd
+}
+
+// printCosts prints the estimates of instances as a table with their totals,
+// and the instances that could not be estimated.
+func printCosts(costs []instanceCost, prices clouds.PriceCatalog) {
+	var hourly, monthly, spotMonthly float64
+	spotComplete := true
+	var missing []instanceCost
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLOUD\tINSTANCE\tTYPE\tDISK\tHOURLY\tMONTHLY\tSPOT MONTHLY")
+	for _, c := range costs {
+		if c.err != nil {
+			missing = append(missing, c)
+			continue
+		}
+		e := c.estimate
+		spot := "-"
+		if e.SpotHourly > 0 {
+			spot = clouds.FormatCost(e.SpotHourly * clouds.HoursPerMonth)
+			spotMonthly += e.SpotHourly * clouds.HoursPerMonth
+		} else {
+			spotComplete = false
+		}
+		hourly += e.OnDemandHourly
+		monthly += e.OnDemandHourly * clouds.HoursPerMonth
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d GiB %s\t%s\t%s\t%s\n", c.cloud, c.name, e.Type, e.DiskGiB, prices[c.cloud].DiskType,
+			clouds.FormatCost(e.OnDemandHourly), clouds.FormatCost(e.OnDemandHourly*clouds.HoursPerMonth), spot)
+	}
+	if len(missing) < len(costs) {
+		spot := "-"
+		if spotComplete {
+			spot = clouds.FormatCost(spotMonthly)
+		}
+		fmt.Fprintf(w, "TOTAL\t\t\t\t%s\t%s\t%s\n", clouds.FormatCost(hourly), clouds.FormatCost(monthly), spot)
+	}
+	w.Flush()
+
+	for _, c := range missing {
+		fmt.Printf("Not estimated: %s instance %s: %v\n", c.cloud, c.name, c.err)
+	}
+}
+
+// printSource prints which pricing catalog the estimates come from.
+func printSource(refreshedAt time.Time) {
+	if refreshedAt.IsZero() {
+		fmt.Println("\nPrices from the bundled pricing catalog, in USD; a month is 730 hours.")
+		return
+	}
+	fmt.Printf("\nPrices from the pricing catalog refreshed on %s, in USD; a month is 730 hours.\n",
+		refreshedAt.Local().Format("2006-01-02 15:04"))
+}
+
+func refreshCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "refresh [URL|FILE]",
+		Short: "Download a pricing catalog and cache it for estimates",
+		Long: "Download a pricing catalog from a URL, or read it from a file, and cache it in the\n" +
+			"configuration directory, in place of the bundled one, for `cost estimate`,\n" +
+			"`create-instance --estimate`, `sizes list` and `create-instance --size`. The catalog is JSON\n" +
+			"in the format of the bundled one. Without an argument, it is downloaded from the\n" +
+			"pricing_url of the configuration.",
+		Example: "  namaste-cloud cost refresh https://example.com/namaste-pricing.json\n" +
+			"  namaste-cloud cost refresh ./pricing.json",
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				fmt.Println("Error loading configuration:", err)
+				return
+			}
+			source := cfg.PricingURL
+			if len(args) > 0 {
+				source = args[0]
+			}
+			if source == "" {
+				fmt.Println("Error: give the URL or file of the pricing catalog, or set pricing_url in the configuration")
+				return
+			}
+
+			prices, err := providers.RefreshPrices(cmd.Context(), cfg, source)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			var names []string
+			for name := range prices {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			fmt.Printf("Cached the pricing catalog from %s.\n", source)
+			for _, name := range names {
+				fmt.Printf("  %s: %d instance types in %s\n", name, len(prices[name].Instances), prices[name].Region)
+			}
+		},
+	}
+}
@@ -0,0 +1,194 @@
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/clouds/providers"
+	"namaste-cloud/internal"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// ruleFile is the format of the files applied with `firewall apply`.
+type ruleFile struct {
+	Rules []clouds.FirewallRule `yaml:"rules"`
+}
+
+// FirewallCommand returns the `firewall` command group.
+func FirewallCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "firewall",
+		Short: "Manage provider-neutral firewall rules",
+		Long: "Manage firewall rules that work the same on every cloud: EC2 security groups on AWS,\n" +
+			"VPC firewall rules on GCP and network security group rules on Azure.\n\n" +
+			"A rule applies to the instances with one of its target tags, written KEY=VALUE, such as\n" +
+			"role=web. A rule without target tags applies to no instance. The instances are matched when\n" +
+			"the rule is applied: run `firewall apply` again after creating or retagging instances, or\n" +
+			"they keep the rules they had.",
+	}
+
+	cmd.AddCommand(applyCommand())
+	cmd.AddCommand(listCommand())
+	cmd.AddCommand(deleteCommand())
+	return cmd
+}
+
+// loadFirewall creates the provider for the active cloud, which must support firewall rules.
+func loadFirewall(ctx context.Context) (clouds.Provider, clouds.Firewall, error) {
+	provider, err := providers.Load(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	firewall, ok := provider.(clouds.Firewall)
+	if !ok {
+		provider.Close()
+		return nil, nil, fmt.Errorf("firewall rules on %s: %w", provider.Name(), clouds.ErrNotSupported)
+	}
+	return provider, firewall, nil
+}
+
+// loadRules reads and validates the rules in a rule file.
+func loadRules(path string) ([]clouds.FirewallRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file ruleFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	seen := make(map[string]bool)
+	for i, rule := range file.Rules {
+		rule = rule.WithDefaults()
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+		if seen[rule.Name] {
+			return nil, fmt.Errorf("firewall rule %s is defined more than once", rule.Name)
+		}
+		seen[rule.Name] = true
+		file.Rules[i] = rule
+	}
+	return file.Rules, nil
+}
+
+func applyCommand() *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Create or update the firewall rules defined in a file",
+		Run: func(cmd *cobra.Command, args []string) {
+			// Validate every rule before changing anything
+			rules, err := loadRules(file)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			provider, firewall, err := loadFirewall(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			defer provider.Close()
+
+			for _, rule := range rules {
+				if err := firewall.ApplyFirewallRule(cmd.Context(), rule); err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+				fmt.Printf("Applied firewall rule: %s\n", rule.Name)
+				if len(rule.TargetTags) == 0 {
+					fmt.Printf("Warning: firewall rule %s has no target tags, so it applies to no instance\n", rule.Name)
+				}
+				if err := providers.Record(cmd.Context(), providers.FirewallRuleRecord(provider.Name(), rule)); err != nil {
+					fmt.Println("Warning: failed to record the firewall rule in the state:", err)
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "YAML file with the firewall rules")
+	cmd.MarkFlagRequired("file")
+	return cmd
+}
+
+func listCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List firewall rules",
+		Run: func(cmd *cobra.Command, args []string) {
+			provider, firewall, err := loadFirewall(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			defer provider.Close()
+
+			rules, err := firewall.ListFirewallRules(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			for _, rule := range rules {
+				ranges := "Sources"
+				if rule.Direction == clouds.Egress {
+					ranges = "Destinations"
+				}
+				fmt.Printf("Firewall Rule: %s, Direction: %s, Protocol: %s, Ports: %s, %s: %s, Targets: %s\n",
+					rule.Name, rule.Direction, rule.Protocol, orAll(rule.Ports), ranges, orAll(rule.Ranges()), orNone(rule.TargetTags))
+			}
+		},
+	}
+}
+
+func deleteCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete [rule-name]",
+		Short: "Delete a firewall rule",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			provider, firewall, err := loadFirewall(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			defer provider.Close()
+
+			if err := firewall.DeleteFirewallRule(cmd.Context(), args[0]); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			fmt.Printf("Deleted firewall rule: %s\n", args[0])
+			if err := providers.Forget(cmd.Context(), internal.StateRecord{Cloud: provider.Name(), Kind: clouds.KindFirewallRule, ID: args[0]}); err != nil {
+				fmt.Println("Warning: failed to remove the firewall rule from the state:", err)
+			}
+		},
+	}
+}
+
+// orAll joins values with commas, or returns "all" when there are none.
+func orAll(values []string) string {
+	if len(values) == 0 {
+		return "all"
+	}
+	return strings.Join(values, ",")
+}
+
+// orNone joins values with commas, or returns "none" when there are none.
+func orNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,113 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/clouds/providers"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// ImagesCommand returns the `images` command group.
+func ImagesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "images",
+		Short: "Look up the images of operating system releases",
+		Long: "Image IDs differ per cloud and, on AWS, per region. Operating system releases such as\n" +
+			"ubuntu-24.04 can be given to `create-instance --image` instead, and are looked up in the\n" +
+			"configured region: from the public SSM parameters of the publisher or its newest AMI on AWS,\n" +
+			"the image family on GCP and the marketplace image on Azure.",
+	}
+
+	cmd.AddCommand(listCommand())
+	return cmd
+}
+
+func listCommand() *cobra.Command {
+	var osName, arch string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the current images of operating system releases in the region",
+		Example: "  namaste-cloud images list --os ubuntu\n" +
+			"  namaste-cloud images list --os debian-12 --arch arm64 --cloud gcp",
+		Run: func(cmd *cobra.Command, args []string) {
+			releases := clouds.MatchOS(osName)
+			if len(releases) == 0 {
+				var aliases []string
+				for _, release := range clouds.OperatingSystems {
+					aliases = append(aliases, release.Alias)
+				}
+				fmt.Printf("Error: unknown operating system %q; use one of %s\n", osName, strings.Join(aliases, ", "))
+				return
+			}
+			archs := []string{clouds.ArchX86, clouds.ArchARM}
+			if arch != "" {
+				archs = []string{arch}
+			}
+
+			// Create the provider for the active cloud
+			provider, err := providers.Load(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			defer provider.Close()
+
+			resolver, ok := provider.(clouds.ImageResolver)
+			if !ok {
+				fmt.Println("Error:", fmt.Errorf("looking up images on %s: %w", provider.Name(), clouds.ErrNotSupported))
+				return
+			}
+
+			var images []clouds.Image
+			for _, release := range releases {
+				for _, arch := range archs {
+					image, err := resolver.ResolveImage(cmd.Context(), release.Alias, arch)
+					if errors.Is(err, clouds.ErrNotFound) {
+						continue
+					}
+					if err != nil {
+						fmt.Println("Error:", err)
+						return
+					}
+					images = append(images, image)
+				}
+			}
+			if len(images) == 0 {
+				fmt.Printf("No images of %s on %s.\n", orAll(osName), provider.Name())
+				return
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "RELEASE\tARCH\tIMAGE\tNAME\tCREATED")
+			for _, image := range images {
+				name, created := "-", "-"
+				if image.Name != "" && image.Name != image.ID {
+					name = image.Name
+				}
+				if !image.CreatedAt.IsZero() {
+					created = image.CreatedAt.Format("2006-01-02")
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", image.Alias, image.Arch, image.ID, name, created)
+			}
+			w.Flush()
+		},
+	}
+
+	cmd.Flags().StringVar(&osName, "os", "", "List the releases of this operating system, e.g. ubuntu, or a single release, e.g. ubuntu-24.04")
+	cmd.Flags().StringVar(&arch, "arch", "", "List the images for this architecture only, x86_64 or arm64")
+	return cmd
+}
+
+// orAll returns the operating system, or "any operating system" when none is given.
+func orAll(osName string) string {
+	if osName == "" {
+		return "any operating system"
+	}
+	return osName
+}
@@ -0,0 +1,213 @@
+package infra
+
+import (
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/clouds/providers"
+	"namaste-cloud/internal"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+// DriftCommand returns the `drift` command.
+func DriftCommand() *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "drift",
+		Short: "Report resources changed or deleted outside the CLI",
+		Long: "Compare the resources recorded in the state with the live ones, and report changed instance\n" +
+			"types, tags and key pairs, changed firewall and security group rules, and resources deleted\n" +
+			"outside the CLI, as recorded -> live. With --file, the resources declared in a spec file are\n" +
+			"compared instead, as live -> declared like plan. Only the cloud given with --cloud is checked,\n" +
+			"when given.\n\n" +
+			"For use in CI, drift exits with status 2 when it finds drift, and 1 when it cannot check.",
+		Example:       "  namaste-cloud drift\n  namaste-cloud drift -f infra.yaml --cloud aws",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var drifted int
+			var err error
+			if file != "" {
+				drifted, err = specDrift(cmd, file)
+			} else {
+				drifted, err = stateDrift(cmd)
+			}
+			if err != nil {
+				fmt.Println("Error:", err)
+				return &internal.ExitError{Code: 1}
+			}
+			if drifted > 0 {
+				return &internal.ExitError{Code: 2}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "Compare the resources declared in this spec file instead of the recorded ones")
+	return cmd
+}
+
+// driftScope is the cloud, region, profile and project of recorded
+// resources, which are compared with the live ones by one provider.
+type driftScope struct {
+	cloud, region, profile, project string
+}
+
+// stateDrift compares the recorded resources with the live ones, prints the
+// drift and returns how many resources drifted.
+func stateDrift(cmd *cobra.Command) (int, error) {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return 0, fmt.Errorf("error loading configuration: %w", err)
+	}
+	backend, err := providers.OpenState(cmd.Context(), cfg)
+	if err != nil {
+		return 0, err
+	}
+	state, err := internal.ReadState(cmd.Context(), backend)
+	if err != nil {
+		return 0, err
+	}
+
+	cloud := ""
+	if flag := cmd.Flag("cloud"); flag != nil && flag.Changed {
+		cloud = flag.Value.String()
+	}
+	groups := make(map[driftScope][]internal.StateRecord)
+	var scopes []driftScope
+	total := 0
+	for _, record := range state.Resources {
+		if cloud != "" && record.Cloud != cloud {
+			continue
+		}
+		scope := driftScope{record.Cloud, record.Region, record.Profile, record.Project}
+		if _, ok := groups[scope]; !ok {
+			scopes = append(scopes, scope)
+		}
+		groups[scope] = append(groups[scope], record)
+		total++
+	}
+	if total == 0 {
+		fmt.Println("No resources recorded; nothing to compare.")
+		return 0, nil
+	}
+	sort.Slice(scopes, func(i, j int) bool {
+		a, b := scopes[i], scopes[j]
+		if a.cloud != b.cloud {
+			return a.cloud < b.cloud
+		}
+		if a.region != b.region {
+			return a.region < b.region
+		}
+		if a.profile != b.profile {
+			return a.profile < b.profile
+		}
+		return a.project < b.project
+	})
+
+	drifted := 0
+	for _, scope := range scopes {
+		scoped := cfg
+		scoped.ActiveCloud = scope.cloud
+		if scope.region != "" {
+			scoped.Region = scope.region
+		}
+		if scope.profile != "" {
+			scoped.Profile = scope.profile
+		}
+		if scope.project != "" {
+			scoped.Project = scope.project
+		}
+		label := scope.cloud
+		if scope.project != "" {
+			label += " " + scope.project
+		}
+		if scope.region != "" {
+			label += " (" + scope.region + ")"
+		}
+
+		provider, err := providers.New(cmd.Context(), scoped)
+		if err != nil {
+			return drifted, err
+		}
+		drifts, err := providers.DetectDrift(cmd.Context(), provider, groups[scope])
+		provider.Close()
+		if err != nil {
+			return drifted, fmt.Errorf("failed to check %s: %w", label, err)
+		}
+
+		if len(drifts) == 0 {
+			fmt.Printf("%s: no drift\n", label)
+			continue
+		}
+		fmt.Printf("%s:\n", label)
+		for _, drift := range drifts {
+			if drift.Deleted {
+				fmt.Printf("  - %s %s deleted outside the CLI\n", drift.Record.Kind, describeRecord(drift.Record))
+				continue
+			}
+			fmt.Printf("  ~ %s %s changed\n", drift.Record.Kind, describeRecord(drift.Record))
+			for _, detail := range drift.Details {
+				fmt.Printf("      %s\n", detail)
+			}
+		}
+		drifted += len(drifts)
+	}
+
+	if drifted == 0 {
+		fmt.Printf("No drift; the %d recorded resources match the live ones.\n", total)
+	} else {
+		fmt.Printf("Drift: %d of %d recorded resources changed or deleted.\n", drifted, total)
+	}
+	return drifted, nil
+}
+
+// specDrift compares the resources declared in a spec file with the live
+// ones, prints the drift and returns how many resources drifted.
+func specDrift(cmd *cobra.Command, file string) (int, error) {
+	plans, err := makePlans(cmd, file)
+	if err != nil {
+		return 0, err
+	}
+	defer closePlans(plans)
+
+	drifted := 0
+	for _, plan := range plans {
+		if len(plan.changes) == 0 {
+			fmt.Printf("%s: no drift\n", plan.cloud)
+			continue
+		}
+		fmt.Printf("%s:\n", plan.cloud)
+		for _, change := range plan.changes {
+			switch change.Action {
+			case clouds.ChangeCreate:
+				fmt.Printf("  - %s %s is declared but does not exist\n", change.Kind, change.Name)
+			case clouds.ChangeDelete:
+				fmt.Printf("  + %s %s%s has the stack tag but is not declared\n", change.Kind, change.Name, withID(change, change.Current.ID))
+			default:
+				fmt.Printf("  ~ %s %s%s differs from the declaration\n", change.Kind, change.Name, withID(change, change.Current.ID))
+			}
+			for _, detail := range change.Details {
+				fmt.Printf("      %s\n", detail)
+			}
+			drifted++
+		}
+	}
+
+	if drifted == 0 {
+		fmt.Printf("No drift; the infrastructure matches %s.\n", file)
+	} else {
+		fmt.Printf("Drift: apply would make %s to match %s.\n", countChanges(drifted), file)
+	}
+	return drifted, nil
+}
+
+// describeRecord names a recorded resource, with its ID when that differs.
+func describeRecord(record internal.StateRecord) string {
+	if record.Name == "" || record.Name == record.ID {
+		return record.ID
+	}
+	return fmt.Sprintf("%s (%s)", record.Name, record.ID)
+}
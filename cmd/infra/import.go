@@ -0,0 +1,260 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	awscloud "namaste-cloud/clouds/aws-cloud"
+	"namaste-cloud/clouds/providers"
+	"namaste-cloud/internal"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// importOptions are the flags of the import command.
+type importOptions struct {
+	filters        []string
+	keyPairs       []string
+	securityGroups []string
+	spec           string
+	stack          string
+}
+
+// discovered are the existing resources selected for import.
+type discovered struct {
+	instances      []clouds.Instance
+	keyPairs       []clouds.KeyPair
+	securityGroups []awscloud.SecurityGroup
+}
+
+// ImportCommand returns the `import` command.
+func ImportCommand() *cobra.Command {
+	var opts importOptions
+
+	cmd := &cobra.Command{
+		Use:   "import [instance...]",
+		Short: "Record existing resources in the state, or declare them in a spec file",
+		Long: "Record resources that were not created by the CLI in the state, so that drift checks them.\n" +
+			"Instances are given by ID, name or tag:KEY=VALUE selector, or selected with --filter as in\n" +
+			"list-instances, and the key pairs they were launched with are imported with them. Other\n" +
+			"key pairs and, on AWS, security groups are given with --key-pair and --security-group.\n\n" +
+			"With --spec, the instances and key pairs are written to a new spec file instead, for plan\n" +
+			"and apply to manage from then on. The first apply tags the instances with the stack name.",
+		Example: "  namaste-cloud import --filter tag:team=payments\n" +
+			"  namaste-cloud import i-0abc123 --security-group sg-0def456\n" +
+			"  namaste-cloud import --filter tag:team=payments --spec payments.yaml --stack payments",
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 && len(opts.filters) == 0 && len(opts.keyPairs) == 0 && len(opts.securityGroups) == 0 {
+				fmt.Println("Error: give one or more instances, --filter, --key-pair or --security-group")
+				return
+			}
+			if opts.spec != "" && len(opts.securityGroups) > 0 {
+				fmt.Println("Error: spec files declare firewall rules rather than security groups; import security groups without --spec")
+				return
+			}
+			filters, err := clouds.ParseFilters(opts.filters)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			// Create the provider for the active cloud
+			provider, err := providers.Load(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			defer provider.Close()
+
+			resources, err := discover(cmd.Context(), provider, args, filters, opts)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			if len(resources.instances)+len(resources.keyPairs)+len(resources.securityGroups) == 0 {
+				fmt.Println("No resources match; nothing was imported.")
+				return
+			}
+
+			if opts.spec != "" {
+				err = writeSpec(provider.Name(), resources, opts)
+			} else {
+				err = recordImported(cmd.Context(), provider.Name(), resources)
+			}
+			if err != nil {
+				fmt.Println("Error:", err)
+			}
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&opts.filters, "filter", nil, "Import instances matching KEY=VALUE, e.g. tag:team=payments (repeatable)")
+	cmd.Flags().StringArrayVar(&opts.keyPairs, "key-pair", nil, "Import the key pair with this name (repeatable)")
+	cmd.Flags().StringArrayVar(&opts.securityGroups, "security-group", nil, "Import the security group with this ID, on AWS (repeatable)")
+	cmd.Flags().StringVar(&opts.spec, "spec", "", "Write the resources to this new spec file instead of recording them in the state")
+	cmd.Flags().StringVar(&opts.stack, "stack", "imported", "Stack name of the spec file written with --spec")
+	return cmd
+}
+
+// discover finds the resources to import: the instances given or matching
+// the filters, the key pairs they use or that are named, and the named
+// security groups.
+func discover(ctx context.Context, provider clouds.Provider, refs []string, filters []clouds.Filter, opts importOptions) (discovered, error) {
+	var resources discovered
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		instance, err := clouds.FindInstance(ctx, provider, ref)
+		if err != nil {
+			return resources, err
+		}
+		if !seen[instance.ID] {
+			seen[instance.ID] = true
+			resources.instances = append(resources.instances, instance)
+		}
+	}
+	if len(filters) > 0 {
+		instances, err := provider.ListInstances(ctx)
+		if err != nil {
+			return resources, err
+		}
+		for _, instance := range clouds.FilterInstances(instances, filters) {
+			if !seen[instance.ID] && !clouds.IsTerminated(instance) {
+				seen[instance.ID] = true
+				resources.instances = append(resources.instances, instance)
+			}
+		}
+	}
+
+	// Key pairs the instances were launched with are imported when they
+	// still exist; those named explicitly must exist.
+	keyNames := slices.Clone(opts.keyPairs)
+	for _, instance := range resources.instances {
+		if instance.KeyName != "" && !slices.Contains(keyNames, instance.KeyName) {
+			keyNames = append(keyNames, instance.KeyName)
+		}
+	}
+	if len(keyNames) > 0 {
+		keys, err := listKeyPairs(ctx, provider, len(opts.keyPairs) > 0)
+		if err != nil {
+			return resources, err
+		}
+		for _, name := range keyNames {
+			i := slices.IndexFunc(keys, func(key clouds.KeyPair) bool { return key.Name == name })
+			if i >= 0 {
+				resources.keyPairs = append(resources.keyPairs, keys[i])
+			} else if slices.Contains(opts.keyPairs, name) {
+				return resources, fmt.Errorf("key pair %s: %w", name, clouds.ErrNotFound)
+			}
+		}
+	}
+
+	if len(opts.securityGroups) > 0 {
+		aws, ok := provider.(*awscloud.Provider)
+		if !ok {
+			return resources, fmt.Errorf("security groups are only available on AWS, not %s; use --cloud aws", provider.Name())
+		}
+		for _, id := range opts.securityGroups {
+			group, err := aws.DescribeSecurityGroup(ctx, id)
+			if err != nil {
+				return resources, err
+			}
+			resources.securityGroups = append(resources.securityGroups, group)
+		}
+	}
+	return resources, nil
+}
+
+// listKeyPairs lists the key pairs of the provider. Providers without key
+// pairs have none to import with instances, but cannot import named ones.
+func listKeyPairs(ctx context.Context, provider clouds.Provider, required bool) ([]clouds.KeyPair, error) {
+	keyPairs, ok := provider.(clouds.KeyPairs)
+	if !ok {
+		if required {
+			return nil, fmt.Errorf("key pairs on %s: %w", provider.Name(), clouds.ErrNotSupported)
+		}
+		return nil, nil
+	}
+	return keyPairs.ListKeyPairs(ctx)
+}
+
+// recordImported records the resources in the state.
+func recordImported(ctx context.Context, cloud string, resources discovered) error {
+	var records []internal.StateRecord
+	for _, instance := range resources.instances {
+		records = append(records, providers.InstanceRecord(cloud, instance, clouds.InstanceSpec{}))
+	}
+	for _, key := range resources.keyPairs {
+		records = append(records, providers.KeyPairRecord(cloud, key))
+	}
+	for _, group := range resources.securityGroups {
+		records = append(records, providers.SecurityGroupRecord(cloud, group))
+	}
+	if err := providers.Record(ctx, records...); err != nil {
+		return fmt.Errorf("failed to record the resources in the state: %w", err)
+	}
+
+	for _, record := range records {
+		fmt.Printf("Imported %s %s\n", record.Kind, describeRecord(record))
+	}
+	return nil
+}
+
+// writeSpec declares the instances and key pairs in a new spec file.
+// Instances without a name cannot be declared and are skipped, as are key
+// pairs whose public key the cloud does not return.
+func writeSpec(cloud string, resources discovered, opts importOptions) error {
+	if _, err := os.Stat(opts.spec); err == nil {
+		return fmt.Errorf("%s already exists", opts.spec)
+	}
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
+
+	resourcesSpec := clouds.CloudSpec{Region: cfg.Region}
+	for _, instance := range resources.instances {
+		if instance.Name == "" {
+			fmt.Printf("Warning: instance %s has no name and was not declared\n", instance.ID)
+			continue
+		}
+		resourcesSpec.Instances = append(resourcesSpec.Instances, clouds.InstanceSpec{
+			Name:    instance.Name,
+			Type:    instance.Type,
+			KeyName: instance.KeyName,
+			Tags:    declaredTags(instance),
+		})
+	}
+	for _, key := range resources.keyPairs {
+		if key.PublicKey == "" {
+			fmt.Printf("Warning: %s does not return the public key of key pair %s, which was not declared\n", cloud, key.Name)
+			continue
+		}
+		resourcesSpec.KeyPairs = append(resourcesSpec.KeyPairs, clouds.KeyPairSpec{Name: key.Name, PublicKey: strings.TrimSpace(key.PublicKey), User: key.User})
+	}
+
+	data, err := clouds.MarshalSpec(clouds.Spec{Name: opts.stack, Clouds: map[string]clouds.CloudSpec{cloud: resourcesSpec}})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(opts.spec, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s; plan -f %s shows what apply will change to adopt the resources.\n", opts.spec, opts.spec)
+	return nil
+}
+
+// declaredTags returns the tags of an instance to declare in a spec, without
+// the stack tag, which apply sets, the Name tag holding its name on AWS and
+// the tags AWS reserves.
+func declaredTags(instance clouds.Instance) map[string]string {
+	tags := make(map[string]string)
+	for key, value := range instance.Tags {
+		if key == clouds.StackTag || (key == "Name" && value == instance.Name) || strings.HasPrefix(key, "aws:") {
+			continue
+		}
+		tags[key] = value
+	}
+	return tags
+}
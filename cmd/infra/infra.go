@@ -0,0 +1,329 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/clouds/providers"
+	"namaste-cloud/internal"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+// specHelp describes the spec files read by plan and apply.
+const specHelp = "The file declares a stack: its name and, for each cloud, the instances, firewall rules and\n" +
+	"key pairs that should exist. Resources are matched with the live ones by name. Instances\n" +
+	"created from the file are tagged " + clouds.StackTag + "=NAME, and instances with that tag\n" +
+	"that the file no longer declares are deleted. Firewall rules and key pairs are only created\n" +
+	"and updated, never deleted. Only the cloud given with --cloud is planned, when given."
+
+// specExample is an example spec file.
+const specExample = `  # infra.yaml
+  name: shop
+  clouds:
+    aws:
+      region: eu-west-1
+      key_pairs:
+        - name: deploy
+          public_key_file: deploy.pub
+      firewall_rules:
+        - name: allow-https
+          protocol: tcp
+          ports: ["443"]
+          sources: [0.0.0.0/0]
+          target_tags: [role=web]
+      instances:
+        - name: web-1
+          image: ami-0abcdef1234567890
+          type: t3.small
+          key_name: deploy
+          tags: {role: web}`
+
+// cloudPlan is the plan for the resources of one cloud of a spec.
+type cloudPlan struct {
+	cloud    string
+	region   string
+	stack    string
+	provider clouds.Provider
+	changes  []clouds.Change
+}
+
+// PlanCommand returns the `plan` command.
+func PlanCommand() *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:     "plan",
+		Short:   "Show the changes that apply would make to match a spec file",
+		Long:    "Show the changes that apply would make to the clouds to match a spec file.\n\n" + specHelp,
+		Example: "  namaste-cloud plan -f infra.yaml\n\n" + specExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			plans, err := makePlans(cmd, file)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			defer closePlans(plans)
+
+			printPlans(plans, file)
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "YAML file declaring the infrastructure")
+	cmd.MarkFlagRequired("file")
+	return cmd
+}
+
+// ApplyCommand returns the `apply` command.
+func ApplyCommand() *cobra.Command {
+	var file string
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Create, update and delete resources to match a spec file",
+		Long: "Create, update and delete resources to match a spec file. The plan is shown and must be\n" +
+			"confirmed, unless --yes is given. Changes are made one at a time, and apply stops at the\n" +
+			"first one that fails. Instances protected by a protection rule in the configuration are\n" +
+			"never replaced or deleted.\n\n" + specHelp,
+		Example:       "  namaste-cloud apply -f infra.yaml\n\n" + specExample,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				fmt.Println("Error:", err)
+				return nil
+			}
+			rules, err := providers.ProtectionRules(cfg)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return nil
+			}
+
+			plans, err := makePlans(cmd, file)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return nil
+			}
+			defer closePlans(plans)
+
+			total := printPlans(plans, file)
+			if total == 0 {
+				return nil
+			}
+			if !checkProtection(plans, rules) {
+				return &internal.ExitError{Code: 1}
+			}
+			if !yes && !internal.Confirm(fmt.Sprintf("Apply %s?", countChanges(total))) {
+				fmt.Println("Aborted; nothing was changed.")
+				return nil
+			}
+
+			counts := make(map[clouds.ChangeAction]int)
+			for _, plan := range plans {
+				for _, change := range plan.changes {
+					id, err := clouds.ApplyChange(cmd.Context(), plan.provider, change)
+					if err != nil {
+						fmt.Printf("Error: %s %s %s: %v\n", change.Action, change.Kind, change.Name, err)
+						fmt.Println("Apply stopped; the remaining changes were not made.")
+						return &internal.ExitError{Code: 1}
+					}
+					counts[change.Action]++
+					fmt.Printf("%s %s %s%s\n", pastTense[change.Action], change.Kind, change.Name, withID(change, id))
+					if err := recordChange(cmd.Context(), plan, change, id); err != nil {
+						fmt.Println("Warning: failed to record the change in the state:", err)
+					}
+				}
+			}
+			fmt.Printf("Apply complete: %d created, %d updated, %d replaced, %d deleted.\n",
+				counts[clouds.ChangeCreate], counts[clouds.ChangeUpdate], counts[clouds.ChangeReplace], counts[clouds.ChangeDelete])
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "YAML file declaring the infrastructure")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Do not ask for confirmation")
+	cmd.MarkFlagRequired("file")
+	return cmd
+}
+
+// makePlans loads a spec file and plans the changes for each of its clouds,
+// or only for the cloud given with --cloud.
+func makePlans(cmd *cobra.Command, file string) ([]cloudPlan, error) {
+	spec, err := clouds.LoadSpec(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name := range spec.Clouds {
+		if !providers.IsSupported(name) {
+			return nil, fmt.Errorf("%s: unsupported cloud %q", file, name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if flag := cmd.Flag("cloud"); flag != nil && flag.Changed {
+		if _, ok := spec.Clouds[flag.Value.String()]; !ok {
+			return nil, fmt.Errorf("%s declares nothing for %s", file, flag.Value.String())
+		}
+		names = []string{flag.Value.String()}
+	}
+
+	var plans []cloudPlan
+	for _, name := range names {
+		plan, err := makePlan(cmd.Context(), name, spec.Name, spec.Clouds[name])
+		if err != nil {
+			closePlans(plans)
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// makePlan creates the provider for a cloud of a spec and plans its changes.
+func makePlan(ctx context.Context, cloud, stack string, resources clouds.CloudSpec) (cloudPlan, error) {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return cloudPlan{}, fmt.Errorf("error loading configuration: %w", err)
+	}
+	cfg.ActiveCloud = cloud
+	if resources.Region != "" {
+		cfg.Region = resources.Region
+	}
+
+	provider, err := providers.New(ctx, cfg)
+	if err != nil {
+		return cloudPlan{}, err
+	}
+	changes, err := clouds.PlanChanges(ctx, provider, stack, resources)
+	if err != nil {
+		provider.Close()
+		return cloudPlan{}, fmt.Errorf("failed to plan %s: %w", cloud, err)
+	}
+	return cloudPlan{cloud: cloud, region: cfg.Region, stack: stack, provider: provider, changes: changes}, nil
+}
+
+// closePlans closes the providers of the plans.
+func closePlans(plans []cloudPlan) {
+	for _, plan := range plans {
+		plan.provider.Close()
+	}
+}
+
+// symbols mark the actions of changes in a plan.
+var symbols = map[clouds.ChangeAction]string{
+	clouds.ChangeCreate:  "+",
+	clouds.ChangeUpdate:  "~",
+	clouds.ChangeReplace: "-/+",
+	clouds.ChangeDelete:  "-",
+}
+
+// pastTense reports the actions of changes once made.
+var pastTense = map[clouds.ChangeAction]string{
+	clouds.ChangeCreate:  "Created",
+	clouds.ChangeUpdate:  "Updated",
+	clouds.ChangeReplace: "Replaced",
+	clouds.ChangeDelete:  "Deleted",
+}
+
+// printPlans prints the changes of every plan and returns how many there are.
+func printPlans(plans []cloudPlan, file string) int {
+	counts := make(map[clouds.ChangeAction]int)
+	total := 0
+	for _, plan := range plans {
+		if len(plan.changes) == 0 {
+			fmt.Printf("%s: no changes\n", plan.cloud)
+			continue
+		}
+		fmt.Printf("%s:\n", plan.cloud)
+		for _, change := range plan.changes {
+			fmt.Printf("  %s %s %s %s%s\n", symbols[change.Action], change.Action, change.Kind, change.Name, withID(change, change.Current.ID))
+			for _, detail := range change.Details {
+				fmt.Printf("      %s\n", detail)
+			}
+			counts[change.Action]++
+			total++
+		}
+	}
+
+	if total == 0 {
+		fmt.Printf("No changes; the infrastructure matches %s.\n", file)
+		return 0
+	}
+	fmt.Printf("Plan: %d to create, %d to update, %d to replace, %d to delete.\n",
+		counts[clouds.ChangeCreate], counts[clouds.ChangeUpdate], counts[clouds.ChangeReplace], counts[clouds.ChangeDelete])
+	return total
+}
+
+// checkProtection refuses to replace or delete instances protected from
+// termination by a protection rule, since apply has no --force. It reports
+// whether the plans may be applied, having printed why not.
+func checkProtection(plans []cloudPlan, rules []clouds.ProtectionRule) bool {
+	allowed := true
+	for _, plan := range plans {
+		for _, change := range plan.changes {
+			if change.Kind != clouds.KindInstance || (change.Action != clouds.ChangeReplace && change.Action != clouds.ChangeDelete) {
+				continue
+			}
+			for _, rule := range rules {
+				if rule.Protects(change.Current, clouds.ActionTerminate) {
+					fmt.Printf("Error: instance %s is protected by the rule %q; terminate it with `terminate-instance --force` first\n", change.Current.ID, rule.String())
+					allowed = false
+					break
+				}
+			}
+		}
+	}
+	return allowed
+}
+
+// recordChange records the resources an applied change created or changed,
+// and forgets the instances it deleted.
+func recordChange(ctx context.Context, plan cloudPlan, change clouds.Change, id string) error {
+	if change.Kind == clouds.KindInstance && (change.Action == clouds.ChangeReplace || change.Action == clouds.ChangeDelete) {
+		deleted := internal.StateRecord{Cloud: plan.cloud, Region: plan.region, Kind: clouds.KindInstance, ID: change.Current.ID}
+		if err := providers.Forget(ctx, deleted); err != nil {
+			return err
+		}
+	}
+
+	var record internal.StateRecord
+	switch change.Kind {
+	case clouds.KindInstance:
+		instance := clouds.Instance{ID: id, Name: change.Name, Type: change.Instance.Type, KeyName: change.Instance.KeyName, Tags: change.Instance.Tags}
+		switch change.Action {
+		case clouds.ChangeDelete:
+			return nil
+		case clouds.ChangeUpdate:
+			instance = change.Current
+			instance.Tags = clouds.MergeTags(instance.Tags, change.Instance.Tags)
+		}
+		record = providers.InstanceRecord(plan.cloud, instance, change.Instance)
+	case clouds.KindFirewallRule:
+		record = providers.FirewallRuleRecord(plan.cloud, change.FirewallRule)
+	case clouds.KindKeyPair:
+		record = providers.KeyPairRecord(plan.cloud, change.KeyPair)
+	}
+	record.Region, record.Stack = plan.region, plan.stack
+	return providers.Record(ctx, record)
+}
+
+// withID returns " (ID)" for instance changes, to tell instances apart.
+func withID(change clouds.Change, id string) string {
+	if change.Kind != clouds.KindInstance || id == "" {
+		return ""
+	}
+	return " (" + id + ")"
+}
+
+// countChanges returns "1 change" or "N changes".
+func countChanges(n int) string {
+	if n == 1 {
+		return "1 change"
+	}
+	return fmt.Sprintf("%d changes", n)
+}
@@ -0,0 +1,143 @@
+package instances
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/clouds/providers"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// loadConsole creates the provider for the active cloud and resolves an
+// instance ID or name, for commands that read an instance's console.
+func loadConsole(ctx context.Context, ref string) (clouds.Provider, clouds.Console, clouds.Instance, error) {
+	provider, err := providers.Load(ctx)
+	if err != nil {
+		return nil, nil, clouds.Instance{}, err
+	}
+
+	console, ok := provider.(clouds.Console)
+	if !ok {
+		provider.Close()
+		return nil, nil, clouds.Instance{}, fmt.Errorf("instance consoles on %s: %w", provider.Name(), clouds.ErrNotSupported)
+	}
+	instance, err := clouds.FindInstance(ctx, provider, ref)
+	if err != nil {
+		provider.Close()
+		return nil, nil, clouds.Instance{}, err
+	}
+	return provider, console, instance, nil
+}
+
+// ConsoleOutputCommand returns the `console-output` command.
+func ConsoleOutputCommand() *cobra.Command {
+	var follow bool
+	var interval time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "console-output [instance]",
+		Short: "Show the serial console output of an instance",
+		Long: "Show the serial console output of an instance, such as its boot log. The instance is given\n" +
+			"by ID, name or tag:KEY=VALUE. On Azure, boot diagnostics must be enabled on the VM.",
+		Example: "  namaste-cloud console-output web\n" +
+			"  namaste-cloud console-output web --follow",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			provider, console, instance, err := loadConsole(cmd.Context(), args[0])
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			defer provider.Close()
+
+			var previous string
+			for {
+				output, err := console.ConsoleOutput(cmd.Context(), instance.ID)
+				if err != nil && cmd.Context().Err() == nil {
+					fmt.Println("Error:", err)
+					return
+				}
+				fmt.Print(clouds.NewConsoleOutput(previous, output))
+				previous = output
+				if !follow {
+					return
+				}
+
+				// Follow until interrupted
+				select {
+				case <-time.After(interval):
+				case <-cmd.Context().Done():
+					return
+				}
+			}
+		},
+	}
+
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "Keep polling for new output until interrupted")
+	cmd.Flags().DurationVar(&interval, "interval", 5*time.Second, "How often to poll for new output with --follow")
+	return cmd
+}
+
+// ConsoleScreenshotCommand returns the `console-screenshot` command.
+func ConsoleScreenshotCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "console-screenshot [instance]",
+		Short: "Save a screenshot of an instance's display",
+		Long: "Save a screenshot of the display of an instance given by ID, name or tag:KEY=VALUE, to see\n" +
+			"where it is stuck when it does not boot. The image is saved as NAME.jpg or NAME.png, after\n" +
+			"the name of the instance, unless --output is given.",
+		Example: "  namaste-cloud console-screenshot web\n" +
+			"  namaste-cloud console-screenshot web --output /tmp/web.jpg",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			provider, console, instance, err := loadConsole(cmd.Context(), args[0])
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			defer provider.Close()
+
+			image, err := console.ConsoleScreenshot(cmd.Context(), instance.ID)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			file := output
+			if file == "" {
+				// IDs on GCP and Azure are qualified with a zone or resource group
+				name := instance.Name
+				if name == "" {
+					name = path.Base(instance.ID)
+				}
+				file = name + imageExtension(image)
+			}
+			if err := os.WriteFile(file, image, 0644); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			fmt.Printf("Saved screenshot of instance %s to %s\n", instance.ID, file)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File to save the screenshot to")
+	return cmd
+}
+
+// imageExtension returns the file extension for an image's format.
+func imageExtension(image []byte) string {
+	switch http.DetectContentType(image) {
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	}
+	return ".img"
+}
@@ -1,41 +1,244 @@
 package instances
 
 import (
+	"context"
 	"fmt"
-	"namaste-cloud/internal" // Import the internal package to load the config
+	"namaste-cloud/clouds"
+	"namaste-cloud/clouds/providers"
+	"namaste-cloud/internal"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // CreateInstanceCommand returns the `create-instance` command.
 func CreateInstanceCommand() *cobra.Command {
-	return &cobra.Command{
+	var spec clouds.InstanceSpec
+	var tagFlags []string
+	var template string
+	var size, arch string
+	var estimate bool
+
+	cmd := &cobra.Command{
 		Use:   "create-instance",
 		Short: "Create an instance in the selected cloud provider",
+		Long: "Create an instance in the selected cloud provider. The default_tags from the configuration\n" +
+			"are added to the tags given with --tag. Firewall rules targeting its tags only apply to it once\n" +
+			"`firewall apply` is run again.\n\n" +
+			"With --template, the instance is created from the variant of a template for the cloud, as\n" +
+			"saved with `template create`. Flags given as well take precedence over the template, and\n" +
+			"tags are added to its tags.\n\n" +
+			"With --size, the instance type is the cheapest in the size catalog of the cloud with at\n" +
+			"least the vCPUs, memory in GiB and GPUs given, as listed by `sizes list`.\n\n" +
+			"The image may be given as an operating system release, such as ubuntu-24.04, debian-12 or\n" +
+			"rhel-9, which is looked up in the region: the current AMI on AWS and the latest image of the\n" +
+			"release's family on GCP. `images list` lists the releases and their images. The image is\n" +
+			"for the architecture given with --arch, or else that of the instance type.\n\n" +
+			"With --estimate, the hourly and monthly cost of the instance and its boot disk, on demand\n" +
+			"and as a spot instance, is printed from the pricing catalog of `cost` instead, and no\n" +
+			"instance is created.",
+		Example: "  namaste-cloud create-instance --name web-07 --image ami-0abcdef1234567890 --type t3.small\n" +
+			"  namaste-cloud create-instance --template web-small --name web-07\n" +
+			"  namaste-cloud create-instance --template web-small --name web-07 --cloud gcp\n" +
+			"  namaste-cloud create-instance --name web-07 --image debian-12 --size 4x16 --cloud gcp\n" +
+			"  namaste-cloud create-instance --name web-07 --image ubuntu-24.04 --type t4g.small\n" +
+			"  namaste-cloud create-instance --name web-07 --size 4x16 --disk-size 50 --estimate",
 		Run: func(cmd *cobra.Command, args []string) {
-			// Load the active cloud provider from the configuration file
+			if size != "" && cmd.Flags().Changed("type") {
+				fmt.Println("Error: give either --size or --type, not both")
+				return
+			}
+			tags, err := clouds.ParseTags(tagFlags)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			if spec.DiskSizeGiB < 0 {
+				fmt.Println("Error: --disk-size must be a positive number of GiB")
+				return
+			}
+			spec := spec
+			spec.Tags = tags
+
 			cfg, err := internal.LoadConfig()
 			if err != nil {
 				fmt.Println("Error loading configuration:", err)
 				return
 			}
+			// Estimates need no provider, so that they work offline
+			var provider clouds.Provider
+			if estimate {
+				if cfg.ActiveCloud == "" {
+					fmt.Println("Error:", providers.ErrNoActiveCloud)
+					return
+				}
+				if !providers.IsSupported(cfg.ActiveCloud) {
+					fmt.Printf("Error: unsupported cloud %q\n", cfg.ActiveCloud)
+					return
+				}
+			} else {
+				provider, err = providers.New(cmd.Context(), cfg)
+				if err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+				defer provider.Close()
+			}
 
-			if cfg.ActiveCloud == "" {
-				fmt.Println("No active cloud provider set. Please use `namaste-cloud use-cloud` to select one.")
+			if template != "" {
+				base, err := providers.TemplateSpec(cfg, template, cfg.ActiveCloud)
+				if err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+				spec = fromTemplate(cmd.Flags(), spec, base)
+			}
+			if size != "" {
+				spec.Type, err = resolveSize(cfg.ActiveCloud, size, arch)
+				if err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+			}
+			if estimate {
+				if err := printEstimate(cfg.ActiveCloud, spec); err != nil {
+					fmt.Println("Error:", err)
+				}
 				return
 			}
 
-			// Assuming we have an amiID for AWS or instance config for other clouds
-			switch cfg.ActiveCloud {
-			case "aws":
-				// awscloud.CreateInstance("ami-12345")
-			case "gcp":
-				// gcpcloud.CreateVM("instance-config")
-			case "azure":
-				// azurecloud.CreateVM("instance-config")
-			default:
-				fmt.Println("Unsupported cloud provider. Please use `namaste-cloud use-cloud` to select a supported cloud provider.")
+			if clouds.IsOSAlias(spec.Image) {
+				imageArch := arch
+				if typeArch := providers.SizeArch(provider.Name(), spec.Type); typeArch != "" && !cmd.Flags().Changed("arch") {
+					imageArch = typeArch
+				}
+				spec.Image, err = resolveImage(cmd.Context(), provider, spec.Image, imageArch)
+				if err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+			}
+
+			instance, err := provider.CreateInstance(cmd.Context(), spec)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			fmt.Printf("Created instance with ID: %s\n", instance.ID)
+			if err := providers.Record(cmd.Context(), providers.InstanceRecord(provider.Name(), instance, spec)); err != nil {
+				fmt.Println("Warning: failed to record the instance in the state:", err)
 			}
 		},
 	}
+
+	cmd.Flags().StringVar(&spec.Name, "name", "", "Name of the instance (required on GCP)")
+	cmd.Flags().StringVar(&spec.Image, "image", "", "Image to boot from: an image ID, such as an AMI ID on AWS, or a release such as ubuntu-24.04")
+	cmd.Flags().StringVar(&spec.Type, "type", "", "Instance or machine type, e.g. t2.micro or e2-micro")
+	cmd.Flags().StringVar(&spec.KeyName, "key-name", "", "Key pair to authorize for SSH, on AWS")
+	cmd.Flags().StringSliceVar(&spec.SecurityGroups, "security-group", nil, "Security group ID to attach, on AWS (repeatable)")
+	cmd.Flags().StringArrayVar(&tagFlags, "tag", nil, "Tag to add as KEY=VALUE (repeatable)")
+	cmd.Flags().StringVar(&template, "template", "", "Template to create the instance from, as saved with `template create`")
+	cmd.Flags().StringVar(&size, "size", "", "Least vCPUs, memory in GiB and GPUs, as CPUSxMEMORY[xGPUS], e.g. 4x16, instead of --type")
+	cmd.Flags().StringVar(&arch, "arch", clouds.ArchX86, "Architecture of the type chosen with --size and of the image given as a release, x86_64 or arm64")
+	cmd.Flags().IntVar(&spec.DiskSizeGiB, "disk-size", 0, "Size of the boot disk in GiB (default: that of the image, or the cloud's default)")
+	cmd.Flags().BoolVar(&estimate, "estimate", false, "Print the estimated hourly and monthly cost of the instance from the pricing catalog, without creating it")
+	return cmd
+}
+
+// fromTemplate returns the spec of a template with the name given, the other
+// flags given taking precedence and the tags given added to its tags.
+func fromTemplate(flags *pflag.FlagSet, spec, template clouds.InstanceSpec) clouds.InstanceSpec {
+	result := template
+	result.Name = spec.Name
+	if flags.Changed("image") {
+		result.Image = spec.Image
+	}
+	if flags.Changed("type") {
+		result.Type = spec.Type
+	}
+	if flags.Changed("disk-size") {
+		result.DiskSizeGiB = spec.DiskSizeGiB
+	}
+	if flags.Changed("key-name") {
+		result.KeyName = spec.KeyName
+	}
+	if flags.Changed("security-group") {
+		result.SecurityGroups = spec.SecurityGroups
+	}
+	result.Tags = clouds.MergeTags(template.Tags, spec.Tags)
+	return result
+}
+
+// resolveSize returns the cheapest instance type of a cloud of at least the
+// given size and of the architecture.
+func resolveSize(cloud, size, arch string) (string, error) {
+	query, err := clouds.ParseSize(size)
+	if err != nil {
+		return "", err
+	}
+	query.Arch = arch
+	catalog, _, err := providers.Sizes(cloud)
+	if err != nil {
+		return "", err
+	}
+	match, err := clouds.CheapestSize(catalog.Sizes, query)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w; see `sizes list`", cloud, err)
+	}
+
+	fmt.Printf("Size %s is %s on %s (%d vCPUs, %s", size, match.Type, cloud, match.CPUs, clouds.FormatGiB(match.MemoryGiB))
+	if match.GPUs > 0 {
+		fmt.Printf(", %d GPUs", match.GPUs)
+	}
+	fmt.Println(")")
+	return match.Type, nil
+}
+
+// resolveImage returns the ID of the current image of an operating system
+// release in the provider's region.
+func resolveImage(ctx context.Context, provider clouds.Provider, alias, arch string) (string, error) {
+	resolver, ok := provider.(clouds.ImageResolver)
+	if !ok {
+		return "", fmt.Errorf("looking up images on %s: %w; give an image ID", provider.Name(), clouds.ErrNotSupported)
+	}
+	image, err := resolver.ResolveImage(ctx, alias, arch)
+	if err != nil {
+		return "", err
+	}
+	if image.Name != "" && image.Name != image.ID {
+		fmt.Printf("Image %s is %s (%s)\n", alias, image.ID, image.Name)
+	} else {
+		fmt.Printf("Image %s is %s\n", alias, image.ID)
+	}
+	return image.ID, nil
+}
+
+// printEstimate prints the cost of an instance of a cloud from the pricing
+// catalog, which works offline.
+func printEstimate(cloud string, spec clouds.InstanceSpec) error {
+	prices, _, err := providers.Prices()
+	if err != nil {
+		return err
+	}
+	estimate, err := providers.EstimateInstance(prices, cloud, spec)
+	if err != nil {
+		return fmt.Errorf("%s: %w; see `sizes list` for the priced types", cloud, err)
+	}
+
+	name := spec.Name
+	if name == "" {
+		name = "the instance"
+	}
+	fmt.Printf("Estimate for %s on %s, from prices in %s (USD):\n", name, cloud, prices[cloud].Region)
+	fmt.Printf("  Instance:   %s\n", estimate.Type)
+	fmt.Printf("  Boot disk:  %d GiB (%s)\n", estimate.DiskGiB, prices[cloud].DiskType)
+	fmt.Printf("  On demand:  %s/hour, %s/month\n", clouds.FormatCost(estimate.OnDemandHourly), clouds.FormatCost(estimate.OnDemandHourly*clouds.HoursPerMonth))
+	if estimate.SpotHourly > 0 {
+		fmt.Printf("  Spot:       %s/hour, %s/month\n", clouds.FormatCost(estimate.SpotHourly), clouds.FormatCost(estimate.SpotHourly*clouds.HoursPerMonth))
+	} else {
+		fmt.Println("  Spot:       no price in the catalog")
+	}
+	fmt.Println("No instance was created; run again without --estimate to create it.")
+	return nil
 }
@@ -0,0 +1,59 @@
+package instances
+
+import (
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/clouds/providers"
+	"namaste-cloud/internal"
+
+	"github.com/spf13/cobra"
+)
+
+// DescribeInstanceCommand returns the `describe-instance` command.
+func DescribeInstanceCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:           "describe-instance [instance]",
+		Short:         "Show the details of an instance",
+		Long:          "Show the details of an instance given by " + instanceRefHelp + ".",
+		Example:       "  namaste-cloud describe-instance web\n  namaste-cloud describe-instance tag:role=db",
+		Args:          cobra.ExactArgs(1),
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Create the provider for the active cloud
+			provider, err := providers.Load(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return &internal.ExitError{Code: 1}
+			}
+			defer provider.Close()
+
+			instance, err := clouds.FindInstance(cmd.Context(), provider, args[0])
+			if err != nil {
+				fmt.Println("Error:", err)
+				return &internal.ExitError{Code: 1}
+			}
+			instance, err = provider.DescribeInstance(cmd.Context(), instance.ID)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return &internal.ExitError{Code: 1}
+			}
+
+			fmt.Printf("Instance ID: %s, State: %s, Public IP: %s\n", instance.ID, instance.State, instance.PublicIP)
+			if len(instance.Tags) > 0 {
+				fmt.Printf("Tags: %s\n", formatTags(instance.Tags))
+			}
+			if protector, ok := provider.(clouds.Protector); ok {
+				protected, err := protector.TerminationProtection(cmd.Context(), instance.ID)
+				if err != nil {
+					fmt.Println("Error:", err)
+					return &internal.ExitError{Code: 1}
+				}
+				if protected {
+					fmt.Println("Termination protection: enabled")
+				}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,255 @@
+package instances
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/clouds/providers"
+	"namaste-cloud/internal"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// StartInstanceCommand returns the `start-instance` command.
+func StartInstanceCommand() *cobra.Command {
+	return lifecycleCommand("start-instance", "Start stopped instances", "Starting", clouds.ActionStart)
+}
+
+// StopInstanceCommand returns the `stop-instance` command.
+func StopInstanceCommand() *cobra.Command {
+	return lifecycleCommand("stop-instance", "Stop running instances", "Stopping", clouds.ActionStop)
+}
+
+// RebootInstanceCommand returns the `reboot-instance` command.
+func RebootInstanceCommand() *cobra.Command {
+	return lifecycleCommand("reboot-instance", "Reboot running instances", "Rebooting", clouds.ActionReboot)
+}
+
+// TerminateInstanceCommand returns the `terminate-instance` command.
+func TerminateInstanceCommand() *cobra.Command {
+	return lifecycleCommand("terminate-instance", "Terminate instances", "Terminating", clouds.ActionTerminate)
+}
+
+// instanceRefHelp describes the ways commands accept to refer to an instance.
+const instanceRefHelp = "ID (ZONE/NAME on GCP, RESOURCE-GROUP/NAME on Azure), name or tag:KEY=VALUE selector;\n" +
+	"a name or selector must match exactly one instance"
+
+// lifecycleOptions are the flags of the lifecycle commands.
+type lifecycleOptions struct {
+	filters  []string
+	yes      bool
+	force    bool
+	dryRun   bool
+	parallel int
+}
+
+// lifecycleCommand builds a command that applies a lifecycle action to the
+// instances given as arguments or selected by filters. The instances are
+// listed and the action confirmed before anything happens.
+func lifecycleCommand(use, short, verb string, action clouds.InstanceAction) *cobra.Command {
+	var opts lifecycleOptions
+
+	cmd := &cobra.Command{
+		Use:   use + " [instance...]",
+		Short: short,
+		Long: short + " given by " + instanceRefHelp + ", or selected with --filter as in\n" +
+			"list-instances. The instances are listed and the action must be confirmed, unless --yes is\n" +
+			"given. The action is applied to all of them in parallel.\n\n" +
+			"Instances matching a protection rule in the configuration are refused unless --force is\n" +
+			"given and their names are typed back, and instances with termination protection enabled\n" +
+			"are never terminated.",
+		Example: fmt.Sprintf("  namaste-cloud %s web\n", use) +
+			fmt.Sprintf("  namaste-cloud %s --filter tag:env=staging --dry-run\n", use) +
+			fmt.Sprintf("  namaste-cloud %s i-0abc123 i-0def456 --yes", use),
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && len(opts.filters) == 0 {
+				fmt.Println("Error: give one or more instances or at least one --filter")
+				return &internal.ExitError{Code: 1}
+			}
+			filters, err := clouds.ParseFilters(opts.filters)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return &internal.ExitError{Code: 1}
+			}
+			if opts.parallel < 1 {
+				fmt.Println("Error: --parallel must be at least 1")
+				return &internal.ExitError{Code: 1}
+			}
+
+			// Create the provider for the active cloud
+			provider, err := providers.Load(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return &internal.ExitError{Code: 1}
+			}
+			defer provider.Close()
+
+			instances, err := selectInstances(cmd.Context(), provider, args, filters)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return &internal.ExitError{Code: 1}
+			}
+			if len(instances) == 0 {
+				fmt.Println("No instances match the filters.")
+				return nil
+			}
+			printInstanceTable(instances)
+
+			allowed, err := checkProtection(cmd.Context(), provider, action, instances, opts.force, opts.dryRun, opts.parallel)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return &internal.ExitError{Code: 1}
+			}
+			if !allowed {
+				return &internal.ExitError{Code: 1}
+			}
+
+			if opts.dryRun {
+				return dryRun(cmd.Context(), provider, action, instances, opts.parallel)
+			}
+			question := fmt.Sprintf("%s %s?", strings.ToUpper(string(action[:1]))+string(action[1:]), countInstances(len(instances)))
+			if !opts.yes && !internal.Confirm(question) {
+				fmt.Println("Aborted; no instances were changed.")
+				return nil
+			}
+
+			errs := forEachInstance(instances, opts.parallel, func(_ int, instance clouds.Instance) error {
+				return clouds.ApplyInstanceAction(cmd.Context(), provider, action, instance.ID)
+			})
+			failed := false
+			var terminated []internal.StateRecord
+			for i, instance := range instances {
+				if errs[i] != nil {
+					fmt.Printf("Error: %s: %v\n", instance.ID, errs[i])
+					failed = true
+					continue
+				}
+				fmt.Printf("%s instance with ID: %s\n", verb, instance.ID)
+				if action == clouds.ActionTerminate {
+					terminated = append(terminated, internal.StateRecord{Cloud: provider.Name(), Kind: clouds.KindInstance, ID: instance.ID})
+				}
+			}
+			if len(terminated) > 0 {
+				if err := providers.Forget(cmd.Context(), terminated...); err != nil {
+					fmt.Println("Warning: failed to remove the instances from the state:", err)
+				}
+			}
+			if failed {
+				return &internal.ExitError{Code: 1}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&opts.filters, "filter", nil, "Select instances matching KEY=VALUE, e.g. tag:env=staging (repeatable)")
+	cmd.Flags().BoolVarP(&opts.yes, "yes", "y", false, "Do not ask for confirmation")
+	cmd.Flags().BoolVar(&opts.force, "force", false, "Act on instances protected by a protection rule in the configuration, after typing their names")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Only check whether the action would succeed, with EC2's DryRun parameter on AWS")
+	cmd.Flags().IntVar(&opts.parallel, "parallel", 10, "Maximum number of instances to act on at once")
+	return cmd
+}
+
+// selectInstances resolves the instance arguments and adds the instances
+// matching the filters, each once, in the order they were given or listed.
+// Filters skip terminated instances.
+func selectInstances(ctx context.Context, provider clouds.Provider, refs []string, filters []clouds.Filter) ([]clouds.Instance, error) {
+	var selected []clouds.Instance
+	seen := make(map[string]bool)
+	add := func(instance clouds.Instance) {
+		if !seen[instance.ID] {
+			seen[instance.ID] = true
+			selected = append(selected, instance)
+		}
+	}
+
+	for _, ref := range refs {
+		instance, err := clouds.FindInstance(ctx, provider, ref)
+		if err != nil {
+			return nil, err
+		}
+		add(instance)
+	}
+
+	if len(filters) > 0 {
+		instances, err := provider.ListInstances(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, instance := range clouds.FilterInstances(instances, filters) {
+			if !clouds.IsTerminated(instance) {
+				add(instance)
+			}
+		}
+	}
+	return selected, nil
+}
+
+// dryRun checks whether the action would succeed on every instance, on
+// clouds that support checking it.
+func dryRun(ctx context.Context, provider clouds.Provider, action clouds.InstanceAction, instances []clouds.Instance, parallel int) error {
+	checker, ok := provider.(clouds.DryRunner)
+	if !ok {
+		fmt.Printf("Dry run: %s cannot check the action in advance; no instances were changed.\n", provider.Name())
+		return nil
+	}
+
+	errs := forEachInstance(instances, parallel, func(_ int, instance clouds.Instance) error {
+		return checker.DryRunInstanceAction(ctx, action, instance.ID)
+	})
+	failed := false
+	for i, instance := range instances {
+		if errs[i] != nil {
+			fmt.Printf("Error: %s: %v\n", instance.ID, errs[i])
+			failed = true
+			continue
+		}
+		fmt.Printf("Dry run: would %s instance with ID: %s\n", action, instance.ID)
+	}
+	if failed {
+		return &internal.ExitError{Code: 1}
+	}
+	return nil
+}
+
+// forEachInstance calls fn for every instance and its index, at most
+// parallel at a time, and returns the errors in the order of the instances.
+func forEachInstance(instances []clouds.Instance, parallel int, fn func(int, clouds.Instance) error) []error {
+	errs := make([]error, len(instances))
+	slots := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, instance := range instances {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, instance clouds.Instance) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			errs[i] = fn(i, instance)
+		}(i, instance)
+	}
+	wg.Wait()
+	return errs
+}
+
+// printInstanceTable lists the instances an action applies to.
+func printInstanceTable(instances []clouds.Instance) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSTATE\tTYPE\tLOCATION")
+	for _, instance := range instances {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", instance.ID, instance.Name, instance.State, instance.Type, instance.Location)
+	}
+	w.Flush()
+}
+
+// countInstances returns "1 instance" or "N instances".
+func countInstances(n int) string {
+	if n == 1 {
+		return "1 instance"
+	}
+	return fmt.Sprintf("%d instances", n)
+}
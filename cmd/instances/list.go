@@ -1,44 +1,49 @@
-// instances/listInstances.go
 package instances
 
 import (
 	"fmt"
-	awscloud "namaste-cloud/clouds/aws-cloud"
-	gcpcloud "namaste-cloud/clouds/gcp-cloud"
-	"namaste-cloud/internal" // Import the internal package to load the config
+	"namaste-cloud/clouds"
+	"namaste-cloud/clouds/providers"
 
 	"github.com/spf13/cobra"
 )
 
 // ListInstancesCommand returns the `list-instances` command.
 func ListInstancesCommand() *cobra.Command {
-	return &cobra.Command{
+	var filterFlags []string
+
+	cmd := &cobra.Command{
 		Use:   "list-instances",
 		Short: "List instances/VMs in the selected cloud provider",
+		Example: "  namaste-cloud list-instances --filter state=running\n" +
+			"  namaste-cloud list-instances --filter tag:role=web --filter name='web-*'",
 		Run: func(cmd *cobra.Command, args []string) {
-			// Load the active cloud provider from the configuration file
-			cfg, err := internal.LoadConfig()
+			filters, err := clouds.ParseFilters(filterFlags)
 			if err != nil {
-				fmt.Println("Error loading configuration:", err)
+				fmt.Println("Error:", err)
 				return
 			}
 
-			if cfg.ActiveCloud == "" {
-				fmt.Println("No active cloud provider set. Please use `namaste-cloud use-cloud` to select one.")
+			// Create the provider for the active cloud
+			provider, err := providers.Load(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
 				return
 			}
+			defer provider.Close()
 
-			// Perform the cloud-specific action based on the active cloud provider
-			switch cfg.ActiveCloud {
-			case "aws":
-				awscloud.ExecuteAWSCommand("list-instances")
-			case "gcp":
-				gcpcloud.ExecuteGCPCommand("list-vms")
-			case "azure":
-				// azurecloud.ExecuteGCPCommand("list-compute")
-			default:
-				fmt.Println("Unsupported cloud provider. Please use `namaste-cloud use-cloud` to select a supported cloud provider.")
+			instances, err := provider.ListInstances(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			for _, instance := range clouds.FilterInstances(instances, filters) {
+				fmt.Printf("Instance ID: %s, State: %s\n", instance.ID, instance.State)
 			}
 		},
 	}
+
+	cmd.Flags().StringArrayVar(&filterFlags, "filter", nil, "Only list instances matching KEY=VALUE, e.g. state=running or tag:role=web (repeatable)")
+	return cmd
 }
@@ -0,0 +1,138 @@
+package instances
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/clouds/providers"
+	"namaste-cloud/internal"
+
+	"github.com/spf13/cobra"
+)
+
+// ProtectCommand returns the `protect` command.
+func ProtectCommand() *cobra.Command {
+	return protectionCommand("protect", "Enable termination protection for instances", true)
+}
+
+// UnprotectCommand returns the `unprotect` command.
+func UnprotectCommand() *cobra.Command {
+	return protectionCommand("unprotect", "Disable termination protection for instances", false)
+}
+
+// protectionCommand builds a command that manages the provider-side
+// termination protection of instances.
+func protectionCommand(use, short string, enabled bool) *cobra.Command {
+	return &cobra.Command{
+		Use:   use + " [instance...]",
+		Short: short,
+		Long: short + " given by " + instanceRefHelp + ".\n" +
+			"This is EC2 termination protection on AWS, deletion protection on GCP and a CanNotDelete\n" +
+			"lock on Azure. Protected instances cannot be terminated, even with --force.",
+		Example: fmt.Sprintf("  namaste-cloud %s web db", use),
+		Args:    cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			// Create the provider for the active cloud
+			provider, err := providers.Load(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			defer provider.Close()
+
+			protector, ok := provider.(clouds.Protector)
+			if !ok {
+				fmt.Println("Error:", fmt.Errorf("termination protection on %s: %w", provider.Name(), clouds.ErrNotSupported))
+				return
+			}
+
+			state := "Disabled"
+			if enabled {
+				state = "Enabled"
+			}
+			for _, ref := range args {
+				instance, err := clouds.FindInstance(cmd.Context(), provider, ref)
+				if err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+				if err := protector.SetTerminationProtection(cmd.Context(), instance.ID, enabled); err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+				fmt.Printf("%s termination protection for instance %s\n", state, instance.ID)
+			}
+		},
+	}
+}
+
+// checkProtection refuses an action on protected instances. Instances with
+// provider-side termination protection are never terminated. Instances
+// protected by a rule in the configuration are only acted on with force,
+// once their names have been typed back, which is skipped for dry runs.
+// It reports whether the action may go ahead, having printed why not.
+func checkProtection(ctx context.Context, provider clouds.Provider, action clouds.InstanceAction, instances []clouds.Instance, force, dryRun bool, parallel int) (bool, error) {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return false, err
+	}
+	rules, err := providers.ProtectionRules(cfg)
+	if err != nil {
+		return false, err
+	}
+
+	allowed := true
+	if protector, ok := provider.(clouds.Protector); ok && action == clouds.ActionTerminate {
+		protected := make([]bool, len(instances))
+		errs := forEachInstance(instances, parallel, func(i int, instance clouds.Instance) error {
+			var err error
+			protected[i], err = protector.TerminationProtection(ctx, instance.ID)
+			return err
+		})
+		for i, instance := range instances {
+			if errs[i] != nil {
+				return false, errs[i]
+			}
+			if protected[i] {
+				fmt.Printf("Error: instance %s has termination protection enabled; run `unprotect %s` first\n", instance.ID, instance.ID)
+				allowed = false
+			}
+		}
+	}
+
+	var guarded []clouds.Instance
+	for _, instance := range instances {
+		for _, rule := range rules {
+			if !rule.Protects(instance, action) {
+				continue
+			}
+			if !force {
+				fmt.Printf("Error: instance %s is protected by the rule %q; use --force to %s it anyway\n", instance.ID, rule.String(), action)
+				allowed = false
+			}
+			guarded = append(guarded, instance)
+			break
+		}
+	}
+	if !allowed || !force {
+		return allowed, nil
+	}
+
+	// Forcing requires typing back the name of every protected instance
+	for _, instance := range guarded {
+		name := instance.Name
+		if name == "" {
+			name = instance.ID
+		}
+		if dryRun {
+			fmt.Printf("Dry run: instance %s is protected; --force will ask for its name\n", instance.ID)
+			continue
+		}
+		typed, err := internal.Prompt(fmt.Sprintf("Instance %s is protected. Type its name, %s, to %s it: ", instance.ID, name, action))
+		if err != nil || typed != name {
+			fmt.Println("The name does not match; no instances were changed.")
+			return false, nil
+		}
+	}
+	return true, nil
+}
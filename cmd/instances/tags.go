@@ -0,0 +1,104 @@
+package instances
+
+import (
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/clouds/providers"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// TagCommand returns the `tag` command.
+func TagCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "tag [instance] [key=value...]",
+		Short: "Add tags to an instance",
+		Long: "Add tags to an instance given by ID, name or tag:KEY=VALUE, replacing the values of tags\n" +
+			"it already has. On GCP tags are labels: keys and values are lowercased and characters labels\n" +
+			"do not allow are replaced with underscores. Firewall rules targeting the new tags only apply\n" +
+			"to the instance once `firewall apply` is run again.",
+		Example: "  namaste-cloud tag web env=prod owner=platform",
+		Args:    cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			tags, err := clouds.ParseTags(args[1:])
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			provider, tagger, instance, err := loadTagger(cmd, args[0])
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			defer provider.Close()
+
+			if err := tagger.TagInstance(cmd.Context(), instance.ID, tags); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			fmt.Printf("Tagged instance %s with %s\n", instance.ID, formatTags(tags))
+			if err := providers.RecordTags(cmd.Context(), provider, instance.ID, tags, nil); err != nil {
+				fmt.Println("Warning: failed to record the tags in the state:", err)
+			}
+		},
+	}
+}
+
+// UntagCommand returns the `untag` command.
+func UntagCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:     "untag [instance] [key...]",
+		Short:   "Remove tags from an instance",
+		Example: "  namaste-cloud untag web owner",
+		Args:    cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			provider, tagger, instance, err := loadTagger(cmd, args[0])
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			defer provider.Close()
+
+			if err := tagger.UntagInstance(cmd.Context(), instance.ID, args[1:]); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			fmt.Printf("Removed tags %s from instance %s\n", strings.Join(args[1:], ", "), instance.ID)
+			if err := providers.RecordTags(cmd.Context(), provider, instance.ID, nil, args[1:]); err != nil {
+				fmt.Println("Warning: failed to record the tags in the state:", err)
+			}
+		},
+	}
+}
+
+// loadTagger creates the provider for the active cloud and resolves an
+// instance ID or name, for the tag and untag commands.
+func loadTagger(cmd *cobra.Command, ref string) (clouds.Provider, clouds.Tagger, clouds.Instance, error) {
+	provider, err := providers.Load(cmd.Context())
+	if err != nil {
+		return nil, nil, clouds.Instance{}, err
+	}
+
+	tagger, ok := provider.(clouds.Tagger)
+	if !ok {
+		provider.Close()
+		return nil, nil, clouds.Instance{}, fmt.Errorf("tags on %s: %w", provider.Name(), clouds.ErrNotSupported)
+	}
+	instance, err := clouds.FindInstance(cmd.Context(), provider, ref)
+	if err != nil {
+		provider.Close()
+		return nil, nil, clouds.Instance{}, err
+	}
+	return provider, tagger, instance, nil
+}
+
+// formatTags formats tags as KEY=VALUE pairs ordered by key.
+func formatTags(tags map[string]string) string {
+	var pairs []string
+	for _, key := range clouds.SortedKeys(tags) {
+		pairs = append(pairs, key+"="+tags[key])
+	}
+	return strings.Join(pairs, ", ")
+}
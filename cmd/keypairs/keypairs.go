@@ -0,0 +1,288 @@
+package keypairs
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/clouds/providers"
+	"namaste-cloud/internal"
+	"os"
+	"os/user"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+// KeyPairsCommand returns the `keypairs` command group.
+func KeyPairsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "keypairs",
+		Aliases: []string{"key-pairs"},
+		Short:   "Manage SSH key pairs",
+		Long: "Manage SSH key pairs: EC2 key pairs on AWS, SSH keys in project or instance metadata on GCP\n" +
+			"and SSH public key resources on Azure. Private keys are never printed.",
+	}
+
+	cmd.AddCommand(createCommand())
+	cmd.AddCommand(importCommand())
+	cmd.AddCommand(listCommand())
+	cmd.AddCommand(deleteCommand())
+	return cmd
+}
+
+// loadKeyPairs creates the provider for the active cloud, which must support key pairs.
+func loadKeyPairs(ctx context.Context) (clouds.Provider, clouds.KeyPairs, error) {
+	provider, err := providers.Load(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyPairs, ok := provider.(clouds.KeyPairs)
+	if !ok {
+		provider.Close()
+		return nil, nil, fmt.Errorf("key pairs on %s: %w", provider.Name(), clouds.ErrNotSupported)
+	}
+	return provider, keyPairs, nil
+}
+
+// addScopeFlags adds the flags selecting who and where a key applies to.
+func addScopeFlags(cmd *cobra.Command, key *clouds.KeyPair) {
+	cmd.Flags().StringVar(&key.User, "user", "", "Login user the key is for, on GCP (defaults to your local user name)")
+	cmd.Flags().StringVar(&key.Instance, "instance", "", "Limit the key to one instance, on GCP (defaults to the whole project)")
+}
+
+// defaultUser fills in the local user name when no login user was given.
+func defaultUser(key *clouds.KeyPair) {
+	if key.User != "" {
+		return
+	}
+	if current, err := user.Current(); err == nil {
+		key.User = current.Username
+	}
+}
+
+// defaultKeyPath returns where a new private key is written when neither
+// --output nor --store is given: ~/.ssh/NAME.pem.
+func defaultKeyPath(name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(home, ".ssh")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".pem"), nil
+}
+
+// writePrivateKey writes a private key to a new file only its owner can read.
+// Existing files are never overwritten.
+func writePrivateKey(path, privateKey string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(privateKey); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func createCommand() *cobra.Command {
+	var scope clouds.KeyPair
+	var output string
+	var store bool
+
+	cmd := &cobra.Command{
+		Use:   "create [name]",
+		Short: "Create a key pair and save its private key to a file or the credential store",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			key := scope
+			key.Name = args[0]
+			defaultUser(&key)
+			if store && output != "" {
+				fmt.Println("Error: use either --output or --store, not both")
+				return
+			}
+
+			// Check where the private key goes before creating anything: the
+			// cloud hands it out only once
+			path := output
+			if !store && path == "" {
+				var err error
+				if path, err = defaultKeyPath(key.Name); err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+			}
+			if path != "" {
+				if _, err := os.Stat(path); err == nil {
+					fmt.Printf("Error: %s already exists\n", path)
+					return
+				}
+			}
+
+			provider, keyPairs, err := loadKeyPairs(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			defer provider.Close()
+
+			created, privateKey, err := keyPairs.CreateKeyPair(cmd.Context(), key)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			if err := providers.Record(cmd.Context(), providers.KeyPairRecord(provider.Name(), created)); err != nil {
+				fmt.Println("Warning: failed to record the key pair in the state:", err)
+			}
+
+			if store {
+				cfg, err := internal.LoadConfig()
+				if err == nil {
+					err = internal.SavePrivateKey(provider.Name(), cfg.Profile, created.Name, privateKey)
+				}
+				if err != nil {
+					fmt.Println("Error: failed to store the private key:", err)
+					return
+				}
+				fmt.Printf("Created key pair: %s; private key saved in the credential store\n", created.Name)
+				return
+			}
+			if err := writePrivateKey(path, privateKey); err != nil {
+				fmt.Println("Error: failed to save the private key:", err)
+				return
+			}
+			fmt.Printf("Created key pair: %s; private key written to %s\n", created.Name, path)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File to write the private key to (defaults to ~/.ssh/NAME.pem)")
+	cmd.Flags().BoolVar(&store, "store", false, "Save the private key in the encrypted credential store instead of a file")
+	addScopeFlags(cmd, &scope)
+	return cmd
+}
+
+func importCommand() *cobra.Command {
+	var scope clouds.KeyPair
+	var publicKeyFile string
+
+	cmd := &cobra.Command{
+		Use:   "import [name]",
+		Short: "Upload the public key of an existing key pair",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			key := scope
+			key.Name = args[0]
+			defaultUser(&key)
+
+			publicKey, err := os.ReadFile(publicKeyFile)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			key.PublicKey = string(publicKey)
+
+			provider, keyPairs, err := loadKeyPairs(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			defer provider.Close()
+
+			imported, err := keyPairs.ImportKeyPair(cmd.Context(), key)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			fmt.Printf("Imported key pair: %s, Fingerprint: %s\n", imported.Name, imported.Fingerprint)
+			if err := providers.Record(cmd.Context(), providers.KeyPairRecord(provider.Name(), imported)); err != nil {
+				fmt.Println("Warning: failed to record the key pair in the state:", err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&publicKeyFile, "public-key-file", "", "Public key to upload, such as ~/.ssh/id_ed25519.pub")
+	cmd.MarkFlagRequired("public-key-file")
+	addScopeFlags(cmd, &scope)
+	return cmd
+}
+
+func listCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List key pairs",
+		Run: func(cmd *cobra.Command, args []string) {
+			provider, keyPairs, err := loadKeyPairs(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			defer provider.Close()
+
+			keys, err := keyPairs.ListKeyPairs(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			for _, key := range keys {
+				fmt.Printf("Key Pair: %s, Fingerprint: %s", key.Name, key.Fingerprint)
+				if key.User != "" {
+					fmt.Printf(", User: %s", key.User)
+				}
+				if key.Instance != "" {
+					fmt.Printf(", Instance: %s", key.Instance)
+				}
+				fmt.Println()
+			}
+		},
+	}
+}
+
+func deleteCommand() *cobra.Command {
+	var instance string
+
+	cmd := &cobra.Command{
+		Use:   "delete [name]",
+		Short: "Delete a key pair and any private key stored for it",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			key := clouds.KeyPair{Name: args[0], Instance: instance}
+
+			provider, keyPairs, err := loadKeyPairs(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			defer provider.Close()
+
+			if err := keyPairs.DeleteKeyPair(cmd.Context(), key); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			cfg, err := internal.LoadConfig()
+			if err == nil {
+				err = internal.DeletePrivateKey(provider.Name(), cfg.Profile, key.Name)
+			}
+			if err != nil {
+				fmt.Println("Error: failed to remove the stored private key:", err)
+				return
+			}
+
+			fmt.Printf("Deleted key pair: %s\n", key.Name)
+			if err := providers.Forget(cmd.Context(), internal.StateRecord{Cloud: provider.Name(), Kind: clouds.KindKeyPair, ID: key.Name}); err != nil {
+				fmt.Println("Warning: failed to remove the key pair from the state:", err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&instance, "instance", "", "Instance the key is limited to, on GCP")
+	return cmd
+}
@@ -1,9 +1,25 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"namaste-cloud/cmd/cost"
+	"namaste-cloud/cmd/firewall"
+	"namaste-cloud/cmd/images"
+	"namaste-cloud/cmd/infra"
 	"namaste-cloud/cmd/instances"
+	"namaste-cloud/cmd/keypairs"
+	"namaste-cloud/cmd/securitygroups"
+	"namaste-cloud/cmd/sizes"
+	"namaste-cloud/cmd/ssh"
+	"namaste-cloud/cmd/state"
+	"namaste-cloud/cmd/templates"
+	"namaste-cloud/internal"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -13,24 +29,145 @@ var RootCmd = &cobra.Command{
 	Use:   "namaste-cloud",
 	Short: "Namaste Cloud CLI for managing cloud resources",
 	Long:  "A CLI tool to manage cloud resources across AWS, GCP, and Azure.",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// Apply the global overrides to this invocation only. TLS verification
+		// is only overridden when the flag is given, so that it can be turned
+		// back on with --skip-tls-verify=false.
+		overrides.SkipTLSVerify = nil
+		if cmd.Flags().Changed("skip-tls-verify") {
+			overrides.SkipTLSVerify = &skipTLSVerify
+		}
+		internal.SetOverrides(overrides)
+
+		// Bound the whole command, including every provider call, by --timeout
+		if timeout > 0 {
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			cancelTimeout = cancel
+			cmd.SetContext(ctx)
+		}
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("Welcome to Namaste Cloud CLI!")
 	},
 }
 
+// overrides holds the values of the global configuration override flags.
+var overrides internal.Config
+
+// skipTLSVerify is the value of --skip-tls-verify.
+var skipTLSVerify bool
+
+// timeout bounds the duration of a command; zero means no limit.
+var timeout time.Duration
+
+// cancelTimeout releases the timeout context once the command has finished.
+var cancelTimeout = func() {}
+
 // Execute adds all child commands to the root command and runs the CLI.
+// The command runs with a context that is cancelled on SIGINT or SIGTERM.
 func Execute() {
-	if err := RootCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Restore the default signal behaviour once cancelled so that a second
+	// Ctrl-C terminates the process immediately.
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := RootCmd.ExecuteContext(ctx)
+	cancelTimeout()
+	var exitErr *internal.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
 		fmt.Println(err)
+	}
+
+	// Commands that fail return an ExitError once cancelled, so the report
+	// comes before the exit code is decided
+	cancelled := reportCancelledOperations()
+	switch {
+	case exitErr != nil:
+		os.Exit(exitErr.Code)
+	case err != nil || cancelled:
 		os.Exit(1)
 	}
 }
 
+// reportCancelledOperations prints which provider operations completed and
+// which were cancelled when the command was interrupted or timed out. It
+// reports whether anything was cancelled.
+func reportCancelledOperations() bool {
+	ops := internal.Operations()
+
+	cancelled := false
+	for _, op := range ops {
+		if op.Cancelled() {
+			cancelled = true
+			break
+		}
+	}
+	if !cancelled {
+		return false
+	}
+
+	fmt.Println("\nThe command was cancelled before it finished:")
+	for _, op := range ops {
+		switch {
+		case op.Cancelled():
+			fmt.Printf("  cancelled  %s\n", op.Description)
+		case op.Failed():
+			fmt.Printf("  failed     %s\n", op.Description)
+		default:
+			fmt.Printf("  completed  %s\n", op.Description)
+		}
+	}
+
+	return true
+}
+
 func init() {
+	RootCmd.PersistentFlags().StringVar(&overrides.ActiveCloud, "cloud", "", "Cloud provider to use for this command (aws, gcp, azure, fake)")
+	RootCmd.PersistentFlags().StringVar(&overrides.Region, "region", "", "Region to use for this command")
+	RootCmd.PersistentFlags().StringVar(&overrides.Zone, "zone", "", "Zone to use for this command")
+	RootCmd.PersistentFlags().StringVar(&overrides.Project, "project", "", "GCP project ID or Azure resource group to use for this command")
+	RootCmd.PersistentFlags().StringVar(&overrides.Profile, "profile", "", "Credential profile to use for this command")
+	RootCmd.PersistentFlags().IntVar(&overrides.Retry.MaxAttempts, "retry-attempts", 0, "Maximum attempts for each cloud API call, including the first (default 5)")
+	RootCmd.PersistentFlags().DurationVar((*time.Duration)(&overrides.Retry.BaseDelay), "retry-delay", 0, "Initial delay between retries, doubled on each attempt (default 500ms)")
+	RootCmd.PersistentFlags().DurationVar((*time.Duration)(&overrides.Retry.MaxDelay), "retry-max-delay", 0, "Maximum delay between retries (default 20s)")
+	RootCmd.PersistentFlags().StringVar(&overrides.EndpointURL, "endpoint-url", "", "Send cloud API calls to this endpoint instead, e.g. a local emulator")
+	RootCmd.PersistentFlags().BoolVar(&skipTLSVerify, "skip-tls-verify", false, "Do not verify the TLS certificate of the endpoint (local emulators only)")
+	RootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "Maximum time the command may run, e.g. 30s or 5m (0 for no limit)")
+
 	RootCmd.AddCommand(ConfigureCommand())
 	RootCmd.AddCommand(UseCloudCommand())
 	RootCmd.AddCommand(StatusCommand())
 	RootCmd.AddCommand(instances.ListInstancesCommand())
 	RootCmd.AddCommand(instances.CreateInstanceCommand())
-
+	RootCmd.AddCommand(templates.TemplateCommand())
+	RootCmd.AddCommand(sizes.SizesCommand())
+	RootCmd.AddCommand(images.ImagesCommand())
+	RootCmd.AddCommand(cost.CostCommand())
+	RootCmd.AddCommand(instances.DescribeInstanceCommand())
+	RootCmd.AddCommand(instances.StartInstanceCommand())
+	RootCmd.AddCommand(instances.StopInstanceCommand())
+	RootCmd.AddCommand(instances.RebootInstanceCommand())
+	RootCmd.AddCommand(instances.TerminateInstanceCommand())
+	RootCmd.AddCommand(instances.TagCommand())
+	RootCmd.AddCommand(instances.UntagCommand())
+	RootCmd.AddCommand(instances.ProtectCommand())
+	RootCmd.AddCommand(instances.UnprotectCommand())
+	RootCmd.AddCommand(instances.ConsoleOutputCommand())
+	RootCmd.AddCommand(instances.ConsoleScreenshotCommand())
+	RootCmd.AddCommand(securitygroups.SecurityGroupsCommand())
+	RootCmd.AddCommand(firewall.FirewallCommand())
+	RootCmd.AddCommand(keypairs.KeyPairsCommand())
+	RootCmd.AddCommand(infra.PlanCommand())
+	RootCmd.AddCommand(infra.ApplyCommand())
+	RootCmd.AddCommand(infra.DriftCommand())
+	RootCmd.AddCommand(infra.ImportCommand())
+	RootCmd.AddCommand(state.StateCommand())
+	RootCmd.AddCommand(ssh.SSHCommand())
+	RootCmd.AddCommand(ssh.SCPCommand())
+	RootCmd.AddCommand(ssh.ExecCommand())
 }
@@ -0,0 +1,750 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"namaste-cloud/internal"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// goldenDir is resolved up front because the tests change directory.
+var goldenDir, _ = filepath.Abs("testdata")
+
+// fakeState is the fake cloud state every command test starts from. No
+// transitions are pending, so the output does not depend on timing.
+const fakeState = `{
+  "instances": {
+    "fake-0a1b2c3d": {
+      "ID": "fake-0a1b2c3d",
+      "Name": "web",
+      "State": "running",
+      "Type": "fake.small",
+      "Location": "fake-region-1a",
+      "PublicIP": "203.0.113.7",
+      "PrivateIP": "10.0.3.4",
+      "LaunchTime": "2024-05-01T09:30:00Z",
+      "KeyName": "deploy",
+      "Tags": {"role": "web"}
+    },
+    "fake-4e5f6a7b": {
+      "ID": "fake-4e5f6a7b",
+      "Name": "worker",
+      "State": "stopped",
+      "Type": "fake.large",
+      "Location": "fake-region-1a",
+      "PrivateIP": "10.0.3.5",
+      "LaunchTime": "2024-05-02T14:00:00Z",
+      "Tags": {"role": "worker"}
+    }
+  }
+}`
+
+// setupFakeCloud creates a configuration directory that selects the fake
+// cloud, seeded with fakeState, and runs the test from an empty directory
+// so that no project configuration is picked up.
+func setupFakeCloud(t *testing.T) {
+	t.Helper()
+
+	configDir := t.TempDir()
+	t.Setenv(internal.ConfigDirEnv, configDir)
+	files := map[string]string{
+		"config.json":     `{"active_cloud": "fake", "region": "fake-region-1"}`,
+		"fake-cloud.json": fakeState,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(configDir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// runCommand runs the CLI with the given arguments and returns what it printed.
+func runCommand(t *testing.T, args ...string) string {
+	t.Helper()
+	return runCommandWithInput(t, "", args...)
+}
+
+// runCommandWithInput runs the CLI with the given arguments and standard
+// input and returns what it printed. Exit statuses are left to tests that
+// check them.
+func runCommandWithInput(t *testing.T, input string, args ...string) string {
+	t.Helper()
+
+	// Flags keep their values between runs
+	overrides = internal.Config{}
+	timeout = 0
+	resetFlags(RootCmd)
+
+	stdinFile := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(stdinFile, []byte(input), 0600); err != nil {
+		t.Fatal(err)
+	}
+	in, err := os.Open(stdinFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	stdin := os.Stdin
+	os.Stdin = in
+	defer func() { os.Stdin = stdin }()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- data
+	}()
+
+	RootCmd.SetArgs(args)
+	err = RootCmd.ExecuteContext(context.Background())
+	w.Close()
+	data := <-output
+	var exitErr *internal.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatalf("%v: %v", args, err)
+	}
+	return string(data)
+}
+
+// resetFlags restores every flag of the command and its subcommands to its default value.
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			slice.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, sub := range cmd.Commands() {
+		resetFlags(sub)
+	}
+}
+
+// assertGolden compares output with the golden file, or rewrites the golden
+// file when the tests run with -update.
+func assertGolden(t *testing.T, name, output string) {
+	t.Helper()
+
+	path := filepath.Join(goldenDir, name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(output), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal([]byte(output), want) {
+		t.Errorf("output differs from %s\ngot:\n%s\nwant:\n%s", path, output, want)
+	}
+}
+
+func TestCommandOutput(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"status", []string{"status"}},
+		{"status_overrides", []string{"status", "--region", "fake-region-2", "--profile", "ci", "--endpoint-url", "http://localhost:4566"}},
+		{"list_instances", []string{"list-instances"}},
+		{"list_instances_filter", []string{"list-instances", "--filter", "tag:role=web", "--filter", "state=run*"}},
+		{"describe_instance", []string{"describe-instance", "fake-0a1b2c3d"}},
+		{"describe_instance_not_found", []string{"describe-instance", "fake-00000000"}},
+		{"describe_instance_by_name", []string{"describe-instance", "web"}},
+		{"describe_instance_by_tag", []string{"describe-instance", "tag:role=worker"}},
+		{"describe_instance_ambiguous", []string{"describe-instance", "tag:role"}},
+		{"stop_instance_by_tag", []string{"stop-instance", "tag:role=web", "--yes"}},
+		{"stop_instance", []string{"stop-instance", "fake-0a1b2c3d", "--yes"}},
+		{"start_instance_running", []string{"start-instance", "fake-0a1b2c3d", "--yes"}},
+		{"console_output", []string{"console-output", "web"}},
+		{"console_screenshot", []string{"console-screenshot", "web"}},
+		{"use_cloud_unsupported", []string{"use-cloud", "oracle"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupFakeCloud(t)
+			assertGolden(t, tt.name, runCommand(t, tt.args...))
+		})
+	}
+}
+
+// TestStartTerminatedInstance checks that filters do not select terminated
+// instances, which cannot be started.
+func TestStartTerminatedInstance(t *testing.T) {
+	setupFakeCloud(t)
+	runCommand(t, "terminate-instance", "worker", "--yes")
+	if output := runCommand(t, "start-instance", "--filter", "tag:role=worker", "--yes"); !strings.Contains(output, "No instances match the filters.") {
+		t.Errorf("got %q, want no instances to start", output)
+	}
+}
+
+// TestInstanceCommandsExitStatus checks that instance commands that fail
+// exit with status 1, so that scripts can tell.
+func TestInstanceCommandsExitStatus(t *testing.T) {
+	for _, args := range [][]string{
+		{"terminate-instance", "nosuch", "--yes"},
+		{"stop-instance", "x", "--cloud", "bogus"},
+		{"start-instance", "--filter", "nosuch"},
+		{"describe-instance", "nosuch"},
+	} {
+		setupFakeCloud(t)
+		overrides = internal.Config{}
+		resetFlags(RootCmd)
+		RootCmd.SetArgs(args)
+		err := RootCmd.ExecuteContext(context.Background())
+		var exitErr *internal.ExitError
+		if !errors.As(err, &exitErr) || exitErr.Code != 1 {
+			t.Errorf("%s: got error %v, want exit status 1", strings.Join(args, " "), err)
+		}
+	}
+}
+
+func TestFirewallCommands(t *testing.T) {
+	setupFakeCloud(t)
+	rules := `rules:
+  - name: allow-ssh
+    protocol: tcp
+    ports: ["22"]
+    sources: ["198.51.100.0/24"]
+    target_tags: [role=web]
+  - name: allow-dns-out
+    direction: egress
+    protocol: udp
+    ports: ["53"]
+    destinations: ["0.0.0.0/0"]
+`
+	if err := os.WriteFile("rules.yaml", []byte(rules), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	output := runCommand(t, "firewall", "apply", "-f", "rules.yaml")
+	output += runCommand(t, "firewall", "list")
+	output += runCommand(t, "firewall", "delete", "allow-ssh")
+	output += runCommand(t, "firewall", "list")
+	assertGolden(t, "firewall", output)
+}
+
+// testPublicKey is an Ed25519 public key used to test key pair commands.
+const testPublicKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAICDHpuYGZs59yi21QuBTSQhM9m1zxhiW1x5MUDTid4Wo laptop\n"
+
+func TestKeyPairCommands(t *testing.T) {
+	setupFakeCloud(t)
+	if err := os.WriteFile("laptop.pub", []byte(testPublicKey), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	output := runCommand(t, "keypairs", "import", "laptop", "--public-key-file", "laptop.pub")
+	output += runCommand(t, "keypairs", "import", "laptop", "--public-key-file", "laptop.pub")
+	output += runCommand(t, "keypairs", "list")
+	output += runCommand(t, "keypairs", "delete", "laptop")
+	output += runCommand(t, "keypairs", "list")
+	assertGolden(t, "keypairs", output)
+}
+
+func TestKeyPairCreate(t *testing.T) {
+	setupFakeCloud(t)
+
+	output := runCommand(t, "keypairs", "create", "deploy", "--output", "deploy.pem")
+	if want := "Created key pair: deploy; private key written to deploy.pem\n"; output != want {
+		t.Errorf("got output %q, want %q", output, want)
+	}
+	info, err := os.Stat("deploy.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("private key file has mode %v, want 0600", info.Mode().Perm())
+	}
+
+	// The private key is never written over an existing file
+	output = runCommand(t, "keypairs", "create", "other", "--output", "deploy.pem")
+	if want := "Error: deploy.pem already exists\n"; output != want {
+		t.Errorf("got output %q, want %q", output, want)
+	}
+
+	output = runCommand(t, "keypairs", "create", "stored", "--store")
+	if want := "Created key pair: stored; private key saved in the credential store\n"; output != want {
+		t.Errorf("got output %q, want %q", output, want)
+	}
+	if _, err := internal.GetPrivateKey("fake", "", "stored"); err != nil {
+		t.Error(err)
+	}
+	runCommand(t, "keypairs", "delete", "stored")
+	if _, err := internal.GetPrivateKey("fake", "", "stored"); err == nil {
+		t.Error("private key is still stored after deleting the key pair")
+	}
+}
+
+// fakeSSHClients puts ssh and scp scripts on the PATH that print their
+// arguments, or fail with status 3 when the last argument is "false".
+func fakeSSHClients(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho \"$(basename \"$0\")\" \"$@\"\neval last=\\${$#}\n[ \"$last\" != false ] || exit 3\n"
+	for _, name := range []string{"ssh", "scp"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestLifecycleCommands(t *testing.T) {
+	setupFakeCloud(t)
+
+	output := runCommand(t, "stop-instance")
+	output += runCommand(t, "stop-instance", "--filter", "tag:role", "--dry-run")
+	output += runCommandWithInput(t, "n\n", "stop-instance", "web", "worker")
+	output += runCommandWithInput(t, "y\n", "reboot-instance", "tag:role=web")
+	output += runCommand(t, "terminate-instance", "--filter", "tag:role", "--yes")
+	output += runCommand(t, "terminate-instance", "--filter", "tag:role", "--yes")
+	assertGolden(t, "lifecycle", output)
+}
+
+// newInstanceID matches the IDs of fake instances created by a test, which are random.
+var newInstanceID = regexp.MustCompile(`fake-[0-9a-f]{8}`)
+
+// maskNewInstanceIDs replaces the IDs of instances that are not in fakeState.
+func maskNewInstanceIDs(output string) string {
+	return newInstanceID.ReplaceAllStringFunc(output, func(id string) string {
+		if strings.Contains(fakeState, id) {
+			return id
+		}
+		return "fake-XXXXXXXX"
+	})
+}
+
+func TestPlanApply(t *testing.T) {
+	setupFakeCloud(t)
+	if err := os.WriteFile("deploy.pub", []byte(testPublicKey), 0600); err != nil {
+		t.Fatal(err)
+	}
+	spec := `name: demo
+clouds:
+  fake:
+    key_pairs:
+      - name: deploy
+        public_key_file: deploy.pub
+    firewall_rules:
+      - name: allow-ssh
+        protocol: tcp
+        ports: ["22"]
+        sources: ["198.51.100.0/24"]
+    instances:
+      - name: web
+        type: fake.small
+        key_name: deploy
+        tags: {role: web}
+      - name: worker
+        type: fake.medium
+      - name: api
+        key_name: deploy
+`
+	if err := os.WriteFile("infra.yaml", []byte(spec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	output := runCommand(t, "plan", "-f", "infra.yaml")
+	output += runCommandWithInput(t, "n\n", "apply", "-f", "infra.yaml")
+	output += runCommand(t, "apply", "-f", "infra.yaml", "--yes")
+	output += runCommand(t, "plan", "-f", "infra.yaml")
+
+	// Dropping an instance of the stack deletes it, unless it is protected
+	spec = strings.Replace(spec, "      - name: api\n        key_name: deploy\n", "", 1)
+	spec = strings.Replace(spec, `ports: ["22"]`, `ports: ["22", "443"]`, 1)
+	if err := os.WriteFile("infra.yaml", []byte(spec), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := `{"active_cloud": "fake", "protection": [{"filters": ["tag:namaste-stack=demo"]}]}`
+	configPath := filepath.Join(os.Getenv(internal.ConfigDirEnv), "config.json")
+	if err := os.WriteFile(configPath, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	output += runCommand(t, "apply", "-f", "infra.yaml", "--yes")
+	if err := os.WriteFile(configPath, []byte(`{"active_cloud": "fake"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	output += runCommandWithInput(t, "y\n", "apply", "-f", "infra.yaml")
+	output += runCommand(t, "plan", "-f", "infra.yaml", "--cloud", "aws")
+	assertGolden(t, "plan_apply", maskNewInstanceIDs(output))
+}
+
+func TestDrift(t *testing.T) {
+	setupFakeCloud(t)
+	output := runCommand(t, "drift")
+
+	// The state records what the fake instances looked like when created,
+	// and resources that were deleted since
+	state := `{"resources": [
+  {"cloud": "fake", "kind": "instance", "id": "fake-0a1b2c3d", "name": "web", "region": "fake-region-1",
+   "attributes": {"type": "fake.medium", "key_name": "deploy", "tag:role": "api", "tag:env": "prod"}},
+  {"cloud": "fake", "kind": "instance", "id": "fake-4e5f6a7b", "name": "worker", "region": "fake-region-1",
+   "attributes": {"type": "fake.large", "tag:role": "worker"}},
+  {"cloud": "fake", "kind": "instance", "id": "fake-deadbeef", "name": "old", "region": "fake-region-1"},
+  {"cloud": "fake", "kind": "key pair", "id": "deploy", "name": "deploy", "region": "fake-region-1"},
+  {"cloud": "aws", "kind": "security group", "id": "sg-0123456789abcdef0", "name": "web", "region": "us-east-1"}
+]}`
+	if err := os.WriteFile(filepath.Join(os.Getenv(internal.ConfigDirEnv), "state.json"), []byte(state), 0600); err != nil {
+		t.Fatal(err)
+	}
+	output += runCommand(t, "drift", "--cloud", "fake")
+
+	spec := "name: demo\nclouds:\n  fake:\n    instances:\n      - name: web\n        type: fake.small\n      - name: api\n"
+	if err := os.WriteFile("infra.yaml", []byte(spec), 0600); err != nil {
+		t.Fatal(err)
+	}
+	output += runCommand(t, "drift", "-f", "infra.yaml")
+	assertGolden(t, "drift", output)
+
+	overrides = internal.Config{}
+	resetFlags(RootCmd)
+	RootCmd.SetArgs([]string{"drift", "--cloud", "fake"})
+	err := RootCmd.ExecuteContext(context.Background())
+	var exitErr *internal.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 2 {
+		t.Errorf("got error %v, want exit status 2", err)
+	}
+}
+
+// TestTagDrift checks that tags changed with the CLI are recorded, so that
+// drift does not report them.
+func TestTagDrift(t *testing.T) {
+	setupFakeCloud(t)
+	runCommand(t, "create-instance", "--name", "web-20", "--tag", "role=web")
+	runCommand(t, "tag", "web-20", "env=prod", "owner=platform")
+	runCommand(t, "untag", "web-20", "role")
+
+	overrides = internal.Config{}
+	resetFlags(RootCmd)
+	RootCmd.SetArgs([]string{"drift"})
+	if err := RootCmd.ExecuteContext(context.Background()); err != nil {
+		t.Errorf("got error %v, want no drift", err)
+	}
+	if output := runCommand(t, "drift"); !strings.Contains(output, "No drift") {
+		t.Errorf("got %q, want no drift", output)
+	}
+}
+
+func TestImport(t *testing.T) {
+	setupFakeCloud(t)
+	if err := os.WriteFile("deploy.pub", []byte(testPublicKey), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// The key pair predates the state
+	runCommand(t, "keypairs", "import", "deploy", "--public-key-file", "deploy.pub")
+	runCommand(t, "state", "forget", "deploy")
+
+	output := runCommand(t, "import")
+	output += runCommand(t, "import", "--filter", "tag:role=db")
+	output += runCommand(t, "import", "--security-group", "sg-0123456789abcdef0")
+	output += runCommand(t, "import", "--filter", "tag:role")
+	output += runCommand(t, "drift")
+
+	output += runCommand(t, "import", "web", "--spec", "web.yaml", "--stack", "web")
+	spec, err := os.ReadFile("web.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output += string(spec)
+	output += runCommand(t, "import", "web", "--spec", "web.yaml")
+	output += runCommand(t, "plan", "-f", "web.yaml")
+	assertGolden(t, "import", output)
+}
+
+func TestTemplates(t *testing.T) {
+	setupFakeCloud(t)
+
+	output := runCommand(t, "template", "list")
+	output += runCommand(t, "template", "create", "web-small", "--type", "fake.medium", "--image", "fake-debian-12", "--tag", "role=web")
+	output += runCommand(t, "template", "create", "web-small", "--cloud", "aws", "--type", "t3.small",
+		"--image", "ami-0abcdef1234567890", "--security-group", "sg-0123456789abcdef0", "--tag", "role=web")
+	output += runCommand(t, "template", "create", "db-large", "--cloud", "gcp", "--type", "n2-standard-8")
+	output += runCommand(t, "template", "create", "db-large", "--cloud", "azure", "--type", "Standard_D8s_v5")
+	output += runCommand(t, "template", "list")
+	output += runCommand(t, "template", "show", "web-small")
+
+	output += runCommand(t, "create-instance", "--template", "web-small", "--name", "web-07", "--tag", "env=prod")
+	output += runCommand(t, "describe-instance", "web-07")
+	output += runCommand(t, "list-instances", "--filter", "name=web-07", "--filter", "type=fake.medium")
+	output += runCommand(t, "create-instance", "--template", "web-small", "--name", "web-08", "--type", "fake.large")
+	output += runCommand(t, "list-instances", "--filter", "name=web-08", "--filter", "type=fake.large")
+	output += runCommand(t, "create-instance", "--template", "db-large", "--name", "db-1")
+	output += runCommand(t, "create-instance", "--template", "missing", "--name", "db-1")
+
+	output += runCommand(t, "template", "delete", "web-small", "--cloud", "aws")
+	output += runCommand(t, "template", "delete", "db-large")
+	output += runCommand(t, "template", "list")
+
+	// Project templates are listed but only deleted by editing the project configuration
+	project := "templates:\n  shared:\n    fake:\n      type: fake.small\n  web-small:\n    gcp:\n      type: e2-small\n"
+	if err := os.WriteFile(internal.ProjectConfigFileName, []byte(project), 0600); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	output += runCommand(t, "template", "list")
+	output += runCommand(t, "template", "delete", "shared")
+	output += runCommand(t, "template", "delete", "web-small", "--cloud", "gcp")
+	output += runCommand(t, "template", "delete", "web-small")
+	output += runCommand(t, "template", "list")
+	assertGolden(t, "templates", strings.ReplaceAll(maskNewInstanceIDs(output), wd, "$PROJECT"))
+}
+
+func TestSizes(t *testing.T) {
+	setupFakeCloud(t)
+
+	output := runCommand(t, "sizes", "list", "--min-cpu", "4", "--min-mem", "16")
+	output += runCommand(t, "sizes", "list", "--cloud", "gcp", "--min-cpu", "4", "--min-mem", "16", "--arch", "x86_64")
+	output += runCommand(t, "sizes", "list", "--gpus", "4")
+	output += runCommand(t, "sizes", "refresh")
+	output += runCommand(t, "sizes", "list", "--arch", "arm64")
+
+	output += runCommand(t, "create-instance", "--name", "big", "--size", "4x16")
+	output += runCommand(t, "list-instances", "--filter", "name=big", "--filter", "type=fake.large")
+	output += runCommand(t, "create-instance", "--name", "big-arm", "--size", "4x16", "--arch", "arm64")
+	output += runCommand(t, "list-instances", "--filter", "name=big-arm", "--filter", "type=fake.large-arm")
+	output += runCommand(t, "create-instance", "--name", "huge", "--size", "64x512")
+	output += runCommand(t, "create-instance", "--name", "big", "--size", "4x16", "--type", "fake.small")
+	output += runCommand(t, "create-instance", "--name", "big", "--size", "large")
+	assertGolden(t, "sizes", stateTimes.ReplaceAllString(maskNewInstanceIDs(output), "XXX"))
+}
+
+func TestImages(t *testing.T) {
+	setupFakeCloud(t)
+
+	output := runCommand(t, "images", "list", "--os", "ubuntu")
+	output += runCommand(t, "images", "list", "--os", "debian-12", "--arch", "arm64")
+	output += runCommand(t, "images", "list", "--os", "amazon-linux")
+	output += runCommand(t, "images", "list", "--os", "windows")
+
+	output += runCommand(t, "create-instance", "--name", "web-09", "--image", "ubuntu-24.04")
+	output += runCommand(t, "create-instance", "--name", "web-10", "--image", "ubuntu-24.04", "--type", "fake.large-arm")
+	output += runCommand(t, "create-instance", "--name", "web-11", "--image", "debian-12", "--size", "4x16", "--arch", "arm64")
+	output += runCommand(t, "create-instance", "--name", "web-12", "--image", "amazon-linux-2023")
+	assertGolden(t, "images", maskNewInstanceIDs(output))
+}
+
+func TestProtection(t *testing.T) {
+	setupFakeCloud(t)
+	config := `{"active_cloud": "fake", "protection": [{"filters": ["tag:role=worker"], "actions": ["stop", "terminate"]}]}`
+	if err := os.WriteFile(filepath.Join(os.Getenv(internal.ConfigDirEnv), "config.json"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	output := runCommand(t, "protect", "web")
+	output += runCommand(t, "describe-instance", "web")
+	output += runCommand(t, "terminate-instance", "web", "--yes", "--force")
+	output += runCommand(t, "unprotect", "web")
+	output += runCommand(t, "terminate-instance", "worker", "--yes")
+	output += runCommand(t, "terminate-instance", "worker", "--force", "--dry-run")
+	output += runCommandWithInput(t, "wroker\n", "terminate-instance", "worker", "--yes", "--force")
+	output += runCommandWithInput(t, "worker\n", "terminate-instance", "worker", "--yes", "--force")
+	assertGolden(t, "protection", output)
+}
+
+// stateTimes matches the creation times and creators printed by the state commands.
+var stateTimes = regexp.MustCompile(`\d{4}-\d\d-\d\d \d\d:\d\d(:\d\d \S+)?|Created by: \S+`)
+
+func TestStateCommands(t *testing.T) {
+	setupFakeCloud(t)
+	if err := os.WriteFile("laptop.pub", []byte(testPublicKey), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	output := runCommand(t, "state", "list")
+	output += runCommand(t, "keypairs", "import", "laptop", "--public-key-file", "laptop.pub")
+	output += runCommand(t, "create-instance", "--name", "api", "--type", "fake.small", "--tag", "role=api")
+	output += runCommand(t, "state", "list")
+	output += runCommand(t, "state", "show", "laptop")
+	output += runCommand(t, "state", "forget", "laptop")
+	output += runCommand(t, "state", "forget", "laptop")
+	output += runCommand(t, "terminate-instance", "api", "--yes")
+	output += runCommand(t, "state", "list")
+	assertGolden(t, "state", stateTimes.ReplaceAllString(maskNewInstanceIDs(output), "XXX"))
+}
+
+func TestTagCommands(t *testing.T) {
+	setupFakeCloud(t)
+
+	output := runCommand(t, "tag", "web", "env=prod", "owner=platform")
+	output += runCommand(t, "describe-instance", "fake-0a1b2c3d")
+	output += runCommand(t, "untag", "web", "owner", "role")
+	output += runCommand(t, "describe-instance", "fake-0a1b2c3d")
+	output += runCommand(t, "tag", "web", "env")
+	output += runCommand(t, "tag", "db", "env=prod")
+	assertGolden(t, "tags", output)
+}
+
+func TestCreateInstanceDefaultTags(t *testing.T) {
+	setupFakeCloud(t)
+	config := `{"active_cloud": "fake", "default_tags": {"created-by": "namaste-cloud", "env": "dev"}}`
+	if err := os.WriteFile(filepath.Join(os.Getenv(internal.ConfigDirEnv), "config.json"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	runCommand(t, "create-instance", "--name", "api", "--tag", "env=prod")
+	output := runCommand(t, "list-instances", "--filter", "name=api", "--filter", "tag:created-by=namaste-cloud", "--filter", "tag:env=prod")
+	if !strings.HasPrefix(output, "Instance ID: fake-") || strings.Count(output, "\n") != 1 {
+		t.Errorf("created instance does not have the default and given tags; got %q", output)
+	}
+}
+
+func TestSSHCommands(t *testing.T) {
+	setupFakeCloud(t)
+	fakeSSHClients(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.Mkdir(filepath.Join(home, ".ssh"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".ssh", "deploy.pem"), []byte("key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	output := runCommand(t, "ssh", "web", "--user", "admin")
+	output += runCommand(t, "ssh", "web", "--user", "admin", "--bastion", "jump@bastion.example.com", "--", "uptime")
+	output += runCommand(t, "ssh", "fake-0a1b2c3d", "--user", "admin", "--identity", "web.pem", "--ssh-args", "-A -o ConnectTimeout=5")
+	output += runCommand(t, "ssh", "web", "--user", "admin", "--key", "other")
+	output += runCommand(t, "ssh", "worker")
+	output += runCommand(t, "ssh", "db")
+	output += runCommand(t, "scp", "--user", "admin", "./app.tar.gz", "web:/tmp/")
+	output += runCommand(t, "scp", "app.tar.gz", "backup.tar.gz")
+	output += runCommand(t, "scp", "--user", "admin", "tag:role=web:/var/log/app.log", ".")
+	assertGolden(t, "ssh", strings.ReplaceAll(output, home, "$HOME"))
+}
+
+func TestExecCommand(t *testing.T) {
+	setupFakeCloud(t)
+	fakeSSHClients(t)
+	t.Setenv("HOME", t.TempDir())
+
+	output := runCommand(t, "exec", "--filter", "tag:role=web", "--user", "admin", "--parallel", "1", "--", "uptime")
+	output += runCommand(t, "exec", "--filter", "tag:role=db", "--", "uptime")
+	output += runCommand(t, "exec", "--", "uptime")
+	output += runCommand(t, "exec", "--filter", "tag:role=web", "--transport", "ssm", "--", "uptime")
+	assertGolden(t, "exec", output)
+}
+
+func TestExecExitStatus(t *testing.T) {
+	setupFakeCloud(t)
+	fakeSSHClients(t)
+	t.Setenv("HOME", t.TempDir())
+
+	overrides = internal.Config{}
+	resetFlags(RootCmd)
+	RootCmd.SetArgs([]string{"exec", "--filter", "tag:role", "--user", "admin", "--", "false"})
+	err := RootCmd.ExecuteContext(context.Background())
+
+	var exitErr *internal.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Errorf("got error %v, want exit status 1", err)
+	}
+}
+
+func TestSSHExitStatus(t *testing.T) {
+	setupFakeCloud(t)
+	fakeSSHClients(t)
+	t.Setenv("HOME", t.TempDir())
+
+	overrides = internal.Config{}
+	resetFlags(RootCmd)
+	RootCmd.SetArgs([]string{"ssh", "web", "--user", "admin", "--", "false"})
+	err := RootCmd.ExecuteContext(context.Background())
+
+	var exitErr *internal.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Errorf("got error %v, want exit status 3", err)
+	}
+}
+
+func TestCost(t *testing.T) {
+	setupFakeCloud(t)
+	spec := `name: demo
+clouds:
+  fake:
+    instances:
+      - name: web
+        type: fake.small
+      - name: worker
+        type: fake.large
+        disk_size_gib: 100
+      - name: api
+      - name: huge
+        type: fake.huge
+  aws:
+    region: eu-west-1
+    instances:
+      - name: web
+        type: t3.small
+        disk_size_gib: 20
+      - name: batch
+        type: c6g.xlarge
+  azure:
+    instances:
+      - name: db
+        type: Standard_D4s_v5
+`
+	if err := os.WriteFile("infra.yaml", []byte(spec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	output := runCommand(t, "cost", "estimate", "-f", "infra.yaml")
+	output += runCommand(t, "cost", "estimate", "-f", "infra.yaml", "--cloud", "aws")
+	output += runCommand(t, "cost", "estimate", "-f", "infra.yaml", "--cloud", "gcp")
+
+	output += runCommand(t, "create-instance", "--name", "web-13", "--size", "4x16", "--disk-size", "50", "--estimate")
+	output += runCommand(t, "create-instance", "--name", "web-13", "--type", "fake.huge", "--estimate")
+	output += runCommand(t, "create-instance", "--name", "web-13", "--type", "t3.small", "--cloud", "aws", "--estimate")
+	output += runCommand(t, "create-instance", "--name", "web-13", "--disk-size", "-1")
+	output += runCommand(t, "list-instances", "--filter", "name=web-13")
+
+	// A refreshed catalog replaces the prices of the clouds it has
+	catalog := `{"fake": {"region": "fake-region-1", "disk_type": "fake", "disk_gib_month": 0.2, "default_disk_gib": 10,
+  "instances": {"fake.small": {"on_demand": 0.05}}}}`
+	if err := os.WriteFile("pricing.json", []byte(catalog), 0600); err != nil {
+		t.Fatal(err)
+	}
+	output += runCommand(t, "cost", "refresh")
+	output += runCommand(t, "cost", "refresh", "missing.json")
+	output += runCommand(t, "cost", "refresh", "pricing.json")
+	output += runCommand(t, "cost", "estimate", "-f", "infra.yaml", "--cloud", "fake")
+	output += runCommand(t, "create-instance", "--name", "web-13", "--type", "fake.small", "--estimate")
+	assertGolden(t, "cost", stateTimes.ReplaceAllString(output, "XXX"))
+}
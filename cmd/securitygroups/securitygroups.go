@@ -0,0 +1,292 @@
+package securitygroups
+
+import (
+	"context"
+	"fmt"
+	awscloud "namaste-cloud/clouds/aws-cloud"
+	"namaste-cloud/clouds/providers"
+	"namaste-cloud/internal"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// SecurityGroupsCommand returns the `security-groups` command group.
+func SecurityGroupsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "security-groups",
+		Aliases: []string{"sg"},
+		Short:   "Manage EC2 security groups and their rules",
+	}
+
+	cmd.AddCommand(createCommand())
+	cmd.AddCommand(listCommand())
+	cmd.AddCommand(describeCommand())
+	cmd.AddCommand(deleteCommand())
+	cmd.AddCommand(ruleCommand("authorize-ingress", "Allow inbound traffic from the given sources", "Authorized ingress on",
+		func(ctx context.Context, p *awscloud.Provider, id string, rule awscloud.Rule) error {
+			return p.AuthorizeIngress(ctx, id, rule)
+		}))
+	cmd.AddCommand(ruleCommand("revoke-ingress", "Remove an inbound rule", "Revoked ingress on",
+		func(ctx context.Context, p *awscloud.Provider, id string, rule awscloud.Rule) error {
+			return p.RevokeIngress(ctx, id, rule)
+		}))
+	cmd.AddCommand(ruleCommand("authorize-egress", "Allow outbound traffic to the given destinations", "Authorized egress on",
+		func(ctx context.Context, p *awscloud.Provider, id string, rule awscloud.Rule) error {
+			return p.AuthorizeEgress(ctx, id, rule)
+		}))
+	cmd.AddCommand(ruleCommand("revoke-egress", "Remove an outbound rule", "Revoked egress on",
+		func(ctx context.Context, p *awscloud.Provider, id string, rule awscloud.Rule) error {
+			return p.RevokeEgress(ctx, id, rule)
+		}))
+	return cmd
+}
+
+// loadProvider creates the provider for the active cloud, which must be AWS.
+func loadProvider(ctx context.Context) (*awscloud.Provider, error) {
+	provider, err := providers.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	aws, ok := provider.(*awscloud.Provider)
+	if !ok {
+		provider.Close()
+		return nil, fmt.Errorf("security groups are only available on AWS, not %s; use --cloud aws", provider.Name())
+	}
+	return aws, nil
+}
+
+func createCommand() *cobra.Command {
+	var description, vpcID string
+
+	cmd := &cobra.Command{
+		Use:   "create [name]",
+		Short: "Create a security group",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			provider, err := loadProvider(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			defer provider.Close()
+
+			if description == "" {
+				description = args[0]
+			}
+			groupID, err := provider.CreateSecurityGroup(cmd.Context(), args[0], description, vpcID)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			fmt.Printf("Created security group with ID: %s\n", groupID)
+			if err := recordGroup(cmd.Context(), provider, groupID); err != nil {
+				fmt.Println("Warning: failed to record the security group in the state:", err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&description, "description", "", "Description of the security group (defaults to its name)")
+	cmd.Flags().StringVar(&vpcID, "vpc", "", "VPC to create the security group in (defaults to the default VPC)")
+	return cmd
+}
+
+func listCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List security groups",
+		Run: func(cmd *cobra.Command, args []string) {
+			provider, err := loadProvider(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			defer provider.Close()
+
+			groups, err := provider.ListSecurityGroups(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			for _, group := range groups {
+				fmt.Printf("Security Group ID: %s, Name: %s, VPC: %s\n", group.ID, group.Name, group.VPCID)
+			}
+		},
+	}
+}
+
+func describeCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "describe [group-id]",
+		Short: "Show a security group and its rules",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			provider, err := loadProvider(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			defer provider.Close()
+
+			group, err := provider.DescribeSecurityGroup(cmd.Context(), args[0])
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			fmt.Printf("Security Group ID: %s, Name: %s, VPC: %s\n", group.ID, group.Name, group.VPCID)
+			fmt.Printf("Description: %s\n", group.Description)
+			fmt.Println("Ingress:")
+			for _, rule := range group.Ingress {
+				fmt.Printf("  %s from %s\n", formatTraffic(rule), formatPeers(rule))
+			}
+			fmt.Println("Egress:")
+			for _, rule := range group.Egress {
+				fmt.Printf("  %s to %s\n", formatTraffic(rule), formatPeers(rule))
+			}
+		},
+	}
+}
+
+func deleteCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete [group-id]",
+		Short: "Delete a security group",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			provider, err := loadProvider(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			defer provider.Close()
+
+			if err := provider.DeleteSecurityGroup(cmd.Context(), args[0]); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			fmt.Printf("Deleted security group with ID: %s\n", args[0])
+			if err := providers.Forget(cmd.Context(), internal.StateRecord{Cloud: provider.Name(), Kind: providers.KindSecurityGroup, ID: args[0]}); err != nil {
+				fmt.Println("Warning: failed to remove the security group from the state:", err)
+			}
+		},
+	}
+}
+
+// recordGroup records a security group with its current rules, so that
+// drift can tell when they are changed outside the CLI.
+func recordGroup(ctx context.Context, provider *awscloud.Provider, groupID string) error {
+	group, err := provider.DescribeSecurityGroup(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	return providers.Record(ctx, providers.SecurityGroupRecord(provider.Name(), group))
+}
+
+// ruleCommand builds a command that adds or removes one rule of a security group.
+// There is deliberately no default source: every rule names who it applies to.
+func ruleCommand(use, short, verb string, action func(context.Context, *awscloud.Provider, string, awscloud.Rule) error) *cobra.Command {
+	var protocol, ports, description string
+	var cidrs, sourceGroups []string
+	var myIP bool
+
+	cmd := &cobra.Command{
+		Use:   use + " [group-id]",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			rule, err := awscloud.NewRule(protocol, ports)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			rule.Description = description
+
+			if myIP {
+				ip, err := internal.PublicIP(cmd.Context())
+				if err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+				cidrs = append(cidrs, ip)
+			}
+			for _, cidr := range cidrs {
+				if err := rule.AddCIDR(cidr); err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+			}
+			for _, group := range sourceGroups {
+				if err := rule.AddSourceGroup(group); err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+			}
+			if err := rule.Validate(); err != nil {
+				fmt.Println("Error:", err, "(use --cidr, --my-ip or --source-group)")
+				return
+			}
+			if use == "authorize-ingress" && rule.OpenToWorld() {
+				fmt.Printf("Warning: this rule allows %s from the whole internet.\n", formatTraffic(rule))
+			}
+
+			provider, err := loadProvider(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			defer provider.Close()
+
+			if err := action(cmd.Context(), provider, args[0], rule); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			fmt.Printf("%s security group %s: %s, %s\n", verb, args[0], formatTraffic(rule), formatPeers(rule))
+			if err := recordGroup(cmd.Context(), provider, args[0]); err != nil {
+				fmt.Println("Warning: failed to record the security group rules in the state:", err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&protocol, "protocol", "tcp", "Protocol: tcp, udp, icmp, icmpv6, all or a protocol number")
+	cmd.Flags().StringVar(&ports, "port", "", "Port or range such as 22 or 8000-8080, or TYPE[:CODE] for ICMP")
+	cmd.Flags().StringSliceVar(&cidrs, "cidr", nil, "IPv4 or IPv6 CIDR the rule applies to (repeatable)")
+	cmd.Flags().BoolVar(&myIP, "my-ip", false, "Add the public IP address of this machine as a CIDR")
+	cmd.Flags().StringSliceVar(&sourceGroups, "source-group", nil, "Security group ID the rule applies to (repeatable)")
+	cmd.Flags().StringVar(&description, "description", "", "Description of the rule")
+	return cmd
+}
+
+// formatTraffic describes the protocol and ports of a rule, e.g. "tcp 8000-8080".
+func formatTraffic(rule awscloud.Rule) string {
+	switch rule.Protocol {
+	case "-1":
+		return "all traffic"
+	case "tcp", "udp":
+		if rule.FromPort == rule.ToPort {
+			return fmt.Sprintf("%s %d", rule.Protocol, rule.FromPort)
+		}
+		return fmt.Sprintf("%s %d-%d", rule.Protocol, rule.FromPort, rule.ToPort)
+	case "icmp", "icmpv6":
+		if rule.FromPort == -1 {
+			return rule.Protocol
+		}
+		if rule.ToPort == -1 {
+			return fmt.Sprintf("%s type %d", rule.Protocol, rule.FromPort)
+		}
+		return fmt.Sprintf("%s type %d code %d", rule.Protocol, rule.FromPort, rule.ToPort)
+	default:
+		return "protocol " + rule.Protocol
+	}
+}
+
+// formatPeers lists the CIDRs and security groups of a rule.
+func formatPeers(rule awscloud.Rule) string {
+	peers := append(append([]string{}, rule.CIDRs...), rule.SourceGroups...)
+	return strings.Join(peers, ", ")
+}
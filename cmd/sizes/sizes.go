@@ -0,0 +1,158 @@
+package sizes
+
+import (
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/clouds/providers"
+	"namaste-cloud/internal"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// SizesCommand returns the `sizes` command group.
+func SizesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sizes",
+		Short: "Find the instance types of a cloud by vCPUs, memory, GPUs and architecture",
+		Long: "Each cloud names its instance types differently: t3.small on AWS, e2-small on GCP and\n" +
+			"Standard_B1ms on Azure. The size catalog maps the resources of each type to its name, so\n" +
+			"that `create-instance --size 4x16` picks the cheapest type with at least 4 vCPUs and 16 GiB\n" +
+			"of memory on whichever cloud is selected.\n\n" +
+			"A catalog of common types is bundled with the CLI, priced from the pricing catalog of\n" +
+			"`cost`. `sizes refresh` replaces it with every type offered in the configured region, cached\n" +
+			"in the configuration directory; types missing from the pricing catalog have no price.",
+	}
+
+	cmd.AddCommand(listCommand())
+	cmd.AddCommand(refreshCommand())
+	return cmd
+}
+
+// loadConfig loads the effective configuration, whose active cloud is the
+// one given with --cloud, if any.
+func loadConfig() (internal.Config, error) {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return cfg, fmt.Errorf("error loading configuration: %w", err)
+	}
+	if cfg.ActiveCloud == "" {
+		return cfg, providers.ErrNoActiveCloud
+	}
+	if !providers.IsSupported(cfg.ActiveCloud) {
+		return cfg, fmt.Errorf("unsupported cloud %q", cfg.ActiveCloud)
+	}
+	return cfg, nil
+}
+
+func listCommand() *cobra.Command {
+	var query clouds.SizeQuery
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the instance types of the cloud with at least the given resources, cheapest first",
+		Example: "  namaste-cloud sizes list --cloud gcp --min-cpu 4 --min-mem 16\n" +
+			"  namaste-cloud sizes list --gpus 1 --arch x86_64",
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := loadConfig()
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			catalog, refreshedAt, err := providers.Sizes(cfg.ActiveCloud)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			sizes := clouds.MatchingSizes(catalog.Sizes, query)
+			if len(sizes) == 0 {
+				fmt.Printf("No %s sizes have at least %s.\n", cfg.ActiveCloud, query)
+				return
+			}
+			printSizes(sizes)
+
+			prices, _, err := providers.Prices()
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			if refreshedAt.IsZero() {
+				fmt.Printf("\nBundled catalog; prices are on-demand Linux prices per hour in %s, in USD.\n", prices[cfg.ActiveCloud].Region)
+			} else {
+				fmt.Printf("\nCatalog for %s refreshed on %s; prices are on-demand Linux prices per hour in %s, in USD.\n",
+					catalog.Region, refreshedAt.Local().Format("2006-01-02 15:04"), prices[cfg.ActiveCloud].Region)
+			}
+		},
+	}
+
+	cmd.Flags().IntVar(&query.CPUs, "min-cpu", 0, "List types with at least this many vCPUs")
+	cmd.Flags().Float64Var(&query.MemoryGiB, "min-mem", 0, "List types with at least this much memory, in GiB")
+	cmd.Flags().IntVar(&query.GPUs, "gpus", 0, "List types with at least this many GPUs")
+	cmd.Flags().StringVar(&query.Arch, "arch", "", "List types of this architecture, x86_64 or arm64")
+	return cmd
+}
+
+// printSizes prints sizes as a table.
+func printSizes(sizes []clouds.Size) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tVCPUS\tMEMORY\tGPUS\tARCH\tPRICE")
+	for _, size := range sizes {
+		price := "-"
+		if size.HourlyPrice > 0 {
+			price = "$" + strconv.FormatFloat(size.HourlyPrice, 'f', -1, 64)
+		}
+		arch := size.Arch
+		if arch == "" {
+			arch = "-"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%s\t%s\n", size.Type, size.CPUs, clouds.FormatGiB(size.MemoryGiB), size.GPUs, arch, price)
+	}
+	w.Flush()
+}
+
+func refreshCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "refresh",
+		Short: "Cache every instance type the cloud offers in the configured region",
+		Long: "List every instance type the cloud offers in the configured region, or zone on GCP, and\n" +
+			"cache them for `sizes list` and `create-instance --size`, in place of the bundled catalog.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := loadConfig()
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			provider, err := providers.New(cmd.Context(), cfg)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			defer provider.Close()
+
+			region := cfg.Region
+			if provider.Name() == "gcp" {
+				region = cfg.Zone
+			}
+			catalog, err := providers.RefreshSizes(cmd.Context(), provider, region)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			priced := 0
+			for _, size := range catalog.Sizes {
+				if size.HourlyPrice > 0 {
+					priced++
+				}
+			}
+			fmt.Printf("Cached %d %s sizes", len(catalog.Sizes), provider.Name())
+			if region != "" {
+				fmt.Printf(" offered in %s", region)
+			}
+			fmt.Printf("; %d of them have a price in the pricing catalog.\n", priced)
+		},
+	}
+}
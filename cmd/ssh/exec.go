@@ -0,0 +1,278 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"namaste-cloud/clouds"
+	"namaste-cloud/internal"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// hostResult is the outcome of running a command on one instance.
+type hostResult struct {
+	label    string
+	exitCode int
+	err      error
+	skipped  string
+}
+
+// prefixWriter writes complete lines to out, each prefixed with the host
+// label, so that the output of hosts running in parallel does not interleave
+// within a line.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    bytes.Buffer
+}
+
+// Write implements io.Writer.
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		line, err := w.buf.ReadBytes('\n')
+		if err != nil {
+			// Keep the incomplete line for the next write
+			w.buf.Write(line)
+			return len(p), nil
+		}
+		w.writeLine(line)
+	}
+}
+
+// Flush writes any final line without a trailing newline.
+func (w *prefixWriter) Flush() {
+	if w.buf.Len() > 0 {
+		w.writeLine(append(w.buf.Bytes(), '\n'))
+		w.buf.Reset()
+	}
+}
+
+// writeLine writes one prefixed line.
+func (w *prefixWriter) writeLine(line []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fmt.Fprintf(w.out, "[%s] %s", w.prefix, line)
+}
+
+// ExecCommand returns the `exec` command.
+func ExecCommand() *cobra.Command {
+	var opts options
+	var filterFlags []string
+	var parallel int
+	var transport string
+
+	cmd := &cobra.Command{
+		Use:   "exec --filter KEY=VALUE... -- command...",
+		Short: "Run a command on every instance matching the filters",
+		Long: "Run a command on every instance matching the filters, as used by list-instances, and\n" +
+			"print each line of output prefixed with the instance name followed by a summary of the exit\n" +
+			"status of every instance. Instances are reached with the system ssh client, as by the ssh\n" +
+			"command, or with AWS Systems Manager when --transport is ssm. On GCP with OS Login, set\n" +
+			"ssh.user in the configuration to your OS Login user name.\n\n" +
+			"ssh runs without prompts: the host keys of instances not yet in known_hosts are accepted and\n" +
+			"added to it, while an instance whose host key changed is refused.",
+		Example: "  namaste-cloud exec --filter tag:role=web -- uptime\n" +
+			"  namaste-cloud exec --filter name='web-*' --filter state=running --parallel 5 -- sudo systemctl restart nginx",
+		Args:          cobra.MinimumNArgs(1),
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(filterFlags) == 0 {
+				fmt.Println("Error: at least one --filter is required; use --filter state=running to select every running instance")
+				return nil
+			}
+			filters, err := clouds.ParseFilters(filterFlags)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return nil
+			}
+			if parallel < 1 {
+				fmt.Println("Error: --parallel must be at least 1")
+				return nil
+			}
+
+			session, err := newSession(cmd.Context(), opts)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return nil
+			}
+			defer session.Close()
+
+			method := transport
+			if method == "" {
+				method = session.config.SSH.Transport
+			}
+			if method == "" {
+				method = "ssh"
+			}
+			var runner clouds.CommandRunner
+			switch method {
+			case "ssh":
+			case "ssm":
+				var ok bool
+				if runner, ok = session.provider.(clouds.CommandRunner); !ok {
+					fmt.Printf("Error: the ssm transport is not supported on %s\n", session.provider.Name())
+					return nil
+				}
+			default:
+				fmt.Printf("Error: unknown transport %q; use ssh or ssm\n", method)
+				return nil
+			}
+
+			instances, err := session.provider.ListInstances(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return nil
+			}
+			instances = clouds.FilterInstances(instances, filters)
+			if len(instances) == 0 {
+				fmt.Println("No instances match the filters.")
+				return nil
+			}
+
+			results := session.execAll(cmd.Context(), instances, strings.Join(args, " "), runner, parallel)
+			if printSummary(results) {
+				return &internal.ExitError{Code: 1}
+			}
+			return nil
+		},
+	}
+
+	addFlags(cmd, &opts)
+	cmd.Flags().StringArrayVar(&filterFlags, "filter", nil, "Run on instances matching KEY=VALUE, e.g. tag:role=web (repeatable, at least one required)")
+	cmd.Flags().IntVar(&parallel, "parallel", 10, "Maximum number of instances to run the command on at once")
+	cmd.Flags().StringVar(&transport, "transport", "", "How to reach instances: ssh or ssm (defaults to ssh.transport in the configuration, else ssh)")
+	return cmd
+}
+
+// execAll runs the command on the instances, at most parallel at a time, and
+// returns the results in the order of the instances. Stopped instances are
+// skipped.
+func (s *session) execAll(ctx context.Context, instances []clouds.Instance, command string, runner clouds.CommandRunner, parallel int) []hostResult {
+	labels := hostLabels(instances)
+	results := make([]hostResult, len(instances))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallel)
+
+	for i, instance := range instances {
+		results[i].label = labels[i]
+		if isStopped(instance) {
+			results[i].skipped = strings.ToLower(instance.State)
+			continue
+		}
+
+		// Private keys are copied out of the credential store here rather
+		// than in parallel, since the session is not safe for concurrent use
+		var conn connection
+		if runner == nil {
+			var err error
+			if conn, err = s.connectTo(ctx, instance); err != nil {
+				results[i].err = err
+				continue
+			}
+		}
+
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, instance clouds.Instance) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			stdout := &prefixWriter{mu: &mu, out: os.Stdout, prefix: labels[i]}
+			stderr := &prefixWriter{mu: &mu, out: os.Stderr, prefix: labels[i]}
+			if runner != nil {
+				results[i].exitCode, results[i].err = runRemote(ctx, runner, instance.ID, command, stdout, stderr)
+			} else {
+				// Nobody answers prompts, so the host keys of new instances are
+				// accepted, while a changed host key still fails
+				args := append(s.clientArgs(conn.identity), "-o", "BatchMode=yes", "-o", "StrictHostKeyChecking=accept-new",
+					conn.user+"@"+conn.host, command)
+				results[i].exitCode, results[i].err = runSSH(ctx, args, stdout, stderr)
+			}
+			stdout.Flush()
+			stderr.Flush()
+		}(i, instance)
+	}
+	wg.Wait()
+	return results
+}
+
+// runSSH runs the system ssh client without a terminal and returns the exit
+// status of the remote command.
+func runSSH(ctx context.Context, args []string, stdout, stderr io.Writer) (int, error) {
+	path, err := exec.LookPath("ssh")
+	if err != nil {
+		return 0, fmt.Errorf("ssh client not found: %w", err)
+	}
+
+	client := exec.CommandContext(ctx, path, args...)
+	client.Stdout, client.Stderr = stdout, stderr
+	err = client.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	return 0, err
+}
+
+// runRemote runs the command through the provider's agent and writes its
+// output once it has finished.
+func runRemote(ctx context.Context, runner clouds.CommandRunner, instanceID, command string, stdout, stderr io.Writer) (int, error) {
+	result, err := runner.RunCommand(ctx, instanceID, command)
+	if err != nil {
+		return 0, err
+	}
+	io.WriteString(stdout, result.Stdout)
+	io.WriteString(stderr, result.Stderr)
+	return result.ExitCode, nil
+}
+
+// hostLabels returns the label to prefix each instance's output with: its
+// name, or its ID when it has no name or shares it with another instance.
+func hostLabels(instances []clouds.Instance) []string {
+	names := make(map[string]int)
+	for _, instance := range instances {
+		names[instance.Name]++
+	}
+
+	labels := make([]string, len(instances))
+	for i, instance := range instances {
+		labels[i] = instance.Name
+		if instance.Name == "" || names[instance.Name] > 1 {
+			labels[i] = instance.ID
+		}
+	}
+	return labels
+}
+
+// printSummary prints the exit status of every instance and reports whether
+// the command failed on any of them. Skipped instances are not failures.
+func printSummary(results []hostResult) bool {
+	failed := false
+	fmt.Println()
+	fmt.Println("Summary:")
+	for _, result := range results {
+		switch {
+		case result.skipped != "":
+			fmt.Printf("  %s: skipped (%s)\n", result.label, result.skipped)
+		case result.err != nil:
+			fmt.Printf("  %s: error: %v\n", result.label, result.err)
+			failed = true
+		default:
+			fmt.Printf("  %s: exit %d\n", result.label, result.exitCode)
+			failed = failed || result.exitCode != 0
+		}
+	}
+	return failed
+}
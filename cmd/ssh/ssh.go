@@ -0,0 +1,354 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/clouds/providers"
+	"namaste-cloud/internal"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultUsers are the login users of the stock images of each cloud. Other
+// clouds default to the local user name, as `keypairs` does for GCP.
+var defaultUsers = map[string]string{
+	"aws":   "ec2-user",
+	"azure": "azureuser",
+}
+
+// options are the flags shared by ssh and scp.
+type options struct {
+	user     string
+	key      string
+	identity string
+	private  bool
+	bastion  string
+	sshArgs  string
+}
+
+// addFlags adds the flags shared by ssh and scp.
+func addFlags(cmd *cobra.Command, opts *options) {
+	cmd.Flags().StringVarP(&opts.user, "user", "l", "", "Login user (defaults to ssh.user in the configuration, else ec2-user on AWS, azureuser on Azure and your local user name elsewhere)")
+	cmd.Flags().StringVar(&opts.key, "key", "", "Key pair whose private key to use (defaults to the instance's key pair)")
+	cmd.Flags().StringVarP(&opts.identity, "identity", "i", "", "Private key file to use instead of looking up the key pair")
+	cmd.Flags().BoolVar(&opts.private, "private", false, "Connect to the private IP address (the default with --bastion)")
+	cmd.Flags().StringVarP(&opts.bastion, "bastion", "J", "", "Jump host to connect through, as [user@]host[:port]")
+	cmd.Flags().StringVar(&opts.sshArgs, "ssh-args", "", "Extra arguments for the ssh client, e.g. \"-A -L 8080:localhost:80\"")
+}
+
+// connection is how to reach one instance.
+type connection struct {
+	user     string
+	host     string
+	identity string
+}
+
+// session holds what is needed to connect to instances of the active cloud.
+type session struct {
+	provider clouds.Provider
+	config   internal.Config
+	opts     options
+
+	// tempDir holds private keys copied out of the credential store.
+	tempDir string
+}
+
+// newSession creates the provider for the active cloud.
+func newSession(ctx context.Context, opts options) (*session, error) {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	provider, err := providers.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &session{provider: provider, config: cfg, opts: opts}, nil
+}
+
+// Close closes the provider and removes any private keys copied out of the credential store.
+func (s *session) Close() error {
+	if s.tempDir != "" {
+		os.RemoveAll(s.tempDir)
+	}
+	return s.provider.Close()
+}
+
+// connect resolves an instance ID or name to the address, user and private key to connect with.
+func (s *session) connect(ctx context.Context, ref string) (connection, error) {
+	instance, err := clouds.FindInstance(ctx, s.provider, ref)
+	if err != nil {
+		return connection{}, err
+	}
+	return s.connectTo(ctx, instance)
+}
+
+// connectTo returns the address, user and private key to connect to an instance with.
+func (s *session) connectTo(ctx context.Context, instance clouds.Instance) (connection, error) {
+	ref := instance.Name
+	if ref == "" {
+		ref = instance.ID
+	}
+	if isStopped(instance) {
+		return connection{}, fmt.Errorf("instance %s is %s", ref, strings.ToLower(instance.State))
+	}
+
+	// Some clouds only return addresses when describing a single instance
+	if instance.PublicIP == "" && instance.PrivateIP == "" {
+		var err error
+		if instance, err = s.provider.DescribeInstance(ctx, instance.ID); err != nil {
+			return connection{}, err
+		}
+	}
+
+	conn := connection{user: s.opts.user, host: instance.PublicIP}
+	if s.opts.private || s.opts.bastion != "" || conn.host == "" {
+		conn.host = instance.PrivateIP
+	}
+	if conn.host == "" {
+		return connection{}, fmt.Errorf("instance %s has no IP address", ref)
+	}
+
+	if conn.user == "" {
+		conn.user = s.config.SSH.User
+	}
+	if conn.user == "" {
+		conn.user = defaultUser(s.provider.Name())
+	}
+
+	var err error
+
+	conn.identity, err = s.identity(instance)
+	if err != nil {
+		return connection{}, err
+	}
+	return conn, nil
+}
+
+// identity returns the private key file for an instance: the --identity file,
+// or the private key of the key pair from the credential store or
+// ~/.ssh/NAME.pem. Without a known key pair, ssh falls back to its own keys.
+func (s *session) identity(instance clouds.Instance) (string, error) {
+	if s.opts.identity != "" {
+		return s.opts.identity, nil
+	}
+
+	name := s.opts.key
+	if name == "" {
+		name = instance.KeyName
+	}
+	if name == "" {
+		return "", nil
+	}
+
+	privateKey, err := internal.GetPrivateKey(s.provider.Name(), s.config.Profile, name)
+	if err == nil {
+		return s.writeTempKey(name, privateKey)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		path := filepath.Join(home, ".ssh", name+".pem")
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	if s.opts.key != "" {
+		return "", fmt.Errorf("no private key found for key pair %s in the credential store or ~/.ssh/%s.pem", name, name)
+	}
+	return "", nil
+}
+
+// writeTempKey writes a stored private key to a file only the user can read,
+// since ssh only reads keys from files. The file is removed by Close.
+func (s *session) writeTempKey(name, privateKey string) (string, error) {
+	if s.tempDir == "" {
+		dir, err := os.MkdirTemp("", "namaste-ssh-")
+		if err != nil {
+			return "", err
+		}
+		s.tempDir = dir
+	}
+
+	path := filepath.Join(s.tempDir, name+".pem")
+	if err := os.WriteFile(path, []byte(privateKey), 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// clientArgs returns the arguments for the ssh or scp client that come before
+// the destination.
+func (s *session) clientArgs(identity string) []string {
+	var args []string
+	if identity != "" {
+		args = append(args, "-i", identity)
+	}
+	if s.opts.bastion != "" {
+		args = append(args, "-J", s.opts.bastion)
+	}
+	return append(args, strings.Fields(s.opts.sshArgs)...)
+}
+
+// defaultUser returns the login user for a cloud when none is given.
+func defaultUser(cloud string) string {
+	if name, ok := defaultUsers[cloud]; ok {
+		return name
+	}
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return ""
+}
+
+// isStopped reports whether an instance is not running and cannot be reached.
+func isStopped(instance clouds.Instance) bool {
+	switch strings.ToLower(instance.State) {
+	case "stopped", "stopping", "terminated", "shutting-down", "suspended", "suspending", "deallocated":
+		return true
+	}
+	return false
+}
+
+// run runs the system ssh or scp client attached to the terminal. A non-zero
+// exit status is passed on as the exit status of the CLI.
+func run(ctx context.Context, name string, args []string) error {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		fmt.Printf("Error: %s client not found: %v\n", name, err)
+		return nil
+	}
+
+	client := exec.CommandContext(ctx, path, args...)
+	client.Stdin, client.Stdout, client.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err = client.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &internal.ExitError{Code: exitErr.ExitCode()}
+	}
+	if err != nil {
+		fmt.Println("Error:", err)
+	}
+	return nil
+}
+
+// SSHCommand returns the `ssh` command.
+func SSHCommand() *cobra.Command {
+	var opts options
+
+	cmd := &cobra.Command{
+		Use:   "ssh [instance] [command...]",
+		Short: "Open an SSH session to an instance, or run a command on it",
+		Long: "Open an SSH session to an instance given by ID, name or tag:KEY=VALUE, using the system\n" +
+			"ssh client. The instance's public IP is used unless --private or --bastion is given, and its\n" +
+			"key pair's private key is looked up in the credential store and in ~/.ssh/NAME.pem.",
+		Example: "  namaste-cloud ssh web\n" +
+			"  namaste-cloud ssh web --bastion ec2-user@bastion.example.com -- sudo systemctl status nginx",
+		Args:          cobra.MinimumNArgs(1),
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			session, err := newSession(cmd.Context(), opts)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return nil
+			}
+			defer session.Close()
+
+			conn, err := session.connect(cmd.Context(), args[0])
+			if err != nil {
+				fmt.Println("Error:", err)
+				return nil
+			}
+
+			sshArgs := append(session.clientArgs(conn.identity), conn.user+"@"+conn.host)
+			return run(cmd.Context(), "ssh", append(sshArgs, args[1:]...))
+		},
+	}
+
+	addFlags(cmd, &opts)
+	return cmd
+}
+
+// SCPCommand returns the `scp` command.
+func SCPCommand() *cobra.Command {
+	var opts options
+
+	cmd := &cobra.Command{
+		Use:   "scp [source...] [target]",
+		Short: "Copy files to or from instances",
+		Long: "Copy files to or from instances using the system scp client. Remote paths are written\n" +
+			"as INSTANCE:PATH, where INSTANCE is an instance ID, name or tag:KEY=VALUE.",
+		Example: "  namaste-cloud scp ./app.tar.gz web:/tmp/\n" +
+			"  namaste-cloud scp web:/var/log/app.log .",
+		Args:          cobra.MinimumNArgs(2),
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			session, err := newSession(cmd.Context(), opts)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return nil
+			}
+			defer session.Close()
+
+			// Resolve every remote path; scp uses a single key, so the first
+			// instance's key is used for all of them
+			var paths []string
+			var identity string
+			remote := false
+			for _, arg := range args {
+				ref, path, ok := splitRemote(arg)
+				if !ok {
+					paths = append(paths, arg)
+					continue
+				}
+				conn, err := session.connect(cmd.Context(), ref)
+				if err != nil {
+					fmt.Println("Error:", err)
+					return nil
+				}
+				if !remote {
+					identity = conn.identity
+				}
+				remote = true
+				paths = append(paths, conn.user+"@"+conn.host+":"+path)
+			}
+			if !remote {
+				fmt.Println("Error: no remote path given; write remote paths as INSTANCE:PATH")
+				return nil
+			}
+
+			return run(cmd.Context(), "scp", append(session.clientArgs(identity), paths...))
+		},
+	}
+
+	addFlags(cmd, &opts)
+	return cmd
+}
+
+// splitRemote splits an scp argument of the form INSTANCE:PATH, where
+// INSTANCE may be a tag:KEY=VALUE selector. As with scp, arguments with a
+// slash before the first colon are local paths.
+func splitRemote(arg string) (string, string, bool) {
+	prefix := ""
+	if rest, ok := strings.CutPrefix(arg, "tag:"); ok {
+		prefix, arg = "tag:", rest
+	}
+	ref, path, ok := strings.Cut(arg, ":")
+	if !ok || ref == "" || strings.Contains(ref, "/") {
+		return "", "", false
+	}
+	return prefix + ref, path, true
+}
@@ -0,0 +1,206 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"namaste-cloud/clouds/providers"
+	"namaste-cloud/internal"
+	"os"
+	"slices"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// StateCommand returns the `state` command group.
+func StateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "state",
+		Short: "Show and edit the record of the resources the CLI created",
+		Long: "The CLI records every instance, firewall rule, key pair and security group it creates: its\n" +
+			"cloud, region, who created it and when. The state is kept in state.json in the configuration\n" +
+			"directory, or in the file or S3 bucket configured in the state section of the configuration:\n\n" +
+			"  state:\n" +
+			"    backend: s3\n" +
+			"    bucket: my-team-state\n" +
+			"    key: namaste-cloud/state.json",
+	}
+
+	cmd.AddCommand(listCommand())
+	cmd.AddCommand(showCommand())
+	cmd.AddCommand(forgetCommand())
+	return cmd
+}
+
+// loadBackend opens the state backend of the effective configuration.
+func loadBackend(ctx context.Context) (internal.StateBackend, error) {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error loading configuration: %w", err)
+	}
+	return providers.OpenState(ctx, cfg)
+}
+
+func listCommand() *cobra.Command {
+	var stack string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the recorded resources",
+		Long: "List the recorded resources, oldest first. Only the resources of the cloud given with\n" +
+			"--cloud are listed, when given.",
+		Run: func(cmd *cobra.Command, args []string) {
+			backend, err := loadBackend(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			state, err := internal.ReadState(cmd.Context(), backend)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			cloud := ""
+			if flag := cmd.Flag("cloud"); flag != nil && flag.Changed {
+				cloud = flag.Value.String()
+			}
+			var records []internal.StateRecord
+			for _, record := range state.Resources {
+				if (cloud == "" || record.Cloud == cloud) && (stack == "" || record.Stack == stack) {
+					records = append(records, record)
+				}
+			}
+			if len(records) == 0 {
+				fmt.Println("No resources recorded.")
+				return
+			}
+			sort.SliceStable(records, func(i, j int) bool {
+				return records[i].CreatedAt.Before(records[j].CreatedAt)
+			})
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tKIND\tNAME\tCLOUD\tREGION\tSTACK\tCREATED")
+			for _, record := range records {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", record.ID, record.Kind, orDash(record.Name), record.Cloud,
+					orDash(record.Region), orDash(record.Stack), record.CreatedAt.Local().Format("2006-01-02 15:04"))
+			}
+			w.Flush()
+		},
+	}
+
+	cmd.Flags().StringVar(&stack, "stack", "", "Only list the resources created by apply from this stack")
+	return cmd
+}
+
+func showCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show [id]",
+		Short: "Show everything recorded about a resource",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			backend, err := loadBackend(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			state, err := internal.ReadState(cmd.Context(), backend)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			found := false
+			for _, record := range state.Resources {
+				if record.ID != args[0] {
+					continue
+				}
+				if found {
+					fmt.Println()
+				}
+				found = true
+				printRecord(record)
+			}
+			if !found {
+				fmt.Printf("Error: %s is not in the state\n", args[0])
+			}
+		},
+	}
+}
+
+func forgetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "forget [id...]",
+		Short: "Remove resources from the state without deleting them",
+		Long: "Remove resources from the state, such as resources deleted outside the CLI. The resources\n" +
+			"themselves are left as they are.",
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			backend, err := loadBackend(cmd.Context())
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			var forgotten []internal.StateRecord
+			err = internal.UpdateState(cmd.Context(), backend, func(state *internal.State) error {
+				forgotten = nil
+				for _, id := range args {
+					if !slices.ContainsFunc(state.Resources, func(record internal.StateRecord) bool { return record.ID == id }) {
+						return fmt.Errorf("%s is not in the state", id)
+					}
+				}
+				state.Resources = slices.DeleteFunc(state.Resources, func(record internal.StateRecord) bool {
+					if slices.Contains(args, record.ID) {
+						forgotten = append(forgotten, record)
+						return true
+					}
+					return false
+				})
+				return nil
+			})
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			for _, record := range forgotten {
+				fmt.Printf("Forgot %s %s\n", record.Kind, record.ID)
+			}
+		},
+	}
+}
+
+// printRecord prints every field of a record.
+func printRecord(record internal.StateRecord) {
+	fmt.Printf("ID: %s\n", record.ID)
+	fmt.Printf("Kind: %s\n", record.Kind)
+	fmt.Printf("Name: %s\n", orDash(record.Name))
+	fmt.Printf("Cloud: %s\n", record.Cloud)
+	fmt.Printf("Region: %s\n", orDash(record.Region))
+	fmt.Printf("Profile: %s\n", orDash(record.Profile))
+	fmt.Printf("Project: %s\n", orDash(record.Project))
+	fmt.Printf("Stack: %s\n", orDash(record.Stack))
+	fmt.Printf("Created by: %s\n", record.CreatedBy)
+	fmt.Printf("Created at: %s\n", record.CreatedAt.Local().Format("2006-01-02 15:04:05 MST"))
+	if len(record.Attributes) > 0 {
+		fmt.Println("Attributes:")
+		var keys []string
+		for key := range record.Attributes {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Printf("  %s: %s\n", key, record.Attributes[key])
+		}
+	}
+}
+
+// orDash returns the value, or "-" when it is empty.
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"namaste-cloud/clouds/providers"
 	"namaste-cloud/internal"
 
 	"github.com/spf13/cobra"
@@ -20,10 +21,45 @@ func StatusCommand() *cobra.Command {
 				return
 			}
 
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				fmt.Println("Error loading configuration:", err)
+				return
+			}
+
 			fmt.Printf("Active Cloud Provider: %s\n", activeCloud)
 
+			// Show which project configuration, if any, is overlaying the user configuration
+			if _, path, err := internal.LoadProjectConfig(); err == nil && path != "" {
+				fmt.Printf("Project Config: %s\n", path)
+			}
+			if cfg.Profile != "" {
+				fmt.Printf("Profile: %s\n", cfg.Profile)
+			}
+			if cfg.Region != "" {
+				fmt.Printf("Region: %s\n", cfg.Region)
+			}
+			if cfg.Zone != "" {
+				fmt.Printf("Zone: %s\n", cfg.Zone)
+			}
+			if cfg.Project != "" {
+				fmt.Printf("Project: %s\n", cfg.Project)
+			}
+			if cfg.EndpointURL != "" {
+				fmt.Printf("Endpoint: %s\n", cfg.EndpointURL)
+				if cfg.SkipsTLSVerify() {
+					fmt.Println("TLS verification: disabled")
+				}
+			}
+
+			// The fake cloud needs no credentials
+			if !providers.RequiresCredentials(activeCloud) {
+				fmt.Printf("%s does not need credentials.\n", activeCloud)
+				return
+			}
+
 			// Validate credentials
-			cred, err := internal.GetCredential(activeCloud)
+			cred, err := internal.GetProfileCredential(activeCloud, cfg.Profile)
 			if err != nil {
 				fmt.Println("Invalid or missing credentials. Use `namaste-cloud configure` to update.")
 				return
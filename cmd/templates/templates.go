@@ -0,0 +1,287 @@
+package templates
+
+import (
+	"fmt"
+	"namaste-cloud/clouds"
+	"namaste-cloud/clouds/providers"
+	"namaste-cloud/internal"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// templateName matches valid template names.
+var templateName = regexp.MustCompile(`^[A-Za-z0-9][-A-Za-z0-9_.]*$`)
+
+// TemplateCommand returns the `template` command group.
+func TemplateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "template",
+		Aliases: []string{"templates"},
+		Short:   "Manage instance templates for create-instance",
+		Long: "Manage named instance templates. A template has a variant for each cloud, so that\n" +
+			"`create-instance --template NAME` creates the equivalent instance on whichever cloud is selected.\n\n" +
+			"Templates are saved in the user configuration. The project configuration (" + internal.ProjectConfigFileName + ")\n" +
+			"can add templates and variants, which list, show and create-instance use; create and delete\n" +
+			"only change the user configuration.",
+	}
+
+	cmd.AddCommand(createCommand())
+	cmd.AddCommand(listCommand())
+	cmd.AddCommand(showCommand())
+	cmd.AddCommand(deleteCommand())
+	return cmd
+}
+
+// selectedCloud returns the cloud given with --cloud, or the active cloud.
+func selectedCloud() (string, error) {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return "", err
+	}
+	if cfg.ActiveCloud == "" {
+		return "", providers.ErrNoActiveCloud
+	}
+	if !providers.IsSupported(cfg.ActiveCloud) {
+		return "", fmt.Errorf("unsupported cloud %q", cfg.ActiveCloud)
+	}
+	return cfg.ActiveCloud, nil
+}
+
+func createCommand() *cobra.Command {
+	var variant internal.InstanceTemplate
+	var tagFlags []string
+
+	cmd := &cobra.Command{
+		Use:   "create [name]",
+		Short: "Save the variant of a template for a cloud",
+		Long: "Save the variant of a template for the cloud given with --cloud, or the active cloud, in the\n" +
+			"user configuration, replacing any earlier variant for that cloud. Clouds on which the CLI\n" +
+			"cannot create instances yet, such as Azure, have no variants.",
+		Example: "  namaste-cloud template create web-small --cloud aws --type t3.small --image ami-0abcdef1234567890 \\\n" +
+			"      --security-group sg-0123456789abcdef0 --tag role=web\n" +
+			"  namaste-cloud template create web-small --cloud gcp --type e2-small --image debian-12 --tag role=web",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			name := args[0]
+			if !templateName.MatchString(name) {
+				fmt.Printf("Error: invalid template name %q: use letters, digits, hyphens, underscores and dots\n", name)
+				return
+			}
+			tags, err := clouds.ParseTags(tagFlags)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			variant.Tags = tags
+			cloud, err := selectedCloud()
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			// A variant that create-instance cannot use is refused now rather than when used
+			if err := providers.CheckCreateInstance(cloud); err != nil {
+				fmt.Printf("Error: template %s: %v\n", name, err)
+				return
+			}
+
+			cfg, err := internal.LoadUserConfig()
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			if cfg.Templates == nil {
+				cfg.Templates = make(map[string]map[string]internal.InstanceTemplate)
+			}
+			if cfg.Templates[name] == nil {
+				cfg.Templates[name] = make(map[string]internal.InstanceTemplate)
+			}
+			_, replaced := cfg.Templates[name][cloud]
+			cfg.Templates[name][cloud] = variant
+			if err := internal.SaveConfig(cfg); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			if replaced {
+				fmt.Printf("Replaced the %s variant of template %s\n", cloud, name)
+			} else {
+				fmt.Printf("Saved the %s variant of template %s\n", cloud, name)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&variant.Image, "image", "", "Image to boot from: an image ID, such as an AMI ID on AWS, or a release such as ubuntu-24.04")
+	cmd.Flags().StringVar(&variant.Type, "type", "", "Instance or machine type, e.g. t3.small or e2-small")
+	cmd.Flags().StringVar(&variant.KeyName, "key-name", "", "Key pair to authorize for SSH, on AWS")
+	cmd.Flags().StringSliceVar(&variant.SecurityGroups, "security-group", nil, "Security group ID to attach, on AWS (repeatable)")
+	cmd.Flags().StringArrayVar(&tagFlags, "tag", nil, "Tag to add as KEY=VALUE (repeatable)")
+	return cmd
+}
+
+func listCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List templates and their variants, from the user and project configurations",
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			if len(cfg.Templates) == 0 {
+				fmt.Println("No templates; save one with `template create`.")
+				return
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "TEMPLATE\tCLOUD\tTYPE\tIMAGE")
+			for _, name := range sortedKeys(cfg.Templates) {
+				for _, cloud := range sortedKeys(cfg.Templates[name]) {
+					variant := cfg.Templates[name][cloud]
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, cloud, orDefault(variant.Type), orDefault(variant.Image))
+				}
+			}
+			w.Flush()
+		},
+	}
+}
+
+func showCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show [name]",
+		Short: "Show every variant of a template",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			variants, ok := cfg.Templates[args[0]]
+			if !ok {
+				fmt.Printf("Error: template %s: %v\n", args[0], clouds.ErrNotFound)
+				return
+			}
+
+			for i, cloud := range sortedKeys(variants) {
+				if i > 0 {
+					fmt.Println()
+				}
+				variant := variants[cloud]
+				fmt.Printf("Cloud: %s\n", cloud)
+				fmt.Printf("  Type: %s\n", orDefault(variant.Type))
+				fmt.Printf("  Image: %s\n", orDefault(variant.Image))
+				if variant.KeyName != "" {
+					fmt.Printf("  Key pair: %s\n", variant.KeyName)
+				}
+				if len(variant.SecurityGroups) > 0 {
+					fmt.Printf("  Security groups: %s\n", strings.Join(variant.SecurityGroups, ", "))
+				}
+				if len(variant.Tags) > 0 {
+					var tags []string
+					for _, key := range clouds.SortedKeys(variant.Tags) {
+						tags = append(tags, key+"="+variant.Tags[key])
+					}
+					fmt.Printf("  Tags: %s\n", strings.Join(tags, ", "))
+				}
+			}
+		},
+	}
+}
+
+func deleteCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete [name]",
+		Short: "Delete every variant of a template, or only the one for the cloud given with --cloud",
+		Long: "Delete a template from the user configuration: every variant, or only the variant for the cloud\n" +
+			"given with --cloud. Templates of the project configuration are edited in " + internal.ProjectConfigFileName + ".",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			name := args[0]
+			cfg, err := internal.LoadUserConfig()
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			project, projectPath, err := internal.LoadProjectConfig()
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			var cloud string
+			if flag := cmd.Flag("cloud"); flag != nil && flag.Changed {
+				cloud = flag.Value.String()
+			}
+
+			deleted := fmt.Sprintf("template %s", name)
+			if cloud != "" {
+				deleted = fmt.Sprintf("the %s variant of template %s", cloud, name)
+			}
+			if !hasVariant(cfg, name, cloud) {
+				switch {
+				case hasVariant(project, name, cloud):
+					fmt.Printf("Error: %s is defined in the project configuration %s; edit that file to delete it\n", deleted, projectPath)
+				case cloud != "" && cfg.Templates[name] != nil:
+					fmt.Printf("Error: template %s has no variant for %s\n", name, cloud)
+				default:
+					fmt.Printf("Error: template %s: %v\n", name, clouds.ErrNotFound)
+				}
+				return
+			}
+
+			if cloud != "" {
+				delete(cfg.Templates[name], cloud)
+				// A template goes with its last variant
+				if len(cfg.Templates[name]) == 0 {
+					delete(cfg.Templates, name)
+				}
+			} else {
+				delete(cfg.Templates, name)
+			}
+			if err := internal.SaveConfig(cfg); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			fmt.Printf("Deleted %s\n", deleted)
+			if hasVariant(project, name, cloud) {
+				fmt.Printf("Warning: the project configuration %s still defines %s; edit that file to delete it\n", projectPath, deleted)
+			}
+		},
+	}
+}
+
+// hasVariant reports whether a configuration has the variant of a template
+// for a cloud, or any variant if the cloud is empty.
+func hasVariant(cfg internal.Config, name, cloud string) bool {
+	variants, ok := cfg.Templates[name]
+	if cloud == "" {
+		return ok
+	}
+	_, ok = variants[cloud]
+	return ok
+}
+
+// sortedKeys returns the keys of a map in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// orDefault returns the value, or "(default)" when it is empty, since the
+// provider then picks one.
+func orDefault(value string) string {
+	if value == "" {
+		return "(default)"
+	}
+	return value
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"namaste-cloud/clouds/providers"
 	"namaste-cloud/internal"
 
 	"github.com/spf13/cobra"
@@ -17,16 +18,22 @@ func UseCloudCommand() *cobra.Command {
 		Args:  cobra.ExactArgs(1), // Ensure exactly one argument is provided
 		Run: func(cmd *cobra.Command, args []string) {
 			cloud := strings.ToLower(args[0])
-			if cloud == "aws" || cloud == "gcp" || cloud == "azure" {
+			if providers.IsSupported(cloud) {
 				// Check if credentials exist for the cloud
-				_, err := internal.GetCredential(cloud)
+				effective, err := internal.LoadConfig()
 				if err != nil {
-					fmt.Printf("No credentials found for %s. Use `namaste-cloud configure` to set them.\n", cloud)
+					fmt.Printf("Failed to load configuration: %v\n", err)
 					return
 				}
+				if providers.RequiresCredentials(cloud) {
+					if _, err := internal.GetProfileCredential(cloud, effective.Profile); err != nil {
+						fmt.Printf("No credentials found for %s. Use `namaste-cloud configure` to set them.\n", cloud)
+						return
+					}
+				}
 
 				// Load existing configuration
-				cfg, err := internal.LoadConfig()
+				cfg, err := internal.LoadUserConfig()
 				if err != nil {
 					fmt.Printf("Failed to load configuration: %v\n", err)
 					return
@@ -41,7 +48,7 @@ func UseCloudCommand() *cobra.Command {
 
 				fmt.Printf("Active cloud provider set to: %s\n", cloud)
 			} else {
-				fmt.Println("Unsupported cloud provider. Please choose from aws, gcp, or azure.")
+				fmt.Println("Unsupported cloud provider. Please choose from aws, gcp, azure, or fake.")
 			}
 		},
 	}
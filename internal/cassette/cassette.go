@@ -0,0 +1,365 @@
+// Package cassette records cloud API interactions to files and replays them,
+// so provider tests run offline against real request and response shapes.
+//
+// Cassettes are replayed by default. Set NAMASTE_RECORD=1 to send requests to
+// the real APIs instead and overwrite the cassettes with what was exchanged.
+// Only methods, URLs and bodies are recorded; headers, which carry the
+// credentials, never are. Secrets in URLs and bodies, such as key material
+// and OAuth tokens, are redacted, and recording fails if a body still holds
+// a private key.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"namaste-cloud/internal"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RecordEnv is the environment variable that switches cassettes to recording.
+const RecordEnv = "NAMASTE_RECORD"
+
+// Interaction is a single recorded request and its response.
+type Interaction struct {
+	Request  Request  `yaml:"request"`
+	Response Response `yaml:"response"`
+}
+
+// Request is the recorded part of an HTTP request.
+type Request struct {
+	Method string `yaml:"method"`
+	URL    string `yaml:"url"`
+	Body   string `yaml:"body,omitempty"`
+}
+
+// Response is the recorded part of an HTTP response.
+type Response struct {
+	Status      int    `yaml:"status"`
+	ContentType string `yaml:"content_type,omitempty"`
+	Body        string `yaml:"body,omitempty"`
+}
+
+// Recorder is an http.RoundTripper that replays or records a cassette.
+type Recorder struct {
+	t         testing.TB
+	path      string
+	recording bool
+	transport http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+
+	// leaked is set when a private key was exchanged, so that the cassette
+	// is not saved.
+	leaked bool
+}
+
+// New returns a recorder for the cassette at path. In replay mode the test
+// fails if the cassette is missing, and at the end of the test if any
+// interaction was never requested. In record mode the cassette is written
+// when the test finishes.
+func New(t testing.TB, path string) *Recorder {
+	t.Helper()
+
+	r := &Recorder{
+		t:         t,
+		path:      path,
+		recording: os.Getenv(RecordEnv) != "",
+		transport: http.DefaultTransport,
+	}
+
+	if r.recording {
+		t.Cleanup(func() {
+			if err := r.save(); err != nil {
+				t.Errorf("failed to save cassette: %v", err)
+			}
+		})
+		return r
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read cassette (set %s=1 to record it): %v", RecordEnv, err)
+	}
+	if err := yaml.Unmarshal(data, &r.interactions); err != nil {
+		t.Fatalf("failed to parse cassette %s: %v", path, err)
+	}
+	r.used = make([]bool, len(r.interactions))
+
+	t.Cleanup(func() {
+		for i, used := range r.used {
+			if !used {
+				req := r.interactions[i].Request
+				t.Errorf("cassette %s: interaction %d (%s %s) was never requested", path, i, req.Method, req.URL)
+			}
+		}
+	})
+	return r
+}
+
+// Recording reports whether the recorder talks to the real APIs.
+func (r *Recorder) Recording() bool {
+	return r.recording
+}
+
+// Use makes every cloud API client created during the test go through the
+// cassette at path, and returns the recorder.
+func Use(t testing.TB, path string) *Recorder {
+	t.Helper()
+
+	r := New(t, path)
+	internal.Transport = r
+	t.Cleanup(func() { internal.Transport = nil })
+	return r
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	// Requests are redacted when replaying too, so that they match what was recorded
+	recorded := Request{Method: req.Method, URL: redactURL(req.URL.String()), Body: redact(string(body))}
+
+	if r.recording {
+		return r.record(req, recorded)
+	}
+	return r.replay(req, recorded)
+}
+
+// record sends the request to the real API and keeps the interaction.
+func (r *Recorder) record(req *http.Request, recorded Request) (*http.Response, error) {
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	interaction := Interaction{
+		Request: recorded,
+		Response: Response{
+			Status:      resp.StatusCode,
+			ContentType: resp.Header.Get("Content-Type"),
+			Body:        redact(string(body)),
+		},
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if strings.Contains(interaction.Request.Body, "PRIVATE KEY") || strings.Contains(interaction.Response.Body, "PRIVATE KEY") {
+		r.leaked = true
+		r.t.Errorf("cassette %s: %s %s exchanged a private key, which must not be recorded", r.path, recorded.Method, recorded.URL)
+		return nil, fmt.Errorf("cassette %s: refusing to record a private key", r.path)
+	}
+	r.interactions = append(r.interactions, interaction)
+	return resp, nil
+}
+
+// replay answers the request with the first unused matching interaction.
+func (r *Recorder) replay(req *http.Request, recorded Request) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.interactions {
+		if r.used[i] || !sameRequest(interaction.Request, recorded) {
+			continue
+		}
+		r.used[i] = true
+
+		header := make(http.Header)
+		if interaction.Response.ContentType != "" {
+			header.Set("Content-Type", interaction.Response.ContentType)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.Status, http.StatusText(interaction.Response.Status)),
+			StatusCode:    interaction.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader([]byte(interaction.Response.Body))),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	r.t.Errorf("cassette %s: no interaction recorded for %s %s\n%s", r.path, recorded.Method, recorded.URL, recorded.Body)
+	return nil, fmt.Errorf("cassette %s: unexpected request %s %s", r.path, recorded.Method, recorded.URL)
+}
+
+// sameRequest reports whether two requests match. JSON bodies match whatever
+// their whitespace, which protojson deliberately varies between builds.
+func sameRequest(a, b Request) bool {
+	return a.Method == b.Method && a.URL == b.URL && compactJSON(a.Body) == compactJSON(b.Body)
+}
+
+// compactJSON returns body without insignificant whitespace if it is JSON.
+func compactJSON(body string) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(body)); err != nil {
+		return body
+	}
+	return buf.String()
+}
+
+// save writes the recorded interactions to the cassette file.
+func (r *Recorder) save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.leaked {
+		return errors.New("a private key was exchanged; the cassette was not saved")
+	}
+	data, err := yaml.Marshal(r.interactions)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, data, 0644)
+}
+
+// Redacted replaces the secrets in recorded URLs and bodies.
+const Redacted = "REDACTED"
+
+// secretFields are the names, in lower case, of the JSON, XML and form fields
+// that hold secrets: key material, passwords, OAuth tokens and client secrets.
+var secretFields = map[string]bool{
+	"keymaterial":     true,
+	"privatekey":      true,
+	"private_key":     true,
+	"password":        true,
+	"adminpassword":   true,
+	"secretaccesskey": true,
+	"sessiontoken":    true,
+	"access_token":    true,
+	"refresh_token":   true,
+	"id_token":        true,
+	"client_secret":   true,
+	"assertion":       true,
+}
+
+// secretParams are the query parameters, in lower case, that sign or
+// authorize a URL, such as the signature of an Azure SAS URI.
+var secretParams = map[string]bool{
+	"sig":                  true,
+	"signature":            true,
+	"x-amz-signature":      true,
+	"x-amz-credential":     true,
+	"x-amz-security-token": true,
+	"access_token":         true,
+}
+
+// xmlSecrets matches the XML elements of secretFields, as in EC2 responses.
+var xmlSecrets = regexp.MustCompile(`(?is)<(keyMaterial|privateKey|password|secretAccessKey|sessionToken)>.*?</(keyMaterial|privateKey|password|secretAccessKey|sessionToken)>`)
+
+// bearerTokens matches bearer tokens, as copied from Authorization headers.
+var bearerTokens = regexp.MustCompile(`Bearer [A-Za-z0-9._~+/=-]+`)
+
+// redact replaces the secrets in a JSON, XML or form-encoded body.
+func redact(body string) string {
+	if body == "" {
+		return body
+	}
+	body = bearerTokens.ReplaceAllString(body, "Bearer "+Redacted)
+	body = xmlSecrets.ReplaceAllStringFunc(body, func(element string) string {
+		name := element[1:strings.IndexByte(element, '>')]
+		return "<" + name + ">" + Redacted + "</" + name + ">"
+	})
+
+	var value any
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err == nil && !decoder.More() {
+		if redactJSON(value) {
+			if data, err := json.MarshalIndent(value, "", "  "); err == nil {
+				return string(data)
+			}
+		}
+		return body
+	}
+
+	if form, err := url.ParseQuery(body); err == nil && redactValues(form, secretFields) {
+		return form.Encode()
+	}
+	return body
+}
+
+// redactJSON replaces the values of secretFields in a decoded JSON value,
+// and reports whether it replaced any.
+func redactJSON(value any) bool {
+	redacted := false
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if secretFields[strings.ToLower(key)] {
+				if _, ok := field.(string); ok {
+					v[key] = Redacted
+					redacted = true
+					continue
+				}
+			}
+			redacted = redactJSON(field) || redacted
+		}
+	case []any:
+		for _, item := range v {
+			redacted = redactJSON(item) || redacted
+		}
+	}
+	return redacted
+}
+
+// redactURL replaces the secretParams in the query of a URL.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" {
+		return rawURL
+	}
+	query := u.Query()
+	if !redactValues(query, secretParams) {
+		return rawURL
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// redactValues replaces the values of the secret keys of a query or form,
+// and reports whether it replaced any.
+func redactValues(values url.Values, secrets map[string]bool) bool {
+	redacted := false
+	for key, vals := range values {
+		if !secrets[strings.ToLower(key)] {
+			continue
+		}
+		for i := range vals {
+			vals[i] = Redacted
+		}
+		redacted = true
+	}
+	return redacted
+}
@@ -7,9 +7,189 @@ import (
 	"path/filepath"
 )
 
+// ConfigDirEnv is the environment variable that overrides the configuration directory.
+const ConfigDirEnv = "NAMASTE_CONFIG_DIR"
+
 // Config structure to store global configuration like active cloud provider.
 type Config struct {
-	ActiveCloud string `json:"active_cloud"`
+	ActiveCloud string            `json:"active_cloud" yaml:"cloud"`
+	Profile     string            `json:"profile,omitempty" yaml:"profile"`
+	Region      string            `json:"region,omitempty" yaml:"region"`
+	Zone        string            `json:"zone,omitempty" yaml:"zone"`
+	Project     string            `json:"project,omitempty" yaml:"project"`
+	DefaultTags map[string]string `json:"default_tags,omitempty" yaml:"default_tags"`
+	Retry       RetryPolicy       `json:"retry,omitempty" yaml:"retry"`
+
+	// EndpointURL points every API call at a different endpoint, such as a
+	// local emulator, and SkipTLSVerify accepts its self-signed certificate.
+	// SkipTLSVerify is nil when not set, so that a later layer can set it to
+	// false. Neither may be set in a project configuration.
+	EndpointURL   string `json:"endpoint_url,omitempty" yaml:"endpoint_url"`
+	SkipTLSVerify *bool  `json:"skip_tls_verify,omitempty" yaml:"skip_tls_verify"`
+
+	// SSH configures how the ssh, scp and exec commands reach instances.
+	SSH SSHConfig `json:"ssh,omitempty" yaml:"ssh"`
+
+	// Protection lists the instances that destructive commands refuse to act
+	// on unless forced.
+	Protection []ProtectionRule `json:"protection,omitempty" yaml:"protection"`
+
+	// State configures where the resources the CLI creates are recorded.
+	// It may not be set in a project configuration.
+	State StateConfig `json:"state,omitempty" yaml:"state"`
+
+	// PricingURL is where `cost refresh` downloads the pricing catalog from.
+	// It may not be set in a project configuration.
+	PricingURL string `json:"pricing_url,omitempty" yaml:"pricing_url"`
+
+	// Templates are reusable instance configurations by name, with a
+	// variant for each cloud by cloud name.
+	Templates map[string]map[string]InstanceTemplate `json:"templates,omitempty" yaml:"templates"`
+
+	// Profiles holds settings that apply only while the named profile is
+	// selected. They may only be set in the user configuration.
+	Profiles map[string]Config `json:"profiles,omitempty" yaml:"profiles"`
+}
+
+// SSHConfig configures how commands are run on instances.
+type SSHConfig struct {
+	// User is the login user, such as the POSIX user name OS Login assigns
+	// on GCP. It defaults to the usual user of the cloud's stock images.
+	User string `json:"user,omitempty" yaml:"user"`
+
+	// Transport is how exec runs commands: "ssh" (the default), or "ssm" to
+	// use AWS Systems Manager instead of connecting to the instances.
+	Transport string `json:"transport,omitempty" yaml:"transport"`
+}
+
+// ProtectionRule protects the instances matching all of its filters, such
+// as tag:env=prod, from the listed actions.
+type ProtectionRule struct {
+	Filters []string `json:"filters" yaml:"filters"`
+
+	// Actions are the lifecycle actions refused without --force: terminate
+	// (the default), stop or reboot.
+	Actions []string `json:"actions,omitempty" yaml:"actions"`
+}
+
+// InstanceTemplate is the variant of an instance template for one cloud.
+type InstanceTemplate struct {
+	Image   string `json:"image,omitempty" yaml:"image"`
+	Type    string `json:"type,omitempty" yaml:"type"`
+	KeyName string `json:"key_name,omitempty" yaml:"key_name"`
+
+	// SecurityGroups are the IDs of the security groups to attach, on AWS.
+	SecurityGroups []string          `json:"security_groups,omitempty" yaml:"security_groups"`
+	Tags           map[string]string `json:"tags,omitempty" yaml:"tags"`
+}
+
+// StateConfig configures the state store.
+type StateConfig struct {
+	// Backend is "file" (the default) or "s3".
+	Backend string `json:"backend,omitempty" yaml:"backend"`
+
+	// Path is the state file of the file backend. It defaults to state.json
+	// in the configuration directory.
+	Path string `json:"path,omitempty" yaml:"path"`
+
+	// Bucket and Key locate the state object of the s3 backend, which uses
+	// the AWS credentials of Profile. EndpointURL points it at S3-compatible
+	// storage such as MinIO, with path-style addressing.
+	Bucket      string `json:"bucket,omitempty" yaml:"bucket"`
+	Key         string `json:"key,omitempty" yaml:"key"`
+	Region      string `json:"region,omitempty" yaml:"region"`
+	Profile     string `json:"profile,omitempty" yaml:"profile"`
+	EndpointURL string `json:"endpoint_url,omitempty" yaml:"endpoint_url"`
+}
+
+// overlay returns a copy of cfg with every value set in other taking precedence.
+func (cfg Config) overlay(other Config) Config {
+	if other.ActiveCloud != "" {
+		cfg.ActiveCloud = other.ActiveCloud
+	}
+	if other.Profile != "" {
+		cfg.Profile = other.Profile
+	}
+	if other.Region != "" {
+		cfg.Region = other.Region
+	}
+	if other.Zone != "" {
+		cfg.Zone = other.Zone
+	}
+	if other.Project != "" {
+		cfg.Project = other.Project
+	}
+	if len(other.DefaultTags) > 0 {
+		tags := make(map[string]string, len(cfg.DefaultTags)+len(other.DefaultTags))
+		for k, v := range cfg.DefaultTags {
+			tags[k] = v
+		}
+		for k, v := range other.DefaultTags {
+			tags[k] = v
+		}
+		cfg.DefaultTags = tags
+	}
+	cfg.Retry = cfg.Retry.overlay(other.Retry)
+	if other.EndpointURL != "" {
+		cfg.EndpointURL = other.EndpointURL
+	}
+	if other.SkipTLSVerify != nil {
+		cfg.SkipTLSVerify = other.SkipTLSVerify
+	}
+	if other.SSH.User != "" {
+		cfg.SSH.User = other.SSH.User
+	}
+	if other.SSH.Transport != "" {
+		cfg.SSH.Transport = other.SSH.Transport
+	}
+	// Protection rules only ever add up, so that a project or profile cannot
+	// lift the protection set up elsewhere
+	if len(other.Protection) > 0 {
+		cfg.Protection = append(append([]ProtectionRule(nil), cfg.Protection...), other.Protection...)
+	}
+	// The state backend is configured as a whole, since its settings only
+	// make sense together
+	if other.State != (StateConfig{}) {
+		cfg.State = other.State
+	}
+	if other.PricingURL != "" {
+		cfg.PricingURL = other.PricingURL
+	}
+	// Templates are merged by name and cloud, so that a project can add the
+	// variant of a template for another cloud
+	if len(other.Templates) > 0 {
+		templates := make(map[string]map[string]InstanceTemplate, len(cfg.Templates)+len(other.Templates))
+		for name, variants := range cfg.Templates {
+			templates[name] = variants
+		}
+		for name, variants := range other.Templates {
+			merged := make(map[string]InstanceTemplate, len(templates[name])+len(variants))
+			for cloud, variant := range templates[name] {
+				merged[cloud] = variant
+			}
+			for cloud, variant := range variants {
+				merged[cloud] = variant
+			}
+			templates[name] = merged
+		}
+		cfg.Templates = templates
+	}
+	if len(other.Profiles) > 0 {
+		profiles := make(map[string]Config, len(cfg.Profiles)+len(other.Profiles))
+		for name, profile := range cfg.Profiles {
+			profiles[name] = profile
+		}
+		for name, profile := range other.Profiles {
+			profiles[name] = profiles[name].overlay(profile)
+		}
+		cfg.Profiles = profiles
+	}
+	return cfg
+}
+
+// SkipsTLSVerify reports whether TLS certificates go unverified.
+func (cfg Config) SkipsTLSVerify() bool {
+	return cfg.SkipTLSVerify != nil && *cfg.SkipTLSVerify
 }
 
 // GetConfigFilePath returns the path to the configuration file.
@@ -22,7 +202,11 @@ func GetConfigFilePath() (string, error) {
 }
 
 // GetUserConfigDir returns the user-specific configuration directory.
+// NAMASTE_CONFIG_DIR takes precedence over ~/.namaste-cloud when set.
 func GetUserConfigDir() (string, error) {
+	if dir := os.Getenv(ConfigDirEnv); dir != "" {
+		return dir, nil
+	}
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("failed to get user home directory: %w", err)
@@ -47,7 +231,7 @@ func SaveActiveCloudProvider(activeCloud string) error {
 	}
 
 	// Load existing configuration or initialize a new one
-	cfg, err := LoadConfig()
+	cfg, err := LoadUserConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load existing config: %w", err)
 	}
@@ -98,8 +282,39 @@ func SaveConfig(cfg Config) error {
 	return nil
 }
 
-// LoadConfig loads the configuration from a file.
+// LoadConfig loads the effective configuration: the user configuration
+// overlaid with the nearest project configuration file, if any, then with
+// the settings of the selected profile, and then with the command-line
+// overrides for this invocation.
 func LoadConfig() (Config, error) {
+	cfg, err := LoadUserConfig()
+	if err != nil {
+		return Config{}, err
+	}
+
+	project, _, err := LoadProjectConfig()
+	if err != nil {
+		return Config{}, err
+	}
+
+	cfg = cfg.overlay(project)
+
+	// The profile may itself be chosen on the command line
+	profile := cfg.Profile
+	if overrides.Profile != "" {
+		profile = overrides.Profile
+	}
+	if settings, ok := cfg.Profiles[profile]; ok {
+		settings.Profiles = nil
+		cfg = cfg.overlay(settings)
+	}
+
+	return cfg.overlay(overrides), nil
+}
+
+// LoadUserConfig loads the configuration from the user configuration file.
+// Use it instead of LoadConfig when the result is going to be saved back.
+func LoadUserConfig() (Config, error) {
 	configFilePath, err := GetConfigFilePath()
 	if err != nil {
 		return Config{}, err
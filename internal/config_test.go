@@ -0,0 +1,232 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// useConfigDirs creates a configuration directory holding userConfig, when
+// not empty, and runs the test from a directory nested in a project whose
+// configuration is projectConfig, when not empty. Overrides are reset.
+func useConfigDirs(t *testing.T, userConfig, projectConfig string) {
+	t.Helper()
+
+	configDir := t.TempDir()
+	t.Setenv(ConfigDirEnv, configDir)
+	if userConfig != "" {
+		if err := os.WriteFile(filepath.Join(configDir, "config.json"), []byte(userConfig), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	project := t.TempDir()
+	workDir := filepath.Join(project, "services", "web")
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if projectConfig != "" {
+		if err := os.WriteFile(filepath.Join(project, ProjectConfigFileName), []byte(projectConfig), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(workDir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	SetOverrides(Config{})
+	t.Cleanup(func() { SetOverrides(Config{}) })
+}
+
+func TestGetUserConfigDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	t.Setenv(ConfigDirEnv, "")
+	dir, err := GetUserConfigDir()
+	if err != nil || dir != filepath.Join(home, ".namaste-cloud") {
+		t.Errorf("got %q, %v, want ~/.namaste-cloud", dir, err)
+	}
+
+	t.Setenv(ConfigDirEnv, "/etc/namaste")
+	dir, err = GetUserConfigDir()
+	if err != nil || dir != "/etc/namaste" {
+		t.Errorf("got %q, %v, want %s to take precedence", dir, err, ConfigDirEnv)
+	}
+}
+
+func TestFindProjectConfig(t *testing.T) {
+	project := t.TempDir()
+	nested := filepath.Join(project, "a", "b")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := FindProjectConfig(nested)
+	if err != nil || path != "" {
+		t.Errorf("got %q, %v, want no project config", path, err)
+	}
+
+	want := filepath.Join(project, ProjectConfigFileName)
+	if err := os.WriteFile(want, []byte("cloud: gcp\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{project, filepath.Join(project, "a"), nested} {
+		path, err := FindProjectConfig(dir)
+		if err != nil || path != want {
+			t.Errorf("FindProjectConfig(%s) = %q, %v, want %q", dir, path, err, want)
+		}
+	}
+
+	// The nearest file wins
+	nearer := filepath.Join(project, "a", ProjectConfigFileName)
+	if err := os.WriteFile(nearer, []byte("cloud: aws\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := FindProjectConfig(nested); err != nil || path != nearer {
+		t.Errorf("got %q, %v, want %q", path, err, nearer)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	yes, no := true, false
+
+	tests := []struct {
+		name      string
+		user      string
+		project   string
+		overrides Config
+		want      Config
+	}{
+		{
+			name: "user only",
+			user: `{"active_cloud": "aws", "region": "us-east-1", "default_tags": {"team": "web"}}`,
+			want: Config{ActiveCloud: "aws", Region: "us-east-1", DefaultTags: map[string]string{"team": "web"}},
+		},
+		{
+			name:    "project over user",
+			user:    `{"active_cloud": "aws", "region": "us-east-1", "default_tags": {"team": "web", "env": "dev"}}`,
+			project: "region: eu-west-1\ndefault_tags:\n  env: prod\n",
+			want:    Config{ActiveCloud: "aws", Region: "eu-west-1", DefaultTags: map[string]string{"team": "web", "env": "prod"}},
+		},
+		{
+			name: "profile over project",
+			user: `{"active_cloud": "aws", "profile": "staging", "region": "us-east-1",
+				"profiles": {"staging": {"region": "us-west-2", "zone": "us-west-2a"}}}`,
+			project: "region: eu-west-1\nproject: web\n",
+			want:    Config{ActiveCloud: "aws", Profile: "staging", Region: "us-west-2", Zone: "us-west-2a", Project: "web"},
+		},
+		{
+			name:    "profile selected by the project",
+			user:    `{"active_cloud": "aws", "profiles": {"staging": {"region": "us-west-2"}}}`,
+			project: "profile: staging\n",
+			want:    Config{ActiveCloud: "aws", Profile: "staging", Region: "us-west-2"},
+		},
+		{
+			name:      "profile selected on the command line",
+			user:      `{"active_cloud": "aws", "region": "us-east-1", "profiles": {"staging": {"region": "us-west-2"}}}`,
+			overrides: Config{Profile: "staging"},
+			want:      Config{ActiveCloud: "aws", Profile: "staging", Region: "us-west-2"},
+		},
+		{
+			name:      "flags over profile",
+			user:      `{"active_cloud": "aws", "profile": "staging", "profiles": {"staging": {"region": "us-west-2"}}}`,
+			project:   "cloud: gcp\n",
+			overrides: Config{ActiveCloud: "azure", Region: "westeurope"},
+			want:      Config{ActiveCloud: "azure", Profile: "staging", Region: "westeurope"},
+		},
+		{
+			name: "profile turns TLS verification back on",
+			user: `{"active_cloud": "aws", "endpoint_url": "https://localhost:4566", "skip_tls_verify": true,
+				"profile": "real", "profiles": {"real": {"skip_tls_verify": false}}}`,
+			want: Config{ActiveCloud: "aws", Profile: "real", EndpointURL: "https://localhost:4566", SkipTLSVerify: &no},
+		},
+		{
+			name:      "flag skips TLS verification",
+			user:      `{"active_cloud": "aws", "endpoint_url": "https://localhost:4566"}`,
+			overrides: Config{SkipTLSVerify: &yes},
+			want:      Config{ActiveCloud: "aws", EndpointURL: "https://localhost:4566", SkipTLSVerify: &yes},
+		},
+		{
+			name:      "flag turns TLS verification back on",
+			user:      `{"active_cloud": "aws", "skip_tls_verify": true}`,
+			overrides: Config{SkipTLSVerify: &no},
+			want:      Config{ActiveCloud: "aws", SkipTLSVerify: &no},
+		},
+		{
+			name: "protection rules add up",
+			user: `{"active_cloud": "aws", "protection": [{"filters": ["tag:env=prod"]}],
+				"profile": "ops", "profiles": {"ops": {"protection": [{"filters": ["name=db"], "actions": ["stop"]}]}}}`,
+			project: "protection:\n  - filters: [\"tag:role=worker\"]\n",
+			want: Config{ActiveCloud: "aws", Profile: "ops", Protection: []ProtectionRule{
+				{Filters: []string{"tag:env=prod"}},
+				{Filters: []string{"tag:role=worker"}},
+				{Filters: []string{"name=db"}, Actions: []string{"stop"}},
+			}},
+		},
+		{
+			name: "templates merge by name and cloud",
+			user: `{"active_cloud": "aws", "templates": {
+				"web": {"aws": {"type": "t3.small"}, "gcp": {"type": "e2-small"}},
+				"db": {"aws": {"type": "r5.large"}}}}`,
+			project: "templates:\n  web:\n    gcp:\n      type: e2-medium\n    azure:\n      type: Standard_B2s\n",
+			want: Config{ActiveCloud: "aws", Templates: map[string]map[string]InstanceTemplate{
+				"web": {"aws": {Type: "t3.small"}, "gcp": {Type: "e2-medium"}, "azure": {Type: "Standard_B2s"}},
+				"db":  {"aws": {Type: "r5.large"}},
+			}},
+		},
+		{
+			name: "state is replaced as a whole",
+			user: `{"active_cloud": "aws", "state": {"backend": "s3", "bucket": "states", "key": "namaste.json"},
+				"profile": "local", "profiles": {"local": {"state": {"path": "./state.json"}}}}`,
+			want: Config{ActiveCloud: "aws", Profile: "local", State: StateConfig{Path: "./state.json"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfigDirs(t, tt.user, tt.project)
+			SetOverrides(tt.overrides)
+
+			got, err := LoadConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got.Profiles = nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProjectConfigUserOnlyKeys(t *testing.T) {
+	for key, project := range map[string]string{
+		"endpoint_url":       "endpoint_url: https://attacker.example\n",
+		"skip_tls_verify":    "skip_tls_verify: false\n",
+		"pricing_url":        "pricing_url: https://attacker.example/pricing.json\n",
+		"state.endpoint_url": "state:\n  backend: s3\n  endpoint_url: https://attacker.example\n",
+		"state.backend":      "state:\n  backend: s3\n",
+		"state.path":         "state:\n  path: /tmp/stolen.json\n",
+		"state.bucket":       "state:\n  bucket: attacker-bucket\n",
+		"state.key":          "state:\n  key: other.json\n",
+		"state.region":       "state:\n  region: us-east-1\n",
+		"state.profile":      "state:\n  profile: prod\n",
+		"profiles":           "profiles:\n  default:\n    region: us-east-1\n",
+	} {
+		t.Run(key, func(t *testing.T) {
+			useConfigDirs(t, `{"active_cloud": "aws"}`, project)
+
+			_, err := LoadConfig()
+			if err == nil || !strings.Contains(err.Error(), key+" may only be set in the user configuration") {
+				t.Errorf("got %v, want %s to be rejected", err, key)
+			}
+		})
+	}
+}
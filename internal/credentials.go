@@ -14,9 +14,21 @@ import (
 
 // Credential structure to store cloud credentials.
 type Credential struct {
-	Cloud     string `json:"cloud"`
-	AccessKey string `json:"access_key"`
-	SecretKey string `json:"secret_key"`
+	Cloud          string `json:"cloud"`
+	Profile        string `json:"profile,omitempty"`
+	AccessKey      string `json:"access_key"`
+	SecretKey      string `json:"secret_key"`
+	TenantID       string `json:"tenant_id,omitempty"`
+	SubscriptionID string `json:"subscription_id,omitempty"`
+}
+
+// credentialKey returns the key a credential is stored under. Credentials for
+// the default profile are keyed by cloud alone so existing stores keep working.
+func credentialKey(cloud, profile string) string {
+	if profile == "" || profile == "default" {
+		return cloud
+	}
+	return cloud + ":" + profile
 }
 
 // GetCredentialFilePath returns the path to the encrypted credentials file.
@@ -61,7 +73,7 @@ func SaveCredential(cred Credential) error {
 	}
 
 	// Add or update the credential for the given cloud.
-	creds[cred.Cloud] = cred
+	creds[credentialKey(cred.Cloud, cred.Profile)] = cred
 
 	// Serialize credentials to JSON.
 	data, err := json.Marshal(creds)
@@ -107,8 +119,13 @@ func LoadAllCredentials() (map[string]Credential, error) {
 	return creds, nil
 }
 
-// GetCredential retrieves a specific cloud's credential.
+// GetCredential retrieves a specific cloud's credential for the default profile.
 func GetCredential(cloud string) (Credential, error) {
+	return GetProfileCredential(cloud, "")
+}
+
+// GetProfileCredential retrieves a specific cloud's credential for the given profile.
+func GetProfileCredential(cloud, profile string) (Credential, error) {
 	// Load all stored credentials.
 	creds, err := LoadAllCredentials()
 	if err != nil {
@@ -116,8 +133,12 @@ func GetCredential(cloud string) (Credential, error) {
 	}
 
 	// Check if the requested cloud's credentials exist.
-	cred, exists := creds[cloud]
+	key := credentialKey(cloud, profile)
+	cred, exists := creds[key]
 	if !exists {
+		if key != cloud {
+			return Credential{}, fmt.Errorf("no credentials found for cloud: %s (profile %s)", cloud, profile)
+		}
 		return Credential{}, fmt.Errorf("no credentials found for cloud: %s", cloud)
 	}
 
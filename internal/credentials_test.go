@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useTempHome points the configuration directory at a fresh home directory.
+func useTempHome(t *testing.T) string {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(ConfigDirEnv, "")
+	return home
+}
+
+func TestSaveAndLoadCredentials(t *testing.T) {
+	home := useTempHome(t)
+
+	aws := Credential{Cloud: "aws", AccessKey: "AKIDEXAMPLE", SecretKey: "secret"}
+	staging := Credential{Cloud: "aws", Profile: "staging", AccessKey: "AKIDSTAGING", SecretKey: "staging-secret"}
+	azure := Credential{Cloud: "azure", AccessKey: "client", SecretKey: "secret", TenantID: "tenant", SubscriptionID: "subscription"}
+	for _, cred := range []Credential{aws, staging, azure} {
+		if err := SaveCredential(cred); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	creds, err := LoadAllCredentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Credential{"aws": aws, "aws:staging": staging, "azure": azure}
+	if len(creds) != len(want) {
+		t.Fatalf("got %d credentials, want %d", len(creds), len(want))
+	}
+	for key, cred := range want {
+		if creds[key] != cred {
+			t.Errorf("credential %s: got %+v, want %+v", key, creds[key], cred)
+		}
+	}
+
+	// Saving again replaces the credential for the same cloud and profile
+	aws.SecretKey = "rotated"
+	if err := SaveCredential(aws); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetCredential("aws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SecretKey != "rotated" {
+		t.Errorf("got secret %q, want %q", got.SecretKey, "rotated")
+	}
+
+	// The store lives in the home directory, private and not in plain text
+	path := filepath.Join(home, ".namaste-cloud", "credentials.enc")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("credentials file has mode %v, want 0600", info.Mode().Perm())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("AKIDEXAMPLE")) {
+		t.Error("credentials file contains a plain-text access key")
+	}
+}
+
+func TestGetProfileCredentialMissing(t *testing.T) {
+	useTempHome(t)
+
+	if err := SaveCredential(Credential{Cloud: "aws", AccessKey: "key", SecretKey: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetProfileCredential("aws", "staging"); err == nil {
+		t.Error("expected an error for a profile without credentials")
+	}
+	if _, err := GetProfileCredential("gcp", ""); err == nil {
+		t.Error("expected an error for a cloud without credentials")
+	}
+
+	// The default profile may be named explicitly
+	if _, err := GetProfileCredential("aws", "default"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	useTempHome(t)
+
+	plaintext := []byte(`{"aws":{"cloud":"aws"}}`)
+	first, err := encrypt(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := encrypt(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(first, second) {
+		t.Error("encrypting twice produced the same ciphertext")
+	}
+
+	for _, ciphertext := range [][]byte{first, second} {
+		got, err := decrypt(ciphertext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("got %q, want %q", got, plaintext)
+		}
+	}
+
+	if _, err := decrypt([]byte("short")); err == nil {
+		t.Error("expected an error for a truncated ciphertext")
+	}
+}
+
+func TestLoadKeyRejectsInvalidKey(t *testing.T) {
+	useTempHome(t)
+
+	if err := EnsureConfigDir(); err != nil {
+		t.Fatal(err)
+	}
+	path, err := GetKeyFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("too short"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKey(); err == nil {
+		t.Error("expected an error for a key of the wrong size")
+	}
+}
@@ -0,0 +1,14 @@
+package internal
+
+import "fmt"
+
+// ExitError makes the CLI exit with the given status without printing
+// anything, e.g. to pass on the exit status of a command it ran.
+type ExitError struct {
+	Code int
+}
+
+// Error implements error.
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
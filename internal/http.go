@@ -0,0 +1,25 @@
+package internal
+
+import (
+	"crypto/tls"
+	"net/http"
+)
+
+// Transport, when set, carries every cloud API call instead of the default
+// transport. Tests use it to record and replay API interactions.
+var Transport http.RoundTripper
+
+// NewHTTPClient returns an HTTP client for cloud API calls. When skipTLSVerify
+// is set the client accepts any server certificate, which is only meant for
+// local emulators with self-signed certificates.
+func NewHTTPClient(skipTLSVerify bool) *http.Client {
+	if Transport != nil {
+		return &http.Client{Transport: Transport}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if skipTLSVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Operation records a single provider call so that an interrupted command can
+// report which calls completed and which were cancelled.
+type Operation struct {
+	Description string
+	Err         error
+	finished    bool
+}
+
+var (
+	operationsMu sync.Mutex
+	operations   []*Operation
+)
+
+// StartOperation records the start of a provider operation.
+func StartOperation(description string) *Operation {
+	op := &Operation{Description: description}
+
+	operationsMu.Lock()
+	defer operationsMu.Unlock()
+	operations = append(operations, op)
+
+	return op
+}
+
+// Finish records the outcome of the operation and returns err unchanged.
+func (op *Operation) Finish(err error) error {
+	operationsMu.Lock()
+	defer operationsMu.Unlock()
+	op.Err = err
+	op.finished = true
+
+	return err
+}
+
+// Cancelled reports whether the operation did not complete because its context
+// was cancelled or timed out.
+func (op *Operation) Cancelled() bool {
+	operationsMu.Lock()
+	defer operationsMu.Unlock()
+
+	return !op.finished || errors.Is(op.Err, context.Canceled) || errors.Is(op.Err, context.DeadlineExceeded)
+}
+
+// Failed reports whether the operation finished with an error other than a cancellation.
+func (op *Operation) Failed() bool {
+	return !op.Cancelled() && op.Err != nil
+}
+
+// Operations returns all operations recorded so far, in the order they started.
+func Operations() []*Operation {
+	operationsMu.Lock()
+	defer operationsMu.Unlock()
+
+	return append([]*Operation(nil), operations...)
+}
@@ -0,0 +1,11 @@
+package internal
+
+// overrides holds configuration values given on the command line. They apply
+// to the current invocation only and are never written back to disk.
+var overrides Config
+
+// SetOverrides sets the configuration values that take precedence over the
+// user and project configuration for the current invocation.
+func SetOverrides(o Config) {
+	overrides = o
+}
@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// GetPrivateKeyFilePath returns the path to the encrypted private key store.
+func GetPrivateKeyFilePath() (string, error) {
+	configDir, err := GetUserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "private-keys.enc"), nil
+}
+
+// privateKeyKey returns the key a private key is stored under. Key pair names
+// are only unique within a cloud account, so the profile is part of the key.
+func privateKeyKey(cloud, profile, name string) string {
+	return credentialKey(cloud, profile) + "/" + name
+}
+
+// SavePrivateKey stores the private key of a key pair, encrypted like the credentials.
+func SavePrivateKey(cloud, profile, name, privateKey string) error {
+	keys, err := loadPrivateKeys()
+	if err != nil {
+		return err
+	}
+	keys[privateKeyKey(cloud, profile, name)] = privateKey
+	return savePrivateKeys(keys)
+}
+
+// GetPrivateKey retrieves the stored private key of a key pair.
+func GetPrivateKey(cloud, profile, name string) (string, error) {
+	keys, err := loadPrivateKeys()
+	if err != nil {
+		return "", err
+	}
+
+	key, exists := keys[privateKeyKey(cloud, profile, name)]
+	if !exists {
+		return "", fmt.Errorf("no private key stored for key pair %s: %w", name, os.ErrNotExist)
+	}
+	return key, nil
+}
+
+// DeletePrivateKey removes the stored private key of a key pair, if there is one.
+func DeletePrivateKey(cloud, profile, name string) error {
+	keys, err := loadPrivateKeys()
+	if err != nil {
+		return err
+	}
+
+	key := privateKeyKey(cloud, profile, name)
+	if _, exists := keys[key]; !exists {
+		return nil
+	}
+	delete(keys, key)
+	return savePrivateKeys(keys)
+}
+
+// loadPrivateKeys decrypts and loads all stored private keys.
+func loadPrivateKeys() (map[string]string, error) {
+	path, err := GetPrivateKeyFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]string)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return keys, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	decryptedData, err := decrypt(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt private keys: %w", err)
+	}
+	if err := json.Unmarshal(decryptedData, &keys); err != nil {
+		return nil, fmt.Errorf("failed to deserialize private keys: %w", err)
+	}
+	return keys, nil
+}
+
+// savePrivateKeys encrypts and writes all private keys.
+func savePrivateKeys(keys map[string]string) error {
+	if err := EnsureConfigDir(); err != nil {
+		return err
+	}
+	path, err := GetPrivateKeyFilePath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return fmt.Errorf("failed to serialize private keys: %w", err)
+	}
+	encryptedData, err := encrypt(data)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt private keys: %w", err)
+	}
+	return os.WriteFile(path, encryptedData, 0600)
+}
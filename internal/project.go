@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectConfigFileName is the name of the project-local configuration file.
+const ProjectConfigFileName = ".namaste-cloud.yaml"
+
+// FindProjectConfig walks up from dir looking for a project configuration file,
+// the same way git looks for a .git directory. It returns an empty path if none is found.
+func FindProjectConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve directory: %w", err)
+	}
+
+	for {
+		path := filepath.Join(dir, ProjectConfigFileName)
+		info, err := os.Stat(path)
+		if err == nil && !info.IsDir() {
+			return path, nil
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("failed to check %s: %w", path, err)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// LoadProjectConfig loads the project configuration file that applies to the
+// current working directory. It returns the path of the file that was loaded,
+// or an empty path and an empty configuration if there is none.
+func LoadProjectConfig() (Config, string, error) {
+	workDir, err := os.Getwd()
+	if err != nil {
+		return Config{}, "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	path, err := FindProjectConfig(workDir)
+	if err != nil || path == "" {
+		return Config{}, "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, "", fmt.Errorf("failed to read project config: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return Config{}, "", fmt.Errorf("failed to parse project config %s: %w", path, err)
+	}
+	if key := userOnlyKey(cfg); key != "" {
+		return Config{}, "", fmt.Errorf("%s: %s may only be set in the user configuration or on the command line", path, key)
+	}
+
+	return cfg, path, nil
+}
+
+// userOnlyKey returns the first setting of a project configuration that only
+// the user may set, or "" if there is none. Any repository can hold a project
+// configuration, so it must not redirect the cloud API calls or the state,
+// with their credentials, nor turn off TLS verification.
+func userOnlyKey(cfg Config) string {
+	switch {
+	case cfg.EndpointURL != "":
+		return "endpoint_url"
+	case cfg.SkipTLSVerify != nil:
+		return "skip_tls_verify"
+	case cfg.PricingURL != "":
+		return "pricing_url"
+	case cfg.State.EndpointURL != "":
+		return "state.endpoint_url"
+	case cfg.State.Backend != "":
+		return "state.backend"
+	case cfg.State.Path != "":
+		return "state.path"
+	case cfg.State.Bucket != "":
+		return "state.bucket"
+	case cfg.State.Key != "":
+		return "state.key"
+	case cfg.State.Region != "":
+		return "state.region"
+	case cfg.State.Profile != "":
+		return "state.profile"
+	case len(cfg.Profiles) > 0:
+		return "profiles"
+	}
+	return ""
+}
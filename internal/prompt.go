@@ -0,0 +1,35 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Prompt prints a prompt and returns the line read from standard input,
+// without surrounding whitespace.
+func Prompt(prompt string) (string, error) {
+	fmt.Print(prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		// Leave the next output on a line of its own
+		fmt.Println()
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// Confirm asks a yes or no question and reports whether it was answered
+// yes. Anything else, including no input at all, is taken as no.
+func Confirm(question string) bool {
+	answer, err := Prompt(question + " [y/N] ")
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// publicIPURL is the service that reports the address requests come from.
+const publicIPURL = "https://checkip.amazonaws.com"
+
+// PublicIP detects the public IP address of this machine, as seen by the
+// internet, so firewall rules can be limited to it.
+func PublicIP(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, publicIPURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := NewHTTPClient(false).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to detect public IP: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to detect public IP: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil {
+		return "", fmt.Errorf("failed to detect public IP: %w", err)
+	}
+	ip := strings.TrimSpace(string(body))
+	if net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("failed to detect public IP: unexpected response %q", ip)
+	}
+	return ip, nil
+}
@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Default retry policy values, used for anything not set in the configuration.
+const (
+	DefaultRetryMaxAttempts = 5
+	DefaultRetryBaseDelay   = 500 * time.Millisecond
+	DefaultRetryMaxDelay    = 20 * time.Second
+)
+
+// Duration is a time.Duration that is stored as a string such as "500ms" in
+// configuration files.
+type Duration time.Duration
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// RetryPolicy controls how failed cloud API calls are retried. It is shared by
+// every provider; each provider decides which of its errors are retryable.
+type RetryPolicy struct {
+	MaxAttempts int      `json:"max_attempts,omitempty" yaml:"max_attempts"`
+	BaseDelay   Duration `json:"base_delay,omitempty" yaml:"base_delay"`
+	MaxDelay    Duration `json:"max_delay,omitempty" yaml:"max_delay"`
+}
+
+// WithDefaults returns a copy of the policy with unset values filled in.
+func (p RetryPolicy) WithDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryMaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = Duration(DefaultRetryBaseDelay)
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = Duration(DefaultRetryMaxDelay)
+	}
+	if p.MaxDelay < p.BaseDelay {
+		p.MaxDelay = p.BaseDelay
+	}
+	return p
+}
+
+// Backoff returns how long to wait before the given retry attempt, counting
+// from 1. The delay grows exponentially from BaseDelay up to MaxDelay and is
+// randomised with full jitter so that parallel callers spread out.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	p = p.WithDefaults()
+
+	ceiling := time.Duration(p.MaxDelay)
+	delay := time.Duration(p.BaseDelay)
+	for i := 1; i < attempt && delay < ceiling; i++ {
+		delay *= 2
+	}
+	if delay > ceiling {
+		delay = ceiling
+	}
+
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+// overlay returns a copy of p with every value set in other taking precedence.
+func (p RetryPolicy) overlay(other RetryPolicy) RetryPolicy {
+	if other.MaxAttempts != 0 {
+		p.MaxAttempts = other.MaxAttempts
+	}
+	if other.BaseDelay != 0 {
+		p.BaseDelay = other.BaseDelay
+	}
+	if other.MaxDelay != 0 {
+		p.MaxDelay = other.MaxDelay
+	}
+	return p
+}
@@ -0,0 +1,241 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+)
+
+// ErrStateConflict is returned by state backends when the state changed
+// since it was read.
+var ErrStateConflict = errors.New("the state was changed by someone else")
+
+// stateConflictRetries is how often an update is retried after a conflict.
+const stateConflictRetries = 5
+
+// stateLockTimeout is how long a write waits for the lock of a state file.
+const stateLockTimeout = 10 * time.Second
+
+// State is the record of the resources the CLI created.
+type State struct {
+	Resources []StateRecord `json:"resources"`
+}
+
+// StateRecord records a resource the CLI created.
+type StateRecord struct {
+	Cloud string `json:"cloud"`
+	Kind  string `json:"kind"`
+	ID    string `json:"id"`
+	Name  string `json:"name,omitempty"`
+
+	Region  string `json:"region,omitempty"`
+	Profile string `json:"profile,omitempty"`
+
+	// Project is the GCP project or Azure resource group of the resource,
+	// which are scopes of their own within a region.
+	Project string `json:"project,omitempty"`
+
+	// Stack is the spec the resource was created from by apply, if any.
+	Stack string `json:"stack,omitempty"`
+
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Attributes are what the resource was created with, such as its
+	// "type" or a "tag:KEY", to compare with the live resource.
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Same reports whether two records are for the same resource.
+func (r StateRecord) Same(other StateRecord) bool {
+	return r.Cloud == other.Cloud && r.Region == other.Region && r.Project == other.Project && r.Kind == other.Kind && r.ID == other.ID
+}
+
+// StateBackend stores the state.
+type StateBackend interface {
+	// Read returns the stored state, or nil when there is none yet, and a
+	// version to pass to Write.
+	Read(ctx context.Context) ([]byte, string, error)
+
+	// Write stores the state, or returns ErrStateConflict when it changed
+	// since the version was read.
+	Write(ctx context.Context, data []byte, version string) error
+
+	// Location describes where the state is stored.
+	Location() string
+}
+
+// ReadState reads the state from a backend.
+func ReadState(ctx context.Context, backend StateBackend) (State, error) {
+	state, _, err := readState(ctx, backend)
+	return state, err
+}
+
+// UpdateState reads the state, applies fn and writes the result, starting
+// again when someone else changed the state in the meantime.
+func UpdateState(ctx context.Context, backend StateBackend, fn func(*State) error) error {
+	for attempt := 0; ; attempt++ {
+		state, version, err := readState(ctx, backend)
+		if err != nil {
+			return err
+		}
+		if err := fn(&state); err != nil {
+			return err
+		}
+
+		data, err := json.MarshalIndent(state, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to serialize state: %w", err)
+		}
+		err = backend.Write(ctx, data, version)
+		if errors.Is(err, ErrStateConflict) && attempt < stateConflictRetries {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write state to %s: %w", backend.Location(), err)
+		}
+		return nil
+	}
+}
+
+// readState reads and parses the state and its version.
+func readState(ctx context.Context, backend StateBackend) (State, string, error) {
+	data, version, err := backend.Read(ctx)
+	if err != nil {
+		return State{}, "", fmt.Errorf("failed to read state from %s: %w", backend.Location(), err)
+	}
+
+	var state State
+	if data != nil {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return State{}, "", fmt.Errorf("failed to parse state from %s: %w", backend.Location(), err)
+		}
+	}
+	return state, version, nil
+}
+
+// Creator identifies who is running the CLI, as user@host.
+func Creator() string {
+	name := "unknown"
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		return name + "@" + host
+	}
+	return name
+}
+
+// FileStateBackend stores the state in a local file.
+type FileStateBackend struct {
+	path string
+}
+
+// NewFileStateBackend returns a backend that stores the state in the given
+// file, or in state.json in the configuration directory.
+func NewFileStateBackend(path string) (*FileStateBackend, error) {
+	if path == "" {
+		configDir, err := GetUserConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(configDir, "state.json")
+	}
+	return &FileStateBackend{path: path}, nil
+}
+
+// Read implements StateBackend. The version is a hash of the contents.
+func (b *FileStateBackend) Read(ctx context.Context) ([]byte, string, error) {
+	data, err := os.ReadFile(b.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return data, contentVersion(data), nil
+}
+
+// Write implements StateBackend. A lock file keeps other processes from
+// writing between the version check and the rename.
+func (b *FileStateBackend) Write(ctx context.Context, data []byte, version string) error {
+	if err := os.MkdirAll(filepath.Dir(b.path), 0700); err != nil {
+		return err
+	}
+	unlock, err := b.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	_, currentVersion, err := b.Read(ctx)
+	if err != nil {
+		return err
+	}
+	if currentVersion != version {
+		return ErrStateConflict
+	}
+
+	// Write to a temporary file first so an interrupted write never corrupts the state.
+	tmp, err := os.CreateTemp(filepath.Dir(b.path), filepath.Base(b.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), b.path)
+}
+
+// lock creates the lock file of the state, waiting while another process
+// holds it. A lock older than stateLockTimeout is left over from a process
+// that was killed and is taken over.
+func (b *FileStateBackend) lock(ctx context.Context) (func(), error) {
+	path := b.path + ".lock"
+	deadline := time.Now().Add(stateLockTimeout)
+	for {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > stateLockTimeout {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("the state is locked by another process; remove %s if none is running", path)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// Location implements StateBackend.
+func (b *FileStateBackend) Location() string {
+	return b.path
+}
+
+// contentVersion returns a version for file contents.
+func contentVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
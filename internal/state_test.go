@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestFileStateBackendConcurrentUpdates checks that concurrent updates,
+// as from several processes, never lose one another's records and leave no
+// temporary or lock files behind.
+func TestFileStateBackendConcurrentUpdates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	const writers = 10
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			backend, err := NewFileStateBackend(path)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			err = UpdateState(context.Background(), backend, func(state *State) error {
+				state.Resources = append(state.Resources, StateRecord{Cloud: "fake", Kind: "instance", ID: fmt.Sprint(i)})
+				return nil
+			})
+			// Updates may give up after too many conflicts, but never silently
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	backend, _ := NewFileStateBackend(path)
+	state, err := ReadState(context.Background(), backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if succeeded == 0 || len(state.Resources) != succeeded {
+		t.Errorf("got %d records after %d successful updates", len(state.Resources), succeeded)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d files in the state directory, want only the state", len(entries))
+	}
+}
+
+// TestFileStateBackendLock checks that a write waits for the lock of the
+// state and takes over a lock left over from a killed process.
+func TestFileStateBackendLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	backend, err := NewFileStateBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".lock", nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := backend.Write(ctx, []byte("{}"), ""); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want the write to wait for the lock", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("the state was written while locked: %v", err)
+	}
+
+	old := time.Now().Add(-2 * stateLockTimeout)
+	if err := os.Chtimes(path+".lock", old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Write(context.Background(), []byte("{}"), ""); err != nil {
+		t.Fatalf("got %v, want the stale lock to be taken over", err)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("the lock was not removed: %v", err)
+	}
+}
+
+func TestStateRecordSame(t *testing.T) {
+	record := StateRecord{Cloud: "gcp", Region: "us-central1", Project: "web", Kind: "instance", ID: "us-central1-a/api"}
+	other := record
+	other.CreatedBy = "someone"
+	if !record.Same(other) {
+		t.Error("records differing only in who created them are not the same")
+	}
+	// Projects in the same region are different scopes
+	other.Project = "billing"
+	if record.Same(other) {
+		t.Error("records of different projects are the same")
+	}
+}